@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/google/go-containerregistry/pkg/crane"
@@ -31,7 +32,7 @@ import (
 // NewCmdAppend creates a new cobra.Command for the append subcommand.
 func NewCmdAppend(options *[]crane.Option) *cobra.Command {
 	var baseRef, newTag, outFile string
-	var newLayers []string
+	var newLayers, layerURLs []string
 	var annotate, ociEmptyBase bool
 
 	appendCmd := &cobra.Command{
@@ -41,11 +42,22 @@ func NewCmdAppend(options *[]crane.Option) *cobra.Command {
 base image, with appended layers containing the contents of the
 provided tarballs.
 
+Layers can also be streamed directly to the registry from an HTTP URL with
+--layer-url https://example.com/layer.tar.gz@sha256:..., without being
+written to disk. The digest of the layer is verified as it is uploaded.
+
 If the base image is a Windows base image (i.e., its config.OS is "windows"),
 the contents of the tarballs will be modified to be suitable for a Windows
 container image.`,
 		Args: cobra.NoArgs,
 		RunE: func(_ *cobra.Command, args []string) error {
+			if len(newLayers) == 0 && len(layerURLs) == 0 {
+				return errors.New("at least one of --new_layer or --layer-url is required")
+			}
+			if len(layerURLs) != 0 && outFile != "" {
+				return errors.New("--layer-url cannot be used with --output")
+			}
+
 			var base v1.Image
 			var err error
 
@@ -67,6 +79,12 @@ container image.`,
 			if err != nil {
 				return fmt.Errorf("appending %v: %w", newLayers, err)
 			}
+			if len(layerURLs) != 0 {
+				img, err = crane.AppendFromURL(img, layerURLs, *options...)
+				if err != nil {
+					return fmt.Errorf("appending %v: %w", layerURLs, err)
+				}
+			}
 
 			if baseRef != "" && annotate {
 				ref, err := name.ParseReference(baseRef)
@@ -110,13 +128,13 @@ container image.`,
 	}
 	appendCmd.Flags().StringVarP(&baseRef, "base", "b", "", "Name of base image to append to")
 	appendCmd.Flags().StringVarP(&newTag, "new_tag", "t", "", "Tag to apply to resulting image")
-	appendCmd.Flags().StringSliceVarP(&newLayers, "new_layer", "f", []string{}, "Path to tarball to append to image")
+	appendCmd.Flags().StringSliceVarP(&newLayers, "new_layer", "f", []string{}, "Path to tarball to append to image (this or --layer-url is required)")
+	appendCmd.Flags().StringSliceVar(&layerURLs, "layer-url", []string{}, "URL of a layer blob to stream to the registry, with its digest, e.g. https://example.com/layer.tar.gz@sha256:...")
 	appendCmd.Flags().StringVarP(&outFile, "output", "o", "", "Path to new tarball of resulting image")
 	appendCmd.Flags().BoolVar(&annotate, "set-base-image-annotations", false, "If true, annotate the resulting image as being based on the base image")
 	appendCmd.Flags().BoolVar(&ociEmptyBase, "oci-empty-base", false, "If true, empty base image will have OCI media types instead of Docker")
 
 	appendCmd.MarkFlagsMutuallyExclusive("oci-empty-base", "base")
 	appendCmd.MarkFlagRequired("new_tag")
-	appendCmd.MarkFlagRequired("new_layer")
 	return appendCmd
 }
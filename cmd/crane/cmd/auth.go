@@ -15,11 +15,14 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 
@@ -28,18 +31,20 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdAuth creates a new cobra.Command for the auth subcommand.
-func NewCmdAuth(options []crane.Option, argv ...string) *cobra.Command {
+func NewCmdAuth(options *[]crane.Option, argv ...string) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "auth",
 		Short: "Log in or access credentials",
 		Args:  cobra.NoArgs,
 		RunE:  func(cmd *cobra.Command, _ []string) error { return cmd.Usage() },
 	}
-	cmd.AddCommand(NewCmdAuthGet(options, argv...), NewCmdAuthLogin(argv...))
+	cmd.AddCommand(NewCmdAuthGet(*options, argv...), NewCmdAuthLogin(options, argv...), NewCmdAuthLogout(argv...), NewCmdAuthToken(options, argv...))
 	return cmd
 }
 
@@ -125,7 +130,7 @@ func NewCmdAuthGet(options []crane.Option, argv ...string) *cobra.Command {
 }
 
 // NewCmdAuthLogin creates a new `crane auth login` command.
-func NewCmdAuthLogin(argv ...string) *cobra.Command {
+func NewCmdAuthLogin(options *[]crane.Option, argv ...string) *cobra.Command {
 	var opts loginOptions
 
 	if len(argv) == 0 {
@@ -133,7 +138,10 @@ func NewCmdAuthLogin(argv ...string) *cobra.Command {
 	}
 
 	eg := fmt.Sprintf(`  # Log in to reg.example.com
-  %s login reg.example.com -u AzureDiamond -p hunter2`, strings.Join(argv, " "))
+  %s login reg.example.com -u AzureDiamond -p hunter2
+
+  # Log in to reg.example.com with a browser, if it supports it
+  %s login reg.example.com --sso`, strings.Join(argv, " "), strings.Join(argv, " "))
 
 	cmd := &cobra.Command{
 		Use:     "login [OPTIONS] [SERVER]",
@@ -141,14 +149,20 @@ func NewCmdAuthLogin(argv ...string) *cobra.Command {
 		Example: eg,
 		Args:    cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			reg, err := name.NewRegistry(args[0])
+			o := crane.GetOptions(*options...)
+			reg, err := name.NewRegistry(args[0], o.Name...)
 			if err != nil {
 				return err
 			}
 
+			opts.registry = reg
 			opts.serverAddress = reg.Name()
+			opts.transport = o.Transport
+			if opts.transport == nil {
+				opts.transport = remote.DefaultTransport
+			}
 
-			return login(opts)
+			return login(cmd.Context(), opts, cmd.ErrOrStderr())
 		},
 	}
 
@@ -157,28 +171,47 @@ func NewCmdAuthLogin(argv ...string) *cobra.Command {
 	flags.StringVarP(&opts.user, "username", "u", "", "Username")
 	flags.StringVarP(&opts.password, "password", "p", "", "Password")
 	flags.BoolVarP(&opts.passwordStdin, "password-stdin", "", false, "Take the password from stdin")
+	flags.BoolVar(&opts.sso, "sso", false, "Log in with a browser, using the OAuth device flow advertised by the registry, and store the resulting refresh token")
+	flags.StringVar(&opts.clientID, "sso-client-id", "crane", "OAuth client ID to use with --sso")
 
 	return cmd
 }
 
 type loginOptions struct {
+	registry      name.Registry
 	serverAddress string
 	user          string
 	password      string
 	passwordStdin bool
+	sso           bool
+	clientID      string
+	transport     http.RoundTripper
 }
 
-func login(opts loginOptions) error {
-	if opts.passwordStdin {
+func login(ctx context.Context, opts loginOptions, out io.Writer) error {
+	auth := types.AuthConfig{
+		Username: opts.user,
+		Password: opts.password,
+	}
+	if opts.sso {
+		if opts.user != "" || opts.password != "" || opts.passwordStdin {
+			return errors.New("--sso can't be used with a username or password")
+		}
+		token, err := newDeviceFlow(opts.transport, out).login(ctx, opts.registry, opts.clientID)
+		if err != nil {
+			return err
+		}
+		auth.IdentityToken = token
+	} else if opts.passwordStdin {
 		contents, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			return err
 		}
 
-		opts.password = strings.TrimSuffix(string(contents), "\n")
-		opts.password = strings.TrimSuffix(opts.password, "\r")
+		auth.Password = strings.TrimSuffix(string(contents), "\n")
+		auth.Password = strings.TrimSuffix(auth.Password, "\r")
 	}
-	if opts.user == "" && opts.password == "" {
+	if auth.Username == "" && auth.Password == "" && auth.IdentityToken == "" {
 		return errors.New("username and password required")
 	}
 	cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
@@ -189,11 +222,8 @@ func login(opts loginOptions) error {
 	if opts.serverAddress == name.DefaultRegistry {
 		opts.serverAddress = authn.DefaultAuthKey
 	}
-	if err := creds.Store(types.AuthConfig{
-		ServerAddress: opts.serverAddress,
-		Username:      opts.user,
-		Password:      opts.password,
-	}); err != nil {
+	auth.ServerAddress = opts.serverAddress
+	if err := creds.Store(auth); err != nil {
 		return err
 	}
 
@@ -203,3 +233,95 @@ func login(opts loginOptions) error {
 	log.Printf("logged in via %s", cf.Filename)
 	return nil
 }
+
+// NewCmdAuthLogout creates a new `crane auth logout` command.
+func NewCmdAuthLogout(argv ...string) *cobra.Command {
+	if len(argv) == 0 {
+		argv = []string{os.Args[0]}
+	}
+
+	eg := fmt.Sprintf(`  # Log out of reg.example.com
+  %s logout reg.example.com`, strings.Join(argv, " "))
+
+	return &cobra.Command{
+		Use:     "logout [SERVER]",
+		Short:   "Log out of a registry",
+		Example: eg,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			reg, err := name.NewRegistry(args[0])
+			if err != nil {
+				return err
+			}
+			serverAddress := reg.Name()
+
+			cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
+			if err != nil {
+				return err
+			}
+			creds := cf.GetCredentialsStore(serverAddress)
+			if serverAddress == name.DefaultRegistry {
+				serverAddress = authn.DefaultAuthKey
+			}
+			if err := creds.Erase(serverAddress); err != nil {
+				return err
+			}
+
+			if err := cf.Save(); err != nil {
+				return err
+			}
+			log.Printf("logged out of %s via %s", serverAddress, cf.Filename)
+			return nil
+		},
+	}
+}
+
+// NewCmdAuthToken creates a new `crane auth token` command.
+func NewCmdAuthToken(options *[]crane.Option, argv ...string) *cobra.Command {
+	var push bool
+
+	if len(argv) == 0 {
+		argv = []string{os.Args[0]}
+	}
+
+	eg := fmt.Sprintf(`  # Print a token that can pull from reg.example.com/repo
+  %s token reg.example.com/repo
+
+  # Use it to fetch a manifest with curl
+  curl -H "Authorization: Bearer $(%s token reg.example.com/repo)" https://reg.example.com/v2/repo/manifests/latest`, strings.Join(argv, " "), strings.Join(argv, " "))
+
+	cmd := &cobra.Command{
+		Use:     "token REPOSITORY",
+		Short:   "Print a bearer token for a repository, using the configured credentials",
+		Example: eg,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := crane.GetOptions(*options...)
+			repo, err := name.NewRepository(args[0], o.Name...)
+			if err != nil {
+				return err
+			}
+			t := o.Transport
+			if t == nil {
+				t = remote.DefaultTransport
+			}
+			auth, err := o.Keychain.Resolve(repo)
+			if err != nil {
+				return err
+			}
+			action := transport.PullScope
+			if push {
+				action = transport.PushScope
+			}
+			token, err := transport.Exchange(cmd.Context(), repo.Registry, auth, t, []string{repo.Scope(action)})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), token)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&push, "push", false, "Request a token that can also push to the repository")
+
+	return cmd
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+)
+
+type countingTransport struct {
+	inner http.RoundTripper
+	n     int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.n++
+	return t.inner.RoundTrip(req)
+}
+
+func TestAuthTokenTransport(t *testing.T) {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, s.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/token":
+			fmt.Fprint(w, `{"token":"hunter2"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	// The options are set after the command is created, as the root command
+	// does when it parses its flags.
+	var options []crane.Option
+	cmd := NewCmdAuthToken(&options)
+	tr := &countingTransport{inner: http.DefaultTransport}
+	options = append(options, crane.Insecure, crane.WithTransport(tr), crane.WithAuthFromKeychain(authn.NewMultiKeychain()))
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{strings.TrimPrefix(s.URL, "http://") + "/repo"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(out.String()), "hunter2"; got != want {
+		t.Errorf("token = %q, want %q", got, want)
+	}
+	if tr.n == 0 {
+		t.Error("the transport from the options wasn't used")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdConvert creates a new cobra.Command for the convert subcommand.
+func NewCmdConvert(options *[]crane.Option) *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "convert",
+		Short: "Convert a local image archive between docker-archive and oci-archive formats",
+		Example: `  # Convert the output of docker save to an OCI image layout tarball
+  crane convert --from docker-archive:img.tar --to oci-archive:img-oci.tar
+
+  # And back again
+  crane convert --from oci-archive:img-oci.tar --to docker-archive:img.tar`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if from == "" || to == "" {
+				return errors.New("both --from and --to are required")
+			}
+			return crane.Convert(from, to, *options...)
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Archive to convert, as docker-archive:PATH or oci-archive:PATH")
+	cmd.Flags().StringVar(&to, "to", "", "Archive to write, as docker-archive:PATH or oci-archive:PATH")
+	return cmd
+}
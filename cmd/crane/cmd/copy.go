@@ -15,20 +15,84 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdCopy creates a new cobra.Command for the copy subcommand.
 func NewCmdCopy(options *[]crane.Option) *cobra.Command {
-	return &cobra.Command{
+	var (
+		report    string
+		recursive bool
+		catalog   bool
+		jobs      int
+	)
+	cmd := &cobra.Command{
 		Use:     "copy SRC DST",
 		Aliases: []string{"cp"},
 		Short:   "Efficiently copy a remote image from src to dst while retaining the digest value",
-		Args:    cobra.ExactArgs(2),
-		RunE: func(_ *cobra.Command, args []string) error {
+		Long: `Efficiently copy a remote image from src to dst while retaining the digest value.
+
+With --recursive, SRC and DST are repositories, and every tag of SRC is copied
+to DST. With --catalog too, they're registries, and every repository in SRC's
+catalog is copied to the same path in DST. Tags DST already has at the same
+digest are skipped, so an interrupted copy can be resumed by running it again.`,
+		Example: `  # Write a JSON summary of the copy to stdout
+  crane copy ubuntu gcr.io/my-project/ubuntu --report -
+
+  # Copy every tag of a repository, 8 blobs at a time
+  crane copy -r --jobs 8 gcr.io/my-project/app registry.example.com/app
+
+  # Migrate a whole registry
+  crane copy -r --catalog gcr.io registry.example.com`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
 			src, dst := args[0], args[1]
-			return crane.Copy(src, dst, *options...)
+			if catalog && !recursive {
+				return errors.New("--catalog requires --recursive")
+			}
+			if cmd.Flags().Changed("jobs") {
+				*options = append(*options, crane.WithJobs(jobs))
+			}
+
+			var (
+				r   interface{}
+				err error
+			)
+			switch {
+			case catalog:
+				r, err = crane.CopyRegistry(src, dst, *options...)
+			case recursive:
+				r, err = crane.CopyRepository(src, dst, *options...)
+			case report == "":
+				return crane.Copy(src, dst, *options...)
+			default:
+				r, err = crane.CopyWithReport(src, dst, *options...)
+			}
+			if err != nil {
+				return err
+			}
+			if report == "" {
+				return nil
+			}
+			f, err := openFile(report)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", report, err)
+			}
+			defer f.Close()
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			return enc.Encode(r)
 		},
 	}
+	cmd.Flags().StringVar(&report, "report", "", "Write a JSON summary of what was copied to this file, or - for stdout")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Copy every tag of the repository SRC to the repository DST")
+	cmd.Flags().BoolVar(&catalog, "catalog", false, "With --recursive, copy every repository in the catalog of the registry SRC to the registry DST")
+	cmd.Flags().IntVar(&jobs, "jobs", 4, "The maximum number of blobs and manifests to copy at once")
+
+	return cmd
 }
@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdDiff creates a new cobra.Command for the diff subcommand.
+func NewCmdDiff(options *[]crane.Option) *cobra.Command {
+	var files, asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "diff IMAGE1 IMAGE2",
+		Short: "Compare the configs, layers and, optionally, files of two images",
+		Long: `Compare the configs, layers and, optionally, files of two images.
+
+Config differences cover the platform, entrypoint, cmd, user, working dir,
+environment variables and labels. Layers are compared by digest.
+
+With --files, the flattened filesystems are compared too. Files are compared
+by their tar headers, not their contents, which requires downloading every
+layer of both images.`,
+		Example: `  # Compare two versions of an image
+  crane diff ubuntu:20.04 ubuntu:22.04
+
+  # Include file-level changes, as JSON
+  crane diff --files --json ubuntu:20.04 ubuntu:22.04`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := crane.Pull(args[0], *options...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", args[0], err)
+			}
+			b, err := crane.Pull(args[1], *options...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", args[1], err)
+			}
+
+			d, err := crane.Diff(a, b)
+			if err != nil {
+				return err
+			}
+			var fcs []crane.FileChange
+			if files {
+				fcs, err = crane.DiffFiles(a, b)
+				if err != nil {
+					return err
+				}
+			}
+
+			w := cmd.OutOrStdout()
+			if asJSON {
+				return json.NewEncoder(w).Encode(struct {
+					*crane.ImageDiff
+					Files []crane.FileChange `json:"files,omitempty"`
+				}{d, fcs})
+			}
+
+			for _, c := range d.Config {
+				fmt.Fprintf(w, "config %s: %q -> %q\n", c.Field, c.Old, c.New)
+			}
+			for _, h := range d.RemovedLayers {
+				fmt.Fprintf(w, "- layer %s\n", h)
+			}
+			for _, h := range d.AddedLayers {
+				fmt.Fprintf(w, "+ layer %s\n", h)
+			}
+			for _, fc := range fcs {
+				prefix := "~"
+				switch fc.Change {
+				case "added":
+					prefix = "+"
+				case "removed":
+					prefix = "-"
+				}
+				fmt.Fprintf(w, "%s %s\n", prefix, fc.Path)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&files, "files", false, "Also compare the files in each image's filesystem")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print differences as JSON")
+	return cmd
+}
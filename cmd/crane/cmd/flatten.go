@@ -16,7 +16,6 @@ package cmd
 
 import (
 	"compress/gzip"
-	"encoding/json"
 	"fmt"
 	"log"
 
@@ -29,6 +28,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/spf13/cobra"
 )
 
@@ -76,7 +76,11 @@ func NewCmdFlatten(options *[]crane.Option) *cobra.Command {
 				log.Fatalf("digesting new image: %v", err)
 			}
 
-			if _, ok := ref.(name.Digest); ok {
+			if dt, ok := name.AsDigestTag(ref); ok {
+				if dst == src {
+					newRef = dt.Tag()
+				}
+			} else if _, ok := ref.(name.Digest); ok {
 				newRef = repo.Digest(digest.String())
 			}
 
@@ -203,52 +207,23 @@ func flattenImage(old v1.Image, repo name.Repository, use string, o crane.Option
 	if err != nil {
 		return nil, fmt.Errorf("getting old digest: %w", err)
 	}
-	m, err := old.Manifest()
-	if err != nil {
-		return nil, fmt.Errorf("reading manifest: %w", err)
-	}
-
-	cf, err := old.ConfigFile()
-	if err != nil {
-		return nil, fmt.Errorf("getting config: %w", err)
-	}
-	cf = cf.DeepCopy()
 
-	oldHistory, err := json.Marshal(cf.History)
-	if err != nil {
-		return nil, fmt.Errorf("marshal history")
-	}
-
-	// Clear layer-specific config file information.
-	cf.RootFS.DiffIDs = []v1.Hash{}
-	cf.History = []v1.History{}
-
-	img, err := mutate.ConfigFile(empty.Image, cf)
+	mt, err := old.MediaType()
 	if err != nil {
-		return nil, fmt.Errorf("mutating config: %w", err)
+		return nil, fmt.Errorf("getting media type: %w", err)
 	}
-
 	// TODO: Make compression configurable?
-	layer := stream.NewLayer(mutate.Extract(old), stream.WithCompressionLevel(gzip.BestCompression))
+	layerOpts := []stream.LayerOption{stream.WithCompressionLevel(gzip.BestCompression)}
+	if mt == types.OCIManifestSchema1 {
+		layerOpts = append(layerOpts, stream.WithMediaType(types.OCILayer))
+	}
+	layer := stream.NewLayer(mutate.Extract(old), layerOpts...)
 	if err := remote.WriteLayer(repo, layer, o.Remote...); err != nil {
 		return nil, fmt.Errorf("uploading layer: %w", err)
 	}
 
-	img, err = mutate.Append(img, mutate.Addendum{
-		Layer: layer,
-		History: v1.History{
-			CreatedBy: fmt.Sprintf("%s flatten %s", use, digest),
-			Comment:   string(oldHistory),
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("appending layers: %w", err)
-	}
-
-	// Retain any annotations from the original image.
-	if len(m.Annotations) != 0 {
-		img = mutate.Annotations(img, m.Annotations).(v1.Image)
-	}
-
-	return img, nil
+	return mutate.Flatten(old,
+		mutate.FlattenLayer(layer),
+		mutate.FlattenCreatedBy(fmt.Sprintf("%s flatten %s", use, digest)),
+	)
 }
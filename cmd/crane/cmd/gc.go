@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdGC creates a new cobra.Command for the gc subcommand.
+func NewCmdGC() *cobra.Command {
+	var (
+		dryRun bool
+		minAge time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gc PATH",
+		Short: "Remove unreferenced blobs from an OCI image layout",
+		Long: `Remove the blobs in the OCI image layout at PATH that aren't referenced by
+anything in its index.json, such as those left behind when images are replaced.
+
+The digest of each blob is printed as it's removed. With --dry-run, nothing is
+removed, but the blobs and the space they take up are still reported.
+
+Blobs written less than --min-age ago are left alone. Registries upload blobs
+before the manifests that refer to them, so set it when collecting a layout
+that a registry is serving. Nothing else, such as other crane commands, may
+write to the layout while it's being collected.`,
+		Example: `  # See how much space a layout used as a cache could reclaim
+  crane gc ./cache --dry-run
+
+  # Collect a layout that a registry is serving
+  crane gc ./registry --min-age=1h`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := layout.FromPath(args[0])
+			if err != nil {
+				return fmt.Errorf("loading %s as OCI layout: %w", args[0], err)
+			}
+			opts := []layout.GCOption{layout.GCMinAge(minAge)}
+			if dryRun {
+				opts = append(opts, layout.GCDryRun)
+			}
+			res, err := p.GC(opts...)
+			if err != nil {
+				return err
+			}
+			for _, h := range res.Blobs {
+				fmt.Fprintln(cmd.OutOrStdout(), h)
+			}
+			verb := "Removed"
+			if dryRun {
+				verb = "Would remove"
+			}
+			logs.Progress.Printf("%s %d blobs (%d bytes) from %s", verb, len(res.Blobs), res.Bytes, args[0])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be removed without removing anything")
+	cmd.Flags().DurationVar(&minAge, "min-age", 0, "Leave blobs written less than this long ago alone")
+
+	return cmd
+}
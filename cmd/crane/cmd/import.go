@@ -0,0 +1,63 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdImport creates a new cobra.Command for the import subcommand.
+func NewCmdImport(options *[]crane.Option) *cobra.Command {
+	return &cobra.Command{
+		Use:   "import TARBALL|- IMAGE",
+		Short: "Import a filesystem tarball as a single-layer container image",
+		Long: `Import a filesystem tarball, such as one written by crane export, as a
+single-layer container image with a minimal config.
+
+The image's platform is linux/amd64, unless --platform is set.`,
+		Example: `  # Round trip an image's filesystem through a tarball
+  crane export ubuntu rootfs.tar
+  crane import rootfs.tar example.com/ubuntu:flat
+
+  # Read tarball from stdin
+  cat rootfs.tar | crane import - example.com/rootfs`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			src, dst := args[0], args[1]
+
+			img, err := crane.Import(src, *options...)
+			if err != nil {
+				return err
+			}
+			if err := crane.Push(img, dst, *options...); err != nil {
+				return fmt.Errorf("pushing %s: %w", dst, err)
+			}
+			ref, err := name.ParseReference(dst, crane.GetOptions(*options...).Name...)
+			if err != nil {
+				return fmt.Errorf("parsing reference %s: %w", dst, err)
+			}
+			d, err := img.Digest()
+			if err != nil {
+				return fmt.Errorf("digest: %w", err)
+			}
+			fmt.Println(ref.Context().Digest(d.String()))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdIndex creates a new cobra.Command for the index subcommand.
+func NewCmdIndex(options *[]crane.Option) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Create or modify an image index",
+		Args:  cobra.NoArgs,
+		RunE:  func(cmd *cobra.Command, _ []string) error { return cmd.Usage() },
+	}
+	cmd.AddCommand(NewCmdIndexCreate(options), NewCmdIndexAppend(options), NewCmdIndexFilter(options))
+	return cmd
+}
+
+// NewCmdIndexCreate creates a new cobra.Command for the index create subcommand.
+func NewCmdIndexCreate(options *[]crane.Option) *cobra.Command {
+	var (
+		tag       string
+		manifests []string
+		noFlatten bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an index from existing manifests",
+		Long: `Create an index from existing manifests and push it to the registry.
+
+The platform of each image is filled in from its config. If a manifest is
+itself an index, its children are added instead, unless --no-flatten is set.
+
+The index is a Docker manifest list if the first image is a Docker image,
+and an OCI image index otherwise.`,
+		Example: `  # Create a multi-arch index from two single-platform images
+  crane index create -t example.com/app:latest -m example.com/app:amd64 -m example.com/app:arm64`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			o := crane.GetOptions(*options...)
+
+			dst, err := name.ParseReference(tag, o.Name...)
+			if err != nil {
+				return fmt.Errorf("parsing tag %q: %w", tag, err)
+			}
+			adds, err := indexAddenda(manifests, !noFlatten, o)
+			if err != nil {
+				return err
+			}
+
+			var base v1.ImageIndex = empty.Index
+			if adds[0].Descriptor.MediaType == types.DockerManifestSchema2 {
+				base = mutate.IndexMediaType(base, types.DockerManifestList)
+			}
+			return writeIndex(cmd.OutOrStdout(), dst, mutate.AppendManifests(base, adds...), o)
+		},
+	}
+	cmd.Flags().StringVarP(&tag, "tag", "t", "", "Tag to apply to the index")
+	cmd.Flags().StringSliceVarP(&manifests, "manifest", "m", nil, "References to manifests to add to the index")
+	cmd.Flags().BoolVar(&noFlatten, "no-flatten", false, "Add manifests that are indexes as they are, rather than adding their children")
+	_ = cmd.MarkFlagRequired("tag")
+	_ = cmd.MarkFlagRequired("manifest")
+
+	return cmd
+}
+
+// NewCmdIndexAppend creates a new cobra.Command for the index append subcommand.
+func NewCmdIndexAppend(options *[]crane.Option) *cobra.Command {
+	var (
+		tag       string
+		manifests []string
+		noFlatten bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "append INDEX",
+		Short: "Append manifests to an existing index",
+		Long: `Append manifests to an existing index and push it to the registry.
+
+The platform of each image is filled in from its config. If a manifest is
+itself an index, its children are added instead, unless --no-flatten is set.
+
+The result replaces INDEX, unless --tag is set.`,
+		Example: `  # Add an arm64 image to a multi-arch index
+  crane index append example.com/app:latest -m example.com/app:arm64`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := crane.GetOptions(*options...)
+
+			src, err := name.ParseReference(args[0], o.Name...)
+			if err != nil {
+				return fmt.Errorf("parsing reference %q: %w", args[0], err)
+			}
+			dst, err := indexDestination(src, tag, o)
+			if err != nil {
+				return err
+			}
+			base, err := remote.Index(src, o.Remote...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", src, err)
+			}
+			adds, err := indexAddenda(manifests, !noFlatten, o)
+			if err != nil {
+				return err
+			}
+			return writeIndex(cmd.OutOrStdout(), dst, mutate.AppendManifests(base, adds...), o)
+		},
+	}
+	cmd.Flags().StringVarP(&tag, "tag", "t", "", "Tag to apply to the resulting index, instead of replacing INDEX")
+	cmd.Flags().StringSliceVarP(&manifests, "manifest", "m", nil, "References to manifests to append to the index")
+	cmd.Flags().BoolVar(&noFlatten, "no-flatten", false, "Append manifests that are indexes as they are, rather than appending their children")
+	_ = cmd.MarkFlagRequired("manifest")
+
+	return cmd
+}
+
+// NewCmdIndexFilter creates a new cobra.Command for the index filter subcommand.
+func NewCmdIndexFilter(options *[]crane.Option) *cobra.Command {
+	var (
+		tag       string
+		platforms []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "filter INDEX",
+		Short: "Remove manifests from an index, keeping only the given platforms",
+		Long: `Remove manifests from an index, keeping only those for the given platforms,
+and push it to the registry.
+
+Variant and OS version are only compared when set. The result replaces INDEX,
+unless --tag is set.`,
+		Example: `  # Drop everything but linux/amd64 and linux/arm64 from an index
+  crane index filter example.com/app:latest --only-platforms linux/amd64,linux/arm64 -t example.com/app:slim`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := crane.GetOptions(*options...)
+
+			selected, err := parsePlatforms(platforms)
+			if err != nil {
+				return err
+			}
+			if len(selected) == 0 {
+				return errors.New("at least one --only-platforms is required")
+			}
+			if cmd.Flags().Changed("platform") {
+				return errors.New("--platform selects a single image, use --only-platforms to filter an index")
+			}
+
+			src, err := name.ParseReference(args[0], o.Name...)
+			if err != nil {
+				return fmt.Errorf("parsing reference %q: %w", args[0], err)
+			}
+			dst, err := indexDestination(src, tag, o)
+			if err != nil {
+				return err
+			}
+			base, err := remote.Index(src, o.Remote...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", src, err)
+			}
+			filtered := mutate.RemoveManifests(base, func(desc v1.Descriptor) bool {
+				return !platformSelected(desc.Platform, selected)
+			})
+			return writeIndex(cmd.OutOrStdout(), dst, filtered, o)
+		},
+	}
+	cmd.Flags().StringVarP(&tag, "tag", "t", "", "Tag to apply to the resulting index, instead of replacing INDEX")
+	cmd.Flags().StringSliceVar(&platforms, "only-platforms", nil, "Platforms to keep, in the form os/arch[/variant][:osversion]")
+
+	return cmd
+}
+
+// indexAddenda resolves refs into manifests to add to an index, with their
+// platforms filled in from their configs. If flatten is set, the children of
+// any index are added rather than the index itself.
+func indexAddenda(refs []string, flatten bool, o crane.Options) ([]mutate.IndexAddendum, error) {
+	var adds []mutate.IndexAddendum
+	for _, r := range refs {
+		ref, err := name.ParseReference(r, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reference %q: %w", r, err)
+		}
+		desc, err := remote.Get(ref, o.Remote...)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case desc.MediaType.IsImage():
+			img, err := desc.Image()
+			if err != nil {
+				return nil, err
+			}
+			cf, err := img.ConfigFile()
+			if err != nil {
+				return nil, fmt.Errorf("getting config of %s: %w", ref, err)
+			}
+			d := desc.Descriptor
+			d.Platform = &v1.Platform{
+				OS:           cf.OS,
+				Architecture: cf.Architecture,
+				Variant:      cf.Variant,
+				OSVersion:    cf.OSVersion,
+			}
+			adds = append(adds, mutate.IndexAddendum{Add: img, Descriptor: d})
+		case desc.MediaType.IsIndex():
+			idx, err := desc.ImageIndex()
+			if err != nil {
+				return nil, err
+			}
+			if !flatten {
+				adds = append(adds, mutate.IndexAddendum{Add: idx, Descriptor: desc.Descriptor})
+				continue
+			}
+			m, err := idx.IndexManifest()
+			if err != nil {
+				return nil, err
+			}
+			ri, ok := idx.(remoteIndex)
+			if !ok {
+				return nil, fmt.Errorf("unexpected index")
+			}
+			children, err := ri.Manifests()
+			if err != nil {
+				return nil, err
+			}
+			for i, child := range children {
+				// Keep the old descriptor (platform, annotations and whatnot).
+				adds = append(adds, mutate.IndexAddendum{Add: child, Descriptor: m.Manifests[i]})
+			}
+		default:
+			return nil, fmt.Errorf("can't add %s to an index: unexpected media type %q", ref, desc.MediaType)
+		}
+	}
+	if len(adds) == 0 {
+		return nil, errors.New("no manifests to add")
+	}
+	return adds, nil
+}
+
+// indexDestination returns where to push the result of modifying src: tag, if
+// set, or else src itself, by tag if it has one. A digest is replaced by the
+// result's in writeIndex.
+func indexDestination(src name.Reference, tag string, o crane.Options) (name.Reference, error) {
+	if tag != "" {
+		dst, err := name.ParseReference(tag, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing tag %q: %w", tag, err)
+		}
+		return dst, nil
+	}
+	if dt, ok := name.AsDigestTag(src); ok {
+		return dt.Tag(), nil
+	}
+	return src, nil
+}
+
+// writeIndex pushes idx to dst, or by digest if dst is a digest, and prints
+// its digest reference.
+func writeIndex(w io.Writer, dst name.Reference, idx v1.ImageIndex, o crane.Options) error {
+	h, err := idx.Digest()
+	if err != nil {
+		return err
+	}
+	if _, ok := dst.(name.Digest); ok {
+		if _, tagged := name.AsDigestTag(dst); !tagged {
+			dst = dst.Context().Digest(h.String())
+		}
+	}
+	if err := remote.WriteIndex(dst, idx, o.Remote...); err != nil {
+		return fmt.Errorf("pushing %s: %w", dst, err)
+	}
+	fmt.Fprintln(w, dst.Context().Digest(h.String()))
+	return nil
+}
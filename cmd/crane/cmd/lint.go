@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdLint creates a new cobra.Command for the lint subcommand.
+func NewCmdLint(options *[]crane.Option) *cobra.Command {
+	var (
+		asJSON bool
+		strict bool
+	)
+	cmd := &cobra.Command{
+		Use:   "lint IMAGE",
+		Short: "Check an image or index for problems that stricter registries may reject",
+		Long: `Check an image or index for problems that stricter registries may reject.
+
+The manifest, and any manifests and configs it refers to, are checked for
+common problems, like missing required fields and media types, malformed
+digests, implausible sizes, duplicate layers and non-canonical JSON.
+
+This is a heuristic linter, not a validator: it doesn't check against the
+image-spec's JSON schemas, so a clean result doesn't guarantee a valid image.
+
+Exits non-zero if any errors are found, or with --strict, any warnings.`,
+		Example: `# Lint an image
+crane lint ubuntu
+
+# Lint an image, and fail on warnings too
+crane lint --strict ubuntu`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			findings, err := crane.Lint(args[0], *options...)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				if findings == nil {
+					findings = []crane.LintFinding{}
+				}
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(findings); err != nil {
+					return err
+				}
+			}
+			var errs, warnings int
+			for _, f := range findings {
+				if !asJSON {
+					fmt.Fprintln(cmd.OutOrStdout(), f)
+				}
+				if f.Severity == crane.LintError {
+					errs++
+				} else {
+					warnings++
+				}
+			}
+			if errs != 0 || (strict && warnings != 0) {
+				return fmt.Errorf("%s: %d errors, %d warnings", args[0], errs, warnings)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print findings as JSON")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Fail if there are any warnings")
+
+	return cmd
+}
@@ -15,29 +15,94 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdList creates a new cobra.Command for the ls subcommand.
 func NewCmdList(options *[]crane.Option) *cobra.Command {
-	return &cobra.Command{
+	var (
+		fullRef bool
+		digests bool
+		output  string
+	)
+
+	cmd := &cobra.Command{
 		Use:   "ls REPO",
 		Short: "List the tags in a repo",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(_ *cobra.Command, args []string) error {
+		Long: `List the tags in a repo, one per line.
+
+With --full-ref, each tag is printed as a full reference. With --digests, each
+tag is followed by a tab and the digest it points to, which takes a HEAD
+request per tag. With -o json, the tags are printed as a JSON array of objects
+with their tag, full reference and, with --digests, digest.`,
+		Example: `  # List the tags of an image along with their digests
+  crane ls ubuntu --digests
+
+  # List the tags as JSON
+  crane ls ubuntu -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
 			repo := args[0]
-			tags, err := crane.ListTags(repo, *options...)
+			if output != "text" && output != "json" {
+				return fmt.Errorf("--output must be text or json, got %q", output)
+			}
+			r, err := name.NewRepository(repo, crane.GetOptions(*options...).Name...)
+			if err != nil {
+				return fmt.Errorf("parsing repo %q: %w", repo, err)
+			}
+
+			var tds []crane.TagDigest
+			if digests {
+				tds, err = crane.ListTagDigests(repo, *options...)
+			} else {
+				var tags []string
+				tags, err = crane.ListTags(repo, *options...)
+				for _, tag := range tags {
+					tds = append(tds, crane.TagDigest{Tag: tag})
+				}
+			}
 			if err != nil {
 				return fmt.Errorf("reading tags for %s: %w", repo, err)
 			}
 
-			for _, tag := range tags {
-				fmt.Println(tag)
+			w := cmd.OutOrStdout()
+			if output == "json" {
+				type listedTag struct {
+					Tag       string `json:"tag"`
+					Reference string `json:"reference"`
+					Digest    string `json:"digest,omitempty"`
+				}
+				out := make([]listedTag, 0, len(tds))
+				for _, td := range tds {
+					out = append(out, listedTag{Tag: td.Tag, Reference: r.Tag(td.Tag).String(), Digest: td.Digest})
+				}
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(out)
+			}
+
+			for _, td := range tds {
+				tag := td.Tag
+				if fullRef {
+					tag = r.Tag(td.Tag).String()
+				}
+				if digests {
+					fmt.Fprintf(w, "%s\t%s\n", tag, td.Digest)
+				} else {
+					fmt.Fprintln(w, tag)
+				}
 			}
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&fullRef, "full-ref", false, "Print each tag as a full reference, e.g. gcr.io/foo/bar:latest")
+	cmd.Flags().BoolVar(&digests, "digests", false, "Print the digest each tag points to, with a HEAD request per tag")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+
+	return cmd
 }
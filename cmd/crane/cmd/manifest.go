@@ -15,15 +15,24 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdManifest creates a new cobra.Command for the manifest subcommand.
 func NewCmdManifest(options *[]crane.Option) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "manifest IMAGE",
 		Short: "Get the manifest of an image",
 		Args:  cobra.ExactArgs(1),
@@ -37,4 +46,100 @@ func NewCmdManifest(options *[]crane.Option) *cobra.Command {
 			return nil
 		},
 	}
+	cmd.AddCommand(NewCmdManifestPut(options))
+	return cmd
+}
+
+// NewCmdManifestPut creates a new cobra.Command for the manifest put subcommand.
+func NewCmdManifestPut(options *[]crane.Option) *cobra.Command {
+	var (
+		file        string
+		contentType string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "put REF",
+		Short: "Push a raw manifest to the registry",
+		Long: `Push a raw manifest to the registry, exactly as it is, and print its digest reference.
+
+The manifest is read from --file, or from stdin if that's unset or "-". Its
+Content-Type is --content-type, or else its mediaType field.
+
+Nothing it refers to is pushed, so its blobs and any child manifests must
+already be in the repository. If REF is a digest, it must match the manifest's.`,
+		Example: `  # Push a hand-edited manifest under a new tag
+  crane manifest ubuntu > manifest.json
+  crane manifest put example.com/ubuntu:edited -f manifest.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := crane.GetOptions(*options...)
+
+			ref, err := name.ParseReference(args[0], o.Name...)
+			if err != nil {
+				return fmt.Errorf("parsing reference %q: %w", args[0], err)
+			}
+
+			var in io.Reader = cmd.InOrStdin()
+			if file != "" && file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			body, err := io.ReadAll(in)
+			if err != nil {
+				return fmt.Errorf("reading manifest: %w", err)
+			}
+
+			mt := types.MediaType(contentType)
+			if mt == "" {
+				var m struct {
+					MediaType types.MediaType `json:"mediaType"`
+				}
+				if err := json.Unmarshal(body, &m); err != nil {
+					return fmt.Errorf("parsing manifest: %w", err)
+				}
+				if m.MediaType == "" {
+					return errors.New("manifest has no mediaType, set --content-type")
+				}
+				mt = m.MediaType
+			}
+
+			h, _, err := v1.SHA256(bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			if d, ok := ref.(name.Digest); ok && d.DigestStr() != h.String() {
+				return fmt.Errorf("manifest digest is %s, not %s", h, d.DigestStr())
+			}
+
+			if err := remote.Put(ref, &rawManifest{body: body, mediaType: mt}, o.Remote...); err != nil {
+				return fmt.Errorf("pushing manifest %s: %w", ref, err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), ref.Context().Digest(h.String()))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", `Path to the manifest, or "-" for stdin`)
+	cmd.Flags().StringVar(&contentType, "content-type", "", "Media type of the manifest, defaults to its mediaType field")
+
+	return cmd
+}
+
+// rawManifest is a manifest to push exactly as it is.
+type rawManifest struct {
+	body      []byte
+	mediaType types.MediaType
+}
+
+// RawManifest implements remote.Taggable.
+func (r *rawManifest) RawManifest() ([]byte, error) {
+	return r.body, nil
+}
+
+// MediaType implements remote.Taggable.
+func (r *rawManifest) MediaType() (types.MediaType, error) {
+	return r.mediaType, nil
 }
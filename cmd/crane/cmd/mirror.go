@@ -0,0 +1,274 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// mirrorConfig is the file passed to crane mirror --config.
+type mirrorConfig struct {
+	// Interval is how long to wait between syncs.
+	Interval time.Duration `yaml:"interval"`
+	// State is the file the digests that have been copied are persisted in.
+	State string `yaml:"state"`
+	// Metrics is the address to serve Prometheus metrics on, if any.
+	Metrics string `yaml:"metrics"`
+	// Mirrors are the repositories to mirror.
+	Mirrors []crane.MirrorRule `yaml:"mirrors"`
+}
+
+// NewCmdMirror creates a new cobra.Command for the mirror subcommand.
+func NewCmdMirror(options *[]crane.Option) *cobra.Command {
+	var (
+		configPath string
+		once       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "mirror --config mirror.yaml",
+		Short: "Continuously copy new and changed tags between repositories",
+		Long: `Continuously copy new and changed tags between repositories.
+
+Every interval, the tags of each source repository that match its filters are
+resolved, and those whose digests have changed since they were last copied
+are copied to the destination repository under the same tag. The copied
+digests are persisted to the state file, so that restarts don't copy
+everything again.
+
+The config file looks like:
+
+  interval: 5m
+  state: /var/lib/crane/mirror.json
+  metrics: :9090
+  mirrors:
+  - source: docker.io/library/alpine
+    destination: registry.example.com/mirror/alpine
+    tags: ['3\.[0-9]+']
+    excludeTags: ['.*-rc.*']
+
+Tag filters are regular expressions that must match the whole tag. Without
+tags, every tag is mirrored.`,
+		Example: `# Mirror until interrupted
+crane mirror --config mirror.yaml
+
+# Sync once, e.g. from a cron job
+crane mirror --config mirror.yaml --once`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadMirrorConfig(configPath)
+			if err != nil {
+				return err
+			}
+			state, err := loadMirrorState(cfg.State)
+			if err != nil {
+				return err
+			}
+
+			m := &mirrorMetrics{}
+			if cfg.Metrics != "" && !once {
+				ln, err := net.Listen("tcp", cfg.Metrics)
+				if err != nil {
+					return err
+				}
+				defer ln.Close()
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", m)
+				go http.Serve(ln, mux) //nolint: errcheck
+				logs.Progress.Printf("serving metrics on %s", ln.Addr())
+			}
+
+			ctx := cmd.Context()
+			for {
+				start := time.Now()
+				results, err := crane.Mirror(cfg.Mirrors, state, *options...)
+				if err != nil {
+					return err
+				}
+				m.observe(results, start, time.Since(start))
+				for _, r := range results {
+					logs.Progress.Printf("%s -> %s: %d copied, %d unchanged, %d failed", r.Source, r.Destination, r.Copied, r.Unchanged, r.Failed)
+				}
+				if err := saveMirrorState(cfg.State, state); err != nil {
+					return err
+				}
+				if once {
+					for _, r := range results {
+						if r.Failed != 0 {
+							return errors.New("some tags failed to mirror")
+						}
+					}
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(cfg.Interval):
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to the mirror config file")
+	cmd.Flags().BoolVar(&once, "once", false, "Sync once and exit, instead of running continuously")
+	_ = cmd.MarkFlagRequired("config")
+
+	return cmd
+}
+
+func loadMirrorConfig(path string) (*mirrorConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &mirrorConfig{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(cfg.Mirrors) == 0 {
+		return nil, fmt.Errorf("%s: no mirrors configured", path)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	return cfg, nil
+}
+
+// loadMirrorState reads the state persisted at path, if any.
+func loadMirrorState(path string) (crane.MirrorState, error) {
+	state := crane.MirrorState{}
+	if path == "" {
+		return state, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// saveMirrorState atomically persists state at path, if set.
+func saveMirrorState(path string, state crane.MirrorState) error {
+	if path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type mirrorKey struct {
+	source, destination string
+}
+
+// mirrorMetrics serves counters about syncs in the Prometheus text
+// exposition format.
+type mirrorMetrics struct {
+	lock sync.Mutex
+
+	syncs        int64
+	lastSync     time.Time
+	lastDuration time.Duration
+	copied       map[mirrorKey]int64
+	failed       map[mirrorKey]int64
+}
+
+func (m *mirrorMetrics) observe(results []crane.MirrorResult, start time.Time, d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.copied == nil {
+		m.copied = map[mirrorKey]int64{}
+		m.failed = map[mirrorKey]int64{}
+	}
+	m.syncs++
+	m.lastSync = start
+	m.lastDuration = d
+	for _, r := range results {
+		k := mirrorKey{r.Source, r.Destination}
+		m.copied[k] += int64(r.Copied)
+		m.failed[k] += int64(r.Failed)
+	}
+}
+
+func (m *mirrorMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP crane_mirror_syncs_total Number of syncs.")
+	fmt.Fprintln(w, "# TYPE crane_mirror_syncs_total counter")
+	fmt.Fprintf(w, "crane_mirror_syncs_total %d\n", m.syncs)
+	if m.syncs != 0 {
+		fmt.Fprintln(w, "# HELP crane_mirror_last_sync_timestamp_seconds When the last sync started.")
+		fmt.Fprintln(w, "# TYPE crane_mirror_last_sync_timestamp_seconds gauge")
+		fmt.Fprintf(w, "crane_mirror_last_sync_timestamp_seconds %d\n", m.lastSync.Unix())
+		fmt.Fprintln(w, "# HELP crane_mirror_last_sync_duration_seconds How long the last sync took.")
+		fmt.Fprintln(w, "# TYPE crane_mirror_last_sync_duration_seconds gauge")
+		fmt.Fprintf(w, "crane_mirror_last_sync_duration_seconds %g\n", m.lastDuration.Seconds())
+	}
+	writeMirrorCounter(w, "crane_mirror_tags_copied_total", "Number of tags copied.", m.copied)
+	writeMirrorCounter(w, "crane_mirror_tags_failed_total", "Number of tags that failed to copy.", m.failed)
+}
+
+func writeMirrorCounter(w http.ResponseWriter, metric, help string, counts map[mirrorKey]int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", metric, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", metric)
+	keys := make([]mirrorKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].destination < keys[j].destination
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{source=%q,destination=%q} %d\n", metric, k.source, k.destination, counts[k])
+	}
+}
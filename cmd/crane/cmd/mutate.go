@@ -17,18 +17,22 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
 )
 
 // NewCmdMutate creates a new cobra.Command for the mutate subcommand.
 func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 	var labels map[string]string
+	var removeLabels []string
 	var annotations map[string]string
 	var entrypoint, cmd []string
 	var envVars map[string]string
@@ -37,90 +41,148 @@ func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 	var newRef string
 	var newRepo string
 	var user string
+	var workdir string
+	var ports []string
+	var volumes []string
+	var mutatePlatforms []string
+	var keepPlatforms []string
 
 	mutateCmd := &cobra.Command{
 		Use:   "mutate",
 		Short: "Modify image labels and annotations. The container must be pushed to a registry, and the manifest is updated there.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Modify image labels and annotations. The container must be pushed to a registry, and the manifest is updated there.
+
+If the reference points to an index and --platform is not set, every image in
+the index (or only those matching --mutate-platform) is mutated, and any
+annotations are set on the index itself.
+
+With --only-platforms, images in the index for any other platform are removed,
+along with their attestation manifests, to make a smaller index. The images that
+are kept are left as they are unless other flags change them.`,
+		Example: `  # Strip a multi-arch image down to the platforms a cluster runs
+  crane mutate ubuntu --only-platforms linux/amd64,linux/arm64 -t registry.example.com/ubuntu`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
+			// We need direct access to the underlying remote options because crane
+			// doesn't expose great facilities for working with an index (yet).
+			o := crane.GetOptions(*options...)
+
 			// Pull image and get config.
 			ref := args[0]
 
-			if len(annotations) != 0 {
-				desc, err := crane.Head(ref, *options...)
-				if err != nil {
-					return err
-				}
-				if desc.MediaType.IsIndex() {
-					return errors.New("mutating annotations on an index is not yet supported")
-				}
-			}
-
 			if newRepo != "" && newRef != "" {
 				return errors.New("repository can't be set when a tag is specified")
 			}
 
-			img, err := crane.Pull(ref, *options...)
-			if err != nil {
-				return fmt.Errorf("pulling %s: %w", ref, err)
-			}
-			if len(newLayers) != 0 {
-				img, err = crane.Append(img, newLayers...)
-				if err != nil {
-					return fmt.Errorf("appending %v: %w", newLayers, err)
-				}
-			}
-			cfg, err := img.ConfigFile()
-			if err != nil {
-				return err
-			}
-			cfg = cfg.DeepCopy()
-
-			// Set labels.
-			if cfg.Config.Labels == nil {
-				cfg.Config.Labels = map[string]string{}
-			}
-
 			if err := validateKeyVals(labels); err != nil {
 				return err
 			}
 
-			for k, v := range labels {
-				cfg.Config.Labels[k] = v
-			}
-
 			if err := validateKeyVals(annotations); err != nil {
 				return err
 			}
 
-			// set envvars if specified
-			if err := setEnvVars(cfg, envVars); err != nil {
+			selected, err := parsePlatforms(mutatePlatforms)
+			if err != nil {
 				return err
 			}
 
-			// Set entrypoint.
-			if len(entrypoint) > 0 {
-				cfg.Config.Entrypoint = entrypoint
-				cfg.Config.Cmd = nil // This matches Docker's behavior.
+			keep, err := parsePlatforms(keepPlatforms)
+			if err != nil {
+				return err
 			}
-
-			// Set cmd.
-			if len(cmd) > 0 {
-				cfg.Config.Cmd = cmd
+			if len(keep) != 0 && o.Platform != nil {
+				return errors.New("--only-platforms can't be used with --platform")
 			}
 
-			// Set user.
-			if len(user) > 0 {
-				cfg.Config.User = user
-			}
+			// Only rewrite the images in an index if something about them is
+			// changing, so that stripping platforms leaves the rest intact.
+			mutatesImages := len(newLayers) != 0 || len(labels) != 0 || len(removeLabels) != 0 ||
+				len(envVars) != 0 || len(entrypoint) != 0 || len(cmd) != 0 || user != "" ||
+				workdir != "" || len(ports) != 0 || len(volumes) != 0
 
-			// Mutate and write image.
-			img, err = mutate.Config(img, cfg.Config)
-			if err != nil {
-				return fmt.Errorf("mutating config: %w", err)
-			}
+			mutateImage := func(img v1.Image) (v1.Image, error) {
+				if len(newLayers) != 0 {
+					var err error
+					img, err = crane.Append(img, newLayers...)
+					if err != nil {
+						return nil, fmt.Errorf("appending %v: %w", newLayers, err)
+					}
+				}
+				cfg, err := img.ConfigFile()
+				if err != nil {
+					return nil, err
+				}
+				cfg = cfg.DeepCopy()
 
-			img = mutate.Annotations(img, annotations).(v1.Image)
+				// Set labels.
+				if cfg.Config.Labels == nil {
+					cfg.Config.Labels = map[string]string{}
+				}
+
+				for _, k := range removeLabels {
+					delete(cfg.Config.Labels, k)
+				}
+
+				for k, v := range labels {
+					cfg.Config.Labels[k] = v
+				}
+
+				// set envvars if specified; setEnvVars consumes its input, so
+				// give it a copy in case we're mutating every image in an index.
+				env := make(map[string]string, len(envVars))
+				for k, v := range envVars {
+					env[k] = v
+				}
+				if err := setEnvVars(cfg, env); err != nil {
+					return nil, err
+				}
+
+				// Set entrypoint.
+				if len(entrypoint) > 0 {
+					cfg.Config.Entrypoint = entrypoint
+					cfg.Config.Cmd = nil // This matches Docker's behavior.
+				}
+
+				// Set cmd.
+				if len(cmd) > 0 {
+					cfg.Config.Cmd = cmd
+				}
+
+				// Set user.
+				if len(user) > 0 {
+					cfg.Config.User = user
+				}
+
+				// Set workdir.
+				if len(workdir) > 0 {
+					cfg.Config.WorkingDir = workdir
+				}
+
+				// Set exposed ports.
+				if len(ports) > 0 {
+					if err := setExposedPorts(cfg, ports); err != nil {
+						return nil, err
+					}
+				}
+
+				// Set volumes.
+				if len(volumes) > 0 {
+					if cfg.Config.Volumes == nil {
+						cfg.Config.Volumes = map[string]struct{}{}
+					}
+					for _, v := range volumes {
+						cfg.Config.Volumes[v] = struct{}{}
+					}
+				}
+
+				// Mutate image.
+				img, err = mutate.Config(img, cfg.Config)
+				if err != nil {
+					return nil, fmt.Errorf("mutating config: %w", err)
+				}
+				return img, nil
+			}
 
 			// If the new ref isn't provided, write over the original image.
 			// If that ref was provided by digest (e.g., output from
@@ -131,6 +193,63 @@ func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 			} else if newRef == "" {
 				newRef = ref
 			}
+
+			if o.Platform == nil {
+				desc, err := crane.Head(ref, *options...)
+				if err != nil {
+					return err
+				}
+				if desc.MediaType.IsIndex() {
+					if outFile != "" {
+						return errors.New("writing an index to a tarball is not supported, use --platform to select an image")
+					}
+					r, err := name.ParseReference(ref, o.Name...)
+					if err != nil {
+						return fmt.Errorf("parsing %s: %w", ref, err)
+					}
+					idx, err := remote.Index(r, o.Remote...)
+					if err != nil {
+						return fmt.Errorf("pulling %s: %w", ref, err)
+					}
+					fn := mutateImage
+					if !mutatesImages && len(keep) != 0 {
+						fn = nil
+					}
+					idx, err = mutateIndex(idx, keep, selected, fn)
+					if err != nil {
+						return err
+					}
+					idx = mutate.Annotations(idx, annotations).(v1.ImageIndex)
+					digest, err := idx.Digest()
+					if err != nil {
+						return fmt.Errorf("digesting new index: %w", err)
+					}
+					dst, err := mutatedRef(ref, newRef, newRepo, digest, o)
+					if err != nil {
+						return err
+					}
+					if err := remote.WriteIndex(dst, idx, o.Remote...); err != nil {
+						return fmt.Errorf("pushing %s: %w", dst, err)
+					}
+					fmt.Println(dst.Context().Digest(digest.String()))
+					return nil
+				}
+			}
+			if len(keep) != 0 {
+				return fmt.Errorf("--only-platforms requires an index, %s is an image", ref)
+			}
+
+			img, err := crane.Pull(ref, *options...)
+			if err != nil {
+				return fmt.Errorf("pulling %s: %w", ref, err)
+			}
+			img, err = mutateImage(img)
+			if err != nil {
+				return err
+			}
+
+			img = mutate.Annotations(img, annotations).(v1.Image)
+
 			digest, err := img.Digest()
 			if err != nil {
 				return fmt.Errorf("digesting new image: %w", err)
@@ -140,23 +259,21 @@ func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 					return fmt.Errorf("writing output %q: %w", outFile, err)
 				}
 			} else {
-				r, err := name.ParseReference(newRef)
+				dst, err := mutatedRef(ref, newRef, newRepo, digest, o)
 				if err != nil {
-					return fmt.Errorf("parsing %s: %w", newRef, err)
-				}
-				if _, ok := r.(name.Digest); ok || newRepo != "" {
-					newRef = r.Context().Digest(digest.String()).String()
+					return err
 				}
-				if err := crane.Push(img, newRef, *options...); err != nil {
-					return fmt.Errorf("pushing %s: %w", newRef, err)
+				if err := crane.Push(img, dst.String(), *options...); err != nil {
+					return fmt.Errorf("pushing %s: %w", dst, err)
 				}
-				fmt.Println(r.Context().Digest(digest.String()))
+				fmt.Println(dst.Context().Digest(digest.String()))
 			}
 			return nil
 		},
 	}
-	mutateCmd.Flags().StringToStringVarP(&annotations, "annotation", "a", nil, "New annotations to add")
+	mutateCmd.Flags().StringToStringVarP(&annotations, "annotation", "a", nil, "New annotations to add. For an index, these are set on the index itself.")
 	mutateCmd.Flags().StringToStringVarP(&labels, "label", "l", nil, "New labels to add")
+	mutateCmd.Flags().StringSliceVar(&removeLabels, "remove-label", nil, "Labels to remove")
 	mutateCmd.Flags().StringToStringVarP(&envVars, "env", "e", nil, "New envvar to add")
 	mutateCmd.Flags().StringSliceVar(&entrypoint, "entrypoint", nil, "New entrypoint to set")
 	mutateCmd.Flags().StringSliceVar(&cmd, "cmd", nil, "New cmd to set")
@@ -165,9 +282,200 @@ func NewCmdMutate(options *[]crane.Option) *cobra.Command {
 	mutateCmd.Flags().StringVarP(&outFile, "output", "o", "", "Path to new tarball of resulting image")
 	mutateCmd.Flags().StringSliceVar(&newLayers, "append", []string{}, "Path to tarball to append to image")
 	mutateCmd.Flags().StringVarP(&user, "user", "u", "", "New user to set")
+	mutateCmd.Flags().StringVarP(&workdir, "workdir", "w", "", "New working dir to set")
+	mutateCmd.Flags().StringSliceVar(&ports, "exposed-ports", nil, "New ports to expose, in the form port[/protocol] (e.g. 8080/tcp)")
+	mutateCmd.Flags().StringSliceVar(&volumes, "volume", nil, "New volumes to add")
+	mutateCmd.Flags().StringSliceVar(&mutatePlatforms, "mutate-platform", nil, "When mutating an index, only mutate images for these platforms in the form os/arch[/variant][:osversion]. Other images are left as is.")
+	mutateCmd.Flags().StringSliceVar(&keepPlatforms, "only-platforms", nil, "When mutating an index, remove the images for every platform but these, in the form os/arch[/variant][:osversion], and their attestations.")
 	return mutateCmd
 }
 
+// mutatedRef determines where to push the result of mutating src.
+func mutatedRef(src, dst, repo string, digest v1.Hash, o crane.Options) (name.Reference, error) {
+	r, err := name.ParseReference(dst, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", dst, err)
+	}
+	if dt, ok := name.AsDigestTag(r); ok && repo == "" {
+		if dst == src {
+			return dt.Tag(), nil
+		}
+		return r, nil
+	}
+	if _, ok := r.(name.Digest); ok || repo != "" {
+		return r.Context().Digest(digest.String()), nil
+	}
+	return r, nil
+}
+
+// mutateIndex applies fn, if set, to each image in idx whose platform matches
+// one of platforms (or every image, if platforms is empty), keeping all other
+// manifests and the index's annotations and media type as they were. If keep
+// is set, images for other platforms, and their attestations, are removed.
+func mutateIndex(idx v1.ImageIndex, keep, platforms []v1.Platform, fn func(v1.Image) (v1.Image, error)) (v1.ImageIndex, error) {
+	ri, ok := idx.(remoteIndex)
+	if !ok {
+		return nil, fmt.Errorf("unexpected index")
+	}
+
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := ri.Manifests()
+	if err != nil {
+		return nil, err
+	}
+
+	kept, err := keptManifests(m, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	adds := make([]mutate.IndexAddendum, 0, len(manifests))
+	for i, child := range manifests {
+		if !kept[i] {
+			continue
+		}
+		// Keep the old descriptor (platform, annotations and whatnot).
+		desc := m.Manifests[i]
+
+		// Attestations describe the original images, so leave them be.
+		img, ok := child.(v1.Image)
+		if !ok || fn == nil || isAttestation(desc) || !platformSelected(desc.Platform, platforms) {
+			adds = append(adds, mutate.IndexAddendum{
+				Add:        child,
+				Descriptor: desc,
+			})
+			continue
+		}
+
+		mutated, err := fn(img)
+		if err != nil {
+			return nil, fmt.Errorf("mutating %s: %w", desc.Digest, err)
+		}
+		desc.Digest, err = mutated.Digest()
+		if err != nil {
+			return nil, err
+		}
+		desc.Size, err = mutated.Size()
+		if err != nil {
+			return nil, err
+		}
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        mutated,
+			Descriptor: desc,
+		})
+	}
+
+	out := mutate.AppendManifests(empty.Index, adds...)
+
+	// Retain any annotations from the original index.
+	if len(m.Annotations) != 0 {
+		out = mutate.Annotations(out, m.Annotations).(v1.ImageIndex)
+	}
+
+	mt, err := idx.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	return mutate.IndexMediaType(out, mt), nil
+}
+
+// Annotations on the attestation manifests that docker buildx adds to an
+// index, which point at the image they describe.
+const (
+	referenceTypeAnnotation   = "vnd.docker.reference.type"
+	referenceDigestAnnotation = "vnd.docker.reference.digest"
+	attestationManifest       = "attestation-manifest"
+)
+
+func isAttestation(desc v1.Descriptor) bool {
+	return desc.Annotations[referenceTypeAnnotation] == attestationManifest
+}
+
+// keptManifests reports which of the manifests in m to keep: the images for
+// platforms, and the attestation manifests for those images, or every manifest
+// if platforms is empty.
+func keptManifests(m *v1.IndexManifest, platforms []v1.Platform) ([]bool, error) {
+	kept := make([]bool, len(m.Manifests))
+	if len(platforms) == 0 {
+		for i := range kept {
+			kept[i] = true
+		}
+		return kept, nil
+	}
+
+	images := map[string]bool{}
+	for i, desc := range m.Manifests {
+		if isAttestation(desc) {
+			continue
+		}
+		if platformSelected(desc.Platform, platforms) {
+			kept[i] = true
+			images[desc.Digest.String()] = true
+		}
+	}
+	if len(images) == 0 {
+		return nil, errors.New("no images in the index match --only-platforms")
+	}
+	for i, desc := range m.Manifests {
+		if isAttestation(desc) && images[desc.Annotations[referenceDigestAnnotation]] {
+			kept[i] = true
+		}
+	}
+	return kept, nil
+}
+
+// platformSelected reports whether p matches any of platforms. Variant and
+// OS version are only compared when set in the selector.
+func platformSelected(p *v1.Platform, platforms []v1.Platform) bool {
+	if len(platforms) == 0 {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	for _, want := range platforms {
+		if want.OS != p.OS || want.Architecture != p.Architecture {
+			continue
+		}
+		if want.Variant != "" && want.Variant != p.Variant {
+			continue
+		}
+		if want.OSVersion != "" && want.OSVersion != p.OSVersion {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// setExposedPorts adds ports to the config's exposed ports, defaulting to tcp
+// when no protocol is given.
+func setExposedPorts(cfg *v1.ConfigFile, ports []string) error {
+	if cfg.Config.ExposedPorts == nil {
+		cfg.Config.ExposedPorts = map[string]struct{}{}
+	}
+	for _, p := range ports {
+		port, proto := p, "tcp"
+		if i := strings.Index(p, "/"); i >= 0 {
+			port, proto = p[:i], p[i+1:]
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("invalid port %q: %w", p, err)
+		}
+		switch proto {
+		case "tcp", "udp", "sctp":
+		default:
+			return fmt.Errorf("invalid protocol %q for port %q", proto, p)
+		}
+		cfg.Config.ExposedPorts[port+"/"+proto] = struct{}{}
+	}
+	return nil
+}
+
 // validateKeyVals ensures no values are empty, returns error if they are
 func validateKeyVals(kvPairs map[string]string) error {
 	for label, value := range kvPairs {
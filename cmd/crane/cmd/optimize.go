@@ -25,10 +25,21 @@ func NewCmdOptimize(options *[]crane.Option) *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:     "optimize SRC DST",
-		Hidden:  true,
 		Aliases: []string{"opt"},
 		Short:   "Optimize a remote container image from src to dst",
-		Args:    cobra.ExactArgs(2),
+		Long: `Copy the image or index at SRC to DST with its layers converted to eStargz,
+a seekable form of gzip that lets lazy pullers such as the containerd
+stargz-snapshotter start containers before the whole image has been fetched.
+
+Files given with --prioritize are moved to the front of the layers that contain
+them, so they're fetched first. It's an error if any are missing from every
+layer. The config, history and annotations are kept, but the diff_ids change
+along with the layers, so the result has a different digest.
+
+With --platform, only the matching image in an index is optimized.`,
+		Example: `  # Make an image lazy-pullable, fetching its entrypoint first
+  crane optimize ubuntu registry.example.com/ubuntu:esgz --prioritize /usr/bin/bash`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(_ *cobra.Command, args []string) error {
 			src, dst := args[0], args[1]
 			return crane.Optimize(src, dst, files, *options...)
@@ -0,0 +1,43 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdVerifyPin creates a new cobra.Command for the verify-pin subcommand.
+func NewCmdVerifyPin(options *[]crane.Option) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify-pin IMAGE",
+		Short: "Verify that a tag still resolves to its pinned digest",
+		Long: `Verify that a reference of the form repo:tag@digest is still accurate.
+
+Exits non-zero if the tag has been moved to a different digest.`,
+		Example: `# Check that ubuntu:22.04 hasn't moved
+crane verify-pin ubuntu:22.04@sha256:4b1d0c4a2d2aaf63b37111f34eb9fa89fa1bf53dd6e4ca954d47caebca4005c2`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := crane.VerifyPin(args[0], *options...); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "PASS: %s\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdPromote creates a new cobra.Command for the promote subcommand.
+func NewCmdPromote(options *[]crane.Option) *cobra.Command {
+	var annotations map[string]string
+	var policyExec string
+
+	cmd := &cobra.Command{
+		Use:   "promote SRC DST",
+		Short: "Promote an image or index by digest from src to the tag dst",
+		Long: `Promote an image or index by digest from src to the tag dst.
+
+SRC is resolved to a digest, which is checked against any policies, copied by
+digest to the repository of DST, and then tagged as DST. A JSON record of the
+promotion is written to stdout.`,
+		Example: `# Promote staging's v1.2.3 to prod, if it passed QA
+crane promote --require-annotation qa=passed \
+  registry.example.com/staging/app:v1.2.3 registry.example.com/prod/app:v1.2.3
+
+# Verify signatures with an external tool, which is passed SRC by digest
+crane promote --policy-exec "cosign verify --key cosign.pub" \
+  registry.example.com/staging/app:v1.2.3 registry.example.com/prod/app:v1.2.3`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, dst := args[0], args[1]
+
+			var policies []crane.PromotionPolicy
+			if len(annotations) != 0 {
+				policies = append(policies, crane.RequireAnnotations(annotations))
+			}
+			if policyExec != "" {
+				policy, err := execPolicy(policyExec, cmd.ErrOrStderr())
+				if err != nil {
+					return err
+				}
+				policies = append(policies, policy)
+			}
+
+			p, err := crane.Promote(src, dst, crane.AllPolicies(policies...), *options...)
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(p)
+		},
+	}
+	cmd.Flags().StringToStringVar(&annotations, "require-annotation", nil, "Only promote manifests with these annotations (key=value)")
+	cmd.Flags().StringVar(&policyExec, "policy-exec", "", "Only promote if this command succeeds when passed SRC by digest as its last argument")
+
+	return cmd
+}
+
+// execPolicy returns a PromotionPolicy that runs command with the source
+// digest appended to its arguments, and fails if the command does.
+func execPolicy(command string, out io.Writer) (crane.PromotionPolicy, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("--policy-exec: empty command")
+	}
+	return func(src name.Digest, _ *remote.Descriptor) error {
+		c := exec.Command(fields[0], append(fields[1:], src.String())...)
+		c.Stdout = out
+		c.Stderr = out
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("%s: %w", command, err)
+		}
+		return nil
+	}, nil
+}
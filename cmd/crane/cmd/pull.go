@@ -23,6 +23,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/cache"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
 )
@@ -94,33 +95,31 @@ func NewCmdPull(options *[]crane.Option) *cobra.Command {
 					}
 				}
 				for ref, img := range imageMap {
-					opts := []layout.Option{}
-					if annotateRef {
-						parsed, err := name.ParseReference(ref, o.Name...)
-						if err != nil {
+					if !annotateRef {
+						if err := p.AppendImage(img); err != nil {
 							return err
 						}
-						opts = append(opts, layout.WithAnnotations(map[string]string{
-							"org.opencontainers.image.ref.name": parsed.Name(),
-						}))
+						continue
 					}
-					if err = p.AppendImage(img, opts...); err != nil {
+					if err := p.WriteImage(img); err != nil {
+						return err
+					}
+					if err := tagLayout(p, ref, img, o); err != nil {
 						return err
 					}
 				}
 
 				for ref, idx := range indexMap {
-					opts := []layout.Option{}
-					if annotateRef {
-						parsed, err := name.ParseReference(ref, o.Name...)
-						if err != nil {
+					if !annotateRef {
+						if err := p.AppendIndex(idx); err != nil {
 							return err
 						}
-						opts = append(opts, layout.WithAnnotations(map[string]string{
-							"org.opencontainers.image.ref.name": parsed.Name(),
-						}))
+						continue
+					}
+					if err := p.WriteIndex(idx); err != nil {
+						return err
 					}
-					if err := p.AppendIndex(idx, opts...); err != nil {
+					if err := tagLayout(p, ref, idx, o); err != nil {
 						return err
 					}
 				}
@@ -132,7 +131,20 @@ func NewCmdPull(options *[]crane.Option) *cobra.Command {
 	}
 	cmd.Flags().StringVarP(&cachePath, "cache_path", "c", "", "Path to cache image layers")
 	cmd.Flags().StringVar(&format, "format", "tarball", fmt.Sprintf("Format in which to save images (%q, %q, or %q)", "tarball", "legacy", "oci"))
-	cmd.Flags().BoolVar(&annotateRef, "annotate-ref", false, "Preserves image reference used to pull as an annotation when used with --format=oci")
+	cmd.Flags().BoolVar(&annotateRef, "annotate-ref", false, "Name images in the layout after the reference used to pull them, replacing any image with the same name, when used with --format=oci")
 
 	return cmd
 }
+
+// tagLayout names the manifest of d in p after the reference ref.
+func tagLayout(p layout.Path, ref string, d partial.Describable, o crane.Options) error {
+	parsed, err := name.ParseReference(ref, o.Name...)
+	if err != nil {
+		return err
+	}
+	desc, err := partial.Descriptor(d)
+	if err != nil {
+		return err
+	}
+	return p.Tag(parsed.Name(), *desc)
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdVerifyPullThrough creates a new cobra.Command for the
+// verify-pull-through subcommand.
+func NewCmdVerifyPullThrough(options *[]crane.Option) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "verify-pull-through MIRROR UPSTREAM",
+		Short: "Check that a pull-through cache serves the same image as its upstream",
+		Long: `Check that a pull-through cache serves the same image as its upstream.
+
+UPSTREAM is resolved to a digest, and that digest is pulled from both the
+repository of MIRROR and UPSTREAM. Every manifest, config and layer is compared
+by digest, and any that the mirror is missing or serves differently are
+printed.
+
+Exits non-zero if the mirror diverges from upstream.`,
+		Example: `# Check a Docker Hub mirror
+crane verify-pull-through mirror.example.com/library/ubuntu ubuntu:22.04`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mirror, upstream := args[0], args[1]
+			r, err := crane.VerifyPullThrough(mirror, upstream, *options...)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(r); err != nil {
+					return err
+				}
+			} else {
+				for _, d := range r.Divergences {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s %s: %s\n", d.Digest, d.MediaType, d.Reason)
+				}
+			}
+			if len(r.Divergences) != 0 {
+				return fmt.Errorf("%s diverges from %s at %s: %d of %d manifests and blobs differ",
+					mirror, upstream, r.Digest, len(r.Divergences), r.Manifests+r.Blobs)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print a JSON report of what was compared")
+
+	return cmd
+}
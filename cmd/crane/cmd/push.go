@@ -15,9 +15,14 @@
 package cmd
 
 import (
+	"archive/tar"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -35,18 +40,35 @@ func NewCmdPush(options *[]crane.Option) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "push PATH IMAGE",
 		Short: "Push local image contents to a remote registry",
-		Long:  `If the PATH is a directory, it will be read as an OCI image layout. Otherwise, PATH is assumed to be a docker-style tarball.`,
-		Args:  cobra.ExactArgs(2),
+		Long: `If the PATH is a directory, or a tarball of one (e.g. an oci-archive from skopeo or buildah), it will be read as an OCI image layout. Otherwise, PATH is assumed to be a docker-style tarball.
+
+If an OCI image layout contains more than one image, the one named after IMAGE
+(e.g. by crane pull --format=oci --annotate-ref), or after IMAGE's tag, is pushed.`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(_ *cobra.Command, args []string) error {
 			path, tag := args[0], args[1]
 
-			img, err := loadImage(path, index)
+			o := crane.GetOptions(*options...)
+			ref, err := name.ParseReference(tag, o.Name...)
 			if err != nil {
 				return err
 			}
 
-			o := crane.GetOptions(*options...)
-			ref, err := name.ParseReference(tag, o.Name...)
+			if ok, err := isLayoutArchive(path); err != nil {
+				return err
+			} else if ok {
+				dir, err := ioutil.TempDir("", "crane-push")
+				if err != nil {
+					return err
+				}
+				defer os.RemoveAll(dir)
+				if err := extractLayoutArchive(path, dir); err != nil {
+					return fmt.Errorf("extracting %s: %w", path, err)
+				}
+				path = dir
+			}
+
+			img, err := loadImage(path, index, ref)
 			if err != nil {
 				return err
 			}
@@ -80,12 +102,12 @@ func NewCmdPush(options *[]crane.Option) *cobra.Command {
 			return nil
 		},
 	}
-	cmd.Flags().BoolVar(&index, "index", false, "push a collection of images as a single index, currently required if PATH contains multiple images")
+	cmd.Flags().BoolVar(&index, "index", false, "push a collection of images as a single index, currently required if PATH contains multiple images and none is named after IMAGE")
 	cmd.Flags().StringVar(&imageRefs, "image-refs", "", "path to file where a list of the published image references will be written")
 	return cmd
 }
 
-func loadImage(path string, index bool) (partial.WithRawManifest, error) {
+func loadImage(path string, index bool, ref name.Reference) (partial.WithRawManifest, error) {
 	stat, err := os.Stat(path)
 	if err != nil {
 		return nil, err
@@ -112,11 +134,17 @@ func loadImage(path string, index bool) (partial.WithRawManifest, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(m.Manifests) != 1 {
-		return nil, fmt.Errorf("layout contains %d entries, consider --index", len(m.Manifests))
+	var desc v1.Descriptor
+	if len(m.Manifests) == 1 {
+		desc = m.Manifests[0]
+	} else {
+		d, err := resolveLayoutTag(layout.Path(path), ref)
+		if err != nil {
+			return nil, fmt.Errorf("layout contains %d entries, consider --index: %w", len(m.Manifests), err)
+		}
+		desc = *d
 	}
 
-	desc := m.Manifests[0]
 	if desc.MediaType.IsImage() {
 		return l.Image(desc.Digest)
 	} else if desc.MediaType.IsIndex() {
@@ -125,3 +153,106 @@ func loadImage(path string, index bool) (partial.WithRawManifest, error) {
 
 	return nil, fmt.Errorf("layout contains non-image (mediaType: %q), consider --index", desc.MediaType)
 }
+
+// resolveLayoutTag returns the descriptor of the manifest in p named after
+// ref, or after its tag.
+func resolveLayoutTag(p layout.Path, ref name.Reference) (*v1.Descriptor, error) {
+	desc, err := p.ResolveTag(ref.Name())
+	if err == nil {
+		return desc, nil
+	}
+	if t, ok := ref.(name.Tag); ok {
+		if desc, terr := p.ResolveTag(t.TagStr()); terr == nil {
+			return desc, nil
+		}
+	}
+	return nil, err
+}
+
+// isLayoutArchive returns whether path is a tarball of an OCI image layout,
+// i.e. whether it contains an oci-layout file at its root.
+//
+// Tools like skopeo write oci-layout after the blobs, so it can't just check
+// the first entry. Instead, it stops at the first entry that can't be part of
+// a layout, e.g. the first entry of a docker-style tarball, so that those are
+// never read through. The headers of a layout's blobs are read, but their
+// contents are skipped.
+func isLayoutArchive(path string) (bool, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if stat.IsDir() {
+		return false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		} else if err != nil {
+			// Not a tarball, let loadImage complain about it.
+			return false, nil
+		}
+		switch name := filepath.ToSlash(filepath.Clean(hdr.Name)); {
+		case name == "oci-layout":
+			return true, nil
+		case name == ".", name == "index.json", name == "blobs", strings.HasPrefix(name, "blobs/"):
+			// Part of a layout, keep looking.
+		default:
+			return false, nil
+		}
+	}
+}
+
+// extractLayoutArchive extracts the tarball of an OCI image layout at path
+// into dir.
+func extractLayoutArchive(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name := filepath.Clean(hdr.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in archive: %q", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil { //nolint: gosec
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
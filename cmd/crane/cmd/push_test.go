@@ -0,0 +1,93 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTar(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIsLayoutArchive(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		names []string
+		want  bool
+	}{{
+		desc:  "oci-layout first",
+		names: []string{"oci-layout", "index.json", "blobs/sha256/abc"},
+		want:  true,
+	}, {
+		// e.g. skopeo's oci-archive.
+		desc:  "oci-layout last",
+		names: []string{"./blobs/sha256/abc", "./blobs/sha256/def", "./index.json", "./oci-layout"},
+		want:  true,
+	}, {
+		desc:  "docker tarball",
+		names: []string{"manifest.json", "abc.tar.gz", "config.json"},
+	}, {
+		// It stops at the first entry that isn't part of a layout.
+		desc:  "oci-layout after other files",
+		names: []string{"manifest.json", "oci-layout"},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := isLayoutArchive(writeTar(t, tc.names...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("isLayoutArchive() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+
+	// Directories and files that aren't tarballs aren't archives.
+	dir := t.TempDir()
+	if got, err := isLayoutArchive(dir); err != nil || got {
+		t.Errorf("isLayoutArchive(dir) = %t, %v, want false", got, err)
+	}
+	notTar := filepath.Join(dir, "not.tar")
+	if err := ioutil.WriteFile(notTar, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := isLayoutArchive(notTar); err != nil || got {
+		t.Errorf("isLayoutArchive(not a tarball) = %t, %v, want false", got, err)
+	}
+}
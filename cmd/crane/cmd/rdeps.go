@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdRdeps creates a new cobra.Command for the rdeps subcommand.
+func NewCmdRdeps(options *[]crane.Option) *cobra.Command {
+	var (
+		repos  []string
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rdeps BASE",
+		Short: "Find the images in some repositories that were built on a base image",
+		Long: `Find the images in some repositories that were built on a base image, i.e.
+that contain every one of its layers, to know what to rebuild when it's patched.
+
+Every tag in each --repo is checked. Images in indexes are checked too, and
+reported along with their platform. If BASE is an index, images built on any
+of its images are reported.
+
+Each image is printed on its own line as the tag it was found by, its digest
+and, for images in an index, its platform.`,
+		Example: `  # Find what needs rebuilding on a new debian
+  crane rdeps debian:bookworm --repo example.com/app --repo example.com/worker`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(repos) == 0 {
+				return errors.New("at least one --repo is required")
+			}
+			deps, err := crane.Dependents(args[0], repos, *options...)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			if asJSON {
+				if deps == nil {
+					deps = []crane.Dependent{}
+				}
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(deps)
+			}
+			for _, d := range deps {
+				if d.Platform != nil {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", d.Reference, d.Digest, d.Platform)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\n", d.Reference, d.Digest)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&repos, "repo", nil, "Repositories to scan")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the images as JSON")
+
+	return cmd
+}
@@ -17,13 +17,14 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"log"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
 )
@@ -35,11 +36,21 @@ func NewCmdRebase(options *[]crane.Option) *cobra.Command {
 	rebaseCmd := &cobra.Command{
 		Use:   "rebase",
 		Short: "Rebase an image onto a new base image",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Rebase an image onto a new base image.
+
+The old and new base images default to those named by the image's
+org.opencontainers.image.base.* annotations, which are updated to the new base.
+
+If the image is an index and --platform is not set, each image in the index is
+rebased onto the image for the same platform in the new base.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if orig == "" {
+				if len(args) == 0 {
+					return errors.New("an image to rebase is required")
+				}
 				orig = args[0]
-			} else if len(args) != 0 || args[0] != "" {
+			} else if len(args) != 0 {
 				return fmt.Errorf("cannot use --original with positional argument")
 			}
 
@@ -51,76 +62,85 @@ func NewCmdRebase(options *[]crane.Option) *cobra.Command {
 				rebased = orig
 			}
 
-			// Stupid hack to support insecure flag.
-			nameOpt := []name.Option{}
-			if ok, err := cmd.Parent().PersistentFlags().GetBool("insecure"); err != nil {
-				log.Fatalf("flag problems: %v", err)
-			} else if ok {
-				nameOpt = append(nameOpt, name.Insecure)
-			}
-			r, err := name.ParseReference(rebased, nameOpt...)
+			o := crane.GetOptions(*options...)
+			r, err := name.ParseReference(rebased, o.Name...)
 			if err != nil {
-				log.Fatalf("parsing %s: %v", rebased, err)
+				return fmt.Errorf("parsing %s: %w", rebased, err)
 			}
-
-			desc, err := crane.Head(orig, *options...)
+			origRef, err := name.ParseReference(orig, o.Name...)
 			if err != nil {
-				log.Fatalf("checking %s: %v", orig, err)
-			}
-			if !cmd.Parent().PersistentFlags().Changed("platform") && desc.MediaType.IsIndex() {
-				log.Fatalf("rebasing an index is not yet supported")
+				return fmt.Errorf("parsing %s: %w", orig, err)
 			}
 
-			origImg, err := crane.Pull(orig, *options...)
-			if err != nil {
-				return err
-			}
-			origMf, err := origImg.Manifest()
-			if err != nil {
-				return err
-			}
-			anns := origMf.Annotations
-			if newBase == "" && anns != nil {
-				newBase = anns[specsv1.AnnotationBaseImageName]
-			}
-			if newBase == "" {
-				return errors.New("could not determine new base image from annotations")
-			}
-			newBaseRef, err := name.ParseReference(newBase)
-			if err != nil {
-				return err
-			}
-			if oldBase == "" && anns != nil {
-				oldBaseDigest := anns[specsv1.AnnotationBaseImageDigest]
-				oldBase = newBaseRef.Context().Digest(oldBaseDigest).String()
-			}
-			if oldBase == "" {
-				return errors.New("could not determine old base image by digest from annotations")
-			}
-
-			rebasedImg, err := rebaseImage(origImg, oldBase, newBase, *options...)
-			if err != nil {
-				return fmt.Errorf("rebasing image: %w", err)
-			}
-
-			rebasedDigest, err := rebasedImg.Digest()
-			if err != nil {
-				return fmt.Errorf("digesting new image: %w", err)
-			}
-			origDigest, err := origImg.Digest()
+			desc, err := crane.Head(orig, *options...)
 			if err != nil {
-				return err
+				return fmt.Errorf("checking %s: %w", orig, err)
+			}
+
+			var (
+				origDigest, rebasedDigest v1.Hash
+				result                    partial.Describable
+			)
+			if o.Platform == nil && desc.MediaType.IsIndex() {
+				idx, err := remote.Index(origRef, o.Remote...)
+				if err != nil {
+					return err
+				}
+				rebasedIdx, err := mutateIndex(idx, nil, nil, func(img v1.Image) (v1.Image, error) {
+					cf, err := img.ConfigFile()
+					if err != nil {
+						return nil, err
+					}
+					platform := &v1.Platform{
+						OS:           cf.OS,
+						Architecture: cf.Architecture,
+						Variant:      cf.Variant,
+						OSVersion:    cf.OSVersion,
+					}
+					logs.Progress.Printf("rebasing %s image", platformToString(platform))
+					return rebaseImage(img, oldBase, newBase, append(*options, crane.WithPlatform(platform))...)
+				})
+				if err != nil {
+					return fmt.Errorf("rebasing index: %w", err)
+				}
+				if origDigest, err = idx.Digest(); err != nil {
+					return err
+				}
+				if rebasedDigest, err = rebasedIdx.Digest(); err != nil {
+					return fmt.Errorf("digesting new index: %w", err)
+				}
+				result = rebasedIdx
+			} else {
+				origImg, err := crane.Pull(orig, *options...)
+				if err != nil {
+					return err
+				}
+				rebasedImg, err := rebaseImage(origImg, oldBase, newBase, *options...)
+				if err != nil {
+					return fmt.Errorf("rebasing image: %w", err)
+				}
+				if origDigest, err = origImg.Digest(); err != nil {
+					return err
+				}
+				if rebasedDigest, err = rebasedImg.Digest(); err != nil {
+					return fmt.Errorf("digesting new image: %w", err)
+				}
+				result = rebasedImg
 			}
 			if rebasedDigest == origDigest {
 				logs.Warn.Println("rebasing was no-op")
 			}
 
-			if _, ok := r.(name.Digest); ok {
-				rebased = r.Context().Digest(rebasedDigest.String()).String()
+			if dt, ok := name.AsDigestTag(r); ok {
+				if rebased == orig {
+					r = dt.Tag()
+				}
+			} else if dr, ok := r.(name.Digest); ok {
+				r = dr.Context().Digest(rebasedDigest.String())
 			}
-			logs.Progress.Println("pushing rebased image as", rebased)
-			if err := crane.Push(rebasedImg, rebased, *options...); err != nil {
-				log.Fatalf("pushing %s: %v", rebased, err)
+			logs.Progress.Println("pushing rebased image as", r)
+			if err := push(result, r, o); err != nil {
+				return fmt.Errorf("pushing %s: %w", r, err)
 			}
 
 			fmt.Println(r.Context().Digest(rebasedDigest.String()))
@@ -166,7 +186,7 @@ func rebaseImage(orig v1.Image, oldBase, newBase string, opt ...crane.Option) (v
 	if oldBase == "" && m.Annotations != nil {
 		oldBase = m.Annotations[specsv1.AnnotationBaseImageDigest]
 		if oldBase != "" {
-			newBaseRef, err := name.ParseReference(newBase)
+			newBaseRef, err := name.ParseReference(newBase, crane.GetOptions(opt...).Name...)
 			if err != nil {
 				return nil, err
 			}
@@ -13,11 +13,13 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/docker/cli/cli/config"
 	"github.com/google/go-containerregistry/internal/cmd"
@@ -41,6 +43,8 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 	insecure := false
 	ndlayers := false
 	platform := &platformValue{}
+	var timeout time.Duration
+	cancel := func() {}
 
 	root := &cobra.Command{
 		Use:               use,
@@ -48,8 +52,29 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 		RunE:              func(cmd *cobra.Command, _ []string) error { return cmd.Usage() },
 		DisableAutoGenTag: true,
 		SilenceUsage:      true,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			options = append(options, crane.WithContext(cmd.Context()))
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Without somewhere to look for one, e.g. if $HOME is unset,
+			// there's no config file, but the environment still applies.
+			path, err := crane.ConfigPath()
+			if err != nil {
+				logs.Debug.Printf("not reading a config file: %v", err)
+				path = ""
+			}
+			settings, err := crane.LoadConfig(path)
+			if err != nil {
+				return err
+			}
+			settingsOpts, err := settings.Options()
+			if err != nil {
+				return configErr(path, err)
+			}
+
+			ctx := cmd.Context()
+			if timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				cmd.SetContext(ctx)
+			}
+			options = append(options, crane.WithContext(ctx))
 			// TODO(jonjohnsonjr): crane.Verbose option?
 			if verbose {
 				logs.Debug.SetOutput(os.Stderr)
@@ -68,14 +93,23 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 				options = append(options, crane.WithUserAgent(fmt.Sprintf("%s/%s", binary, Version)))
 			}
 
-			options = append(options, crane.WithPlatform(platform.platform))
+			options = append(options, settingsOpts...)
+
+			// Only let --platform override the config file's if it's set, so
+			// that its default doesn't.
+			if cmd.Flags().Changed("platform") || settings.Platform == "" {
+				options = append(options, crane.WithPlatform(platform.platform))
+			}
 
 			transport := remote.DefaultTransport.(*http.Transport).Clone()
 			transport.TLSClientConfig = &tls.Config{
 				InsecureSkipVerify: insecure, //nolint: gosec
 			}
 
-			var rt http.RoundTripper = transport
+			rt, err := settings.Transport(transport)
+			if err != nil {
+				return configErr(path, err)
+			}
 
 			// Add any http headers if they are set in the config file.
 			cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
@@ -89,31 +123,48 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 			}
 
 			options = append(options, crane.WithTransport(rt))
+			return nil
+		},
+		PersistentPostRun: func(*cobra.Command, []string) {
+			cancel()
 		},
 	}
 
 	commands := []*cobra.Command{
 		NewCmdAppend(&options),
-		NewCmdAuth(options, "crane", "auth"),
+		NewCmdAuth(&options, "crane", "auth"),
 		NewCmdBlob(&options),
 		NewCmdCatalog(&options),
 		NewCmdConfig(&options),
+		NewCmdConvert(&options),
 		NewCmdCopy(&options),
 		NewCmdDelete(&options),
+		NewCmdDiff(&options),
 		NewCmdDigest(&options),
 		cmd.NewCmdEdit(&options),
 		NewCmdExport(&options),
 		NewCmdFlatten(&options),
+		NewCmdGC(),
+		NewCmdImport(&options),
+		NewCmdIndex(&options),
+		NewCmdLint(&options),
 		NewCmdList(&options),
 		NewCmdManifest(&options),
+		NewCmdMirror(&options),
 		NewCmdMutate(&options),
 		NewCmdOptimize(&options),
+		NewCmdPromote(&options),
 		NewCmdPull(&options),
 		NewCmdPush(&options),
+		NewCmdRdeps(&options),
 		NewCmdRebase(&options),
 		NewCmdTag(&options),
+		NewCmdTriangulate(&options),
 		NewCmdValidate(&options),
+		NewCmdVerifyPin(&options),
+		NewCmdVerifyPullThrough(&options),
 		NewCmdVersion(),
+		NewCmdWatch(&options),
 	}
 
 	root.AddCommand(commands...)
@@ -121,6 +172,7 @@ func New(use, short string, options []crane.Option) *cobra.Command {
 	root.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable debug logs")
 	root.PersistentFlags().BoolVar(&insecure, "insecure", false, "Allow image references to be fetched without TLS")
 	root.PersistentFlags().BoolVar(&ndlayers, "allow-nondistributable-artifacts", false, "Allow pushing non-distributable (foreign) layers")
+	root.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Give up on the command after this long (e.g. 5m). No timeout if unset.")
 	root.PersistentFlags().Var(platform, "platform", "Specifies the platform in the form os/arch[/variant][:osversion] (e.g. linux/amd64).")
 
 	return root
@@ -144,3 +196,11 @@ func (ht *headerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 	}
 	return ht.inner.RoundTrip(in)
 }
+
+// configErr annotates err with the config file it came from, if any.
+func configErr(path string, err error) error {
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
@@ -0,0 +1,282 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	authchallenge "github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// oauthMetadata is the subset of RFC 8414 authorization server metadata that
+// the device flow needs.
+type oauthMetadata struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.2
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5
+type deviceToken struct {
+	RefreshToken string `json:"refresh_token"`
+	Error        string `json:"error"`
+}
+
+// deviceFlow completes the OAuth 2.0 device flow (RFC 8628) of the
+// authorization server that issues tokens for a registry.
+type deviceFlow struct {
+	client *http.Client
+	out    io.Writer
+
+	// now, sleep and browse are time.Now, a time.Sleep that can be
+	// cancelled, and openBrowser, except in tests.
+	now    func() time.Time
+	sleep  func(context.Context, time.Duration) error
+	browse func(string) error
+}
+
+func newDeviceFlow(t http.RoundTripper, out io.Writer) *deviceFlow {
+	return &deviceFlow{
+		client: &http.Client{Transport: t},
+		out:    out,
+		now:    time.Now,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(d):
+				return nil
+			}
+		},
+		browse: openBrowser,
+	}
+}
+
+// login completes the device flow for reg, opening the user's browser to
+// approve it, and returns the resulting refresh token.
+//
+// Registries advertise support by serving RFC 8414 metadata with a
+// device_authorization_endpoint from the host of the realm of their Bearer
+// challenge.
+func (d *deviceFlow) login(ctx context.Context, reg name.Registry, clientID string) (string, error) {
+	md, err := d.discover(ctx, reg)
+	if err != nil {
+		return "", err
+	}
+
+	var da deviceAuthorization
+	if err := d.postForm(ctx, md.DeviceAuthorizationEndpoint, url.Values{"client_id": {clientID}}, &da); err != nil {
+		return "", fmt.Errorf("starting device authorization: %w", err)
+	}
+	if da.DeviceCode == "" || da.VerificationURI == "" {
+		return "", errors.New("starting device authorization: response is missing device_code or verification_uri")
+	}
+	// The user is sent to these, so don't let the server pick anything but a
+	// web page.
+	for _, u := range []string{da.VerificationURI, da.VerificationURIComplete} {
+		if err := checkHTTPS(u); u != "" && err != nil {
+			return "", fmt.Errorf("starting device authorization: %w", err)
+		}
+	}
+
+	verify := da.VerificationURIComplete
+	if verify == "" {
+		verify = da.VerificationURI
+	}
+	fmt.Fprintf(d.out, "To log in to %s, visit %s and enter the code %s\n", reg, da.VerificationURI, da.UserCode)
+	if err := d.browse(verify); err != nil {
+		fmt.Fprintf(d.out, "Could not open a browser: %v\n", err)
+	}
+
+	return d.poll(ctx, md.TokenEndpoint, clientID, &da)
+}
+
+// poll asks the token endpoint for the token of da until the user approves or
+// denies the login, or it expires.
+func (d *deviceFlow) poll(ctx context.Context, tokenEndpoint, clientID string, da *deviceAuthorization) (string, error) {
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expires := time.Duration(da.ExpiresIn) * time.Second
+	if expires <= 0 {
+		expires = 10 * time.Minute
+	}
+	deadline := d.now().Add(expires)
+
+	form := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {da.DeviceCode},
+		"client_id":   {clientID},
+	}
+	for {
+		if err := d.sleep(ctx, interval); err != nil {
+			return "", err
+		}
+		if d.now().After(deadline) {
+			return "", errors.New("timed out waiting for the login to be approved")
+		}
+
+		var tok deviceToken
+		if err := d.postForm(ctx, tokenEndpoint, form, &tok); err != nil && tok.Error == "" {
+			return "", fmt.Errorf("polling for token: %w", err)
+		}
+		// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+		switch tok.Error {
+		case "":
+			if tok.RefreshToken == "" {
+				return "", errors.New("the authorization server did not issue a refresh token")
+			}
+			return tok.RefreshToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return "", errors.New("the login was denied")
+		case "expired_token":
+			return "", errors.New("the login expired before it was approved")
+		default:
+			return "", fmt.Errorf("polling for token: %s", tok.Error)
+		}
+	}
+}
+
+// discover pings reg and fetches the authorization server metadata for the
+// realm of its Bearer challenge.
+func (d *deviceFlow) discover(ctx context.Context, reg name.Registry) (*oauthMetadata, error) {
+	u := fmt.Sprintf("%s://%s/v2/", reg.Scheme(), reg.RegistryStr())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	var realm string
+	for _, c := range authchallenge.ResponseChallenges(resp) {
+		if strings.EqualFold(c.Scheme, "bearer") {
+			realm = c.Parameters["realm"]
+		}
+	}
+	if realm == "" {
+		return nil, fmt.Errorf("%s does not use token authentication, so it doesn't support --sso", reg)
+	}
+	ru, err := url.Parse(realm)
+	if err != nil {
+		return nil, fmt.Errorf("parsing realm %q: %w", realm, err)
+	}
+
+	wk := &url.URL{Scheme: ru.Scheme, Host: ru.Host, Path: "/.well-known/oauth-authorization-server"}
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, wk.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var md oauthMetadata
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&md); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", wk, err)
+		}
+	}
+	if md.DeviceAuthorizationEndpoint == "" || md.TokenEndpoint == "" {
+		return nil, fmt.Errorf("%s does not advertise an OAuth device flow at %s, so it doesn't support --sso", reg, wk)
+	}
+	return &md, nil
+}
+
+// postForm posts form to u and decodes the JSON response into v, which is
+// also done for error responses, since OAuth errors are JSON.
+func (d *deviceFlow) postForm(ctx context.Context, u string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unexpected response from %s (%s): %s", u, resp.Status, b)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from %s: %s", u, resp.Status)
+	}
+	return nil
+}
+
+// checkHTTPS returns an error unless u is an absolute https URL.
+func checkHTTPS(u string) error {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", u, err)
+	}
+	if pu.Scheme != "https" || pu.Host == "" {
+		return fmt.Errorf("refusing to open %q, which isn't an https URL", u)
+	}
+	return nil
+}
+
+// openBrowser opens u, which must be an https URL, in the user's default
+// browser.
+func openBrowser(u string) error {
+	if err := checkHTTPS(u); err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// fakeAuthServer is a registry and authorization server that answers token
+// polls with responses, in order, repeating the last one.
+func fakeAuthServer(t *testing.T, da deviceAuthorization, responses ...string) (*httptest.Server, *int) {
+	t.Helper()
+	polls := 0
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token"`, s.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/.well-known/oauth-authorization-server":
+			json.NewEncoder(w).Encode(oauthMetadata{
+				DeviceAuthorizationEndpoint: s.URL + "/device",
+				TokenEndpoint:               s.URL + "/token",
+			})
+		case "/device":
+			json.NewEncoder(w).Encode(da)
+		case "/token":
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+			}
+			if got, want := r.Form.Get("device_code"), da.DeviceCode; got != want {
+				t.Errorf("device_code = %q, want %q", got, want)
+			}
+			resp := responses[len(responses)-1]
+			if polls < len(responses) {
+				resp = responses[polls]
+			}
+			polls++
+			if strings.Contains(resp, `"error"`) {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			fmt.Fprint(w, resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, &polls
+}
+
+// fakeFlow returns a deviceFlow whose clock only advances when it sleeps, and
+// the sleeps it has done.
+func fakeFlow(browsed *[]string) (*deviceFlow, *[]time.Duration) {
+	var slept []time.Duration
+	now := time.Unix(0, 0)
+	d := newDeviceFlow(http.DefaultTransport, ioutil.Discard)
+	d.now = func() time.Time { return now }
+	d.sleep = func(_ context.Context, dur time.Duration) error {
+		slept = append(slept, dur)
+		now = now.Add(dur)
+		return nil
+	}
+	d.browse = func(u string) error {
+		*browsed = append(*browsed, u)
+		return nil
+	}
+	return d, &slept
+}
+
+func TestDeviceFlow(t *testing.T) {
+	pending := `{"error":"authorization_pending"}`
+	slowDown := `{"error":"slow_down"}`
+	da := deviceAuthorization{
+		DeviceCode:              "device",
+		UserCode:                "ABCD-EFGH",
+		VerificationURI:         "https://example.com/device",
+		VerificationURIComplete: "https://example.com/device?code=ABCD-EFGH",
+		Interval:                2,
+		ExpiresIn:               60,
+	}
+
+	for _, tc := range []struct {
+		name      string
+		da        deviceAuthorization
+		responses []string
+		want      string
+		wantErr   string
+		wantSlept []time.Duration
+		wantPolls int
+	}{{
+		name:      "approved",
+		da:        da,
+		responses: []string{pending, slowDown, pending, `{"refresh_token":"hunter2"}`},
+		want:      "hunter2",
+		wantSlept: []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second},
+		wantPolls: 4,
+	}, {
+		name: "default interval",
+		da: deviceAuthorization{
+			DeviceCode:      "device",
+			VerificationURI: "https://example.com/device",
+		},
+		responses: []string{slowDown, `{"refresh_token":"hunter2"}`},
+		want:      "hunter2",
+		wantSlept: []time.Duration{5 * time.Second, 10 * time.Second},
+		wantPolls: 2,
+	}, {
+		name: "expired",
+		da: deviceAuthorization{
+			DeviceCode:      "device",
+			VerificationURI: "https://example.com/device",
+			Interval:        4,
+			ExpiresIn:       10,
+		},
+		responses: []string{pending},
+		wantErr:   "timed out",
+		wantSlept: []time.Duration{4 * time.Second, 4 * time.Second, 4 * time.Second},
+		wantPolls: 2,
+	}, {
+		name:      "expired token",
+		da:        da,
+		responses: []string{pending, `{"error":"expired_token"}`},
+		wantErr:   "expired",
+		wantSlept: []time.Duration{2 * time.Second, 2 * time.Second},
+		wantPolls: 2,
+	}, {
+		name:      "denied",
+		da:        da,
+		responses: []string{`{"error":"access_denied"}`},
+		wantErr:   "denied",
+		wantSlept: []time.Duration{2 * time.Second},
+		wantPolls: 1,
+	}, {
+		name: "not https",
+		da: deviceAuthorization{
+			DeviceCode:      "device",
+			VerificationURI: "file:///etc/passwd",
+		},
+		responses: []string{`{"refresh_token":"hunter2"}`},
+		wantErr:   "https",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, polls := fakeAuthServer(t, tc.da, tc.responses...)
+			reg, err := name.NewRegistry(strings.TrimPrefix(s.URL, "http://"), name.Insecure)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var browsed []string
+			d, slept := fakeFlow(&browsed)
+			got, err := d.login(context.Background(), reg, "crane")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("login() = %v, wanted error containing %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("login() = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("login() = %q, want %q", got, tc.want)
+			}
+			if diff := cmp.Diff(tc.wantSlept, *slept); diff != "" {
+				t.Errorf("slept (-want +got) = %s", diff)
+			}
+			if *polls != tc.wantPolls {
+				t.Errorf("polled %d times, want %d", *polls, tc.wantPolls)
+			}
+			var wantBrowsed []string
+			if tc.wantErr != "https" {
+				wantBrowsed = []string{tc.da.VerificationURIComplete}
+				if wantBrowsed[0] == "" {
+					wantBrowsed[0] = tc.da.VerificationURI
+				}
+			}
+			if diff := cmp.Diff(wantBrowsed, browsed); diff != "" {
+				t.Errorf("browsed (-want +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestOpenBrowserRefusesNonHTTPS(t *testing.T) {
+	for _, u := range []string{
+		"http://example.com/device",
+		"file:///etc/passwd",
+		"javascript:alert(1)",
+		"calc.exe",
+		"https:///no-host",
+	} {
+		if err := openBrowser(u); err == nil {
+			t.Errorf("openBrowser(%q) = nil, wanted error", u)
+		}
+	}
+}
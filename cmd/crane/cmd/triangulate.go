@@ -0,0 +1,52 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdTriangulate creates a new cobra.Command for the triangulate subcommand.
+func NewCmdTriangulate(options *[]crane.Option) *cobra.Command {
+	var typ string
+	cmd := &cobra.Command{
+		Use:   "triangulate IMAGE",
+		Short: "Print the tag where cosign stores signatures, attestations or SBOMs for an image",
+		Example: `  # Find the signature tag of an image
+  crane triangulate ubuntu
+
+  # Find the attestation tag
+  crane triangulate ubuntu --type att`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch typ {
+			case "sig", "att", "sbom":
+			default:
+				return fmt.Errorf("--type must be one of sig, att or sbom, got %q", typ)
+			}
+			tag, err := crane.Triangulate(args[0], typ, *options...)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), tag)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&typ, "type", "sig", "Type of attachment: sig, att or sbom")
+	return cmd
+}
@@ -71,3 +71,18 @@ func parsePlatform(platform string) (*v1.Platform, error) {
 
 	return p, nil
 }
+
+// parsePlatforms parses each of platforms, ignoring "all".
+func parsePlatforms(platforms []string) ([]v1.Platform, error) {
+	parsed := make([]v1.Platform, 0, len(platforms))
+	for _, s := range platforms {
+		p, err := parsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			parsed = append(parsed, *p)
+		}
+	}
+	return parsed, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdWatch creates a new cobra.Command for the watch subcommand.
+func NewCmdWatch(options *[]crane.Option) *cobra.Command {
+	var interval time.Duration
+	var command string
+
+	cmd := &cobra.Command{
+		Use:   "watch REF",
+		Short: "Watch a tag and report or act on changes to its digest",
+		Long: `Watch a tag and report or act on changes to its digest.
+
+REF is polled every --interval. Whenever the digest it resolves to changes, the
+--exec command is run by "sh -c", with any {} in it replaced by REF pinned to
+the new digest, so it can use quotes, pipes and the like. Without --exec, a
+line with the time, REF, and old and new digests is printed instead. The digest
+REF resolves to at startup is not considered a change.`,
+		Example: `# Print a line whenever ubuntu:latest moves
+crane watch ubuntu:latest
+
+# Roll out new builds of app:main as they're pushed
+crane watch --interval 1m --exec "kubectl set image deployment/app app={}" \
+  registry.example.com/app:main`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref := args[0]
+
+			f := func(ev crane.WatchEvent) error {
+				_, err := fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s -> %s\n", ev.Time.Format(time.RFC3339), ev.Reference, ev.Previous, ev.Digest)
+				return err
+			}
+			if command != "" {
+				if strings.TrimSpace(command) == "" {
+					return errors.New("--exec: empty command")
+				}
+				r, err := name.ParseReference(ref, crane.GetOptions(*options...).Name...)
+				if err != nil {
+					return fmt.Errorf("parsing reference %q: %w", ref, err)
+				}
+				f = execWatch(cmd.Context(), r.Context(), command, cmd.OutOrStdout(), cmd.ErrOrStderr())
+			}
+
+			err := crane.Watch(cmd.Context(), ref, interval, f, *options...)
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "How often to poll REF")
+	cmd.Flags().StringVar(&command, "exec", "", "Shell command to run when REF changes, with {} replaced by REF@digest")
+
+	return cmd
+}
+
+// execWatch returns a callback for crane.Watch that runs command with sh,
+// with {} replaced by repo pinned to the new digest, until ctx is done. A
+// pinned reference only has characters that are safe in a shell command.
+// Failures are logged rather than returned, so one bad run doesn't end the
+// watch.
+func execWatch(ctx context.Context, repo name.Repository, command string, stdout, stderr io.Writer) func(crane.WatchEvent) error {
+	return func(ev crane.WatchEvent) error {
+		script := strings.ReplaceAll(command, "{}", repo.Digest(ev.Digest).String())
+		c := exec.CommandContext(ctx, "sh", "-c", script)
+		c.Stdout = stdout
+		c.Stderr = stderr
+		if err := c.Run(); err != nil {
+			logs.Warn.Printf("%s: %v", script, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestExecWatch(t *testing.T) {
+	repo, err := name.NewRepository("registry.example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := "sha256:" + fmt.Sprintf("%064x", 1)
+	out := filepath.Join(t.TempDir(), "out")
+
+	// The command is run by a shell, so quotes and redirection work.
+	f := execWatch(context.Background(), repo, fmt.Sprintf(`echo "new: {}" > %q`, out), ioutil.Discard, ioutil.Discard)
+	if err := f(crane.WatchEvent{Digest: digest}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "new: registry.example.com/app@"+digest+"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Nothing is run once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	other := filepath.Join(t.TempDir(), "other")
+	f = execWatch(ctx, repo, fmt.Sprintf("touch %q", other), ioutil.Discard, ioutil.Discard)
+	if err := f(crane.WatchEvent{Digest: digest}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(other); err == nil {
+		t.Error("command ran after the context was done")
+	}
+}
@@ -198,8 +198,10 @@ func editConfig(in io.Reader, out io.Writer, src, dst string, options ...crane.O
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := ref.(name.Digest); ok {
-			dst = ref.Context().Digest(digest.String()).String()
+		if dt, ok := name.AsDigestTag(ref); ok {
+			dst = dt.Tag().String()
+		} else if r, ok := ref.(name.Digest); ok {
+			dst = r.Context().Digest(digest.String()).String()
 		}
 	}
 
@@ -249,8 +251,10 @@ func editManifest(in io.Reader, out io.Writer, src string, dst string, options .
 
 	if dst == "" {
 		dst = src
-		if _, ok := ref.(name.Digest); ok {
-			dst = ref.Context().Digest(digest.String()).String()
+		if dt, ok := name.AsDigestTag(ref); ok {
+			dst = dt.Tag().String()
+		} else if r, ok := ref.(name.Digest); ok {
+			dst = r.Context().Digest(digest.String()).String()
 		}
 	}
 	dstRef, err := name.ParseReference(dst, o.Name...)
@@ -351,8 +355,10 @@ func editFile(in io.Reader, out io.Writer, src, file, dst string, options ...cra
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := ref.(name.Digest); ok {
-			dst = ref.Context().Digest(digest.String()).String()
+		if dt, ok := name.AsDigestTag(ref); ok {
+			dst = dt.Tag().String()
+		} else if r, ok := ref.(name.Digest); ok {
+			dst = r.Context().Digest(digest.String()).String()
 		}
 	}
 
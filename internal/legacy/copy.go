@@ -26,6 +26,13 @@ import (
 // CopySchema1 allows `[g]crane cp` to work with old images without adding
 // full support for schema 1 images to this package.
 func CopySchema1(desc *remote.Descriptor, srcRef, dstRef name.Reference, opts ...remote.Option) error {
+	return CopySchema1With(desc, srcRef, dstRef, opts, func() []remote.Option { return opts })
+}
+
+// CopySchema1With is like CopySchema1, but calls writeOpts for the options of
+// each write, e.g. to pass each one its own WithEvents channel, which every
+// write closes.
+func CopySchema1With(desc *remote.Descriptor, srcRef, dstRef name.Reference, opts []remote.Option, writeOpts func() []remote.Option) error {
 	m := schema1{}
 	if err := json.NewDecoder(bytes.NewReader(desc.Manifest)).Decode(&m); err != nil {
 		return err
@@ -40,12 +47,12 @@ func CopySchema1(desc *remote.Descriptor, srcRef, dstRef name.Reference, opts ..
 			return err
 		}
 
-		if err := remote.WriteLayer(dst.Context(), blob, opts...); err != nil {
+		if err := remote.WriteLayer(dst.Context(), blob, writeOpts()...); err != nil {
 			return err
 		}
 	}
 
-	return remote.Put(dstRef, desc, opts...)
+	return remote.Put(dstRef, desc, writeOpts()...)
 }
 
 type fslayer struct {
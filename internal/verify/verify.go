@@ -45,6 +45,16 @@ type Error struct {
 	gotSize int64
 }
 
+// NewError returns an Error for gotSize bytes of content whose digest is got,
+// rather than want.
+func NewError(got, want v1.Hash, gotSize int64) Error {
+	return Error{
+		got:     got.String(),
+		want:    want,
+		gotSize: gotSize,
+	}
+}
+
 func (v Error) Error() string {
 	return fmt.Sprintf("error verifying %s checksum after reading %d bytes; got %q, want %q",
 		v.want.Algorithm, v.gotSize, v.got, v.want)
@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	want := "This is the input string."
+	buf := bytes.NewBufferString(want)
+	zipped := ReadCloser(ioutil.NopCloser(buf))
+	unzipped, err := UnzipReadCloser(zipped)
+	if err != nil {
+		t.Error("UnzipReadCloser() =", err)
+	}
+
+	b, err := ioutil.ReadAll(unzipped)
+	if err != nil {
+		t.Error("ReadAll() =", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("ReadAll(); got %q, want %q", got, want)
+	}
+	if err := unzipped.Close(); err != nil {
+		t.Error("Close() =", err)
+	}
+}
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		out bool
+	}{
+		{[]byte{}, false},
+		{[]byte{'\x28', '\xb5'}, false},
+		{[]byte{'\x1f', '\x8b', '\x1b', '\x00'}, false},
+		{[]byte{'\x28', '\xb5', '\x2f', '\xfd', '\x00'}, true},
+	}
+	for _, test := range tests {
+		got, err := Is(bytes.NewReader(test.in))
+		if err != nil {
+			t.Errorf("Is(%v) = %v", test.in, err)
+		}
+		if got != test.out {
+			t.Errorf("Is(%v); got %v, wanted %v", test.in, got, test.out)
+		}
+
+		got, pr, err := Peek(bytes.NewReader(test.in))
+		if err != nil {
+			t.Errorf("Peek(%v) = %v", test.in, err)
+		}
+		if got != test.out {
+			t.Errorf("Peek(%v); got %v, wanted %v", test.in, got, test.out)
+		}
+		// Peeking doesn't consume the input.
+		if b, err := ioutil.ReadAll(pr); err != nil || !bytes.Equal(b, test.in) {
+			t.Errorf("ReadAll(Peek(%v)) = %v, %v", test.in, b, err)
+		}
+	}
+
+	zipped, err := ioutil.ReadAll(ReadCloser(ioutil.NopCloser(strings.NewReader("zip me"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Is(bytes.NewReader(zipped)); err != nil || !ok {
+		t.Errorf("Is(zipped) = %v, %v", ok, err)
+	}
+}
@@ -87,6 +87,32 @@ func NewNoClient(ctx context.Context) (authn.Keychain, error) {
 	), nil
 }
 
+// NewNodeIdentity returns a new authn.Keychain that resolves credentials with
+// the Google, Amazon and Azure keychains. Unlike NewNoClient, it doesn't read
+// the Docker config file either, so node-level agents (e.g. device plugins and
+// CSI drivers) that pull images need neither a Kubernetes client nor RBAC to
+// read secrets.
+//
+// On a node, these keychains usually find the node's identity, from the GCE
+// metadata server, EC2 instance role or Azure managed identity. They look in
+// the environment and on disk first, though, so they authenticate as whatever
+// they find there instead:
+//
+//   - Google: GOOGLE_APPLICATION_CREDENTIALS and gcloud's application default
+//     credentials file, then the metadata server, then the gcloud CLI.
+//   - Amazon: the AWS SDK's default chain of AWS_* environment variables, the
+//     shared config and credentials files and web identity tokens, then the
+//     ECS task or EC2 instance role.
+//   - Azure: the AZURE_* environment variables for a service principal, then
+//     the managed identity.
+func NewNodeIdentity() authn.Keychain {
+	return authn.NewMultiKeychain(
+		google.Keychain,
+		amazonKeychain,
+		azureKeychain,
+	)
+}
+
 // NewFromPullSecrets returns a new authn.Keychain suitable for resolving image references as
 // scoped by the pull secrets.
 func NewFromPullSecrets(ctx context.Context, pullSecrets []corev1.Secret) (authn.Keychain, error) {
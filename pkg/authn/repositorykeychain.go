@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+type repositoryKeychain struct {
+	// prefixes maps normalized registry or repository names to credentials.
+	prefixes map[string]Authenticator
+}
+
+// Assert that our repository keychain implements Keychain.
+var _ (Keychain) = (*repositoryKeychain)(nil)
+
+// NewRepositoryKeychain returns a Keychain that resolves each target to the
+// credential for the longest prefix of it in creds, for registries that issue
+// different credentials per repository, e.g. per GitLab project.
+//
+// Keys of creds are registries (e.g. "registry.gitlab.com") or repositories
+// (e.g. "registry.gitlab.com/group/project"). They match whole path
+// components, so "registry.gitlab.com/group" matches
+// "registry.gitlab.com/group/project" but not "registry.gitlab.com/groups".
+// Targets that match no key resolve to Anonymous.
+func NewRepositoryKeychain(creds map[string]Authenticator) (Keychain, error) {
+	prefixes := make(map[string]Authenticator, len(creds))
+	for prefix, auth := range creds {
+		// Normalize the registry so that e.g. "docker.io/foo" matches the
+		// index.docker.io/foo/bar repository. The rest is kept as-is, since
+		// parsing it as a repository would add "library/" to "foo".
+		parts := strings.SplitN(prefix, "/", 2)
+		reg, err := name.NewRegistry(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("parsing registry of %q: %w", prefix, err)
+		}
+		normalized := reg.Name()
+		if len(parts) == 2 {
+			if _, err := name.NewRepository(prefix); err != nil {
+				return nil, fmt.Errorf("parsing repository %q: %w", prefix, err)
+			}
+			normalized += "/" + parts[1]
+		}
+		prefixes[normalized] = auth
+	}
+	return &repositoryKeychain{prefixes: prefixes}, nil
+}
+
+// Resolve implements Keychain.
+func (rk *repositoryKeychain) Resolve(target Resource) (Authenticator, error) {
+	// Try the target, then each of its parents in turn.
+	for s := target.String(); s != ""; {
+		if auth, ok := rk.prefixes[s]; ok {
+			return auth, nil
+		}
+		i := strings.LastIndex(s, "/")
+		if i < 0 {
+			break
+		}
+		s = s[:i]
+	}
+	return Anonymous, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestRepositoryKeychain(t *testing.T) {
+	registry := &Basic{Username: "registry", Password: "secret"}
+	group := &Basic{Username: "group", Password: "secret"}
+	project := &Basic{Username: "project", Password: "secret"}
+	hub := &Basic{Username: "hub", Password: "secret"}
+
+	kc, err := NewRepositoryKeychain(map[string]Authenticator{
+		"registry.gitlab.com":                 registry,
+		"registry.gitlab.com/group":           group,
+		"registry.gitlab.com/group/project":   project,
+		"docker.io/someone":                   hub,
+		"registry.gitlab.com/other/project/x": Anonymous,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		target string
+		want   Authenticator
+	}{
+		{"registry.gitlab.com/group/project", project},
+		{"registry.gitlab.com/group/project/image", project},
+		{"registry.gitlab.com/group/other", group},
+		{"registry.gitlab.com/groups/project", registry},
+		{"registry.gitlab.com/other/project", registry},
+		{"someone/image", hub},
+		{"index.docker.io/someone/image", hub},
+		{"library/ubuntu", Anonymous},
+		{"gcr.io/group/project", Anonymous},
+	} {
+		repo, err := name.NewRepository(test.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := kc.Resolve(repo)
+		if err != nil {
+			t.Errorf("Resolve(%s) = %v", test.target, err)
+		}
+		if got != test.want {
+			t.Errorf("Resolve(%s) = %v, want %v", test.target, got, test.want)
+		}
+	}
+
+	// Registries only match registry credentials.
+	reg, err := name.NewRegistry("registry.gitlab.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := kc.Resolve(reg); err != nil || got != registry {
+		t.Errorf("Resolve(%s) = %v, %v, want %v", reg, got, err, registry)
+	}
+
+	if _, err := NewRepositoryKeychain(map[string]Authenticator{"Not/A/Repo!": registry}); err == nil {
+		t.Error("expected error for invalid prefix")
+	}
+}
@@ -15,12 +15,21 @@
 package crane_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
 
 func TestAppendWithOCIBaseImage(t *testing.T) {
@@ -71,3 +80,107 @@ func TestAppendWithDockerBaseImage(t *testing.T) {
 		t.Errorf("MediaType(): want %q, got %q", want, got)
 	}
 }
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAppendFromURL(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffID, err := layer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(b)
+	}))
+	defer blobs.Close()
+
+	reg := httptest.NewServer(registry.New())
+	defer reg.Close()
+	u, err := url.Parse(reg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := fmt.Sprintf("%s/test/append:url", u.Host)
+
+	// The layer is fetched with the transport from the options.
+	var fetched int32
+	counting := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&fetched, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	t.Run("verified", func(t *testing.T) {
+		img, err := crane.AppendFromURL(empty.Image, []string{blobs.URL + "/layer.tar.gz@" + digest.String()}, crane.WithTransport(counting))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crane.Push(img, dst); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&fetched) == 0 {
+			t.Error("the layer wasn't fetched with the transport from WithTransport")
+		}
+
+		pulled, err := crane.Pull(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers, err := pulled.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(layers) != 1 {
+			t.Fatalf("got %d layers, want 1", len(layers))
+		}
+		if got, err := layers[0].Digest(); err != nil {
+			t.Fatal(err)
+		} else if got != digest {
+			t.Errorf("Digest(): got %s, want %s", got, digest)
+		}
+		if got, err := layers[0].DiffID(); err != nil {
+			t.Fatal(err)
+		} else if got != diffID {
+			t.Errorf("DiffID(): got %s, want %s", got, diffID)
+		}
+		if err := validate.Image(pulled); err != nil {
+			t.Errorf("validate.Image(): %v", err)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		wrong := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		img, err := crane.AppendFromURL(empty.Image, []string{blobs.URL + "/layer.tar.gz@" + wrong})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crane.Push(img, dst); err == nil {
+			t.Error("Push(): expected digest mismatch error")
+		}
+	})
+
+	t.Run("missing digest", func(t *testing.T) {
+		if _, err := crane.AppendFromURL(empty.Image, []string{blobs.URL + "/layer.tar.gz"}); err == nil {
+			t.Error("AppendFromURL(): expected error for URL without digest")
+		}
+	})
+}
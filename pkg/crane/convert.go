@@ -0,0 +1,323 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+const (
+	// DockerArchive is the format of tarballs produced by `docker save`.
+	DockerArchive = "docker-archive"
+	// OCIArchive is the format of tarballs of an OCI image layout.
+	OCIArchive = "oci-archive"
+
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// Convert converts the archive at src to another format, without involving a
+// registry. Both src and dst are of the form FORMAT:PATH, where FORMAT is
+// DockerArchive or OCIArchive.
+//
+// Tags in a docker-archive are kept as the "org.opencontainers.image.ref.name"
+// annotation in an oci-archive, and vice versa. Since a docker-archive can only
+// hold images, converting an oci-archive containing an index requires
+// WithPlatform to choose one of its images.
+func Convert(src, dst string, opt ...Option) error {
+	o := makeOptions(opt...)
+	srcFormat, srcPath, err := parseArchive(src)
+	if err != nil {
+		return err
+	}
+	dstFormat, dstPath, err := parseArchive(dst)
+	if err != nil {
+		return err
+	}
+
+	// Everything passes through an OCI image layout in a temporary directory.
+	dir, err := ioutil.TempDir("", "crane-convert")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	switch srcFormat {
+	case DockerArchive:
+		if err := dockerArchiveToLayout(srcPath, dir); err != nil {
+			return fmt.Errorf("reading %s: %w", src, err)
+		}
+	case OCIArchive:
+		if err := untar(srcPath, dir); err != nil {
+			return fmt.Errorf("reading %s: %w", src, err)
+		}
+	}
+
+	switch dstFormat {
+	case DockerArchive:
+		if err := layoutToDockerArchive(layout.Path(dir), dstPath, o); err != nil {
+			return fmt.Errorf("writing %s: %w", dst, err)
+		}
+	case OCIArchive:
+		if err := tarDir(dir, dstPath); err != nil {
+			return fmt.Errorf("writing %s: %w", dst, err)
+		}
+	}
+	return nil
+}
+
+func parseArchive(s string) (format, path string, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		switch parts[0] {
+		case DockerArchive, OCIArchive:
+			return parts[0], parts[1], nil
+		}
+	}
+	return "", "", fmt.Errorf("%q must be of the form %s:PATH or %s:PATH", s, DockerArchive, OCIArchive)
+}
+
+// dockerArchiveToLayout writes the images in the docker-archive at path to an
+// OCI image layout in dir, annotated with their tags.
+func dockerArchiveToLayout(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	opener := tarball.ReaderAtOpener(f, fi.Size())
+
+	m, err := tarball.LoadManifest(opener)
+	if err != nil {
+		return err
+	}
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return err
+	}
+	for _, desc := range m {
+		if len(desc.RepoTags) == 0 {
+			// Untagged images can only be found if they're alone.
+			img, err := tarball.Image(opener, nil)
+			if err != nil {
+				return err
+			}
+			if err := p.AppendImage(img); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, t := range desc.RepoTags {
+			tag, err := name.NewTag(t)
+			if err != nil {
+				return err
+			}
+			img, err := tarball.Image(opener, &tag)
+			if err != nil {
+				return err
+			}
+			if err := p.AppendImage(img, layout.WithAnnotations(map[string]string{
+				refNameAnnotation: tag.Name(),
+			})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// layoutToDockerArchive writes the images in the OCI image layout p to a
+// docker-archive at path, tagged by their annotations.
+func layoutToDockerArchive(p layout.Path, path string, o Options) error {
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return err
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	refToImage := map[name.Reference]v1.Image{}
+	// Reuse images tagged more than once, so that they're only written once.
+	images := map[v1.Hash]v1.Image{}
+	for _, desc := range m.Manifests {
+		img, ok := images[desc.Digest]
+		switch {
+		case ok:
+		case desc.MediaType.IsImage():
+			img, err = idx.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+		case desc.MediaType.IsIndex():
+			if o.Platform == nil {
+				return fmt.Errorf("%s is an index, which a %s can't hold without choosing a platform", desc.Digest, DockerArchive)
+			}
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			imgs, err := partial.FindImages(child, match.Platforms(*o.Platform))
+			if err != nil {
+				return err
+			}
+			if len(imgs) != 1 {
+				return fmt.Errorf("found %d images for platform %s in %s, want 1", len(imgs), o.Platform, desc.Digest)
+			}
+			img = imgs[0]
+		default:
+			return fmt.Errorf("unexpected media type %s for %s", desc.MediaType, desc.Digest)
+		}
+		images[desc.Digest] = img
+
+		// Annotations that aren't a full tag, like a bare "latest", don't say
+		// which repository to tag the image with, so leave it untagged. The
+		// digest only serves as a key here; it isn't written.
+		if tag, err := name.NewTag(desc.Annotations[refNameAnnotation], name.StrictValidation); err == nil {
+			refToImage[tag] = img
+			continue
+		}
+		ref, err := name.NewDigest("untagged@" + desc.Digest.String())
+		if err != nil {
+			return err
+		}
+		refToImage[ref] = img
+	}
+	return tarball.MultiRefWriteToFile(path, refToImage)
+}
+
+// untar extracts the tarball at path into dir.
+func untar(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if target == filepath.Clean(dir) {
+			continue
+		}
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in tarball: %q", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// tarDir writes the regular files under dir to a tarball at path.
+func tarDir(dir, path string) error {
+	var files []string
+	if err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	tw := tar.NewWriter(out)
+	for _, p := range files {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if err := addFile(tw, p, filepath.ToSlash(rel)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     fi.Size(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
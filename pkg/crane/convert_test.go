@@ -0,0 +1,186 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	one, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := map[name.Tag]v1.Image{
+		name.MustParseReference("example.com/one:v1").(name.Tag): one,
+		name.MustParseReference("example.com/one:v2").(name.Tag): one,
+		name.MustParseReference("example.com/two:v1").(name.Tag): two,
+	}
+	docker := filepath.Join(dir, "docker.tar")
+	if err := tarball.MultiWriteToFile(docker, tags); err != nil {
+		t.Fatal(err)
+	}
+
+	oci := filepath.Join(dir, "oci.tar")
+	if err := crane.Convert("docker-archive:"+docker, "oci-archive:"+oci); err != nil {
+		t.Fatalf("Convert to oci-archive: %v", err)
+	}
+	back := filepath.Join(dir, "back.tar")
+	if err := crane.Convert("oci-archive:"+oci, "docker-archive:"+back); err != nil {
+		t.Fatalf("Convert to docker-archive: %v", err)
+	}
+
+	m, err := tarball.LoadManifest(func() (io.ReadCloser, error) { return os.Open(back) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 {
+		t.Errorf("got %d images, want 2", len(m))
+	}
+	for tag, want := range tags {
+		got, err := tarball.ImageFromPath(back, &tag)
+		if err != nil {
+			t.Fatalf("ImageFromPath(%s): %v", tag, err)
+		}
+		for _, f := range []func(v1.Image) (v1.Hash, error){
+			func(img v1.Image) (v1.Hash, error) { return img.ConfigName() },
+			func(img v1.Image) (v1.Hash, error) { return img.Digest() },
+		} {
+			gh, err := f(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wh, err := f(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gh != wh {
+				t.Errorf("%s: got %s, want %s", tag, gh, wh)
+			}
+		}
+	}
+}
+
+func TestConvertIndex(t *testing.T) {
+	dir := t.TempDir()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform := &v1.Platform{OS: "linux", Architecture: "arm64"}
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: platform},
+	})
+	lp, err := layout.Write(filepath.Join(dir, "layout"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.AppendIndex(idx, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": "example.com/multi:v1",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	oci := filepath.Join(dir, "oci.tar")
+	if err := tarDir(string(lp), oci); err != nil {
+		t.Fatal(err)
+	}
+
+	docker := filepath.Join(dir, "docker.tar")
+	if err := crane.Convert("oci-archive:"+oci, "docker-archive:"+docker); err == nil {
+		t.Error("expected error converting an index without a platform")
+	}
+	if err := crane.Convert("oci-archive:"+oci, "docker-archive:"+docker, crane.WithPlatform(platform)); err != nil {
+		t.Fatalf("Convert with platform: %v", err)
+	}
+	tag := name.MustParseReference("example.com/multi:v1").(name.Tag)
+	got, err := tarball.ImageFromPath(docker, &tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gd, err := got.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gd != wd {
+		t.Errorf("got %s, want %s", gd, wd)
+	}
+}
+
+// tarDir writes the files under dir to a tarball at path, like an oci-archive.
+func tarDir(dir, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	tw := tar.NewWriter(out)
+	if err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: rel, Mode: 0644, Size: int64(len(b))}); err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		return err
+	}); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func TestConvertBadFormat(t *testing.T) {
+	for _, args := range [][2]string{
+		{"docker-archive:a.tar", "b.tar"},
+		{"tarball:a.tar", "oci-archive:b.tar"},
+		{"oci-archive:", "docker-archive:b.tar"},
+	} {
+		if err := crane.Convert(args[0], args[1]); err == nil {
+			t.Errorf("Convert(%q, %q): expected error", args[0], args[1])
+		}
+	}
+}
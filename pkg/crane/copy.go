@@ -20,27 +20,34 @@ import (
 	"github.com/google/go-containerregistry/internal/legacy"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // Copy copies a remote image or index from src to dst.
 func Copy(src, dst string, opt ...Option) error {
-	o := makeOptions(opt...)
+	_, err := copyRef(src, dst, makeOptions(opt...))
+	return err
+}
+
+// copyRef copies src to dst and returns the descriptor of what was copied.
+func copyRef(src, dst string, o Options) (*v1.Descriptor, error) {
 	srcRef, err := name.ParseReference(src, o.Name...)
 	if err != nil {
-		return fmt.Errorf("parsing reference %q: %w", src, err)
+		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
 	}
 
 	dstRef, err := name.ParseReference(dst, o.Name...)
 	if err != nil {
-		return fmt.Errorf("parsing reference for %q: %w", dst, err)
+		return nil, fmt.Errorf("parsing reference for %q: %w", dst, err)
 	}
 
 	logs.Progress.Printf("Copying from %v to %v", srcRef, dstRef)
 	desc, err := remote.Get(srcRef, o.Remote...)
 	if err != nil {
-		return fmt.Errorf("fetching %q: %w", src, err)
+		return nil, fmt.Errorf("fetching %q: %w", src, err)
 	}
 
 	switch desc.MediaType {
@@ -48,35 +55,41 @@ func Copy(src, dst string, opt ...Option) error {
 		// Handle indexes separately.
 		if o.Platform != nil {
 			// If platform is explicitly set, don't copy the whole index, just the appropriate image.
-			if err := copyImage(desc, dstRef, o); err != nil {
-				return fmt.Errorf("failed to copy image: %w", err)
-			}
-		} else {
-			if err := copyIndex(desc, dstRef, o); err != nil {
-				return fmt.Errorf("failed to copy index: %w", err)
+			d, err := copyImage(desc, dstRef, o)
+			if err != nil {
+				return nil, fmt.Errorf("failed to copy image: %w", err)
 			}
+			return d, nil
+		}
+		if err := copyIndex(desc, dstRef, o); err != nil {
+			return nil, fmt.Errorf("failed to copy index: %w", err)
 		}
 	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
 		// Handle schema 1 images separately.
-		if err := legacy.CopySchema1(desc, srcRef, dstRef, o.Remote...); err != nil {
-			return fmt.Errorf("failed to copy schema 1 image: %w", err)
+		if err := legacy.CopySchema1With(desc, srcRef, dstRef, o.Remote, o.writeOptions); err != nil {
+			return nil, fmt.Errorf("failed to copy schema 1 image: %w", err)
 		}
 	default:
 		// Assume anything else is an image, since some registries don't set mediaTypes properly.
-		if err := copyImage(desc, dstRef, o); err != nil {
-			return fmt.Errorf("failed to copy image: %w", err)
+		if _, err := copyImage(desc, dstRef, o); err != nil {
+			return nil, fmt.Errorf("failed to copy image: %w", err)
 		}
 	}
 
-	return nil
+	return &desc.Descriptor, nil
 }
 
-func copyImage(desc *remote.Descriptor, dstRef name.Reference, o Options) error {
+// copyImage copies the image desc refers to, resolving an index to the
+// platform in o, and returns the image's descriptor.
+func copyImage(desc *remote.Descriptor, dstRef name.Reference, o Options) (*v1.Descriptor, error) {
 	img, err := desc.Image()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := remote.Write(dstRef, img, o.writeOptions()...); err != nil {
+		return nil, err
 	}
-	return remote.Write(dstRef, img, o.Remote...)
+	return partial.Descriptor(img)
 }
 
 func copyIndex(desc *remote.Descriptor, dstRef name.Reference, o Options) error {
@@ -84,5 +97,5 @@ func copyIndex(desc *remote.Descriptor, dstRef name.Reference, o Options) error
 	if err != nil {
 		return err
 	}
-	return remote.WriteIndex(dstRef, idx, o.Remote...)
+	return remote.WriteIndex(dstRef, idx, o.writeOptions()...)
 }
@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/internal/legacy"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// RepositoryCopy summarizes what CopyRepository did.
+type RepositoryCopy struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Copied are the tags that were copied.
+	Copied []string `json:"copied"`
+	// Unchanged are the tags that the destination already had at the same
+	// digest, and weren't copied again.
+	Unchanged []string `json:"unchanged"`
+}
+
+// CopyRepository copies every tag of the repository src to the repository
+// dst.
+//
+// Tags that dst already has at the same digest are skipped, so an interrupted
+// copy can be resumed by calling CopyRepository again. Blobs shared by several
+// tags are only copied once, and up to WithJobs blobs are copied at a time.
+// Indexes are always copied in their entirety, whatever WithPlatform is.
+func CopyRepository(src, dst string, opt ...Option) (*RepositoryCopy, error) {
+	o := makeOptions(opt...)
+	srcRepo, err := name.NewRepository(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository %q: %w", src, err)
+	}
+	dstRepo, err := name.NewRepository(dst, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository %q: %w", dst, err)
+	}
+	return copyRepository(srcRepo, dstRepo, o)
+}
+
+func copyRepository(src, dst name.Repository, o Options) (*RepositoryCopy, error) {
+	rc := &RepositoryCopy{
+		Source:      src.String(),
+		Destination: dst.String(),
+		Copied:      []string{},
+		Unchanged:   []string{},
+	}
+	tags, err := remote.List(src, o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags of %s: %w", src, err)
+	}
+	sort.Strings(tags)
+
+	logs.Progress.Printf("Copying %d tags from %s to %s", len(tags), src, dst)
+	m := map[name.Reference]remote.Taggable{}
+	for _, tag := range tags {
+		srcTag, dstTag := src.Tag(tag), dst.Tag(tag)
+		desc, err := remote.Get(srcTag, o.Remote...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", srcTag, err)
+		}
+		if existing, err := remote.Head(dstTag, o.Remote...); err == nil && existing.Digest == desc.Digest {
+			rc.Unchanged = append(rc.Unchanged, tag)
+			continue
+		}
+
+		switch desc.MediaType {
+		case types.OCIImageIndex, types.DockerManifestList:
+			idx, err := desc.ImageIndex()
+			if err != nil {
+				return nil, err
+			}
+			m[dstTag] = idx
+		case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+			// These can't be written with MultiWrite, so copy them now.
+			if err := legacy.CopySchema1(desc, srcTag, dstTag, o.Remote...); err != nil {
+				return nil, fmt.Errorf("copying schema 1 image %s: %w", srcTag, err)
+			}
+		default:
+			img, err := desc.Image()
+			if err != nil {
+				return nil, err
+			}
+			m[dstTag] = img
+		}
+		rc.Copied = append(rc.Copied, tag)
+	}
+
+	if len(m) != 0 {
+		if err := remote.MultiWrite(m, o.Remote...); err != nil {
+			return nil, fmt.Errorf("copying %s to %s: %w", src, dst, err)
+		}
+	}
+	return rc, nil
+}
+
+// CopyRegistry copies every repository in the catalog of the registry src to
+// the same path in the registry dst, with CopyRepository.
+//
+// A repository that can't be copied is logged and skipped, so that it doesn't
+// stop the rest from being copied, and the error returned at the end lists
+// all of them. Calling CopyRegistry again resumes the copy.
+func CopyRegistry(src, dst string, opt ...Option) ([]RepositoryCopy, error) {
+	o := makeOptions(opt...)
+	srcReg, err := name.NewRegistry(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing registry %q: %w", src, err)
+	}
+	dstReg, err := name.NewRegistry(dst, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing registry %q: %w", dst, err)
+	}
+	repos, err := Catalog(src, opt...)
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories in %s: %w", srcReg, err)
+	}
+
+	copies := []RepositoryCopy{}
+	var failed []string
+	for _, repo := range repos {
+		srcRepo, err := name.NewRepository(srcReg.Name()+"/"+repo, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing repository %q: %w", repo, err)
+		}
+		dstRepo, err := name.NewRepository(dstReg.Name()+"/"+repo, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing repository %q: %w", repo, err)
+		}
+		rc, err := copyRepository(srcRepo, dstRepo, o)
+		if err != nil {
+			logs.Warn.Printf("%v", err)
+			failed = append(failed, repo)
+			continue
+		}
+		copies = append(copies, *rc)
+	}
+	if len(failed) != 0 {
+		return copies, fmt.Errorf("failed to copy %d of %d repositories: %s", len(failed), len(repos), strings.Join(failed, ", "))
+	}
+	return copies, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// seedRepository pushes an image to each of tags, and an index to "index", in
+// repo, and returns the digests of each tag.
+func seedRepository(t *testing.T, repo string, tags ...string) map[string]string {
+	t.Helper()
+	digests := map[string]string{}
+	for _, tag := range tags {
+		img, err := random.Image(1024, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := fmt.Sprintf("%s:%s", repo, tag)
+		if err := crane.Push(img, ref); err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests[tag] = d.String()
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(repo + ":index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digests["index"] = d.String()
+	return digests
+}
+
+func checkRepository(t *testing.T, repo string, want map[string]string) {
+	t.Helper()
+	for tag, d := range want {
+		got, err := crane.Digest(fmt.Sprintf("%s:%s", repo, tag))
+		if err != nil {
+			t.Errorf("Digest(%s:%s) = %v", repo, tag, err)
+			continue
+		}
+		if got != d {
+			t.Errorf("Digest(%s:%s) = %s, want %s", repo, tag, got, d)
+		}
+	}
+}
+
+func TestCopyRepository(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/src/repo", u.Host)
+	dst := fmt.Sprintf("%s/dst/repo", u.Host)
+	digests := seedRepository(t, src, "a", "b")
+
+	// Copy one tag by hand, to check that it's not copied again.
+	if err := crane.Copy(src+":a", dst+":a"); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := crane.CopyRepository(src, dst, crane.WithJobs(2))
+	if err != nil {
+		t.Fatalf("CopyRepository() = %v", err)
+	}
+	if want := []string{"b", "index"}; !cmp.Equal(rc.Copied, want) {
+		t.Errorf("Copied = %v, want %v", rc.Copied, want)
+	}
+	if want := []string{"a"}; !cmp.Equal(rc.Unchanged, want) {
+		t.Errorf("Unchanged = %v, want %v", rc.Unchanged, want)
+	}
+	checkRepository(t, dst, digests)
+
+	// Copying again is a no-op.
+	rc, err = crane.CopyRepository(src, dst)
+	if err != nil {
+		t.Fatalf("CopyRepository() = %v", err)
+	}
+	if len(rc.Copied) != 0 || len(rc.Unchanged) != 3 {
+		t.Errorf("second CopyRepository() = %+v, want everything unchanged", rc)
+	}
+}
+
+func TestCopyRegistry(t *testing.T) {
+	srcServer := httptest.NewServer(registry.New())
+	defer srcServer.Close()
+	dstServer := httptest.NewServer(registry.New())
+	defer dstServer.Close()
+	srcURL, err := url.Parse(srcServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstURL, err := url.Parse(dstServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]string{
+		"foo":     seedRepository(t, srcURL.Host+"/foo", "latest"),
+		"bar/baz": seedRepository(t, srcURL.Host+"/bar/baz", "v1", "v2"),
+	}
+
+	copies, err := crane.CopyRegistry(srcURL.Host, dstURL.Host)
+	if err != nil {
+		t.Fatalf("CopyRegistry() = %v", err)
+	}
+	var got []string
+	for _, rc := range copies {
+		got = append(got, rc.Destination)
+	}
+	sort.Strings(got)
+	if want := []string{dstURL.Host + "/bar/baz", dstURL.Host + "/foo"}; !cmp.Equal(got, want) {
+		t.Errorf("copied %v, want %v", got, want)
+	}
+	for repo, digests := range want {
+		checkRepository(t, dstURL.Host+"/"+repo, digests)
+	}
+}
@@ -179,6 +179,24 @@ func TestCraneRegistry(t *testing.T) {
 		t.Fatalf("wanted 6 tags, got %d", len(tags))
 	}
 
+	// List Tags along with their digests, which are all dst's.
+	dstDigest, err := crane.Digest(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tds, err := crane.ListTagDigests(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tds) != 6 {
+		t.Fatalf("wanted 6 tags, got %d", len(tds))
+	}
+	for i, td := range tds {
+		if td.Tag != tags[i] || td.Digest != dstDigest {
+			t.Errorf("ListTagDigests()[%d] = %v, want {%s %s}", i, td, tags[i], dstDigest)
+		}
+	}
+
 	// Delete the non existing image
 	if err := crane.Delete(dst + ":honk-image"); err == nil {
 		t.Fatal("wanted err, got nil")
@@ -565,6 +583,8 @@ func TestBadInputs(t *testing.T) {
 		{"Config(404)", e(crane.Config(valid404))},
 		{"ListTags(invalid)", e(crane.ListTags(invalid))},
 		{"ListTags(404)", e(crane.ListTags(valid404))},
+		{"ListTagDigests(invalid)", e(crane.ListTagDigests(invalid))},
+		{"ListTagDigests(404)", e(crane.ListTagDigests(valid404))},
 		{"Append(_, invalid)", e(crane.Append(nil, invalid))},
 		{"Catalog(invalid)", e(crane.Catalog(invalid))},
 		{"Catalog(404)", e(crane.Catalog(u.Host))},
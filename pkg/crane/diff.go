@@ -0,0 +1,239 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// ImageDiff describes how one image differs from another.
+type ImageDiff struct {
+	// Config lists the config fields that differ.
+	Config []ConfigChange `json:"config,omitempty"`
+	// RemovedLayers are the digests of layers only in the first image.
+	RemovedLayers []v1.Hash `json:"removedLayers,omitempty"`
+	// AddedLayers are the digests of layers only in the second image.
+	AddedLayers []v1.Hash `json:"addedLayers,omitempty"`
+}
+
+// ConfigChange is a config field that differs between two images. Old or New
+// is empty if the field is only set in one of them.
+type ConfigChange struct {
+	// Field is the name of the field, e.g. "Entrypoint", or "Env[PATH]" for
+	// a single environment variable or label.
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// FileChange is a file that differs between the filesystems of two images.
+type FileChange struct {
+	Path string `json:"path"`
+	// Change is one of "added", "removed" or "changed".
+	Change string `json:"change"`
+}
+
+// Diff compares the configs and layers of a and b.
+func Diff(a, b v1.Image) (*ImageDiff, error) {
+	acf, err := a.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+	bcf, err := b.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+
+	d := &ImageDiff{}
+	field := func(name, old, new string) {
+		if old != new {
+			d.Config = append(d.Config, ConfigChange{Field: name, Old: old, New: new})
+		}
+	}
+	field("OS", acf.OS, bcf.OS)
+	field("Architecture", acf.Architecture, bcf.Architecture)
+	field("Variant", acf.Variant, bcf.Variant)
+	field("Entrypoint", jsonString(acf.Config.Entrypoint), jsonString(bcf.Config.Entrypoint))
+	field("Cmd", jsonString(acf.Config.Cmd), jsonString(bcf.Config.Cmd))
+	field("User", acf.Config.User, bcf.Config.User)
+	field("WorkingDir", acf.Config.WorkingDir, bcf.Config.WorkingDir)
+	d.Config = append(d.Config, diffMaps("Env", envMap(acf.Config.Env), envMap(bcf.Config.Env))...)
+	d.Config = append(d.Config, diffMaps("Labels", acf.Config.Labels, bcf.Config.Labels)...)
+
+	al, err := layerDigests(a)
+	if err != nil {
+		return nil, err
+	}
+	bl, err := layerDigests(b)
+	if err != nil {
+		return nil, err
+	}
+	d.RemovedLayers = missing(al, bl)
+	d.AddedLayers = missing(bl, al)
+
+	return d, nil
+}
+
+// DiffFiles compares the flattened filesystems of a and b, as returned by
+// Export. Files are compared by their tar headers: type, mode, owner, size,
+// link target and modification time. Contents aren't compared.
+func DiffFiles(a, b v1.Image) ([]FileChange, error) {
+	af, err := fileHeaders(a)
+	if err != nil {
+		return nil, err
+	}
+	bf, err := fileHeaders(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FileChange{}
+	for p, ah := range af {
+		bh, ok := bf[p]
+		if !ok {
+			changes = append(changes, FileChange{Path: p, Change: "removed"})
+		} else if ah != bh {
+			changes = append(changes, FileChange{Path: p, Change: "changed"})
+		}
+	}
+	for p := range bf {
+		if _, ok := af[p]; !ok {
+			changes = append(changes, FileChange{Path: p, Change: "added"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// fileHeader is the part of a tar header that DiffFiles compares.
+type fileHeader struct {
+	typeflag byte
+	mode     int64
+	uid, gid int
+	size     int64
+	linkname string
+	modTime  int64
+}
+
+func fileHeaders(img v1.Image) (map[string]fileHeader, error) {
+	rc := mutate.Extract(img)
+	defer rc.Close()
+
+	files := map[string]fileHeader{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading filesystem: %w", err)
+		}
+		p := "/" + strings.TrimSuffix(strings.TrimPrefix(hdr.Name, "./"), "/")
+		files[p] = fileHeader{
+			typeflag: hdr.Typeflag,
+			mode:     hdr.Mode,
+			uid:      hdr.Uid,
+			gid:      hdr.Gid,
+			size:     hdr.Size,
+			linkname: hdr.Linkname,
+			modTime:  hdr.ModTime.Unix(),
+		}
+	}
+	return files, nil
+}
+
+func layerDigests(img v1.Image) ([]v1.Hash, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+	digests := make([]v1.Hash, 0, len(m.Layers))
+	for _, l := range m.Layers {
+		digests = append(digests, l.Digest)
+	}
+	return digests, nil
+}
+
+// missing returns the hashes in a that aren't in b, in order.
+func missing(a, b []v1.Hash) []v1.Hash {
+	inB := map[v1.Hash]bool{}
+	for _, h := range b {
+		inB[h] = true
+	}
+	var out []v1.Hash
+	for _, h := range a {
+		if !inB[h] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func diffMaps(name string, a, b map[string]string) []ConfigChange {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []ConfigChange
+	for _, k := range sorted {
+		av, aok := a[k]
+		bv, bok := b[k]
+		if aok != bok || av != bv {
+			changes = append(changes, ConfigChange{Field: fmt.Sprintf("%s[%s]", name, k), Old: av, New: bv})
+		}
+	}
+	return changes
+}
+
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		k, v := kv, ""
+		if i := strings.Index(kv, "="); i >= 0 {
+			k, v = kv[:i], kv[i+1:]
+		}
+		m[k] = v
+	}
+	return m
+}
+
+func jsonString(s []string) string {
+	if s == nil {
+		return ""
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Sprint(s)
+	}
+	return string(b)
+}
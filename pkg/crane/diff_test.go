@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+func diffImage(t *testing.T, cfg v1.Config, layers ...fstest.MapFS) v1.Image {
+	t.Helper()
+	img := empty.Image
+	for _, fsys := range layers {
+		l, err := mutate.LayerFromFS(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.AppendLayers(img, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	img, err := mutate.Config(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestDiff(t *testing.T) {
+	common := fstest.MapFS{
+		"bin/sh":   {Data: []byte("sh")},
+		"etc/motd": {Data: []byte("hi")},
+	}
+	a := diffImage(t, v1.Config{
+		Env:        []string{"PATH=/bin", "OLD=1"},
+		Labels:     map[string]string{"version": "1"},
+		Entrypoint: []string{"/bin/sh"},
+	}, common, fstest.MapFS{"app": {Data: []byte("v1")}, "removed": {Data: []byte("x")}})
+	b := diffImage(t, v1.Config{
+		Env:        []string{"PATH=/bin", "NEW=1"},
+		Labels:     map[string]string{"version": "2"},
+		Entrypoint: []string{"/bin/sh"},
+		User:       "nobody",
+	}, common, fstest.MapFS{"app": {Data: []byte("v2!")}, "added": {Data: []byte("x")}})
+
+	d, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantConfig := []ConfigChange{
+		{Field: "User", New: "nobody"},
+		{Field: "Env[NEW]", New: "1"},
+		{Field: "Env[OLD]", Old: "1"},
+		{Field: "Labels[version]", Old: "1", New: "2"},
+	}
+	if diff := cmp.Diff(wantConfig, d.Config); diff != "" {
+		t.Errorf("Config (-want +got): %s", diff)
+	}
+	if len(d.RemovedLayers) != 1 || len(d.AddedLayers) != 1 {
+		t.Errorf("got %d removed and %d added layers, want 1 and 1", len(d.RemovedLayers), len(d.AddedLayers))
+	}
+
+	files, err := DiffFiles(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := []FileChange{
+		{Path: "/added", Change: "added"},
+		{Path: "/app", Change: "changed"},
+		{Path: "/removed", Change: "removed"},
+	}
+	if diff := cmp.Diff(wantFiles, files); diff != "" {
+		t.Errorf("DiffFiles (-want +got): %s", diff)
+	}
+
+	// An image doesn't differ from itself.
+	if d, err := Diff(a, a); err != nil {
+		t.Fatal(err)
+	} else if diff := cmp.Diff(&ImageDiff{}, d); diff != "" {
+		t.Errorf("Diff(a, a) (-want +got): %s", diff)
+	}
+}
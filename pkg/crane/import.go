@@ -0,0 +1,53 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Import returns a single-layer image whose filesystem is the contents of the
+// tarball at path, or stdin if path is "-". This is the inverse of Export.
+//
+// The image has a minimal config for the platform given by WithPlatform, or
+// linux/amd64 by default.
+func Import(path string, opt ...Option) (v1.Image, error) {
+	o := makeOptions(opt...)
+	platform := v1.Platform{OS: "linux", Architecture: "amd64"}
+	if o.Platform != nil {
+		platform = *o.Platform
+	}
+
+	base, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{
+		OS:           platform.OS,
+		Architecture: platform.Architecture,
+		Variant:      platform.Variant,
+		OSVersion:    platform.OSVersion,
+		RootFS:       v1.RootFS{Type: "layers"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := Append(base, path)
+	if err != nil {
+		return nil, fmt.Errorf("importing %s: %w", path, err)
+	}
+	return img, nil
+}
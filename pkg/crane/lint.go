@@ -0,0 +1,390 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// LintSeverity is how serious a LintFinding is.
+type LintSeverity string
+
+const (
+	// LintError means the manifest or config breaks a rule of the OCI
+	// image-spec, and registries or runtimes are likely to reject it.
+	LintError LintSeverity = "error"
+	// LintWarning means the manifest or config is valid, but likely to cause
+	// problems with stricter registries or tools.
+	LintWarning LintSeverity = "warning"
+)
+
+// LintFinding is a problem found by Lint.
+type LintFinding struct {
+	Severity LintSeverity `json:"severity"`
+	// Digest is the digest of the manifest or config the problem was found in.
+	Digest string `json:"digest"`
+	// Path is the location of the problem within it, e.g. "layers[1].size".
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Digest, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", f.Severity, f.Digest, f.Path, f.Message)
+}
+
+// maxLintSize is the size above which a blob is assumed to be a mistake.
+const maxLintSize = 100 << 30
+
+// digestRE and mediaTypeRE are the patterns from the OCI image-spec's JSON
+// schemas.
+var (
+	digestRE    = regexp.MustCompile(`^[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	mediaTypeRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}/[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}$`)
+	sha256RE    = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	sha512RE    = regexp.MustCompile(`^sha512:[a-f0-9]{128}$`)
+)
+
+// Lint checks the manifest of the remote image or index ref, and the
+// manifests and configs it refers to, for common problems, e.g. missing
+// required fields, malformed digests and duplicate layers. It returns what it
+// found, which is empty if nothing looks wrong.
+//
+// Lint is a heuristic linter: it checks the fields and patterns of the OCI
+// image-spec that registries and tools most often trip on, but it doesn't
+// validate against the image-spec's JSON schemas, so an empty result doesn't
+// mean a manifest is valid.
+func Lint(ref string, opt ...Option) ([]LintFinding, error) {
+	o := makeOptions(opt...)
+	r, err := name.ParseReference(ref, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", ref, err)
+	}
+	desc, err := remote.Get(r, o.Remote...)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{}
+	if err := l.lint(desc, o); err != nil {
+		return nil, err
+	}
+	return l.findings, nil
+}
+
+type linter struct {
+	findings []LintFinding
+	digest   string
+}
+
+func (l *linter) errorf(path, format string, args ...interface{}) {
+	l.findings = append(l.findings, LintFinding{LintError, l.digest, path, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(path, format string, args ...interface{}) {
+	l.findings = append(l.findings, LintFinding{LintWarning, l.digest, path, fmt.Sprintf(format, args...)})
+}
+
+// lintDescriptor has pointers to tell missing fields from zero values.
+type lintDescriptor struct {
+	MediaType   *string           `json:"mediaType"`
+	Digest      *string           `json:"digest"`
+	Size        *int64            `json:"size"`
+	URLs        []string          `json:"urls"`
+	Platform    *v1.Platform      `json:"platform"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type lintManifest struct {
+	SchemaVersion *int              `json:"schemaVersion"`
+	MediaType     *string           `json:"mediaType"`
+	Config        *lintDescriptor   `json:"config"`
+	Layers        []lintDescriptor  `json:"layers"`
+	Manifests     []lintDescriptor  `json:"manifests"`
+	Annotations   map[string]string `json:"annotations"`
+}
+
+func (l *linter) lint(desc *remote.Descriptor, o Options) error {
+	l.digest = desc.Digest.String()
+	switch desc.MediaType {
+	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+		l.warnf("", "Docker schema 1 manifests are deprecated and rejected by many registries")
+		return nil
+	}
+
+	raw := desc.Manifest
+	l.lintJSON(raw)
+	var m lintManifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		l.errorf("", "is not a manifest: %v", err)
+		return nil
+	}
+
+	if m.SchemaVersion == nil {
+		l.errorf("schemaVersion", "is required")
+	} else if *m.SchemaVersion != 2 {
+		l.errorf("schemaVersion", "is %d, want 2", *m.SchemaVersion)
+	}
+	if m.MediaType == nil {
+		l.warnf("mediaType", "is missing, so registries have to guess the type from the Content-Type")
+	} else if *m.MediaType != string(desc.MediaType) {
+		l.errorf("mediaType", "is %q, but it was served as %q", *m.MediaType, desc.MediaType)
+	}
+
+	if desc.MediaType.IsIndex() {
+		return l.lintIndex(desc, m, o)
+	}
+	if desc.MediaType.IsImage() || m.Config != nil {
+		return l.lintImage(desc, m, o)
+	}
+	l.warnf("", "unknown manifest media type %q", desc.MediaType)
+	return nil
+}
+
+func (l *linter) lintIndex(desc *remote.Descriptor, m lintManifest, o Options) error {
+	if m.Manifests == nil {
+		l.errorf("manifests", "is required")
+	}
+	if m.Config != nil || m.Layers != nil {
+		l.warnf("", "index has config or layers, which will be ignored")
+	}
+	for i, d := range m.Manifests {
+		path := fmt.Sprintf("manifests[%d]", i)
+		l.lintDescriptor(path, d)
+		if p := d.Platform; p != nil {
+			if p.Architecture == "" {
+				l.errorf(path+".platform.architecture", "is required")
+			}
+			if p.OS == "" {
+				l.errorf(path+".platform.os", "is required")
+			}
+		}
+	}
+
+	// Only lint children with valid digests, which Get can fetch.
+	ref := desc.Ref.Context()
+	digest := l.digest
+	for _, d := range m.Manifests {
+		if d.Digest == nil || !sha256RE.MatchString(*d.Digest) {
+			continue
+		}
+		child, err := remote.Get(ref.Digest(*d.Digest), o.Remote...)
+		if err != nil {
+			return fmt.Errorf("fetching child %s: %w", *d.Digest, err)
+		}
+		if err := l.lint(child, o); err != nil {
+			return err
+		}
+	}
+	l.digest = digest
+	return nil
+}
+
+func (l *linter) lintImage(desc *remote.Descriptor, m lintManifest, o Options) error {
+	if m.Manifests != nil {
+		l.warnf("manifests", "image has manifests, which will be ignored")
+	}
+	if m.Config == nil {
+		l.errorf("config", "is required")
+	} else {
+		l.lintDescriptor("config", *m.Config)
+	}
+	if m.Layers == nil && desc.MediaType.IsImage() {
+		l.errorf("layers", "is required")
+	}
+	seen := map[string]int{}
+	for i, d := range m.Layers {
+		path := fmt.Sprintf("layers[%d]", i)
+		l.lintDescriptor(path, d)
+		if d.Digest == nil {
+			continue
+		}
+		if j, ok := seen[*d.Digest]; ok {
+			l.warnf(path, "duplicates layers[%d]", j)
+		} else {
+			seen[*d.Digest] = i
+		}
+	}
+
+	if !desc.MediaType.IsImage() || m.Config == nil || m.Config.Digest == nil || !sha256RE.MatchString(*m.Config.Digest) {
+		return nil
+	}
+	l.lintConfig(desc.Ref.Context().Digest(*m.Config.Digest), len(m.Layers), o)
+	return nil
+}
+
+// lintConfig checks the parts of the config that the image-spec requires.
+func (l *linter) lintConfig(ref name.Digest, layers int, o Options) {
+	layer, err := remote.Layer(ref, o.Remote...)
+	if err != nil {
+		l.errorf("config", "could not be fetched: %v", err)
+		return
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		l.errorf("config", "could not be fetched: %v", err)
+		return
+	}
+	defer rc.Close()
+	raw, err := ioutil.ReadAll(rc)
+	if err != nil {
+		l.errorf("config", "could not be fetched: %v", err)
+		return
+	}
+
+	digest := l.digest
+	l.digest = ref.DigestStr()
+	defer func() { l.digest = digest }()
+
+	l.lintJSON(raw)
+	var cfg struct {
+		Architecture *string `json:"architecture"`
+		OS           *string `json:"os"`
+		RootFS       *struct {
+			Type    string   `json:"type"`
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		l.errorf("", "is not a config: %v", err)
+		return
+	}
+	if cfg.Architecture == nil || *cfg.Architecture == "" {
+		l.errorf("architecture", "is required")
+	}
+	if cfg.OS == nil || *cfg.OS == "" {
+		l.errorf("os", "is required")
+	}
+	if cfg.RootFS == nil {
+		l.errorf("rootfs", "is required")
+		return
+	}
+	if cfg.RootFS.Type != "layers" {
+		l.errorf("rootfs.type", "is %q, want \"layers\"", cfg.RootFS.Type)
+	}
+	if len(cfg.RootFS.DiffIDs) != layers {
+		l.errorf("rootfs.diff_ids", "has %d entries, but the manifest has %d layers", len(cfg.RootFS.DiffIDs), layers)
+	}
+	for i, d := range cfg.RootFS.DiffIDs {
+		if !digestRE.MatchString(d) {
+			l.errorf(fmt.Sprintf("rootfs.diff_ids[%d]", i), "%q is not a valid digest", d)
+		}
+	}
+}
+
+func (l *linter) lintDescriptor(path string, d lintDescriptor) {
+	if d.MediaType == nil {
+		l.errorf(path+".mediaType", "is required")
+	} else if !mediaTypeRE.MatchString(*d.MediaType) {
+		l.errorf(path+".mediaType", "%q is not a valid media type", *d.MediaType)
+	}
+
+	switch {
+	case d.Digest == nil:
+		l.errorf(path+".digest", "is required")
+	case !digestRE.MatchString(*d.Digest):
+		l.errorf(path+".digest", "%q is not a valid digest", *d.Digest)
+	case strings.HasPrefix(*d.Digest, "sha256:") && !sha256RE.MatchString(*d.Digest),
+		strings.HasPrefix(*d.Digest, "sha512:") && !sha512RE.MatchString(*d.Digest):
+		l.errorf(path+".digest", "%q has the wrong length or is not lowercase hex", *d.Digest)
+	}
+
+	switch {
+	case d.Size == nil:
+		l.errorf(path+".size", "is required")
+	case *d.Size < 0:
+		l.errorf(path+".size", "is negative")
+	case *d.Size > maxLintSize:
+		l.warnf(path+".size", "is %d bytes, which is implausibly large", *d.Size)
+	}
+
+	for i, u := range d.URLs {
+		if pu, err := url.Parse(u); err != nil || !pu.IsAbs() {
+			l.errorf(fmt.Sprintf("%s.urls[%d]", path, i), "%q is not an absolute URL", u)
+		}
+	}
+}
+
+// lintJSON warns about JSON that parses, but that stricter parsers or
+// tools that re-encode it may not handle the same way.
+func (l *linter) lintJSON(raw []byte) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		l.errorf("", "is not valid JSON: %v", err)
+		return
+	}
+	if !bytes.Equal(buf.Bytes(), raw) {
+		l.warnf("", "is not canonical JSON: it contains insignificant whitespace")
+	}
+	if path, ok := duplicateKey(json.NewDecoder(bytes.NewReader(raw)), ""); ok {
+		l.warnf(path, "is a duplicate key")
+	}
+}
+
+// duplicateKey walks the next JSON value in dec and returns the path of the
+// first duplicate object key it finds.
+func duplicateKey(dec *json.Decoder, path string) (string, bool) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", false
+	}
+	switch tok {
+	case json.Delim('{'):
+		seen := map[string]bool{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return "", false
+			}
+			key, _ := tok.(string)
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if seen[key] {
+				return child, true
+			}
+			seen[key] = true
+			if p, ok := duplicateKey(dec, child); ok {
+				return p, true
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return "", false
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if p, ok := duplicateKey(dec, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return p, true
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return "", false
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+type rawManifest struct {
+	manifest  string
+	mediaType types.MediaType
+}
+
+func (r rawManifest) RawManifest() ([]byte, error) {
+	return []byte(r.manifest), nil
+}
+
+func (r rawManifest) MediaType() (types.MediaType, error) {
+	return r.mediaType, nil
+}
+
+func TestLint(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Random images have no platform in their config, which is required.
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.Architecture, cf.OS = "amd64", "linux"
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), mutate.IndexAddendum{Add: img})
+	src := fmt.Sprintf("%s/test/lint:index", u.Host)
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := crane.Lint(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("Lint(%s) = %v, want no findings", src, findings)
+	}
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	for _, tc := range []struct {
+		name     string
+		manifest rawManifest
+		want     []string
+	}{{
+		name: "missing fields",
+		manifest: rawManifest{
+			manifest:  `{"config":{"digest":"` + digest + `","size":-1},"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"sha256:ABC","size":1}]}`,
+			mediaType: types.OCIManifestSchema1,
+		},
+		want: []string{
+			"error: schemaVersion: is required",
+			"warning: mediaType: is missing",
+			"error: config.mediaType: is required",
+			"error: config.size: is negative",
+			`error: layers[0].digest: "sha256:ABC" has the wrong length`,
+			"error: config: could not be fetched",
+		},
+	}, {
+		name: "duplicate layers",
+		manifest: rawManifest{
+			manifest: `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha512:abc", "size": 2},
+  "layers": [
+    {"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": "` + digest + `", "size": 1, "size": 1},
+    {"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": "` + digest + `", "size": 1099511627776}
+  ]
+}`,
+			mediaType: types.OCIManifestSchema1,
+		},
+		want: []string{
+			"warning: is not canonical JSON",
+			"warning: layers[0].size: is a duplicate key",
+			`error: config.digest: "sha512:abc" has the wrong length`,
+			"warning: layers[1].size: is 1099511627776 bytes",
+			"warning: layers[1]: duplicates layers[0]",
+		},
+	}, {
+		name: "mismatched media type",
+		manifest: rawManifest{
+			manifest:  `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`,
+			mediaType: types.OCIManifestSchema1,
+		},
+		want: []string{
+			`error: mediaType: is "application/vnd.oci.image.index.v1+json", but it was served as`,
+			"warning: manifests: image has manifests",
+			"error: config: is required",
+			"error: layers: is required",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := fmt.Sprintf("%s/test/lint:%s", u.Host, strings.ReplaceAll(tc.name, " ", "-"))
+			ref, err := name.ParseReference(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := remote.Put(ref, tc.manifest); err != nil {
+				t.Fatal(err)
+			}
+			findings, err := crane.Lint(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != len(tc.want) {
+				t.Fatalf("Lint(%s) = %v, want %d findings", src, findings, len(tc.want))
+			}
+			for i, f := range findings {
+				// Drop the digest, which depends on the manifest.
+				got := strings.Replace(f.String(), " "+f.Digest+":", "", 1)
+				if !strings.HasPrefix(got, tc.want[i]) {
+					t.Errorf("finding %d = %q, want prefix %q", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
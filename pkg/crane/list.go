@@ -31,3 +31,38 @@ func ListTags(src string, opt ...Option) ([]string, error) {
 
 	return remote.List(repo, o.Remote...)
 }
+
+// TagDigest is a tag and the digest of the manifest it points to.
+type TagDigest struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+}
+
+// ListTagDigests returns the tags in repository src, like ListTags, along with
+// their digests. Each tag takes a HEAD request, and up to the number of jobs
+// set by WithJobs are made at once. Tags that are deleted before they can be
+// resolved are left out.
+func ListTagDigests(src string, opt ...Option) ([]TagDigest, error) {
+	o := makeOptions(opt...)
+	repo, err := name.NewRepository(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repo %q: %w", src, err)
+	}
+	tags, err := remote.List(repo, o.Remote...)
+	if err != nil {
+		return nil, err
+	}
+	descs, err := remote.HeadTags(repo, tags, o.Remote...)
+	if err != nil {
+		return nil, err
+	}
+
+	tds := make([]TagDigest, 0, len(tags))
+	for i, desc := range descs {
+		if desc == nil {
+			continue
+		}
+		tds = append(tds, TagDigest{Tag: tags[i], Digest: desc.Digest.String()})
+	}
+	return tds, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// MirrorRule describes which tags of a repository Mirror copies, and where to.
+type MirrorRule struct {
+	// Source is the repository to copy from.
+	Source string `json:"source" yaml:"source"`
+	// Destination is the repository to copy to. Tags keep their names.
+	Destination string `json:"destination" yaml:"destination"`
+	// Tags are regular expressions, which must match the whole tag, for the
+	// tags to copy. If there are none, every tag is copied.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// ExcludeTags are regular expressions, which must match the whole tag,
+	// for tags not to copy even if they match Tags.
+	ExcludeTags []string `json:"excludeTags,omitempty" yaml:"excludeTags,omitempty"`
+}
+
+// MirrorState maps each destination tag that Mirror has copied to the digest
+// it copied, so that tags whose digests haven't changed aren't copied again.
+// Callers can persist it between calls, e.g. as JSON.
+type MirrorState map[string]string
+
+// MirrorResult summarizes what Mirror did for one MirrorRule.
+type MirrorResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Copied is the number of tags that were new or had changed, and were
+	// copied.
+	Copied int `json:"copied"`
+	// Unchanged is the number of tags that had already been copied.
+	Unchanged int `json:"unchanged"`
+	// Failed is the number of tags that couldn't be copied, plus one if the
+	// source's tags couldn't be listed.
+	Failed int `json:"failed"`
+}
+
+// Mirror copies the tags of each rule's source repository whose digests
+// aren't recorded in state to its destination repository, and records them.
+// Failures to list or copy tags are logged and counted in the results, so that
+// one unavailable repository doesn't stop the others from being mirrored;
+// Mirror only returns an error for invalid rules.
+func Mirror(rules []MirrorRule, state MirrorState, opt ...Option) ([]MirrorResult, error) {
+	o := makeOptions(opt...)
+	type compiled struct {
+		src, dst         name.Repository
+		include, exclude []*regexp.Regexp
+	}
+	var cs []compiled
+	for _, rule := range rules {
+		src, err := name.NewRepository(rule.Source, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing source %q: %w", rule.Source, err)
+		}
+		dst, err := name.NewRepository(rule.Destination, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing destination %q: %w", rule.Destination, err)
+		}
+		include, err := compileTagPatterns(rule.Tags)
+		if err != nil {
+			return nil, err
+		}
+		exclude, err := compileTagPatterns(rule.ExcludeTags)
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, compiled{src: src, dst: dst, include: include, exclude: exclude})
+	}
+
+	results := make([]MirrorResult, 0, len(cs))
+	for i, c := range cs {
+		result := MirrorResult{Source: rules[i].Source, Destination: rules[i].Destination}
+		tags, err := remote.List(c.src, o.Remote...)
+		if err != nil {
+			logs.Warn.Printf("listing tags of %s: %v", c.src, err)
+			result.Failed++
+			results = append(results, result)
+			continue
+		}
+		for _, tag := range tags {
+			if !matchesTag(c.include, tag, true) || matchesTag(c.exclude, tag, false) {
+				continue
+			}
+			src, dst := c.src.Tag(tag), c.dst.Tag(tag)
+			desc, err := remote.Head(src, o.Remote...)
+			if err != nil {
+				logs.Warn.Printf("resolving %s: %v", src, err)
+				result.Failed++
+				continue
+			}
+			digest := desc.Digest.String()
+			if state[dst.String()] == digest {
+				result.Unchanged++
+				continue
+			}
+			// Copy by digest, in case the tag moves while it's being copied.
+			if _, err := copyRef(src.Context().Digest(digest).String(), dst.String(), o); err != nil {
+				logs.Warn.Printf("copying %s to %s: %v", src, dst, err)
+				result.Failed++
+				continue
+			}
+			state[dst.String()] = digest
+			result.Copied++
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func compileTagPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("parsing tag pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// matchesTag returns whether any of res match tag, or empty if there are none.
+func matchesTag(res []*regexp.Regexp, tag string, empty bool) bool {
+	if len(res) == 0 {
+		return empty
+	}
+	for _, re := range res {
+		if re.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestMirror(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/upstream/app", u.Host)
+	dst := fmt.Sprintf("%s/mirror/app", u.Host)
+
+	push := func(tag string) string {
+		t.Helper()
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crane.Push(img, src+":"+tag); err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.String()
+	}
+	want1 := push("v1")
+	push("v2-rc1")
+	push("latest")
+
+	rules := []crane.MirrorRule{{
+		Source:      src,
+		Destination: dst,
+		Tags:        []string{"v.*"},
+		ExcludeTags: []string{".*-rc.*"},
+	}, {
+		Source:      fmt.Sprintf("%s/missing", u.Host),
+		Destination: fmt.Sprintf("%s/mirror/missing", u.Host),
+	}}
+	state := crane.MirrorState{}
+	results, err := crane.Mirror(rules, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []crane.MirrorResult{{
+		Source:      rules[0].Source,
+		Destination: rules[0].Destination,
+		Copied:      1,
+	}, {
+		Source:      rules[1].Source,
+		Destination: rules[1].Destination,
+		Failed:      1,
+	}}
+	if diff := cmp.Diff(want, results); diff != "" {
+		t.Errorf("Mirror() (-want +got) = %s", diff)
+	}
+	if diff := cmp.Diff(crane.MirrorState{dst + ":v1": want1}, state); diff != "" {
+		t.Errorf("state (-want +got) = %s", diff)
+	}
+	if got, err := crane.Digest(dst + ":v1"); err != nil {
+		t.Fatal(err)
+	} else if got != want1 {
+		t.Errorf("mirrored digest = %s, want %s", got, want1)
+	}
+	if tags, err := crane.ListTags(dst); err != nil {
+		t.Fatal(err)
+	} else if diff := cmp.Diff([]string{"v1"}, tags); diff != "" {
+		t.Errorf("mirrored tags (-want +got) = %s", diff)
+	}
+
+	// Only new or changed tags are copied.
+	want1 = push("v1")
+	push("v3")
+	results, err = crane.Mirror(rules[:1], state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]crane.MirrorResult{{Source: src, Destination: dst, Copied: 2}}, results); diff != "" {
+		t.Errorf("Mirror() (-want +got) = %s", diff)
+	}
+	if got, err := crane.Digest(dst + ":v1"); err != nil {
+		t.Fatal(err)
+	} else if got != want1 {
+		t.Errorf("mirrored digest = %s, want %s", got, want1)
+	}
+	results, err = crane.Mirror(rules[:1], state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]crane.MirrorResult{{Source: src, Destination: dst, Unchanged: 2}}, results); diff != "" {
+		t.Errorf("Mirror() (-want +got) = %s", diff)
+	}
+
+	if _, err := crane.Mirror([]crane.MirrorRule{{Source: src, Destination: dst, Tags: []string{"("}}}, state); err == nil {
+		t.Error("Mirror() with an invalid tag pattern succeeded, want error")
+	}
+}
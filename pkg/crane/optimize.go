@@ -18,18 +18,18 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/containerd/stargz-snapshotter/estargz"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
-// Optimize optimizes a remote image or index from src to dst.
+// Optimize copies a remote image or index from src to dst with its layers
+// converted to eStargz, so that the files in prioritize are fetched first by
+// lazy pullers. See mutate.Estargz.
 // THIS API IS EXPERIMENTAL AND SUBJECT TO CHANGE WITHOUT WARNING.
 func Optimize(src, dst string, prioritize []string, opt ...Option) error {
 	pset := newStringSet(prioritize)
@@ -96,50 +96,14 @@ func optimizeAndPushImage(desc *remote.Descriptor, dstRef name.Reference, priori
 }
 
 func optimizeImage(img v1.Image, prioritize stringSet) (stringSet, v1.Image, error) {
-	cfg, err := img.ConfigFile()
+	var missing []string
+	oimg, err := mutate.Estargz(img,
+		mutate.EstargzPrioritizedFiles(prioritize.List()...),
+		mutate.EstargzMissingFiles(&missing))
 	if err != nil {
 		return nil, nil, err
 	}
-	ocfg := cfg.DeepCopy()
-	ocfg.History = nil
-	ocfg.RootFS.DiffIDs = nil
-
-	oimg, err := mutate.ConfigFile(empty.Image, ocfg)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	layers, err := img.Layers()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	missingFromImage := newStringSet(prioritize.List())
-	olayers := make([]mutate.Addendum, 0, len(layers))
-	for _, layer := range layers {
-		missingFromLayer := []string{}
-		olayer, err := tarball.LayerFromOpener(layer.Uncompressed,
-			tarball.WithEstargz,
-			tarball.WithEstargzOptions(
-				estargz.WithPrioritizedFiles(prioritize.List()),
-				estargz.WithAllowPrioritizeNotFound(&missingFromLayer),
-			))
-		if err != nil {
-			return nil, nil, err
-		}
-		missingFromImage = missingFromImage.Intersection(newStringSet(missingFromLayer))
-
-		olayers = append(olayers, mutate.Addendum{
-			Layer:     olayer,
-			MediaType: types.DockerLayer,
-		})
-	}
-
-	oimg, err = mutate.Append(oimg, olayers...)
-	if err != nil {
-		return nil, nil, err
-	}
-	return missingFromImage, oimg, nil
+	return newStringSet(missing), oimg, nil
 }
 
 func optimizeAndPushIndex(desc *remote.Descriptor, dstRef name.Reference, prioritize stringSet, o Options) error {
@@ -30,6 +30,14 @@ type Options struct {
 	Remote   []remote.Option
 	Platform *v1.Platform
 	Keychain authn.Keychain
+
+	// Transport is the transport set by WithTransport, if any, for requests
+	// that don't go through Remote.
+	Transport http.RoundTripper
+
+	// report, if set, counts what each write to the destination does, for
+	// CopyWithReport.
+	report *reportEvents
 }
 
 // GetOptions exposes the underlying []remote.Option, []name.Option, and
@@ -61,6 +69,7 @@ type Option func(*Options)
 func WithTransport(t http.RoundTripper) Option {
 	return func(o *Options) {
 		o.Remote = append(o.Remote, remote.WithTransport(t))
+		o.Transport = t
 	}
 }
 
@@ -125,3 +134,11 @@ func WithContext(ctx context.Context) Option {
 		o.Remote = append(o.Remote, remote.WithContext(ctx))
 	}
 }
+
+// WithJobs sets how many blobs and manifests are copied at once by operations
+// that support parallelism, such as CopyRepository.
+func WithJobs(jobs int) Option {
+	return func(o *Options) {
+		o.Remote = append(o.Remote, remote.WithJobs(jobs))
+	}
+}
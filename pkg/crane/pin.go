@@ -0,0 +1,58 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PinDriftError is returned by VerifyPin when the tag of a pinned reference
+// no longer resolves to the pinned digest.
+type PinDriftError struct {
+	Tag    name.Tag
+	Pinned string
+	Actual string
+}
+
+// Error implements error.
+func (e *PinDriftError) Error() string {
+	return fmt.Sprintf("%s has drifted: pinned to %s, but resolves to %s", e.Tag, e.Pinned, e.Actual)
+}
+
+// VerifyPin checks that the tag portion of ref (e.g. ubuntu:22.04@sha256:...)
+// still resolves to its digest portion. If it does not, the returned error is
+// a *PinDriftError.
+func VerifyPin(ref string, opt ...Option) error {
+	o := makeOptions(opt...)
+	dt, err := name.NewDigestTag(ref, o.Name...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", ref, err)
+	}
+	desc, err := remote.Head(dt.Tag(), o.Remote...)
+	if err != nil {
+		return fmt.Errorf("resolving %q: %w", dt.Tag(), err)
+	}
+	if got := desc.Digest.String(); got != dt.DigestStr() {
+		return &PinDriftError{
+			Tag:    dt.Tag(),
+			Pinned: dt.DigestStr(),
+			Actual: got,
+		}
+	}
+	return nil
+}
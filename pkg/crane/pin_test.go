@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestVerifyPin(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := fmt.Sprintf("%s/test/pin:v1", u.Host)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, tag); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := tag + "@" + d.String()
+
+	if err := crane.VerifyPin(pinned); err != nil {
+		t.Errorf("VerifyPin(%q): %v", pinned, err)
+	}
+
+	// Move the tag.
+	moved, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(moved, tag); err != nil {
+		t.Fatal(err)
+	}
+	md, err := moved.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = crane.VerifyPin(pinned)
+	var drift *crane.PinDriftError
+	if !errors.As(err, &drift) {
+		t.Fatalf("VerifyPin(%q) = %v, want PinDriftError", pinned, err)
+	}
+	if drift.Pinned != d.String() || drift.Actual != md.String() {
+		t.Errorf("PinDriftError = %+v, want pinned %s actual %s", drift, d, md)
+	}
+
+	for _, bad := range []string{
+		tag,
+		fmt.Sprintf("%s/test/pin@%s", u.Host, d),
+	} {
+		if err := crane.VerifyPin(bad); err == nil {
+			t.Errorf("VerifyPin(%q): expected error", bad)
+		}
+	}
+}
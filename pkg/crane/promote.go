@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/go-containerregistry/internal/legacy"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// PromotionPolicy is consulted by Promote before anything is copied. It is
+// passed the source, pinned to the digest it resolved to, and its descriptor.
+// Returning an error blocks the promotion.
+type PromotionPolicy func(src name.Digest, desc *remote.Descriptor) error
+
+// Promotion records the result of a call to Promote.
+type Promotion struct {
+	// Source is the source reference as given to Promote.
+	Source string `json:"source"`
+	// Digest is the digest that Source resolved to and that was promoted.
+	Digest string `json:"digest"`
+	// MediaType is the media type of the promoted manifest.
+	MediaType types.MediaType `json:"mediaType"`
+	// Destination is the destination tag.
+	Destination string `json:"destination"`
+	// Timestamp is when the promotion completed.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Promote resolves src to a digest, checks it against policy (which may be
+// nil), copies it by digest to the repository of dst, and then points the tag
+// dst at it.
+//
+// Unlike Copy, Promote always copies an index in its entirety, so that the
+// promoted digest matches the source digest.
+func Promote(src, dst string, policy PromotionPolicy, opt ...Option) (*Promotion, error) {
+	o := makeOptions(opt...)
+	srcRef, err := name.ParseReference(src, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	dstTag, err := name.NewTag(dst, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing tag %q: %w", dst, err)
+	}
+
+	desc, err := remote.Get(srcRef, o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", src, err)
+	}
+	srcDigest := srcRef.Context().Digest(desc.Digest.String())
+	if policy != nil {
+		if err := policy(srcDigest, desc); err != nil {
+			return nil, fmt.Errorf("policy rejected %s: %w", srcDigest, err)
+		}
+	}
+
+	dstDigest := dstTag.Context().Digest(desc.Digest.String())
+	logs.Progress.Printf("Promoting %v to %v", srcDigest, dstDigest)
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		if err := copyIndex(desc, dstDigest, o); err != nil {
+			return nil, fmt.Errorf("failed to copy index: %w", err)
+		}
+	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+		if err := legacy.CopySchema1(desc, srcDigest, dstDigest, o.Remote...); err != nil {
+			return nil, fmt.Errorf("failed to copy schema 1 image: %w", err)
+		}
+	default:
+		if _, err := copyImage(desc, dstDigest, o); err != nil {
+			return nil, fmt.Errorf("failed to copy image: %w", err)
+		}
+	}
+
+	logs.Progress.Printf("Tagging %v as %v", dstDigest, dstTag)
+	if err := remote.Tag(dstTag, desc, o.Remote...); err != nil {
+		return nil, fmt.Errorf("tagging %q: %w", dstTag, err)
+	}
+
+	return &Promotion{
+		Source:      src,
+		Digest:      desc.Digest.String(),
+		MediaType:   desc.MediaType,
+		Destination: dstTag.String(),
+		Timestamp:   time.Now().UTC(),
+	}, nil
+}
+
+// RequireAnnotations returns a PromotionPolicy that only allows promoting
+// manifests that have all the given annotations set to the given values.
+func RequireAnnotations(annotations map[string]string) PromotionPolicy {
+	return func(src name.Digest, desc *remote.Descriptor) error {
+		var m struct {
+			Annotations map[string]string `json:"annotations"`
+		}
+		if err := json.Unmarshal(desc.Manifest, &m); err != nil {
+			return err
+		}
+		for k, v := range annotations {
+			got, ok := m.Annotations[k]
+			if !ok {
+				return fmt.Errorf("missing annotation %q", k)
+			}
+			if got != v {
+				return fmt.Errorf("annotation %q is %q, want %q", k, got, v)
+			}
+		}
+		return nil
+	}
+}
+
+// AllPolicies returns a PromotionPolicy that requires every one of policies
+// to allow a promotion.
+func AllPolicies(policies ...PromotionPolicy) PromotionPolicy {
+	return func(src name.Digest, desc *remote.Descriptor) error {
+		for _, p := range policies {
+			if err := p(src, desc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
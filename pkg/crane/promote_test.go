@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPromote(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/staging/app:v1", u.Host)
+	dst := fmt.Sprintf("%s/prod/app:v1", u.Host)
+
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx = mutate.Annotations(idx, map[string]string{"qa": "passed"}).(v1.ImageIndex)
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocked by policy.
+	if _, err := crane.Promote(src, dst, crane.RequireAnnotations(map[string]string{"qa": "failed"})); err == nil {
+		t.Error("Promote succeeded despite policy, wanted err")
+	}
+	if _, err := crane.Digest(dst); err == nil {
+		t.Error("dst exists after rejected promotion")
+	}
+
+	// A platform shouldn't stop the whole index being promoted.
+	p, err := crane.Promote(src, dst, crane.RequireAnnotations(map[string]string{"qa": "passed"}), crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "amd64"}))
+	if err != nil {
+		t.Fatalf("Promote: %v", err)
+	}
+	if p.Digest != d.String() || p.Source != src || p.Destination != dst || p.Timestamp.IsZero() {
+		t.Errorf("unexpected promotion record: %+v", p)
+	}
+
+	got, err := crane.Digest(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != d.String() {
+		t.Errorf("Digest(%q) = %s, want %s", dst, got, d)
+	}
+	if _, err := crane.Digest(fmt.Sprintf("%s/prod/app@%s", u.Host, d)); err != nil {
+		t.Errorf("promoted digest missing: %v", err)
+	}
+}
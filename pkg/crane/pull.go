@@ -62,15 +62,20 @@ func MultiSave(imgMap map[string]v1.Image, path string, opt ...Option) error {
 
 		// WriteToFile wants a tag to write to the tarball, but we might have
 		// been given a digest.
-		// If the original ref was a tag, use that. Otherwise, if it was a
+		// If the original ref had a tag, use that. Otherwise, if it was a
 		// digest, tag the image with :i-was-a-digest instead.
-		tag, ok := ref.(name.Tag)
-		if !ok {
-			d, ok := ref.(name.Digest)
-			if !ok {
+		var tag name.Tag
+		if dt, ok := name.AsDigestTag(ref); ok {
+			tag = dt.Tag()
+		} else {
+			switch r := ref.(type) {
+			case name.Tag:
+				tag = r
+			case name.Digest:
+				tag = r.Repository.Tag(iWasADigestTag)
+			default:
 				return fmt.Errorf("ref wasn't a tag or digest")
 			}
-			tag = d.Repository.Tag(iWasADigestTag)
 		}
 		tagToImage[tag] = img
 	}
@@ -0,0 +1,215 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Divergence describes a manifest or blob that a pull-through cache serves
+// differently from its upstream.
+type Divergence struct {
+	Digest    string          `json:"digest"`
+	MediaType types.MediaType `json:"mediaType,omitempty"`
+	// Reason says how the mirror's copy differs, e.g. that it's missing or
+	// that its contents don't match the digest.
+	Reason string `json:"reason"`
+}
+
+// PullThroughReport summarizes what VerifyPullThrough compared.
+type PullThroughReport struct {
+	Mirror   string `json:"mirror"`
+	Upstream string `json:"upstream"`
+	Digest   string `json:"digest"`
+
+	// Manifests is the number of manifests compared, including the children
+	// of an index.
+	Manifests int `json:"manifests"`
+	// Blobs is the number of config and layer blobs compared.
+	Blobs int `json:"blobs"`
+	// Divergences are the manifests and blobs that the mirror serves
+	// differently from upstream.
+	Divergences []Divergence `json:"divergences,omitempty"`
+}
+
+// VerifyPullThrough checks that the repository of mirror, a pull-through
+// cache of upstream, serves the same image or index as upstream. If upstream
+// is a tag, it is resolved to a digest first, so that both are compared at the
+// same digest. Every manifest, config and layer is pulled from both and
+// compared by digest; anything the mirror is missing or serves differently is
+// recorded in the report's Divergences rather than returned as an error.
+// Non-distributable layers are skipped.
+func VerifyPullThrough(mirror, upstream string, opt ...Option) (*PullThroughReport, error) {
+	o := makeOptions(opt...)
+	mirrorRef, err := name.ParseReference(mirror, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing mirror reference %q: %w", mirror, err)
+	}
+	upstreamRef, err := name.ParseReference(upstream, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing upstream reference %q: %w", upstream, err)
+	}
+
+	desc, err := remote.Head(upstreamRef, o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", upstreamRef, err)
+	}
+
+	v := &pullThroughVerifier{
+		mirror:   mirrorRef.Context(),
+		upstream: upstreamRef.Context(),
+		opt:      o.Remote,
+		seen:     map[v1.Hash]bool{},
+		report: &PullThroughReport{
+			Mirror:   mirror,
+			Upstream: upstream,
+			Digest:   desc.Digest.String(),
+		},
+	}
+	if err := v.manifest(desc.Digest); err != nil {
+		return nil, err
+	}
+	r := v.report
+	logs.Progress.Printf("Compared %d manifests and %d blobs of %s with %s: %d divergences",
+		r.Manifests, r.Blobs, upstream, mirror, len(r.Divergences))
+	return r, nil
+}
+
+type pullThroughVerifier struct {
+	mirror, upstream name.Repository
+	opt              []remote.Option
+	seen             map[v1.Hash]bool
+	report           *PullThroughReport
+}
+
+func (v *pullThroughVerifier) diverged(h v1.Hash, mt types.MediaType, format string, args ...interface{}) {
+	d := Divergence{Digest: h.String(), MediaType: mt, Reason: fmt.Sprintf(format, args...)}
+	logs.Warn.Printf("%s: %s", d.Digest, d.Reason)
+	v.report.Divergences = append(v.report.Divergences, d)
+}
+
+// manifest compares the manifest h and everything it refers to.
+func (v *pullThroughVerifier) manifest(h v1.Hash) error {
+	if v.seen[h] {
+		return nil
+	}
+	v.seen[h] = true
+	v.report.Manifests++
+
+	up, err := remote.Get(v.upstream.Digest(h.String()), v.opt...)
+	if err != nil {
+		return fmt.Errorf("fetching %s from upstream: %w", h, err)
+	}
+	mirrored, err := remote.Get(v.mirror.Digest(h.String()), v.opt...)
+	switch {
+	case remote.IsNotFound(err):
+		v.diverged(h, up.MediaType, "missing from mirror")
+	case err != nil:
+		v.diverged(h, up.MediaType, "fetching from mirror: %v", err)
+	case mirrored.MediaType != up.MediaType:
+		v.diverged(h, up.MediaType, "mirror serves media type %s", mirrored.MediaType)
+	case !bytes.Equal(mirrored.Manifest, up.Manifest):
+		v.diverged(h, up.MediaType, "mirror serves different manifest contents")
+	}
+
+	// Walk upstream's manifest, so that a missing or broken manifest in the
+	// mirror doesn't hide the state of its children.
+	if up.MediaType.IsIndex() {
+		idx, err := up.ImageIndex()
+		if err != nil {
+			return err
+		}
+		m, err := idx.IndexManifest()
+		if err != nil {
+			return err
+		}
+		for _, child := range m.Manifests {
+			if err := v.manifest(child.Digest); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !up.MediaType.IsImage() {
+		return nil
+	}
+	img, err := up.Image()
+	if err != nil {
+		return err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	if err := v.blob(m.Config); err != nil {
+		return err
+	}
+	for _, l := range m.Layers {
+		if !l.MediaType.IsDistributable() {
+			continue
+		}
+		if err := v.blob(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blob compares the blob that desc describes.
+func (v *pullThroughVerifier) blob(desc v1.Descriptor) error {
+	if v.seen[desc.Digest] {
+		return nil
+	}
+	v.seen[desc.Digest] = true
+	v.report.Blobs++
+
+	if _, err := v.readBlob(v.upstream, desc); err != nil {
+		return fmt.Errorf("fetching %s from upstream: %w", desc.Digest, err)
+	}
+	n, err := v.readBlob(v.mirror, desc)
+	switch {
+	case remote.IsNotFound(err):
+		v.diverged(desc.Digest, desc.MediaType, "missing from mirror")
+	case err != nil:
+		v.diverged(desc.Digest, desc.MediaType, "fetching from mirror: %v", err)
+	case n != desc.Size:
+		v.diverged(desc.Digest, desc.MediaType, "mirror serves %d bytes, want %d", n, desc.Size)
+	}
+	return nil
+}
+
+// readBlob reads the blob desc from repo, verifying it against its digest, and
+// returns its size.
+func (v *pullThroughVerifier) readBlob(repo name.Repository, desc v1.Descriptor) (int64, error) {
+	l, err := remote.Layer(repo.Digest(desc.Digest.String()), v.opt...)
+	if err != nil {
+		return 0, err
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return io.Copy(ioutil.Discard, rc)
+}
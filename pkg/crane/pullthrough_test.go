@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestVerifyPullThrough(t *testing.T) {
+	// Paths under the mirror repository that are missing or corrupt.
+	missing, corrupt := map[string]bool{}, map[string]bool{}
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case missing[r.URL.Path]:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"code":"BLOB_UNKNOWN","message":"gone"}]}`)
+		case corrupt[r.URL.Path]:
+			fmt.Fprint(w, "garbage")
+		default:
+			reg.ServeHTTP(w, r)
+		}
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := fmt.Sprintf("%s/upstream/app:latest", u.Host)
+	mirror := fmt.Sprintf("%s/mirror/app", u.Host)
+
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Copy(upstream, mirror+":latest"); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := crane.VerifyPullThrough(mirror, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Digest != digest.String() {
+		t.Errorf("Digest = %s, want %s", r.Digest, digest)
+	}
+	// The index and its two images, each with a config and two layers.
+	if r.Manifests != 3 || r.Blobs != 6 {
+		t.Errorf("compared %d manifests and %d blobs, want 3 and 6", r.Manifests, r.Blobs)
+	}
+	if len(r.Divergences) != 0 {
+		t.Errorf("Divergences = %v, want none", r.Divergences)
+	}
+
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := m.Manifests[0].Digest
+	img, err := idx.Image(m.Manifests[1].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := layers[1].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing["/v2/mirror/app/manifests/"+child.String()] = true
+	missing["/v2/mirror/app/blobs/"+gone.String()] = true
+	corrupt["/v2/mirror/app/blobs/"+bad.String()] = true
+
+	r, err = crane.VerifyPullThrough(mirror+":ignored", upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, d := range r.Divergences {
+		got[d.Digest] = d.Reason
+	}
+	for _, h := range []string{child.String(), gone.String()} {
+		if got[h] != "missing from mirror" {
+			t.Errorf("reason for %s = %q, want missing", h, got[h])
+		}
+	}
+	if !strings.HasPrefix(got[bad.String()], "fetching from mirror") {
+		t.Errorf("reason for %s = %q, want a fetch error", bad, got[bad.String()])
+	}
+	var digests []string
+	for h := range got {
+		digests = append(digests, h)
+	}
+	sort.Strings(digests)
+	want := []string{child.String(), gone.String(), bad.String()}
+	sort.Strings(want)
+	if diff := cmp.Diff(want, digests); diff != "" {
+		t.Errorf("diverged digests (-want +got): %s", diff)
+	}
+
+	// Failures to read upstream are errors.
+	if _, err := crane.VerifyPullThrough(mirror, fmt.Sprintf("%s/upstream/missing:latest", u.Host)); err == nil {
+		t.Error("VerifyPullThrough() with missing upstream = nil, wanted error")
+	}
+}
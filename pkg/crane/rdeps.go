@@ -0,0 +1,250 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Dependent is an image that was built on a base image, as found by
+// Dependents.
+type Dependent struct {
+	// Reference is the tag that the image was found by.
+	Reference string `json:"reference"`
+	// Digest is the digest of the image, which is a child of the index at
+	// Reference if Platform is set.
+	Digest string `json:"digest"`
+	// Platform is the platform of the image in the index at Reference, if
+	// Reference is an index.
+	Platform *v1.Platform `json:"platform,omitempty"`
+}
+
+// Dependents scans the tags of repos for images that contain every layer of
+// the image at base, by digest, i.e. images that need to be rebuilt when base
+// is. If base is an index, images that contain every layer of any of its
+// images are included. Indexes in repos are searched for matching images, but
+// base itself isn't reported.
+func Dependents(base string, repos []string, opt ...Option) ([]Dependent, error) {
+	o := makeOptions(opt...)
+	ref, err := name.ParseReference(base, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", base, err)
+	}
+	baseLayers, err := layerSets(ref, o)
+	if err != nil {
+		return nil, fmt.Errorf("getting layers of %s: %w", base, err)
+	}
+
+	s := &dependentScan{
+		o:     o,
+		base:  baseLayers,
+		cache: map[v1.Hash]bool{},
+	}
+	var deps []Dependent
+	for _, r := range repos {
+		repo, err := name.NewRepository(r, o.Name...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing repo %q: %w", r, err)
+		}
+		found, err := s.repository(repo)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, found...)
+	}
+	return deps, nil
+}
+
+// layerSets returns the layer digests of the image at ref, or of each image
+// in the index at ref, by image digest.
+func layerSets(ref name.Reference, o Options) (map[v1.Hash]map[v1.Hash]bool, error) {
+	desc, err := remote.Get(ref, o.Remote...)
+	if err != nil {
+		return nil, err
+	}
+	sets := map[v1.Hash]map[v1.Hash]bool{}
+	add := func(img v1.Image) error {
+		h, err := img.Digest()
+		if err != nil {
+			return err
+		}
+		layers, err := layerSet(img)
+		if err != nil {
+			return err
+		}
+		if len(layers) != 0 {
+			sets[h] = layers
+		}
+		return nil
+	}
+
+	switch {
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return nil, err
+		}
+		if err := add(img); err != nil {
+			return nil, err
+		}
+	case desc.MediaType.IsIndex():
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		m, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range m.Manifests {
+			if !child.MediaType.IsImage() {
+				continue
+			}
+			img, err := idx.Image(child.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(img); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected media type %q", desc.MediaType)
+	}
+	if len(sets) == 0 {
+		return nil, errors.New("no layers")
+	}
+	return sets, nil
+}
+
+func layerSet(img v1.Image) (map[v1.Hash]bool, error) {
+	digests, err := layerDigests(img)
+	if err != nil {
+		return nil, err
+	}
+	layers := make(map[v1.Hash]bool, len(digests))
+	for _, h := range digests {
+		layers[h] = true
+	}
+	return layers, nil
+}
+
+// dependentScan remembers which images have been checked, so that images
+// with many tags, or in many indexes, are only fetched once.
+type dependentScan struct {
+	o     Options
+	base  map[v1.Hash]map[v1.Hash]bool
+	cache map[v1.Hash]bool
+}
+
+func (s *dependentScan) repository(repo name.Repository) ([]Dependent, error) {
+	tags, err := remote.List(repo, s.o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", repo, err)
+	}
+	sort.Strings(tags)
+
+	var deps []Dependent
+	for _, tag := range tags {
+		t := repo.Tag(tag)
+		logs.Debug.Printf("checking %s", t)
+		desc, err := remote.Get(t, s.o.Remote...)
+		if err != nil {
+			return nil, fmt.Errorf("getting %s: %w", t, err)
+		}
+
+		switch {
+		case desc.MediaType.IsImage():
+			ok, err := s.image(desc.Digest, func() (v1.Image, error) { return desc.Image() })
+			if err != nil {
+				return nil, fmt.Errorf("checking %s: %w", t, err)
+			}
+			if ok {
+				deps = append(deps, Dependent{Reference: t.String(), Digest: desc.Digest.String()})
+			}
+		case desc.MediaType.IsIndex():
+			idx, err := desc.ImageIndex()
+			if err != nil {
+				return nil, err
+			}
+			m, err := idx.IndexManifest()
+			if err != nil {
+				return nil, fmt.Errorf("checking %s: %w", t, err)
+			}
+			for _, child := range m.Manifests {
+				if !child.MediaType.IsImage() {
+					continue
+				}
+				h := child.Digest
+				ok, err := s.image(h, func() (v1.Image, error) { return idx.Image(h) })
+				if err != nil {
+					return nil, fmt.Errorf("checking %s: %w", t, err)
+				}
+				if ok {
+					deps = append(deps, Dependent{Reference: t.String(), Digest: h.String(), Platform: child.Platform})
+				}
+			}
+		default:
+			logs.Debug.Printf("skipping %s: media type %q", t, desc.MediaType)
+		}
+	}
+	return deps, nil
+}
+
+// image returns whether the image with digest h contains every layer of one of
+// the base images, without being one of them.
+func (s *dependentScan) image(h v1.Hash, img func() (v1.Image, error)) (bool, error) {
+	if ok, checked := s.cache[h]; checked {
+		return ok, nil
+	}
+	if _, ok := s.base[h]; ok {
+		s.cache[h] = false
+		return false, nil
+	}
+	i, err := img()
+	if err != nil {
+		return false, err
+	}
+	layers, err := layerSet(i)
+	if err != nil {
+		return false, err
+	}
+	s.cache[h] = containsAny(layers, s.base)
+	return s.cache[h], nil
+}
+
+// containsAny returns whether layers contains all of any of bases.
+func containsAny(layers map[v1.Hash]bool, bases map[v1.Hash]map[v1.Hash]bool) bool {
+	for _, base := range bases {
+		all := true
+		for l := range base {
+			if !layers[l] {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestDependents(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := fmt.Sprintf("%s/test/app", u.Host)
+	other := fmt.Sprintf("%s/test/other", u.Host)
+
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onBase := func() v1.Image {
+		t.Helper()
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.AppendLayers(base, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	digest := func(img v1.Image) string {
+		t.Helper()
+		h, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.String()
+	}
+
+	app, arm := onBase(), onBase()
+	unrelated, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform := &v1.Platform{OS: "linux", Architecture: "arm64"}
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: unrelated, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm, Descriptor: v1.Descriptor{Platform: platform}},
+	)
+
+	for ref, img := range map[string]v1.Image{
+		repo + ":base":       base,
+		repo + ":v1":         app,
+		repo + ":v1-again":   app,
+		other + ":unrelated": unrelated,
+	} {
+		if err := crane.Push(img, ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	multi, err := name.ParseReference(other + ":multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(multi, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := crane.Dependents(repo+":base", []string{repo, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []crane.Dependent{
+		{Reference: repo + ":v1", Digest: digest(app)},
+		{Reference: repo + ":v1-again", Digest: digest(app)},
+		{Reference: other + ":multi", Digest: digest(arm), Platform: platform},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Dependents() (-want +got) = %s", diff)
+	}
+
+	// Nothing is built on the unrelated image.
+	got, err = crane.Dependents(other+":unrelated", []string{repo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Dependents(unrelated) = %v, want none", got)
+	}
+}
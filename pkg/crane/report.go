@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CopyReport summarizes what CopyWithReport wrote to the destination.
+type CopyReport struct {
+	Source      string          `json:"source"`
+	Destination string          `json:"destination"`
+	Digest      string          `json:"digest"`
+	MediaType   types.MediaType `json:"mediaType"`
+
+	// Manifests is the number of manifests written, including the children
+	// of an index.
+	Manifests int `json:"manifests"`
+	// BlobsUploaded is the number of blobs uploaded.
+	BlobsUploaded int `json:"blobsUploaded"`
+	// BlobsSkipped is the number of blobs that already existed in, or were
+	// mounted into, the destination repository.
+	BlobsSkipped int `json:"blobsSkipped"`
+	// BytesTransferred is the number of blob bytes uploaded.
+	BytesTransferred int64 `json:"bytesTransferred"`
+	// Duration is how long the copy took, in seconds.
+	Duration float64 `json:"durationSeconds"`
+}
+
+// CopyWithReport copies a remote image or index from src to dst, like Copy,
+// and reports what was written to dst.
+func CopyWithReport(src, dst string, opt ...Option) (*CopyReport, error) {
+	o := makeOptions(opt...)
+	o.report = &reportEvents{report: &CopyReport{Source: src, Destination: dst}}
+
+	start := time.Now()
+	desc, err := copyRef(src, dst, o)
+	if err != nil {
+		return nil, err
+	}
+	// Every write has returned, and closed its channel, so this only waits
+	// for the last events to be counted.
+	o.report.wg.Wait()
+
+	r := o.report.report
+	r.Digest = desc.Digest.String()
+	r.MediaType = desc.MediaType
+	r.Duration = time.Since(start).Seconds()
+	logs.Progress.Printf("Copied %s to %s: %d manifests, %d blobs (%d bytes) uploaded, %d blobs skipped in %.1fs",
+		src, dst, r.Manifests, r.BlobsUploaded, r.BytesTransferred, r.BlobsSkipped, r.Duration)
+	return r, nil
+}
+
+// writeOptions returns the options for a write to the destination, which
+// report the write's events, if CopyWithReport is used.
+func (o Options) writeOptions() []remote.Option {
+	if o.report == nil {
+		return o.Remote
+	}
+	// Copy o.Remote so that writes don't share the events option.
+	opts := append([]remote.Option{}, o.Remote...)
+	return append(opts, remote.WithEvents(o.report.events()))
+}
+
+// reportEvents counts the events of the writes to the destination.
+type reportEvents struct {
+	wg sync.WaitGroup
+
+	lock   sync.Mutex
+	report *CopyReport
+}
+
+// events returns a channel for the events of one write, which closes it when
+// it returns.
+func (re *reportEvents) events() chan<- remote.Event {
+	ch := make(chan remote.Event, 16)
+	re.wg.Add(1)
+	go func() {
+		defer re.wg.Done()
+		for e := range ch {
+			re.count(e)
+		}
+	}()
+	return ch
+}
+
+func (re *reportEvents) count(e remote.Event) {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+	r := re.report
+	switch e.Kind {
+	case remote.BlobExisting, remote.BlobMounted:
+		r.BlobsSkipped++
+	case remote.BlobPushed:
+		r.BlobsUploaded++
+		r.BytesTransferred += e.Size
+	case remote.ManifestPushed, remote.TagUpdated:
+		r.Manifests++
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestCopyWithReport(t *testing.T) {
+	srcServer := httptest.NewServer(registry.New())
+	defer srcServer.Close()
+	dstServer := httptest.NewServer(registry.New())
+	defer dstServer.Close()
+	su, err := url.Parse(srcServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	du, err := url.Parse(dstServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/report", su.Host)
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := m.Config.Size
+	for _, l := range m.Layers {
+		want += l.Size
+	}
+
+	dst := fmt.Sprintf("%s/test/report", du.Host)
+	r, err := crane.CopyWithReport(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Source != src || r.Destination != dst || r.Digest != d.String() {
+		t.Errorf("got %s -> %s @ %s, want %s -> %s @ %s", r.Source, r.Destination, r.Digest, src, dst, d)
+	}
+	// Two layers and the config.
+	if r.Manifests != 1 || r.BlobsUploaded != 3 || r.BlobsSkipped != 0 {
+		t.Errorf("got %d manifests, %d uploaded, %d skipped, want 1, 3, 0", r.Manifests, r.BlobsUploaded, r.BlobsSkipped)
+	}
+	if r.BytesTransferred != want {
+		t.Errorf("got %d bytes transferred, want %d", r.BytesTransferred, want)
+	}
+
+	// Copying again skips every blob, with the transport from the options.
+	var requests int32
+	counting := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&requests, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	r, err = crane.CopyWithReport(src, dst, crane.WithTransport(counting))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BlobsUploaded != 0 || r.BlobsSkipped != 3 || r.BytesTransferred != 0 {
+		t.Errorf("got %d uploaded, %d skipped, %d bytes, want 0, 3, 0", r.BlobsUploaded, r.BlobsSkipped, r.BytesTransferred)
+	}
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Error("CopyWithReport didn't use the transport from WithTransport")
+	}
+}
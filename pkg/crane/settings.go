@@ -0,0 +1,238 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are defaults for crane, so that teams can standardize them without
+// passing the same flags everywhere. They are read by LoadConfig from a YAML
+// file like:
+//
+//	platform: linux/arm64
+//	insecure:
+//	- registry.internal:5000
+//	mirrors:
+//	  docker.io: mirror.gcr.io
+//	userAgent: example-ci
+type Settings struct {
+	// Platform is the default platform, in the form
+	// os/arch[/variant][:osversion].
+	Platform string `yaml:"platform,omitempty"`
+	// Insecure are registries that may be used without TLS, or with
+	// certificates that can't be verified.
+	Insecure []string `yaml:"insecure,omitempty"`
+	// Mirrors maps registries to mirrors of them that are tried first when
+	// reading from them. Mirrors are read anonymously. The registry is used
+	// if the mirror is unreachable, fails or doesn't have what's requested.
+	Mirrors map[string]string `yaml:"mirrors,omitempty"`
+	// UserAgent is added to the User-Agent header of requests.
+	UserAgent string `yaml:"userAgent,omitempty"`
+}
+
+// Environment variables that override the config file.
+const (
+	// EnvConfig is the path of the config file, see ConfigPath.
+	EnvConfig = "CRANE_CONFIG"
+	// EnvPlatform overrides Settings.Platform.
+	EnvPlatform = "CRANE_PLATFORM"
+	// EnvInsecure overrides Settings.Insecure, as a comma-separated list.
+	EnvInsecure = "CRANE_INSECURE"
+	// EnvMirrors overrides Settings.Mirrors, as a comma-separated list of
+	// registry=mirror pairs.
+	EnvMirrors = "CRANE_MIRRORS"
+	// EnvUserAgent overrides Settings.UserAgent.
+	EnvUserAgent = "CRANE_USER_AGENT"
+)
+
+// ConfigPath returns the path of crane's config file: $CRANE_CONFIG if set,
+// or else crane/config.yaml in the user's config directory, e.g.
+// ~/.config/crane/config.yaml on Linux.
+func ConfigPath() (string, error) {
+	if p := os.Getenv(EnvConfig); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "crane", "config.yaml"), nil
+}
+
+// LoadConfig reads the Settings in the YAML file at path, which may be
+// missing or empty for none, and applies any overrides from the environment.
+func LoadConfig(path string) (*Settings, error) {
+	s := &Settings{}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		} else if err == nil {
+			if err := yaml.Unmarshal(b, s); err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", path, err)
+			}
+		}
+	}
+
+	if v, ok := os.LookupEnv(EnvPlatform); ok {
+		s.Platform = v
+	}
+	if v, ok := os.LookupEnv(EnvInsecure); ok {
+		s.Insecure = splitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvMirrors); ok {
+		s.Mirrors = map[string]string{}
+		for _, pair := range splitList(v) {
+			reg, mirror, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid mirror %q, want registry=mirror", EnvMirrors, pair)
+			}
+			s.Mirrors[reg] = mirror
+		}
+	}
+	if v, ok := os.LookupEnv(EnvUserAgent); ok {
+		s.UserAgent = v
+	}
+	return s, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Options returns the Options for s, except for Mirrors and the TLS settings
+// of Insecure, which need a transport, see Transport.
+func (s *Settings) Options() ([]Option, error) {
+	var opts []Option
+	if s.Platform != "" {
+		p, err := v1.ParsePlatform(s.Platform)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPlatform(p))
+	}
+	if len(s.Insecure) != 0 {
+		insecure := s.Insecure
+		opts = append(opts, func(o *Options) {
+			o.Name = append(o.Name, name.InsecureRegistries(insecure...))
+		})
+	}
+	if s.UserAgent != "" {
+		opts = append(opts, WithUserAgent(s.UserAgent))
+	}
+	return opts, nil
+}
+
+// Transport wraps t to apply Mirrors and to skip verifying the certificates
+// of Insecure registries, if t is an *http.Transport.
+func (s *Settings) Transport(t http.RoundTripper) (http.RoundTripper, error) {
+	if ht, ok := t.(*http.Transport); ok && len(s.Insecure) != 0 && (ht.TLSClientConfig == nil || !ht.TLSClientConfig.InsecureSkipVerify) {
+		insecure := ht.Clone()
+		if insecure.TLSClientConfig == nil {
+			insecure.TLSClientConfig = &tls.Config{}
+		}
+		insecure.TLSClientConfig.InsecureSkipVerify = true //nolint: gosec
+		it := &insecureTransport{inner: t, insecure: insecure, skip: map[string]bool{}}
+		for _, r := range s.Insecure {
+			it.skip[strings.Trim(r, "[]")] = true
+		}
+		t = it
+	}
+
+	if len(s.Mirrors) == 0 {
+		return t, nil
+	}
+	mt := &mirrorTransport{inner: t, mirrors: map[string]name.Registry{}}
+	for r, m := range s.Mirrors {
+		reg, err := name.NewRegistry(r)
+		if err != nil {
+			return nil, fmt.Errorf("parsing registry %q: %w", r, err)
+		}
+		mirror, err := name.NewRegistry(m)
+		if err != nil {
+			return nil, fmt.Errorf("parsing mirror %q: %w", m, err)
+		}
+		mt.mirrors[reg.RegistryStr()] = mirror
+	}
+	return mt, nil
+}
+
+// insecureTransport sends requests to the registries in skip with insecure,
+// which doesn't verify certificates, and everything else to inner. Registries
+// in skip with a port only match that port; those without match any.
+type insecureTransport struct {
+	inner    http.RoundTripper
+	insecure http.RoundTripper
+	skip     map[string]bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *insecureTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	if t.skip[in.URL.Host] || t.skip[in.URL.Hostname()] {
+		return t.insecure.RoundTrip(in)
+	}
+	return t.inner.RoundTrip(in)
+}
+
+// mirrorTransport sends reads from registries to their mirrors first, and
+// falls back to the registry if the mirror doesn't have what's requested.
+// Pings go to the registry, so that its scheme and auth are used as usual,
+// but its credentials aren't sent to the mirror.
+type mirrorTransport struct {
+	inner   http.RoundTripper
+	mirrors map[string]name.Registry
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *mirrorTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	mirror, ok := t.mirrors[in.URL.Host]
+	if !ok || in.URL.Path == "/v2/" || (in.Method != http.MethodGet && in.Method != http.MethodHead) {
+		return t.inner.RoundTrip(in)
+	}
+
+	out := in.Clone(in.Context())
+	out.URL.Scheme = mirror.Scheme()
+	out.URL.Host = mirror.RegistryStr()
+	out.Host = ""
+	out.Header.Del("Authorization")
+	resp, err := t.inner.RoundTrip(out)
+	if err == nil && resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+	if err == nil {
+		resp.Body.Close()
+		err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	logs.Debug.Printf("mirror %s failed, falling back to %s: %v", mirror, in.URL.Host, err)
+	return t.inner.RoundTrip(in)
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`platform: linux/arm64
+insecure:
+- registry.internal:5000
+mirrors:
+  docker.io: mirror.gcr.io
+userAgent: example-ci
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := crane.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &crane.Settings{
+		Platform:  "linux/arm64",
+		Insecure:  []string{"registry.internal:5000"},
+		Mirrors:   map[string]string{"docker.io": "mirror.gcr.io"},
+		UserAgent: "example-ci",
+	}
+	if diff := cmp.Diff(want, s); diff != "" {
+		t.Errorf("LoadConfig() (-want +got) = %s", diff)
+	}
+
+	t.Setenv(crane.EnvPlatform, "linux/amd64")
+	t.Setenv(crane.EnvInsecure, "a.example, b.example")
+	t.Setenv(crane.EnvMirrors, "gcr.io=mirror.example")
+	s, err = crane.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &crane.Settings{
+		Platform:  "linux/amd64",
+		Insecure:  []string{"a.example", "b.example"},
+		Mirrors:   map[string]string{"gcr.io": "mirror.example"},
+		UserAgent: "example-ci",
+	}
+	if diff := cmp.Diff(want, s); diff != "" {
+		t.Errorf("LoadConfig() with env (-want +got) = %s", diff)
+	}
+
+	o := crane.GetOptions(mustOptions(t, s)...)
+	if diff := cmp.Diff(&v1.Platform{OS: "linux", Architecture: "amd64"}, o.Platform); diff != "" {
+		t.Errorf("Platform (-want +got) = %s", diff)
+	}
+
+	// A missing file is fine.
+	if _, err := crane.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err != nil {
+		t.Errorf("LoadConfig(missing) = %v", err)
+	}
+
+	t.Setenv(crane.EnvMirrors, "gcr.io")
+	if _, err := crane.LoadConfig(path); err == nil {
+		t.Error("LoadConfig() with a bad mirror: expected error")
+	}
+}
+
+func TestSettingsMirrors(t *testing.T) {
+	upstream := httptest.NewServer(registry.New())
+	defer upstream.Close()
+	mirror := httptest.NewServer(registry.New())
+	defer mirror.Close()
+	uu, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu, err := url.Parse(mirror.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirrored, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(mirrored, mu.Host+"/app:mirrored"); err != nil {
+		t.Fatal(err)
+	}
+	missing, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(missing, uu.Host+"/app:missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &crane.Settings{Mirrors: map[string]string{uu.Host: mu.Host}}
+	rt, err := s.Transport(http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := crane.WithTransport(rt)
+
+	for tag, img := range map[string]v1.Image{"mirrored": mirrored, "missing": missing} {
+		want, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := crane.Digest(uu.Host+"/app:"+tag, opt)
+		if err != nil {
+			t.Fatalf("Digest(%s): %v", tag, err)
+		}
+		if got != want.String() {
+			t.Errorf("Digest(%s) = %s, want %s", tag, got, want)
+		}
+	}
+
+	// Writes aren't mirrored.
+	if err := crane.Tag(uu.Host+"/app:missing", "pushed", opt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crane.Digest(mu.Host + "/app:pushed"); err == nil {
+		t.Error("tag was pushed to the mirror")
+	}
+}
+
+func TestSettingsInsecure(t *testing.T) {
+	a := httptest.NewTLSServer(registry.New())
+	defer a.Close()
+	b := httptest.NewTLSServer(registry.New())
+	defer b.Close()
+	au, err := url.Parse(a.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(s *crane.Settings, u string) error {
+		rt, err := s.Transport(http.DefaultTransport.(*http.Transport).Clone())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: rt}).Get(u + "/v2/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// With a port, only that port is insecure.
+	s := &crane.Settings{Insecure: []string{au.Host}}
+	if err := get(s, a.URL); err != nil {
+		t.Errorf("Get(%s): %v", au.Host, err)
+	}
+	if err := get(s, b.URL); err == nil {
+		t.Error("Get() of another port succeeded, wanted certificate error")
+	}
+
+	// Without one, every port is.
+	s = &crane.Settings{Insecure: []string{au.Hostname()}}
+	for _, u := range []string{a.URL, b.URL} {
+		if err := get(s, u); err != nil {
+			t.Errorf("Get(%s): %v", u, err)
+		}
+	}
+}
+
+func mustOptions(t *testing.T, s *crane.Settings) []crane.Option {
+	t.Helper()
+	opts, err := s.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Triangulate returns the tag that cosign's naming convention uses for
+// attachments of type typ, e.g. "sig", "att" or "sbom", to the image at ref.
+// The tag is in ref's repository and is named after the image's digest, e.g.
+// sha256-<hex>.sig. If ref is a tag, it is resolved to a digest first.
+func Triangulate(ref, typ string, opt ...Option) (string, error) {
+	o := makeOptions(opt...)
+	r, err := name.ParseReference(ref, o.Name...)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %w", ref, err)
+	}
+
+	digest := r.Identifier()
+	if _, ok := r.(name.Digest); !ok {
+		digest, err = Digest(ref, opt...)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tag := fmt.Sprintf("%s.%s", strings.Replace(digest, ":", "-", 1), typ)
+	t, err := name.NewTag(fmt.Sprintf("%s:%s", r.Context(), tag), o.Name...)
+	if err != nil {
+		return "", err
+	}
+	return t.String(), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestTriangulate(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := fmt.Sprintf("%s/test/triangulate", u.Host)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, repo+":v1"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%s:sha256-%s", repo, d.Hex)
+
+	for _, ref := range []string{repo + ":v1", repo + "@" + d.String()} {
+		for _, typ := range []string{"sig", "att", "sbom"} {
+			got, err := crane.Triangulate(ref, typ)
+			if err != nil {
+				t.Fatalf("Triangulate(%q, %q): %v", ref, typ, err)
+			}
+			if got != want+"."+typ {
+				t.Errorf("Triangulate(%q, %q) = %q, want %q", ref, typ, got, want+"."+typ)
+			}
+		}
+	}
+
+	if _, err := crane.Triangulate(repo+":missing", "sig"); err == nil || !strings.Contains(err.Error(), "MANIFEST_UNKNOWN") {
+		t.Errorf("Triangulate of missing tag: got %v, want MANIFEST_UNKNOWN", err)
+	}
+}
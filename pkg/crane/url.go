@@ -0,0 +1,307 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/internal/and"
+	"github.com/google/go-containerregistry/internal/gzip"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/stream"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// AppendFromURL appends a layer to the v1.Image base for each of urls, which
+// must be of the form https://example.com/path/to/layer.tar.gz@sha256:...
+// where the digest is that of the (usually gzipped) blob served at the URL.
+//
+// Layers are streamed from their URLs while the image is written, e.g. by
+// Push, without being buffered on disk, and their digests are verified as
+// they are read. As with stream.Layer, their digests, diff IDs and sizes
+// aren't available until they have been read, so the resulting image can't
+// be written with tarball.Write or with progress updates.
+//
+// Layers are fetched with the transport set by WithTransport, if any.
+//
+// Windows base images aren't supported, since their layers must be rewritten.
+func AppendFromURL(base v1.Image, urls []string, opt ...Option) (v1.Image, error) {
+	o := makeOptions(opt...)
+	client := &http.Client{Transport: o.Transport}
+	if client.Transport == nil {
+		client.Transport = remote.DefaultTransport
+	}
+	if base == nil {
+		return nil, fmt.Errorf("invalid argument: base")
+	}
+
+	win, err := isWindows(base)
+	if err != nil {
+		return nil, fmt.Errorf("getting base image: %w", err)
+	}
+	if win {
+		return nil, errors.New("appending layers from URLs to Windows base images is not supported")
+	}
+
+	baseMediaType, err := base.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("getting base image media type: %w", err)
+	}
+
+	layerType := types.DockerLayer
+	if baseMediaType == types.OCIManifestSchema1 {
+		layerType = types.OCILayer
+	}
+
+	layers := make([]v1.Layer, 0, len(urls))
+	for _, u := range urls {
+		layer, err := layerFromURL(client, u, layerType)
+		if err != nil {
+			return nil, fmt.Errorf("parsing layer URL %q: %w", u, err)
+		}
+		layers = append(layers, layer)
+	}
+
+	return mutate.AppendLayers(base, layers...)
+}
+
+// urlLayer is a v1.Layer whose compressed contents are fetched from a URL.
+type urlLayer struct {
+	client    *http.Client
+	url       string
+	expected  v1.Hash
+	mediaType types.MediaType
+
+	lock           sync.Mutex
+	digest, diffID *v1.Hash
+	size           int64
+}
+
+func layerFromURL(client *http.Client, s string, mt types.MediaType) (*urlLayer, error) {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return nil, errors.New("missing @sha256:... digest")
+	}
+	h, err := v1.NewHash(s[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s[:i])
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return &urlLayer{
+		client:    client,
+		url:       u.String(),
+		expected:  h,
+		mediaType: mt,
+	}, nil
+}
+
+// Digest implements v1.Layer.
+func (l *urlLayer) Digest() (v1.Hash, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.digest == nil {
+		return v1.Hash{}, stream.ErrNotComputed
+	}
+	return *l.digest, nil
+}
+
+// DiffID implements v1.Layer.
+func (l *urlLayer) DiffID() (v1.Hash, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.diffID == nil {
+		return v1.Hash{}, stream.ErrNotComputed
+	}
+	return *l.diffID, nil
+}
+
+// Size implements v1.Layer.
+func (l *urlLayer) Size() (int64, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.digest == nil {
+		return -1, stream.ErrNotComputed
+	}
+	return l.size, nil
+}
+
+// MediaType implements v1.Layer.
+func (l *urlLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+// Uncompressed implements v1.Layer.
+func (l *urlLayer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	zipped, pr, err := gzip.Peek(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	prc := &and.ReadCloser{Reader: pr, CloseFunc: rc.Close}
+	if !zipped {
+		return prc, nil
+	}
+	return gzip.UnzipReadCloser(prc)
+}
+
+// Compressed implements v1.Layer.
+//
+// The returned reader fails at EOF if the contents don't match the expected
+// digest, and the layer's digest, diff ID and size are only set once it has
+// been read successfully.
+func (l *urlLayer) Compressed() (io.ReadCloser, error) {
+	zh, err := v1.Hasher(l.expected.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Get(l.url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: unexpected status %s", l.url, resp.Status)
+	}
+
+	// The contents are also piped to a goroutine that computes the diff ID.
+	pr, pw := io.Pipe()
+	diffID := make(chan diffIDResult, 1)
+	go func() {
+		h, err := uncompressedDigest(pr)
+		pr.CloseWithError(err)
+		diffID <- diffIDResult{h, err}
+	}()
+
+	return &urlReader{
+		l:      l,
+		body:   resp.Body,
+		zh:     zh,
+		pw:     pw,
+		diffID: diffID,
+	}, nil
+}
+
+type diffIDResult struct {
+	h   v1.Hash
+	err error
+}
+
+// uncompressedDigest returns the digest of the contents of r, after
+// decompressing them if they are gzipped.
+func uncompressedDigest(r io.Reader) (v1.Hash, error) {
+	zipped, pr, err := gzip.Peek(r)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	var ur io.Reader = pr
+	if zipped {
+		gr, err := gzip.UnzipReadCloser(ioutil.NopCloser(pr))
+		if err != nil {
+			return v1.Hash{}, err
+		}
+		defer gr.Close()
+		ur = gr
+	}
+	h, _, err := v1.SHA256(ur)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	// Drain anything after the end of the gzip stream, so that the writer
+	// never blocks.
+	_, err = io.Copy(ioutil.Discard, pr)
+	return h, err
+}
+
+type urlReader struct {
+	l      *urlLayer
+	body   io.ReadCloser
+	zh     hash.Hash
+	n      int64
+	pw     *io.PipeWriter
+	diffID chan diffIDResult
+	err    error
+	done   bool
+}
+
+func (r *urlReader) Read(b []byte) (int, error) {
+	n, err := r.body.Read(b)
+	if n > 0 {
+		r.zh.Write(b[:n])
+		r.n += int64(n)
+		if _, werr := r.pw.Write(b[:n]); werr != nil {
+			return n, fmt.Errorf("reading %s: %w", r.l.url, werr)
+		}
+	}
+	if err == io.EOF {
+		if !r.done {
+			r.done = true
+			r.err = r.finalize()
+		}
+		if r.err != nil {
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+// finalize verifies the digest of the contents once they have been read, and
+// records the layer's digest, diff ID and size.
+func (r *urlReader) finalize() error {
+	r.pw.Close()
+	res := <-r.diffID
+	if res.err != nil {
+		return fmt.Errorf("computing diff ID of %s: %w", r.l.url, res.err)
+	}
+	digest := v1.Hash{
+		Algorithm: r.l.expected.Algorithm,
+		Hex:       hex.EncodeToString(r.zh.Sum(nil)),
+	}
+	if digest != r.l.expected {
+		return fmt.Errorf("digest mismatch for %s: got %s, want %s", r.l.url, digest, r.l.expected)
+	}
+
+	r.l.lock.Lock()
+	defer r.l.lock.Unlock()
+	r.l.digest = &digest
+	r.l.diffID = &res.h
+	r.l.size = r.n
+	return nil
+}
+
+func (r *urlReader) Close() error {
+	r.pw.CloseWithError(io.ErrClosedPipe)
+	return r.body.Close()
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// WatchEvent records a change in the digest that a watched reference resolves
+// to.
+type WatchEvent struct {
+	// Reference is the watched reference as given to Watch.
+	Reference string `json:"reference"`
+	// Previous is the digest that Reference resolved to before the change.
+	Previous string `json:"previous"`
+	// Digest is the digest that Reference now resolves to.
+	Digest string `json:"digest"`
+	// Time is when the change was observed.
+	Time time.Time `json:"time"`
+}
+
+// Watch polls ref every interval and calls f whenever the digest it resolves
+// to changes. The digest ref resolves to when Watch starts is the baseline,
+// and does not produce an event.
+//
+// Errors resolving ref are logged and retried at the next interval, so that a
+// flaky registry doesn't end the watch. Watch returns when ctx is done, with
+// ctx.Err(), or when f returns an error, with that error.
+func Watch(ctx context.Context, ref string, interval time.Duration, f func(WatchEvent) error, opt ...Option) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s: must be positive", interval)
+	}
+	o := makeOptions(append(opt, WithContext(ctx))...)
+	r, err := name.ParseReference(ref, o.Name...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", ref, err)
+	}
+
+	var last string
+	for {
+		desc, err := remote.Head(r, o.Remote...)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			logs.Warn.Printf("resolving %s: %v", r, err)
+		case last == "":
+			last = desc.Digest.String()
+		case desc.Digest.String() != last:
+			ev := WatchEvent{
+				Reference: ref,
+				Previous:  last,
+				Digest:    desc.Digest.String(),
+				Time:      time.Now(),
+			}
+			last = ev.Digest
+			if err := f(ev); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestWatch(t *testing.T) {
+	// Signal the first HEAD so we know the baseline has been resolved.
+	resolved := make(chan struct{})
+	var once sync.Once
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.ServeHTTP(w, r)
+		if r.Method == http.MethodHead {
+			once.Do(func() { close(resolved) })
+		}
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := fmt.Sprintf("%s/test/watch:latest", u.Host)
+
+	img1, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img1, ref); err != nil {
+		t.Fatal(err)
+	}
+	d1, err := img1.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan crane.WatchEvent, 10)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- crane.Watch(ctx, ref, 10*time.Millisecond, func(ev crane.WatchEvent) error {
+			events <- ev
+			return nil
+		})
+	}()
+
+	select {
+	case <-resolved:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for baseline")
+	}
+
+	img2, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img2, ref); err != nil {
+		t.Fatal(err)
+	}
+	d2, err := img2.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Reference != ref || ev.Previous != d1.String() || ev.Digest != d2.String() || ev.Time.IsZero() {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch() = %v, wanted context.Canceled", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("unexpected extra events: %d", len(events))
+	}
+}
+
+func TestWatchCallbackError(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := fmt.Sprintf("%s/test/watch:latest", u.Host)
+
+	// Keep moving the tag until Watch has a baseline and sees it change.
+	errc := make(chan error, 1)
+	want := errors.New("boom")
+	go func() {
+		errc <- crane.Watch(context.Background(), ref, 10*time.Millisecond, func(crane.WatchEvent) error {
+			return want
+		})
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crane.Push(img, ref); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-errc:
+			if !errors.Is(err, want) {
+				t.Errorf("Watch() = %v, wanted %v", err, want)
+			}
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for Watch to return")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestWatchInvalid(t *testing.T) {
+	noop := func(crane.WatchEvent) error { return nil }
+	if err := crane.Watch(context.Background(), "example.com/foo", 0, noop); err == nil {
+		t.Error("Watch() with zero interval succeeded, wanted err")
+	}
+	if err := crane.Watch(context.Background(), "@@@", time.Second, noop); err == nil {
+		t.Error("Watch() with bad reference succeeded, wanted err")
+	}
+}
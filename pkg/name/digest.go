@@ -24,10 +24,14 @@ import (
 const digestDelim = "@"
 
 // Digest stores a digest name in a structured form.
+//
+// If the name also had a tag, e.g. ubuntu:22.04@sha256:..., the tag is kept
+// alongside the digest, and AsDigestTag returns both.
 type Digest struct {
 	Repository
 	digest   string
 	original string
+	tag      Tag
 }
 
 // Ensure Digest implements Reference
@@ -76,8 +80,14 @@ func NewDigest(name string, opts ...Option) (Digest, error) {
 		return Digest{}, err
 	}
 
+	var explicit Tag
 	tag, err := NewTag(base, opts...)
 	if err == nil {
+		// NewTag defaults to "latest" if no tag was given, so only keep the
+		// tag if it was actually there.
+		if strings.HasSuffix(base, tagDelim+tag.tag) {
+			explicit = tag
+		}
 		base = tag.Repository.Name()
 	}
 
@@ -89,5 +99,6 @@ func NewDigest(name string, opts ...Option) (Digest, error) {
 		Repository: repo,
 		digest:     dig,
 		original:   name,
+		tag:        explicit,
 	}, nil
 }
@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package name
+
+// DigestTag stores a name that has both a tag and a digest, e.g.
+// ubuntu:22.04@sha256:... in a structured form.
+//
+// Its Identifier is the digest, so reading a DigestTag fetches content by
+// digest; the tag is retained so that it can be reported or written to.
+//
+// ParseReference and NewDigest return such names as a Digest, which keeps the
+// tag; use AsDigestTag to get both halves of any Reference.
+type DigestTag struct {
+	Digest
+	tag Tag
+}
+
+// Ensure DigestTag implements Reference
+var _ Reference = (*DigestTag)(nil)
+
+// TagStr returns the tag component of the DigestTag.
+func (d DigestTag) TagStr() string {
+	return d.tag.TagStr()
+}
+
+// Tag returns the Tag portion of the DigestTag.
+func (d DigestTag) Tag() Tag {
+	return d.tag
+}
+
+// Name returns the name from which the DigestTag was derived.
+func (d DigestTag) Name() string {
+	return d.Repository.Name() + tagDelim + d.TagStr() + digestDelim + d.DigestStr()
+}
+
+// NewDigestTag returns a new DigestTag representing the given name, which must
+// contain both an explicit tag and a digest.
+func NewDigestTag(name string, opts ...Option) (DigestTag, error) {
+	d, err := NewDigest(name, opts...)
+	if err != nil {
+		return DigestTag{}, err
+	}
+	dt, ok := AsDigestTag(d)
+	if !ok {
+		return DigestTag{}, newErrBadName("a digest tag must contain both a tag and a digest (e.g. registry/repository:tag@digest) saw: %s", name)
+	}
+	return dt, nil
+}
+
+// AsDigestTag returns ref as a DigestTag if it has both a tag and a digest,
+// i.e. if it is a DigestTag, or a Digest that was parsed from a name with a
+// tag.
+func AsDigestTag(ref Reference) (DigestTag, bool) {
+	switch r := ref.(type) {
+	case DigestTag:
+		return r, true
+	case *DigestTag:
+		return *r, true
+	case Digest:
+		if r.tag.tag != "" {
+			return DigestTag{Digest: r, tag: r.tag}, true
+		}
+	case *Digest:
+		return AsDigestTag(*r)
+	}
+	return DigestTag{}, false
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package name
+
+import (
+	"testing"
+)
+
+func TestNewDigestTag(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range goodStrictValidationTagDigestNames {
+		dt, err := NewDigestTag(name, StrictValidation)
+		if err != nil {
+			t.Errorf("`%s` should be a valid DigestTag name, got error: %v", name, err)
+			continue
+		}
+		if dt.Name() != name {
+			t.Errorf("`%v` .Name() should reproduce the original name. Wanted: %s Got: %s", dt, name, dt.Name())
+		}
+		if dt.Identifier() != validDigest {
+			t.Errorf("`%v` .Identifier() should be the digest. Wanted: %s Got: %s", dt, validDigest, dt.Identifier())
+		}
+	}
+
+	for _, name := range goodWeakValidationTagDigestNames {
+		if _, err := NewDigestTag(name, WeakValidation); err != nil {
+			t.Errorf("`%s` should be a valid DigestTag name, got error: %v", name, err)
+		}
+	}
+
+	for _, name := range append(goodWeakValidationDigestNames, badDigestNames...) {
+		if dt, err := NewDigestTag(name, WeakValidation); err == nil {
+			t.Errorf("`%s` should be an invalid DigestTag name, got DigestTag: %#v", name, dt)
+		}
+	}
+}
+
+func TestDigestTagComponents(t *testing.T) {
+	t.Parallel()
+
+	name := "gcr.io/project-id/image:v1.2@" + validDigest
+	dt, err := NewDigestTag(name, StrictValidation)
+	if err != nil {
+		t.Fatalf("`%s` should be a valid DigestTag name, got error: %v", name, err)
+	}
+	if got, want := dt.TagStr(), "v1.2"; got != want {
+		t.Errorf("TagStr() was incorrect for %v. Wanted: `%s` Got: `%s`", dt, want, got)
+	}
+	if got, want := dt.Tag().Name(), "gcr.io/project-id/image:v1.2"; got != want {
+		t.Errorf("Tag() was incorrect for %v. Wanted: `%s` Got: `%s`", dt, want, got)
+	}
+	if got, want := dt.DigestStr(), validDigest; got != want {
+		t.Errorf("DigestStr() was incorrect for %v. Wanted: `%s` Got: `%s`", dt, want, got)
+	}
+	if got, want := dt.String(), name; got != want {
+		t.Errorf("String() was incorrect for %v. Wanted: `%s` Got: `%s`", dt, want, got)
+	}
+
+	ref, err := ParseReference(name)
+	if err != nil {
+		t.Fatalf("ParseReference(%q): %v", name, err)
+	}
+	// ParseReference still returns a Digest, which keeps the tag.
+	d, ok := ref.(Digest)
+	if !ok {
+		t.Fatalf("ParseReference(%q) = %T, want Digest", name, ref)
+	}
+	got, ok := AsDigestTag(d)
+	if !ok {
+		t.Fatalf("AsDigestTag(%v) = false, want true", d)
+	}
+	if got != dt {
+		t.Errorf("AsDigestTag(%v) = %#v, want %#v", d, got, dt)
+	}
+
+	untagged, err := NewDigest("gcr.io/project-id/image@" + validDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := AsDigestTag(untagged); ok {
+		t.Errorf("AsDigestTag(%v) = true, want false", untagged)
+	}
+}
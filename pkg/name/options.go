@@ -26,10 +26,11 @@ const (
 )
 
 type options struct {
-	strict          bool // weak by default
-	insecure        bool // secure by default
-	defaultRegistry string
-	defaultTag      string
+	strict             bool // weak by default
+	insecure           bool // secure by default
+	insecureRegistries map[string]bool
+	defaultRegistry    string
+	defaultTag         string
 }
 
 func makeOptions(opts ...Option) options {
@@ -64,6 +65,22 @@ func Insecure(opts *options) {
 	opts.insecure = true
 }
 
+// InsecureRegistries is an Option that allows image references to be fetched
+// without TLS, like Insecure, but only from the given registries.
+func InsecureRegistries(registries ...string) Option {
+	return func(opts *options) {
+		if opts.insecureRegistries == nil {
+			opts.insecureRegistries = map[string]bool{}
+		}
+		for _, r := range registries {
+			if r == defaultRegistryAlias {
+				r = DefaultRegistry
+			}
+			opts.insecureRegistries[r] = true
+		}
+	}
+}
+
 // OptionFn is a function that returns an option.
 type OptionFn func() Option
 
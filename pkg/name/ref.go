@@ -37,6 +37,9 @@ type Reference interface {
 }
 
 // ParseReference parses the string as a reference, either by tag or digest.
+//
+// If the string has both a tag and a digest, the result is a Digest that
+// keeps the tag, which AsDigestTag returns along with the digest.
 func ParseReference(s string, opts ...Option) (Reference, error) {
 	if t, err := NewTag(s, opts...); err == nil {
 		return t, nil
@@ -124,7 +124,7 @@ func NewRegistry(name string, opts ...Option) (Registry, error) {
 		name = DefaultRegistry
 	}
 
-	return Registry{registry: name, insecure: opt.insecure}, nil
+	return Registry{registry: name, insecure: opt.insecure || opt.insecureRegistries[name]}, nil
 }
 
 // NewInsecureRegistry returns an Insecure Registry based on the given name.
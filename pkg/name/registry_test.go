@@ -250,3 +250,25 @@ func TestRegistryInsecureScheme(t *testing.T) {
 		t.Errorf("scheme(%v); got %v, want http", reg, got)
 	}
 }
+
+func TestInsecureRegistries(t *testing.T) {
+	t.Parallel()
+	opt := InsecureRegistries("registry.example.com", "docker.io")
+
+	for _, tc := range []struct {
+		ref  string
+		want string
+	}{
+		{"registry.example.com/foo/bar", "http"},
+		{"ubuntu", "http"},
+		{"gcr.io/foo/bar", "https"},
+	} {
+		ref, err := ParseReference(tc.ref, opt)
+		if err != nil {
+			t.Fatalf("ParseReference(%s) = %v", tc.ref, err)
+		}
+		if got := ref.Context().Registry.Scheme(); got != tc.want {
+			t.Errorf("scheme(%v); got %v, want %v", ref, got, tc.want)
+		}
+	}
+}
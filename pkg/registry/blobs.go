@@ -21,10 +21,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -49,44 +49,54 @@ func isBlob(req *http.Request) bool {
 		elem[len(elem)-2] == "uploads")
 }
 
-// blobHandler represents a minimal blob storage backend, capable of serving
-// blob contents.
-type blobHandler interface {
-	// Get gets the blob contents, or errNotFound if the blob wasn't found.
+// BlobHandler represents a minimal blob storage backend, capable of serving
+// blob contents. Use WithBlobHandler to serve blobs from it.
+//
+// Backends may also implement BlobStatHandler, BlobPutHandler and
+// BlobDeleteHandler to support more of the registry API.
+type BlobHandler interface {
+	// Get gets the blob contents, or ErrNotFound if the blob wasn't found.
+	//
+	// The registry stops reading the contents once ctx is done, e.g.
+	// because the client disconnected, and closes them.
 	Get(ctx context.Context, repo string, h v1.Hash) (io.ReadCloser, error)
 }
 
-// blobStatHandler is an extension interface representing a blob storage
+// BlobStatHandler is an extension interface representing a blob storage
 // backend that can serve metadata about blobs.
-type blobStatHandler interface {
-	// Stat returns the size of the blob, or errNotFound if the blob wasn't
-	// found, or redirectError if the blob can be found elsewhere.
+type BlobStatHandler interface {
+	// Stat returns the size of the blob, or ErrNotFound if the blob wasn't
+	// found, or RedirectError if the blob can be found elsewhere.
 	Stat(ctx context.Context, repo string, h v1.Hash) (int64, error)
 }
 
-// blobPutHandler is an extension interface representing a blob storage backend
+// BlobPutHandler is an extension interface representing a blob storage backend
 // that can write blob contents.
-type blobPutHandler interface {
+type BlobPutHandler interface {
 	// Put puts the blob contents.
 	//
 	// The contents will be verified against the expected size and digest
 	// as the contents are read, and an error will be returned if these
 	// don't match. Implementations should return that error, or a wrapper
 	// around that error, to return the correct error when these don't match.
+	//
+	// Reading rc also fails once ctx is done, e.g. because the client
+	// disconnected, in which case implementations should discard anything
+	// they have written.
 	Put(ctx context.Context, repo string, h v1.Hash, rc io.ReadCloser) error
 }
 
-// blobDeleteHandler is an extension interface representing a blob storage
+// BlobDeleteHandler is an extension interface representing a blob storage
 // backend that can delete blob contents.
-type blobDeleteHandler interface {
+type BlobDeleteHandler interface {
 	// Delete the blob contents.
 	Delete(ctx context.Context, repo string, h v1.Hash) error
 }
 
-// redirectError represents a signal that the blob handler doesn't have the blob
+// RedirectError represents a signal that the blob handler doesn't have the blob
 // contents, but that those contents are at another location which registry
 // clients should redirect to.
-type redirectError struct {
+type RedirectError struct {
 	// Location is the location to find the contents.
 	Location string
 
@@ -94,10 +104,10 @@ type redirectError struct {
 	Code int
 }
 
-func (e redirectError) Error() string { return fmt.Sprintf("redirecting (%d): %s", e.Code, e.Location) }
+func (e RedirectError) Error() string { return fmt.Sprintf("redirecting (%d): %s", e.Code, e.Location) }
 
-// errNotFound represents an error locating the blob.
-var errNotFound = errors.New("not found")
+// ErrNotFound represents an error locating a blob or manifest.
+var ErrNotFound = errors.New("not found")
 
 type memHandler struct {
 	m    map[string][]byte
@@ -110,7 +120,7 @@ func (m *memHandler) Stat(_ context.Context, _ string, h v1.Hash) (int64, error)
 
 	b, found := m.m[h.String()]
 	if !found {
-		return 0, errNotFound
+		return 0, ErrNotFound
 	}
 	return int64(len(b)), nil
 }
@@ -120,19 +130,20 @@ func (m *memHandler) Get(_ context.Context, _ string, h v1.Hash) (io.ReadCloser,
 
 	b, found := m.m[h.String()]
 	if !found {
-		return nil, errNotFound
+		return nil, ErrNotFound
 	}
 	return ioutil.NopCloser(bytes.NewReader(b)), nil
 }
 func (m *memHandler) Put(_ context.Context, _ string, h v1.Hash, rc io.ReadCloser) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
+	// Read the contents before locking, so slow uploads don't block others.
 	defer rc.Close()
 	all, err := ioutil.ReadAll(rc)
 	if err != nil {
 		return err
 	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	m.m[h.String()] = all
 	return nil
 }
@@ -141,7 +152,7 @@ func (m *memHandler) Delete(_ context.Context, _ string, h v1.Hash) error {
 	defer m.lock.Unlock()
 
 	if _, found := m.m[h.String()]; !found {
-		return errNotFound
+		return ErrNotFound
 	}
 
 	delete(m.m, h.String())
@@ -150,11 +161,92 @@ func (m *memHandler) Delete(_ context.Context, _ string, h v1.Hash) error {
 
 // blobs
 type blobs struct {
-	blobHandler blobHandler
+	blobHandler BlobHandler
+	log         LogHandler
 
 	// Each upload gets a unique id that writes occur to until finalized.
 	uploads map[string][]byte
 	lock    sync.Mutex
+
+	// uploadLimit is the maximum size of an upload, if positive.
+	uploadLimit int64
+
+	// lenient disables checking upload bodies against their Content-Length.
+	lenient bool
+
+	// chunkMinLength is advertised to clients starting an upload, if
+	// positive, see ChunkMinLength.
+	chunkMinLength int64
+
+	// prefix is prepended to upload locations, see PathPrefix.
+	prefix string
+}
+
+// parseRange parses a Range header of a single range of bytes, like
+// "bytes=0-99", "bytes=100-" or "bytes=-100", into the inclusive start and end
+// of the range of a blob of size bytes it refers to. ok is false if the range
+// is invalid or starts past the end of the blob.
+func parseRange(rng string, size int64) (start, end int64, ok bool) {
+	spec := strings.TrimPrefix(rng, "bytes=")
+	if spec == rng || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	dash := strings.Index(spec, "-")
+	if dash < 0 {
+		return 0, 0, false
+	}
+	first, last := spec[:dash], spec[dash+1:]
+	switch {
+	case first == "":
+		// The last n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	default:
+		var err error
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil || start < 0 {
+			return 0, 0, false
+		}
+		end = size - 1
+		if last != "" {
+			if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+				return 0, 0, false
+			}
+			if end > size-1 {
+				end = size - 1
+			}
+		}
+	}
+	if start >= size {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// uploadRange returns the Range header for an upload of n bytes so far.
+func uploadRange(n int) string {
+	if n == 0 {
+		return "0-0"
+	}
+	return fmt.Sprintf("0-%d", n-1)
+}
+
+// uploadStatus sets the Location and Range headers that describe the state
+// of the upload id in repo, which clients use to resume it.
+func (b *blobs) uploadStatus(resp http.ResponseWriter, repo, id string) {
+	resp.Header().Set("Location", b.prefix+"/"+path.Join("v2", repo, "blobs/uploads", id))
+	resp.Header().Set("Range", uploadRange(len(b.uploads[id])))
+}
+
+var regErrBlobUploadUnknown = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "BLOB_UPLOAD_UNKNOWN",
+	Message: "Unknown upload",
 }
 
 func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
@@ -177,6 +269,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 	contentRange := req.Header.Get("Content-Range")
 
 	repo := req.URL.Host + path.Join(elem[1:len(elem)-2]...)
+	if service == "uploads" {
+		// The path ends with /blobs/uploads/<id>.
+		repo = req.URL.Host + path.Join(elem[1:len(elem)-3]...)
+	}
 
 	switch req.Method {
 	case http.MethodHead:
@@ -190,12 +286,12 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 
 		var size int64
-		if bsh, ok := b.blobHandler.(blobStatHandler); ok {
+		if bsh, ok := b.blobHandler.(BlobStatHandler); ok {
 			size, err = bsh.Stat(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -204,10 +300,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		} else {
 			rc, err := b.blobHandler.Get(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -227,6 +323,18 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		return nil
 
 	case http.MethodGet:
+		if service == "uploads" {
+			// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pushing-a-blob-in-chunks
+			b.lock.Lock()
+			defer b.lock.Unlock()
+			if _, ok := b.uploads[target]; !ok {
+				return regErrBlobUploadUnknown
+			}
+			b.uploadStatus(resp, path.Join(elem[1:len(elem)-3]...), target)
+			resp.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+
 		h, err := v1.NewHash(target)
 		if err != nil {
 			return &regError{
@@ -238,12 +346,12 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 
 		var size int64
 		var r io.Reader
-		if bsh, ok := b.blobHandler.(blobStatHandler); ok {
+		if bsh, ok := b.blobHandler.(BlobStatHandler); ok {
 			size, err = bsh.Stat(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -252,10 +360,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 
 			rc, err := b.blobHandler.Get(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -267,10 +375,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			r = rc
 		} else {
 			tmp, err := b.blobHandler.Get(req.Context(), repo, h)
-			if errors.Is(err, errNotFound) {
+			if errors.Is(err, ErrNotFound) {
 				return regErrBlobUnknown
 			} else if err != nil {
-				var rerr redirectError
+				var rerr RedirectError
 				if errors.As(err, &rerr) {
 					http.Redirect(resp, req, rerr.Location, rerr.Code)
 					return nil
@@ -280,19 +388,41 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 			defer tmp.Close()
 			var buf bytes.Buffer
-			io.Copy(&buf, tmp)
+			if _, err := copyContext(req.Context(), &buf, tmp); err != nil {
+				return regErrInternal(err)
+			}
 			size = int64(buf.Len())
 			r = &buf
 		}
 
-		resp.Header().Set("Content-Length", fmt.Sprint(size))
 		resp.Header().Set("Docker-Content-Digest", h.String())
+		resp.Header().Set("Accept-Ranges", "bytes")
+		if rng := req.Header.Get("Range"); rng != "" {
+			start, end, ok := parseRange(rng, size)
+			if !ok {
+				resp.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+				return &regError{
+					Status:  http.StatusRequestedRangeNotSatisfiable,
+					Code:    "RANGE_INVALID",
+					Message: fmt.Sprintf("We can't serve Range %q of a %d byte blob", rng, size),
+				}
+			}
+			if _, err := io.CopyN(ioutil.Discard, r, start); err != nil {
+				return regErrInternal(err)
+			}
+			resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+			resp.Header().Set("Content-Length", fmt.Sprint(end-start+1))
+			resp.WriteHeader(http.StatusPartialContent)
+			copyContext(req.Context(), resp, io.LimitReader(r, end-start+1))
+			return nil
+		}
+		resp.Header().Set("Content-Length", fmt.Sprint(size))
 		resp.WriteHeader(http.StatusOK)
-		io.Copy(resp, r)
+		copyContext(req.Context(), resp, r)
 		return nil
 
 	case http.MethodPost:
-		bph, ok := b.blobHandler.(blobPutHandler)
+		bph, ok := b.blobHandler.(BlobPutHandler)
 		if !ok {
 			return regErrUnsupported
 		}
@@ -313,15 +443,25 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 				return regErrDigestInvalid
 			}
 
-			vrc, err := verify.ReadCloser(req.Body, req.ContentLength, h)
+			if b.uploadLimit > 0 && req.ContentLength > b.uploadLimit {
+				return regErrBlobTooLarge(b.uploadLimit)
+			}
+			in := ioutil.NopCloser(limit(b.body(req), b.uploadLimit))
+			vrc, err := verify.ReadCloser(in, req.ContentLength, h)
 			if err != nil {
 				return regErrInternal(err)
 			}
 			defer vrc.Close()
 
 			if err = bph.Put(req.Context(), repo, h, vrc); err != nil {
+				if errors.Is(err, errSizeLimit) {
+					return regErrBlobTooLarge(b.uploadLimit)
+				}
+				if errors.Is(err, errBodyLength) {
+					return regErrSizeInvalid(err)
+				}
 				if errors.As(err, &verify.Error{}) {
-					log.Printf("Digest mismatch: %v", err)
+					b.log.Log(logEntry(req, LevelWarn, fmt.Sprintf("Digest mismatch: %v", err)))
 					return regErrDigestMismatch
 				}
 				return regErrInternal(err)
@@ -332,8 +472,13 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 
 		id := fmt.Sprint(rand.Int63())
-		resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-2]...), "blobs/uploads", id))
-		resp.Header().Set("Range", "0-0")
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		b.uploads[id] = []byte{}
+		b.uploadStatus(resp, path.Join(elem[1:len(elem)-2]...), id)
+		if b.chunkMinLength > 0 {
+			resp.Header().Set("OCI-Chunk-Min-Length", fmt.Sprint(b.chunkMinLength))
+		}
 		resp.WriteHeader(http.StatusAccepted)
 		return nil
 
@@ -346,54 +491,48 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if _, ok := b.uploads[target]; !ok {
+			return regErrBlobUploadUnknown
+		}
+		uploadRepo := path.Join(elem[1 : len(elem)-3]...)
+
+		// Chunks must be uploaded in order, so the Content-Range, if any, must
+		// start where the upload left off. Streamed uploads without one are
+		// appended. Either way, the 416 response tells the client where to
+		// resume from.
 		if contentRange != "" {
 			start, end := 0, 0
 			if _, err := fmt.Sscanf(contentRange, "%d-%d", &start, &end); err != nil {
+				b.uploadStatus(resp, uploadRepo, target)
 				return &regError{
 					Status:  http.StatusRequestedRangeNotSatisfiable,
-					Code:    "BLOB_UPLOAD_UNKNOWN",
+					Code:    "BLOB_UPLOAD_INVALID",
 					Message: "We don't understand your Content-Range",
 				}
 			}
-			b.lock.Lock()
-			defer b.lock.Unlock()
 			if start != len(b.uploads[target]) {
+				b.uploadStatus(resp, uploadRepo, target)
 				return &regError{
 					Status:  http.StatusRequestedRangeNotSatisfiable,
-					Code:    "BLOB_UPLOAD_UNKNOWN",
+					Code:    "BLOB_UPLOAD_INVALID",
 					Message: "Your content range doesn't match what we have",
 				}
 			}
-			l := bytes.NewBuffer(b.uploads[target])
-			io.Copy(l, req.Body)
-			b.uploads[target] = l.Bytes()
-			resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-3]...), "blobs/uploads", target))
-			resp.Header().Set("Range", fmt.Sprintf("0-%d", len(l.Bytes())-1))
-			resp.WriteHeader(http.StatusNoContent)
-			return nil
 		}
 
-		b.lock.Lock()
-		defer b.lock.Unlock()
-		if _, ok := b.uploads[target]; ok {
-			return &regError{
-				Status:  http.StatusBadRequest,
-				Code:    "BLOB_UPLOAD_INVALID",
-				Message: "Stream uploads after first write are not allowed",
-			}
+		l := bytes.NewBuffer(b.uploads[target])
+		if err := b.appendUpload(l, b.body(req)); err != nil {
+			return err
 		}
-
-		l := &bytes.Buffer{}
-		io.Copy(l, req.Body)
-
 		b.uploads[target] = l.Bytes()
-		resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-3]...), "blobs/uploads", target))
-		resp.Header().Set("Range", fmt.Sprintf("0-%d", len(l.Bytes())-1))
-		resp.WriteHeader(http.StatusNoContent)
+		b.uploadStatus(resp, uploadRepo, target)
+		resp.WriteHeader(http.StatusAccepted)
 		return nil
 
 	case http.MethodPut:
-		bph, ok := b.blobHandler.(blobPutHandler)
+		bph, ok := b.blobHandler.(BlobPutHandler)
 		if !ok {
 			return regErrUnsupported
 		}
@@ -427,12 +566,15 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 
 		defer req.Body.Close()
-		in := ioutil.NopCloser(io.MultiReader(bytes.NewBuffer(b.uploads[target]), req.Body))
+		in := ioutil.NopCloser(limit(io.MultiReader(bytes.NewBuffer(b.uploads[target]), b.body(req)), b.uploadLimit))
 
 		size := int64(verify.SizeUnknown)
 		if req.ContentLength > 0 {
 			size = int64(len(b.uploads[target])) + req.ContentLength
 		}
+		if b.uploadLimit > 0 && size > b.uploadLimit {
+			return regErrBlobTooLarge(b.uploadLimit)
+		}
 
 		vrc, err := verify.ReadCloser(in, size, h)
 		if err != nil {
@@ -441,8 +583,14 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		defer vrc.Close()
 
 		if err := bph.Put(req.Context(), repo, h, vrc); err != nil {
+			if errors.Is(err, errSizeLimit) {
+				return regErrBlobTooLarge(b.uploadLimit)
+			}
+			if errors.Is(err, errBodyLength) {
+				return regErrSizeInvalid(err)
+			}
 			if errors.As(err, &verify.Error{}) {
-				log.Printf("Digest mismatch: %v", err)
+				b.log.Log(logEntry(req, LevelWarn, fmt.Sprintf("Digest mismatch: %v", err)))
 				return regErrDigestMismatch
 			}
 			return regErrInternal(err)
@@ -454,7 +602,19 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		return nil
 
 	case http.MethodDelete:
-		bdh, ok := b.blobHandler.(blobDeleteHandler)
+		if service == "uploads" {
+			// Cancel the upload.
+			b.lock.Lock()
+			defer b.lock.Unlock()
+			if _, ok := b.uploads[target]; !ok {
+				return regErrBlobUploadUnknown
+			}
+			delete(b.uploads, target)
+			resp.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+
+		bdh, ok := b.blobHandler.(BlobDeleteHandler)
 		if !ok {
 			return regErrUnsupported
 		}
@@ -481,3 +641,76 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 	}
 }
+
+// appendUpload appends the contents of r to the upload buffered in l, failing
+// if that would make it larger than the upload limit.
+func (b *blobs) appendUpload(l *bytes.Buffer, r io.Reader) *regError {
+	n := int64(0)
+	if b.uploadLimit > 0 {
+		n = b.uploadLimit - int64(l.Len())
+		if n <= 0 {
+			return regErrBlobTooLarge(b.uploadLimit)
+		}
+	}
+	_, err := io.Copy(l, limit(r, n))
+	if errors.Is(err, errSizeLimit) {
+		return regErrBlobTooLarge(b.uploadLimit)
+	}
+	if errors.Is(err, errBodyLength) {
+		return regErrSizeInvalid(err)
+	}
+	if err != nil {
+		return regErrInternal(err)
+	}
+	return nil
+}
+
+// errBodyLength is returned when reading a request body that is shorter or
+// longer than its Content-Length.
+var errBodyLength = errors.New("request body does not match Content-Length")
+
+// body returns the body of the upload request req, which fails once the
+// request's context is done, or with errBodyLength if it doesn't match the
+// request's Content-Length, unless the registry is lenient.
+func (b *blobs) body(req *http.Request) io.Reader {
+	var r io.Reader = req.Body
+	if !b.lenient {
+		r = &strictBody{r: r, want: req.ContentLength}
+	}
+	return &ctxReader{ctx: req.Context(), r: r}
+}
+
+// ctxReader is an io.Reader that fails once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// copyContext is like io.Copy, but stops once ctx is done.
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	return io.Copy(dst, &ctxReader{ctx: ctx, r: src})
+}
+
+type strictBody struct {
+	r       io.Reader
+	n, want int64
+}
+
+func (s *strictBody) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	if s.want >= 0 && s.n > s.want {
+		return n, fmt.Errorf("%w: got more than %d bytes", errBodyLength, s.want)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || (err == io.EOF && s.want >= 0 && s.n != s.want) {
+		return n, fmt.Errorf("%w: got %d of %d bytes", errBodyLength, s.n, s.want)
+	}
+	return n, err
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestChunkedUpload(t *testing.T) {
+	reg := registry.New(registry.ChunkMinLength(3), registry.Logger(log.New(ioutil.Discard, "", 0)))
+
+	do := func(method, url, contentRange string, body io.Reader) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, url, body)
+		if contentRange != "" {
+			req.Header.Set("Content-Range", contentRange)
+		}
+		resp := httptest.NewRecorder()
+		reg.ServeHTTP(resp, req)
+		return resp
+	}
+	check := func(resp *httptest.ResponseRecorder, code int, rng string) {
+		t.Helper()
+		if resp.Code != code {
+			t.Fatalf("got status %d, want %d: %s", resp.Code, code, resp.Body.String())
+		}
+		if got := resp.Header().Get("Range"); rng != "" && got != rng {
+			t.Errorf("got Range %q, want %q", got, rng)
+		}
+	}
+
+	resp := do(http.MethodPost, "/v2/foo/blobs/uploads/", "", nil)
+	check(resp, http.StatusAccepted, "0-0")
+	if got, want := resp.Header().Get("OCI-Chunk-Min-Length"), "3"; got != want {
+		t.Errorf("got OCI-Chunk-Min-Length %q, want %q", got, want)
+	}
+	loc := resp.Header().Get("Location")
+
+	// The new upload's status can be queried.
+	check(do(http.MethodGet, loc, "", nil), http.StatusNoContent, "0-0")
+
+	// Chunks with and without a Content-Range are appended in order.
+	check(do(http.MethodPatch, loc, "0-2", strings.NewReader("foo")), http.StatusAccepted, "0-2")
+	check(do(http.MethodPatch, loc, "", strings.NewReader("bar")), http.StatusAccepted, "0-5")
+
+	// Out of order chunks tell the client where to resume from.
+	resp = do(http.MethodPatch, loc, "3-5", strings.NewReader("bar"))
+	check(resp, http.StatusRequestedRangeNotSatisfiable, "0-5")
+	if got := resp.Header().Get("Location"); got != loc {
+		t.Errorf("got Location %q, want %q", got, loc)
+	}
+	check(do(http.MethodGet, loc, "", nil), http.StatusNoContent, "0-5")
+
+	check(do(http.MethodPut, loc+"?digest=sha256:"+sha256String("foobarbaz"), "6-8", strings.NewReader("baz")), http.StatusCreated, "")
+	check(do(http.MethodGet, "/v2/foo/blobs/sha256:"+sha256String("foobarbaz"), "", nil), http.StatusOK, "")
+
+	// The upload is gone once it has been committed.
+	check(do(http.MethodGet, loc, "", nil), http.StatusNotFound, "")
+}
+
+func TestCancelUpload(t *testing.T) {
+	reg := registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)))
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/foo/blobs/uploads/", nil)
+	resp := httptest.NewRecorder()
+	reg.ServeHTTP(resp, req)
+	loc := resp.Header().Get("Location")
+	if resp.Header().Get("OCI-Chunk-Min-Length") != "" {
+		t.Errorf("got OCI-Chunk-Min-Length %q, want none", resp.Header().Get("OCI-Chunk-Min-Length"))
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		resp := httptest.NewRecorder()
+		reg.ServeHTTP(resp, httptest.NewRequest(http.MethodDelete, loc, nil))
+		if resp.Code != want {
+			t.Errorf("DELETE %s: got status %d, want %d", loc, resp.Code, want)
+		}
+	}
+	resp = httptest.NewRecorder()
+	reg.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, loc, nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("GET %s: got status %d, want %d", loc, resp.Code, http.StatusNotFound)
+	}
+}
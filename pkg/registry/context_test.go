@@ -0,0 +1,118 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+// endlessReader never ends, and calls cancel once it has been read n times.
+type endlessReader struct {
+	n      int
+	cancel func()
+}
+
+func (e *endlessReader) Read(p []byte) (int, error) {
+	if e.n--; e.n == 0 {
+		e.cancel()
+	}
+	return len(p), nil
+}
+
+// cancelingWriter calls cancel once it has been written to n times.
+type cancelingWriter struct {
+	*httptest.ResponseRecorder
+	n      int
+	cancel func()
+}
+
+func (c *cancelingWriter) Write(p []byte) (int, error) {
+	if c.n--; c.n == 0 {
+		c.cancel()
+	}
+	return c.ResponseRecorder.Write(p)
+}
+
+// serve serves req with h, failing if it doesn't return promptly.
+func serve(t *testing.T, h http.Handler, resp http.ResponseWriter, req *http.Request) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(resp, req)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s %s didn't stop after its context was canceled", req.Method, req.URL)
+	}
+}
+
+func TestUploadCanceled(t *testing.T) {
+	for _, tc := range []struct {
+		method, url string
+	}{
+		{http.MethodPatch, "/v2/foo/blobs/uploads/1"},
+		{http.MethodPost, "/v2/foo/blobs/uploads/?digest=sha256:" + sha256String("foo")},
+		{http.MethodPut, "/v2/foo/blobs/uploads/1?digest=sha256:" + sha256String("foo")},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			reg := registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			url := strings.Replace(tc.url, "/v2/foo/blobs/uploads/1", startUpload(t, reg), 1)
+			req := httptest.NewRequest(tc.method, url, &endlessReader{n: 10, cancel: cancel}).WithContext(ctx)
+			resp := httptest.NewRecorder()
+			serve(t, reg, resp, req)
+
+			if resp.Code < 400 {
+				t.Errorf("got status %d, want an error", resp.Code)
+			}
+		})
+	}
+}
+
+func TestDownloadCanceled(t *testing.T) {
+	reg := registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)))
+
+	blob := strings.Repeat("a", 10<<20)
+	digest := "sha256:" + sha256String(blob)
+	req := httptest.NewRequest(http.MethodPost, "/v2/foo/blobs/uploads/?digest="+digest, strings.NewReader(blob))
+	resp := httptest.NewRecorder()
+	reg.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("upload: got status %d, want %d", resp.Code, http.StatusCreated)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req = httptest.NewRequest(http.MethodGet, "/v2/foo/blobs/"+digest, nil).WithContext(ctx)
+	w := &cancelingWriter{ResponseRecorder: httptest.NewRecorder(), n: 3, cancel: cancel}
+	serve(t, reg, w, req)
+
+	if got := w.Body.Len(); got >= len(blob) {
+		t.Errorf("got %d bytes after canceling, want fewer than %d", got, len(blob))
+	}
+}
@@ -30,6 +30,57 @@ func TestDeps(t *testing.T) {
 
 			"github.com/google/go-containerregistry/internal/verify",
 			"github.com/google/go-containerregistry/internal/and",
+			"github.com/google/go-containerregistry/internal/flock",
+
+			// WithMirror writes to its targets with pkg/v1/remote, which needs
+			// these.
+			"github.com/google/go-containerregistry/pkg/v1/remote",
+			"github.com/google/go-containerregistry/internal/estargz",
+			"github.com/google/go-containerregistry/internal/gzip",
+			"github.com/google/go-containerregistry/internal/redact",
+			"github.com/google/go-containerregistry/internal/retry",
+			"github.com/google/go-containerregistry/internal/retry/wait",
+			"github.com/google/go-containerregistry/internal/zstd",
+			"github.com/google/go-containerregistry/pkg/authn",
+			"github.com/google/go-containerregistry/pkg/compression",
+			"github.com/google/go-containerregistry/pkg/logs",
+			"github.com/google/go-containerregistry/pkg/name",
+			"github.com/google/go-containerregistry/pkg/v1/empty",
+			"github.com/google/go-containerregistry/pkg/v1/match",
+			"github.com/google/go-containerregistry/pkg/v1/mutate",
+			"github.com/google/go-containerregistry/pkg/v1/partial",
+			"github.com/google/go-containerregistry/pkg/v1/remote/transport",
+			"github.com/google/go-containerregistry/pkg/v1/static",
+			"github.com/google/go-containerregistry/pkg/v1/stream",
+			"github.com/google/go-containerregistry/pkg/v1/tarball",
+
+			"github.com/containerd/stargz-snapshotter/estargz",
+			"github.com/containerd/stargz-snapshotter/estargz/errorutil",
+			"github.com/docker/cli/cli/config",
+			"github.com/docker/cli/cli/config/configfile",
+			"github.com/docker/cli/cli/config/credentials",
+			"github.com/docker/cli/cli/config/types",
+			"github.com/docker/distribution/registry/client/auth/challenge",
+			"github.com/docker/docker-credential-helpers/client",
+			"github.com/docker/docker-credential-helpers/credentials",
+			"github.com/docker/docker/pkg/homedir",
+			"github.com/klauspost/compress",
+			"github.com/klauspost/compress/fse",
+			"github.com/klauspost/compress/huff0",
+			"github.com/klauspost/compress/internal/cpuinfo",
+			"github.com/klauspost/compress/internal/snapref",
+			"github.com/klauspost/compress/zstd",
+			"github.com/klauspost/compress/zstd/internal/xxhash",
+			"github.com/mitchellh/go-homedir",
+			"github.com/opencontainers/go-digest",
+			"github.com/opencontainers/image-spec/specs-go",
+			"github.com/opencontainers/image-spec/specs-go/v1",
+			"github.com/pkg/errors",
+			"github.com/sirupsen/logrus",
+			"github.com/vbatts/tar-split/archive/tar",
+			"golang.org/x/sync/errgroup",
+			"golang.org/x/sys/execabs",
+			"golang.org/x/sys/unix",
 		),
 	})
 }
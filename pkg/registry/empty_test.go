@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestEmptyReposNotFound(t *testing.T) {
+	for _, tc := range []struct {
+		desc            string
+		opts            []registry.Option
+		wantEmpty       int
+		wantNonexistent int
+	}{{
+		desc:            "default",
+		wantEmpty:       http.StatusOK,
+		wantNonexistent: http.StatusNotFound,
+	}, {
+		desc:            "EmptyReposNotFound",
+		opts:            []registry.Option{registry.EmptyReposNotFound()},
+		wantEmpty:       http.StatusNotFound,
+		wantNonexistent: http.StatusNotFound,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			reg := registry.New(append(tc.opts, registry.Logger(log.New(ioutil.Discard, "", 0)))...)
+
+			// Push a tag, then delete it, leaving the repository empty.
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodPut, "/v2/empty/manifests/latest", strings.NewReader("foo")),
+				httptest.NewRequest(http.MethodDelete, "/v2/empty/manifests/latest", nil),
+			} {
+				resp := httptest.NewRecorder()
+				reg.ServeHTTP(resp, req)
+				if resp.Code >= 300 {
+					t.Fatalf("%s %s: got status %d", req.Method, req.URL, resp.Code)
+				}
+			}
+
+			for repo, want := range map[string]int{"empty": tc.wantEmpty, "nonexistent": tc.wantNonexistent} {
+				resp := httptest.NewRecorder()
+				reg.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/v2/"+repo+"/tags/list", nil))
+				if resp.Code != want {
+					t.Errorf("%s: got status %d, want %d", repo, resp.Code, want)
+				}
+				if resp.Code == http.StatusNotFound && !strings.Contains(resp.Body.String(), "NAME_UNKNOWN") {
+					t.Errorf("%s: got body %q, want NAME_UNKNOWN", repo, resp.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -60,6 +60,18 @@ var regErrBlobUnknown = &regError{
 	Message: "Unknown blob",
 }
 
+var regErrNameUnknown = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "NAME_UNKNOWN",
+	Message: "Unknown name",
+}
+
+var regErrManifestUnknown = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "MANIFEST_UNKNOWN",
+	Message: "Unknown manifest",
+}
+
 var regErrUnsupported = &regError{
 	Status:  http.StatusMethodNotAllowed,
 	Code:    "UNSUPPORTED",
@@ -77,3 +89,13 @@ var regErrDigestInvalid = &regError{
 	Code:    "NAME_INVALID",
 	Message: "invalid digest",
 }
+
+// regErrSizeInvalid returns an error for an upload whose body doesn't match
+// its Content-Length.
+func regErrSizeInvalid(err error) *regError {
+	return &regError{
+		Status:  http.StatusBadRequest,
+		Code:    "SIZE_INVALID",
+		Message: err.Error(),
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// refNameAnnotation names each manifest in the index.json of an export.
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+
+	exportIndex = "index.json"
+	exportBlobs = "blobs"
+)
+
+// Export writes the state of h, which must have been returned by New, to w as
+// a tarball of an OCI image layout, so that it can be restored with Import
+// or used by other tools that understand layouts.
+//
+// Every manifest and blob is written to the blobs directory. The index.json
+// has an entry for each repository's tags and manifests, named by the
+// org.opencontainers.image.ref.name annotation: "repo:tag" for tags and
+// "repo@digest" for manifests. In-progress uploads are not included.
+func Export(h http.Handler, w io.Writer) error {
+	r, ok := h.(*registry)
+	if !ok {
+		return errors.New("registry.Export: handler was not created by registry.New")
+	}
+	mh, ok := r.blobs.blobHandler.(*memHandler)
+	if !ok {
+		return errors.New("registry.Export: blob handler does not support export")
+	}
+	mm, ok := r.manifests.manifestHandler.(*memManifests)
+	if !ok {
+		return errors.New("registry.Export: manifest handler does not support export")
+	}
+
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+	mh.lock.Lock()
+	defer mh.lock.Unlock()
+
+	tw := tar.NewWriter(w)
+	if err := writeTarEntry(tw, "oci-layout", []byte(layoutFile)); err != nil {
+		return err
+	}
+
+	blobs := map[string][]byte{}
+	index := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+	}
+	for repo, mfs := range mm.m {
+		for digest, mf := range mfs {
+			desc, err := exportDescriptor(digest, mf, repo+"@"+digest)
+			if err != nil {
+				return err
+			}
+			index.Manifests = append(index.Manifests, desc)
+			blobs[digest] = mf.Blob
+		}
+		for tag, digest := range mm.tags[repo] {
+			desc, err := exportDescriptor(digest, mfs[digest], repo+":"+tag)
+			if err != nil {
+				return err
+			}
+			index.Manifests = append(index.Manifests, desc)
+		}
+	}
+	for digest, b := range mh.m {
+		blobs[digest] = b
+	}
+
+	// Write everything in order, so that exports of the same state are
+	// identical.
+	digests := make([]string, 0, len(blobs))
+	for digest := range blobs {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	for _, digest := range digests {
+		if err := writeTarEntry(tw, exportPath(digest), blobs[digest]); err != nil {
+			return err
+		}
+	}
+	sort.Slice(index.Manifests, func(i, j int) bool {
+		return index.Manifests[i].Annotations[refNameAnnotation] < index.Manifests[j].Annotations[refNameAnnotation]
+	})
+	idx, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, exportIndex, idx); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func exportDescriptor(digest string, mf Manifest, refName string) (v1.Descriptor, error) {
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	return v1.Descriptor{
+		MediaType:   types.MediaType(mf.ContentType),
+		Size:        int64(len(mf.Blob)),
+		Digest:      h,
+		Annotations: map[string]string{refNameAnnotation: refName},
+	}, nil
+}
+
+// Import replaces the state of h, which must have been returned by New, with
+// the contents of a tarball of an OCI image layout, such as one written by
+// Export.
+//
+// Each entry of the layout's index.json is stored under the repository and
+// tag or digest in its org.opencontainers.image.ref.name annotation, e.g.
+// "repo:tag" or "repo@digest". Entries without one are an error, since they
+// don't belong to any repository. Every file in the blobs directory is
+// stored as a blob, once its contents have been verified against its name.
+func Import(h http.Handler, rd io.Reader) error {
+	r, ok := h.(*registry)
+	if !ok {
+		return errors.New("registry.Import: handler was not created by registry.New")
+	}
+	mh, ok := r.blobs.blobHandler.(*memHandler)
+	if !ok {
+		return errors.New("registry.Import: blob handler does not support import")
+	}
+	mm, ok := r.manifests.manifestHandler.(*memManifests)
+	if !ok {
+		return errors.New("registry.Import: manifest handler does not support import")
+	}
+
+	var index *v1.IndexManifest
+	blobs := map[string][]byte{}
+	tr := tar.NewReader(rd)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("reading layout: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", hdr.Name, err)
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == exportIndex:
+			index = &v1.IndexManifest{}
+			if err := json.Unmarshal(b, index); err != nil {
+				return fmt.Errorf("parsing %s: %w", exportIndex, err)
+			}
+		case strings.HasPrefix(name, exportBlobs+"/"):
+			dir, file := path.Split(name)
+			digest := path.Base(dir) + ":" + file
+			if err := verifyBlob(digest, b); err != nil {
+				return fmt.Errorf("reading %s: %w", hdr.Name, err)
+			}
+			blobs[digest] = b
+		}
+	}
+	if index == nil {
+		return fmt.Errorf("layout is missing %s", exportIndex)
+	}
+
+	loaded := newMemManifests()
+	for _, desc := range index.Manifests {
+		refName, ok := desc.Annotations[refNameAnnotation]
+		if !ok {
+			return fmt.Errorf("layout entry %s has no %s annotation", desc.Digest, refNameAnnotation)
+		}
+		repo, target, err := splitRefName(refName)
+		if err != nil {
+			return err
+		}
+		// The blob's digest has been verified, but not the descriptor's size.
+		b, ok := blobs[desc.Digest.String()]
+		if !ok {
+			return fmt.Errorf("layout is missing manifest %s for %s", desc.Digest, refName)
+		}
+		if int64(len(b)) != desc.Size {
+			return fmt.Errorf("manifest %s for %s is %d bytes, want %d", desc.Digest, refName, len(b), desc.Size)
+		}
+		if err := loaded.Put(context.Background(), repo, target, Manifest{
+			ContentType: string(desc.MediaType),
+			Blob:        b,
+		}); err != nil {
+			return err
+		}
+	}
+
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+	mh.lock.Lock()
+	defer mh.lock.Unlock()
+
+	mm.m, mm.tags = loaded.m, loaded.tags
+	mh.m = blobs
+	return nil
+}
+
+// splitRefName splits "repo:tag" or "repo@digest" into its repository and
+// its tag or digest.
+func splitRefName(refName string) (string, string, error) {
+	if i := strings.Index(refName, "@"); i > 0 {
+		return refName[:i], refName[i+1:], nil
+	}
+	if i := strings.LastIndex(refName, ":"); i > strings.LastIndex(refName, "/") && i > 0 {
+		return refName[:i], refName[i+1:], nil
+	}
+	return "", "", fmt.Errorf("invalid %s annotation %q, want repo:tag or repo@digest", refNameAnnotation, refName)
+}
+
+// verifyBlob returns an error unless digest, with one of digestAlgorithms, is
+// the digest of b.
+func verifyBlob(digest string, b []byte) error {
+	alg, _, _ := strings.Cut(digest, ":")
+	if _, ok := digestAlgorithms[alg]; !ok {
+		return fmt.Errorf("unsupported digest algorithm %q", alg)
+	}
+	if got := hashOf(alg, b); got != digest {
+		return fmt.Errorf("digest mismatch: got %s, want %s", got, digest)
+	}
+	return nil
+}
+
+// exportPath returns the path of a blob in a layout, e.g. blobs/sha256/abc.
+func exportPath(digest string) string {
+	return path.Join(exportBlobs, strings.Replace(digest, ":", "/", 1))
+}
+
+func writeTarEntry(tw *tar.Writer, name string, b []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(b)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestExportImport(t *testing.T) {
+	h := registry.New()
+	s := httptest.NewServer(h)
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	tag, err := name.NewTag(host + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxTag, err := name.NewTag(host + "/foo/index:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(idxTag, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := registry.Export(h, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	// The export is a valid layout.
+	dir := t.TempDir()
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(dir, hdr.Name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lp, err := layout.FromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := lp.ResolveTag("foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limg, err := lp.Image(desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(limg); err != nil {
+		t.Errorf("validate.Image(layout): %v", err)
+	}
+
+	// Restore into a fresh registry.
+	h2 := registry.New()
+	s2 := httptest.NewServer(h2)
+	defer s2.Close()
+	if err := registry.Import(h2, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	host2 := strings.TrimPrefix(s2.URL, "http://")
+
+	ref2, err := name.ParseReference(host2 + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := remote.Image(ref2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := got.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != want {
+		t.Errorf("imported digest = %s, want %s", d, want)
+	}
+
+	idxRef2, err := name.ParseReference(host2 + "/foo/index:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotIdx, err := remote.Index(idxRef2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(gotIdx); err != nil {
+		t.Errorf("validate.Index: %v", err)
+	}
+
+	// Exports of the same state are identical.
+	for i := 0; i < 3; i++ {
+		var again bytes.Buffer
+		if err := registry.Export(h2, &again); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+			t.Fatal("exporting an imported registry produced a different tarball")
+		}
+	}
+}
+
+func TestImportCorrupt(t *testing.T) {
+	h := registry.New()
+	s := httptest.NewServer(h)
+	defer s.Close()
+	tag, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := registry.Export(h, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte of every blob, which Import must notice.
+	var corrupt bytes.Buffer
+	tr := tar.NewReader(&buf)
+	tw := tar.NewWriter(&corrupt)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(hdr.Name, "blobs/") {
+			b[0] ^= 0xff
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Import(registry.New(), &corrupt); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Import(corrupt) = %v, wanted digest mismatch", err)
+	}
+	if err := registry.Export(http.NotFoundHandler(), &buf); err == nil {
+		t.Error("Export() of a foreign handler should fail")
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithManifestPutDelay delays storing each pushed manifest by d, after it has
+// been received, to widen the window in which concurrent pushes race.
+func WithManifestPutDelay(d time.Duration) Option {
+	return func(r *registry) {
+		r.manifests.putDelay = d
+	}
+}
+
+// WithManifestPutHook calls hook just before each pushed manifest is stored
+// under ref (a tag or digest) in repo, after any WithManifestPutDelay.
+//
+// hook is called concurrently, without any locks held, so it may block to
+// interleave concurrent pushes deterministically, or push to the registry
+// itself, e.g. to have another writer update :latest first.
+func WithManifestPutHook(hook func(repo, ref string)) Option {
+	return func(r *registry) {
+		r.manifests.putHook = hook
+	}
+}
+
+// beforePut applies WithManifestPutDelay and WithManifestPutHook, if set.
+func (m *manifests) beforePut(req *http.Request, repo, ref string) *regError {
+	if m.putDelay > 0 {
+		t := time.NewTimer(m.putDelay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-req.Context().Done():
+			return regErrInternal(req.Context().Err())
+		}
+	}
+	if m.putHook != nil {
+		m.putHook(repo, ref)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestManifestPutDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	s := httptest.NewServer(registry.New(registry.WithManifestPutDelay(delay), registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+	tag, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/test/delay:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("Write took %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestManifestPutHook(t *testing.T) {
+	var (
+		tag            name.Tag
+		first, second  v1.Image
+		raced          int32
+		interleaveErr  error
+		interleavedRef string
+	)
+	// When the first writer is about to update :latest, the second writer
+	// updates it first, so that the first writer's (stale) update wins.
+	hook := func(repo, ref string) {
+		if ref != "latest" {
+			return
+		}
+		// The second writer's own push calls hook too, so only
+		// interleave once.
+		if !atomic.CompareAndSwapInt32(&raced, 0, 1) {
+			return
+		}
+		interleavedRef = repo + ":" + ref
+		interleaveErr = remote.Write(tag, second)
+	}
+	s := httptest.NewServer(registry.New(registry.WithManifestPutHook(hook), registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+
+	var err error
+	tag, err = name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/test/race:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err = random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err = random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first writer checks that :latest doesn't exist yet, but loses the
+	// race anyway, since the check isn't atomic.
+	if err := remote.Write(tag, first, remote.WithExpectedDigest(v1.Hash{})); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if interleaveErr != nil {
+		t.Fatalf("interleaved Write: %v", interleaveErr)
+	}
+	if want := "test/race:latest"; interleavedRef != want {
+		t.Errorf("hook called for %q, want %q", interleavedRef, want)
+	}
+
+	desc, err := remote.Head(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := first.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != want {
+		t.Errorf("latest is %s, want the first writer's %s", desc.Digest, want)
+	}
+}
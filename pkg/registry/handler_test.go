@@ -0,0 +1,192 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// repoBlobs stores blobs per repository, and can't delete them.
+type repoBlobs struct {
+	lock sync.Mutex
+	m    map[string][]byte
+}
+
+func (b *repoBlobs) Stat(_ context.Context, repo string, h v1.Hash) (int64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	blob, ok := b.m[repo+"@"+h.String()]
+	if !ok {
+		return 0, registry.ErrNotFound
+	}
+	return int64(len(blob)), nil
+}
+
+func (b *repoBlobs) Get(_ context.Context, repo string, h v1.Hash) (io.ReadCloser, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	blob, ok := b.m[repo+"@"+h.String()]
+	if !ok {
+		return nil, registry.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(blob)), nil
+}
+
+func (b *repoBlobs) Put(_ context.Context, repo string, h v1.Hash, rc io.ReadCloser) error {
+	defer rc.Close()
+	blob, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.m[repo+"@"+h.String()] = blob
+	return nil
+}
+
+// flatManifests stores manifests in a single map, like a key-value store.
+type flatManifests map[string]registry.Manifest
+
+func (f flatManifests) Get(_ context.Context, repo, ref string) (registry.Manifest, error) {
+	mf, ok := f[repo+"@"+ref]
+	if !ok {
+		return registry.Manifest{}, registry.ErrNotFound
+	}
+	return mf, nil
+}
+
+func (f flatManifests) Put(_ context.Context, repo, ref string, mf registry.Manifest) error {
+	f[repo+"@"+ref] = mf
+	return nil
+}
+
+func (f flatManifests) Delete(_ context.Context, repo, ref string) error {
+	if _, ok := f[repo+"@"+ref]; !ok {
+		return registry.ErrNotFound
+	}
+	delete(f, repo+"@"+ref)
+	return nil
+}
+
+func (f flatManifests) References(_ context.Context, repo string) ([]string, error) {
+	var refs []string
+	for key := range f {
+		if r := strings.SplitN(key, "@", 2); r[0] == repo {
+			refs = append(refs, r[1])
+		}
+	}
+	if refs == nil {
+		return nil, registry.ErrNotFound
+	}
+	return refs, nil
+}
+
+func (f flatManifests) Repositories(_ context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var repos []string
+	for key := range f {
+		if r := strings.SplitN(key, "@", 2)[0]; !seen[r] {
+			seen[r] = true
+			repos = append(repos, r)
+		}
+	}
+	return repos, nil
+}
+
+func TestHandlers(t *testing.T) {
+	blobs := &repoBlobs{m: map[string][]byte{}}
+	manifests := flatManifests{}
+	reg := registry.New(
+		registry.WithBlobHandler(blobs),
+		registry.WithManifestHandler(manifests),
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+	)
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	u := strings.TrimPrefix(s.URL, "http://")
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	got, err := remote.Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	// Two layers and the config, in the pushed repository.
+	if len(blobs.m) != 3 {
+		t.Errorf("got %d blobs, want 3", len(blobs.m))
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"foo/bar@latest", "foo/bar@" + d.String()} {
+		if _, ok := manifests[key]; !ok {
+			t.Errorf("manifest %s wasn't stored", key)
+		}
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/v2/foo/bar/tags/list", http.StatusOK},
+		{http.MethodGet, "/v2/_catalog", http.StatusOK},
+		{http.MethodGet, "/v2/foo/bar/manifests/missing", http.StatusNotFound},
+		{http.MethodGet, "/v2/foo/missing/manifests/latest", http.StatusNotFound},
+		{http.MethodDelete, "/v2/foo/bar/manifests/latest", http.StatusAccepted},
+		// The blob handler doesn't support deletes.
+		{http.MethodDelete, fmt.Sprintf("/v2/foo/bar/blobs/%s", d), http.StatusMethodNotAllowed},
+	} {
+		resp := httptest.NewRecorder()
+		reg.ServeHTTP(resp, httptest.NewRequest(tc.method, tc.path, nil))
+		if resp.Code != tc.want {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, resp.Code, tc.want)
+		}
+	}
+
+	if err := registry.Export(reg, ioutil.Discard); err == nil {
+		t.Error("Export() with custom handlers should fail")
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/internal/flock"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const layoutFile = `{"imageLayoutVersion": "1.0.0"}`
+
+// BlobLayout stores blobs and manifests on disk in the OCI image layout at
+// dir, which is created if necessary.
+//
+// Manifests are written to the blobs directory along with everything else,
+// and each tag and manifest has an entry in the layout's index.json, named by
+// the org.opencontainers.image.ref.name annotation as "repo:tag" or
+// "repo@digest", as in Export. A repository exists for as long as it has
+// entries, or, once it has been pushed to by this registry, until the
+// registry is restarted.
+//
+// The layout may be shared by several registries, including in other
+// processes, that are pushed to concurrently. Updates to index.json are
+// serialized with a lock on the index.json.lock file.
+func BlobLayout(dir string) Option {
+	return func(r *registry) {
+		r.blobs.blobHandler = &layoutHandler{dir: dir}
+		r.manifests.manifestHandler = &layoutManifests{layoutHandler: layoutHandler{dir: dir}}
+	}
+}
+
+// layoutHandler is a BlobHandler backed by an OCI image layout.
+//
+// Each blob is written to a temporary file in the same directory as its final
+// path and only renamed into place once it has been fully written and
+// verified. Renames are atomic, so concurrent uploads of the same or
+// different blobs never observe, or leave behind, a partially written blob;
+// the last complete upload of a blob wins, and all are identical.
+type layoutHandler struct {
+	dir string
+}
+
+func (l *layoutHandler) blobPath(h v1.Hash) string {
+	return filepath.Join(l.dir, "blobs", h.Algorithm, h.Hex)
+}
+
+func (l *layoutHandler) Stat(_ context.Context, _ string, h v1.Hash) (int64, error) {
+	fi, err := os.Stat(l.blobPath(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (l *layoutHandler) Get(_ context.Context, _ string, h v1.Hash) (io.ReadCloser, error) {
+	f, err := os.Open(l.blobPath(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *layoutHandler) Put(_ context.Context, _ string, h v1.Hash, rc io.ReadCloser) error {
+	defer rc.Close()
+
+	if err := l.init(h); err != nil {
+		return err
+	}
+	return writeFileAtomic(l.blobPath(h), rc)
+}
+
+// init creates the layout, if necessary, and the directory for blobs with
+// h's algorithm.
+func (l *layoutHandler) init(h v1.Hash) error {
+	if err := os.MkdirAll(filepath.Dir(l.blobPath(h)), 0755); err != nil {
+		return err
+	}
+	layout := filepath.Join(l.dir, "oci-layout")
+	if _, err := os.Stat(layout); errors.Is(err, os.ErrNotExist) {
+		return writeFileAtomic(layout, strings.NewReader(layoutFile))
+	}
+	return nil
+}
+
+func (l *layoutHandler) Delete(_ context.Context, _ string, h v1.Hash) error {
+	err := os.Remove(l.blobPath(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// writeFileAtomic writes the contents of r to a temporary file alongside path,
+// and renames it to path once it is complete.
+func writeFileAtomic(path string, r io.Reader) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".upload-")
+	if err != nil {
+		return err
+	}
+	if err := func() error {
+		defer f.Close()
+		if _, err := io.Copy(f, r); err != nil {
+			return err
+		}
+		return f.Chmod(0644)
+	}(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// layoutManifests is a ManifestHandler backed by the index.json of an OCI
+// image layout. Manifests are stored as blobs, by their sha256 digest.
+//
+// Every read-modify-write of index.json holds the layout's lock, and
+// index.json is replaced atomically, so it can be read without the lock.
+type layoutManifests struct {
+	layoutHandler
+
+	// repos are the repositories pushed to by this registry, which, like in
+	// memory, still exist once all of their entries have been deleted.
+	mu    sync.Mutex
+	repos map[string]bool
+}
+
+func (l *layoutManifests) indexPath() string {
+	return filepath.Join(l.dir, "index.json")
+}
+
+// index returns the contents of index.json, which is empty if it doesn't
+// exist yet.
+func (l *layoutManifests) index() (*v1.IndexManifest, error) {
+	b, err := ioutil.ReadFile(l.indexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &v1.IndexManifest{SchemaVersion: 2, MediaType: types.OCIImageIndex}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return v1.ParseIndexManifest(bytes.NewReader(b))
+}
+
+// update calls fn with the contents of index.json, holding the layout's lock,
+// and writes it back if fn succeeds.
+func (l *layoutManifests) update(fn func(*v1.IndexManifest) error) (err error) {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	unlock, err := flock.Lock(l.indexPath() + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+
+	index, err := l.index()
+	if err != nil {
+		return err
+	}
+	if err := fn(index); err != nil {
+		return err
+	}
+	sort.SliceStable(index.Manifests, func(i, j int) bool {
+		return index.Manifests[i].Annotations[refNameAnnotation] < index.Manifests[j].Annotations[refNameAnnotation]
+	})
+	b, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.indexPath(), bytes.NewReader(b))
+}
+
+// layoutRefName returns the ref name of ref, a tag or digest, in repo.
+func layoutRefName(repo, ref string) string {
+	if strings.Contains(ref, ":") {
+		return repo + "@" + ref
+	}
+	return repo + ":" + ref
+}
+
+func (l *layoutManifests) Get(_ context.Context, repo, ref string) (Manifest, error) {
+	index, err := l.index()
+	if err != nil {
+		return Manifest{}, err
+	}
+	want := layoutRefName(repo, ref)
+	for _, desc := range index.Manifests {
+		if desc.Annotations[refNameAnnotation] != want {
+			continue
+		}
+		b, err := ioutil.ReadFile(l.blobPath(desc.Digest))
+		if errors.Is(err, os.ErrNotExist) {
+			return Manifest{}, ErrNotFound
+		} else if err != nil {
+			return Manifest{}, err
+		}
+		return Manifest{ContentType: string(desc.MediaType), Blob: b}, nil
+	}
+	return Manifest{}, ErrNotFound
+}
+
+// Put stores mf under ref and, like in memory, its digest.
+func (l *layoutManifests) Put(_ context.Context, repo, ref string, mf Manifest) error {
+	h, err := v1.NewHash(digestOf(mf.Blob))
+	if err != nil {
+		return err
+	}
+	if err := l.init(h); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(l.blobPath(h), bytes.NewReader(mf.Blob)); err != nil {
+		return err
+	}
+	refNames := map[string]bool{
+		layoutRefName(repo, ref):        true,
+		layoutRefName(repo, h.String()): true,
+	}
+	if err := l.update(func(index *v1.IndexManifest) error {
+		manifests := index.Manifests[:0]
+		for _, desc := range index.Manifests {
+			if !refNames[desc.Annotations[refNameAnnotation]] {
+				manifests = append(manifests, desc)
+			}
+		}
+		for refName := range refNames {
+			manifests = append(manifests, v1.Descriptor{
+				MediaType:   types.MediaType(mf.ContentType),
+				Size:        int64(len(mf.Blob)),
+				Digest:      h,
+				Annotations: map[string]string{refNameAnnotation: refName},
+			})
+		}
+		index.Manifests = manifests
+		return nil
+	}); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.repos == nil {
+		l.repos = map[string]bool{}
+	}
+	l.repos[repo] = true
+	return nil
+}
+
+// Delete removes the entry for ref. Like in memory, deleting a manifest by
+// its digest also removes the tags that refer to it. Blobs are left in place.
+func (l *layoutManifests) Delete(_ context.Context, repo, ref string) error {
+	refName := layoutRefName(repo, ref)
+	return l.update(func(index *v1.IndexManifest) error {
+		found := false
+		manifests := index.Manifests[:0]
+		for _, desc := range index.Manifests {
+			name := desc.Annotations[refNameAnnotation]
+			if name == refName {
+				found = true
+				continue
+			}
+			if r, _, err := splitRefName(name); err == nil && r == repo && desc.Digest.String() == ref {
+				continue
+			}
+			manifests = append(manifests, desc)
+		}
+		if !found {
+			return ErrNotFound
+		}
+		index.Manifests = manifests
+		return nil
+	})
+}
+
+func (l *layoutManifests) References(_ context.Context, repo string) ([]string, error) {
+	index, err := l.index()
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, desc := range index.Manifests {
+		if r, ref, err := splitRefName(desc.Annotations[refNameAnnotation]); err == nil && r == repo {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.repos[repo] {
+			return nil, ErrNotFound
+		}
+	}
+	return refs, nil
+}
+
+func (l *layoutManifests) Repositories(_ context.Context) ([]string, error) {
+	index, err := l.index()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	repos := []string{}
+	for _, desc := range index.Manifests {
+		if r, _, err := splitRefName(desc.Annotations[refNameAnnotation]); err == nil && !seen[r] {
+			seen[r] = true
+			repos = append(repos, r)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for r := range l.repos {
+		if !seen[r] {
+			repos = append(repos, r)
+		}
+	}
+	return repos, nil
+}
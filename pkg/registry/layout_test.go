@@ -0,0 +1,292 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestBlobLayoutConcurrentPush(t *testing.T) {
+	dir := t.TempDir()
+
+	// Two registries sharing one layout, as if run by separate processes.
+	var servers []*httptest.Server
+	for i := 0; i < 2; i++ {
+		s := httptest.NewServer(registry.New(registry.BlobLayout(dir)))
+		defer s.Close()
+		servers = append(servers, s)
+	}
+
+	// Every image shares a base layer, so the same blob is pushed concurrently.
+	base, err := random.Image(4096, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const pushes = 8
+	refs := make([]name.Reference, pushes)
+	imgs := make([]v1.Image, pushes)
+	var wg sync.WaitGroup
+	errs := make(chan error, pushes)
+	for i := 0; i < pushes; i++ {
+		layer, err := random.Layer(1024, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.AppendLayers(base, layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := strings.TrimPrefix(servers[i%len(servers)].URL, "http://")
+		ref, err := name.ParseReference(fmt.Sprintf("%s/test/image:%d", u, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs[i], imgs[i] = ref, img
+		// Compute the image up front; mutate images aren't safe to
+		// compute concurrently.
+		if _, err := img.Digest(); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- remote.Write(ref, img)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Write: %v", err)
+		}
+	}
+
+	for i, ref := range refs {
+		img, err := remote.Image(ref)
+		if err != nil {
+			t.Fatalf("Image(%s): %v", ref, err)
+		}
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image(%s): %v", ref, err)
+		}
+		want, err := imgs[i].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := img.Digest(); err != nil {
+			t.Fatal(err)
+		} else if got != want {
+			t.Errorf("Digest(%s) = %s, want %s", ref, got, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err != nil {
+		t.Errorf("oci-layout: %v", err)
+	}
+	// Every blob is complete and no temporary files are left behind.
+	files, err := ioutil.ReadDir(filepath.Join(dir, "blobs", "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "blobs", "sha256", fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); got != fi.Name() {
+			t.Errorf("blob %s has digest %s", fi.Name(), got)
+		}
+	}
+}
+
+func TestBlobLayoutDigestMismatch(t *testing.T) {
+	dir := t.TempDir()
+	s := httptest.NewServer(registry.New(registry.BlobLayout(dir)))
+	defer s.Close()
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/v2/foo/blobs/uploads/?digest="+digest, strings.NewReader("not the right content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "blobs", "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("unexpected files after failed upload: %v", files)
+	}
+}
+
+func TestBlobLayoutIndex(t *testing.T) {
+	dir := t.TempDir()
+	var hosts []string
+	for i := 0; i < 4; i++ {
+		s := httptest.NewServer(registry.New(registry.BlobLayout(dir)))
+		defer s.Close()
+		hosts = append(hosts, strings.TrimPrefix(s.URL, "http://"))
+	}
+
+	// The registries are pushed tags at the same time, so that they update
+	// index.json at once.
+	const pushes = 64
+	want := map[string]v1.Hash{}
+	var wg sync.WaitGroup
+	errs := make(chan error, pushes)
+	for i := 0; i < pushes; i++ {
+		img, err := random.Image(16, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag := fmt.Sprintf("tag%d", i)
+		want["test/image:"+tag] = h
+		want["test/image@"+h.String()] = h
+		ref, err := name.ParseReference(fmt.Sprintf("%s/test/image:%s", hosts[i%len(hosts)], tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- remote.Write(ref, img)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Write: %v", err)
+		}
+	}
+
+	// index.json has every tag, and the result is a valid layout.
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]v1.Hash{}
+	for _, desc := range m.Manifests {
+		got[desc.Annotations["org.opencontainers.image.ref.name"]] = desc.Digest
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("index.json (-want +got) = %s", diff)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index: %v", err)
+	}
+
+	// Each registry serves what was pushed to another.
+	for i, host := range hosts {
+		ref, err := name.ParseReference(fmt.Sprintf("%s/test/image:tag%d", host, i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := remote.Image(ref)
+		if err != nil {
+			t.Fatalf("Image(%s): %v", ref, err)
+		}
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image(%s): %v", ref, err)
+		}
+	}
+	repo, err := name.NewRepository(hosts[0] + "/test/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := remote.List(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != pushes {
+		t.Errorf("List() = %v, want %d tags", tags, pushes)
+	}
+}
+
+func TestBlobLayoutEmptyRepo(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.BlobLayout(t.TempDir())))
+	defer s.Close()
+
+	img, err := random.Image(16, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/test/image:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := remote.Delete(ref.Context().Digest(h.String())); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// Like in memory, the repository still exists without any manifests.
+	tags, err := remote.List(ref.Context())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("List() = %v, want no tags", tags)
+	}
+}
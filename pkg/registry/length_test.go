@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+// truncatedReader returns its contents and then io.ErrUnexpectedEOF, like the
+// body of a request whose client hung up early.
+type truncatedReader struct{ r io.Reader }
+
+func (t *truncatedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestContentLength(t *testing.T) {
+	const body = "foo"
+	digest := "sha256:" + sha256String(body)
+
+	for _, tc := range []struct {
+		desc          string
+		lenient       bool
+		method, url   string
+		contentLength int64
+		truncated     bool
+		code          int
+	}{{
+		desc:          "stream upload",
+		method:        http.MethodPatch,
+		url:           "/v2/foo/blobs/uploads/1",
+		contentLength: 3,
+		code:          http.StatusAccepted,
+	}, {
+		desc:          "stream upload short",
+		method:        http.MethodPatch,
+		url:           "/v2/foo/blobs/uploads/1",
+		contentLength: 10,
+		code:          http.StatusBadRequest,
+	}, {
+		desc:          "stream upload long",
+		method:        http.MethodPatch,
+		url:           "/v2/foo/blobs/uploads/1",
+		contentLength: 2,
+		code:          http.StatusBadRequest,
+	}, {
+		desc:          "stream upload truncated",
+		method:        http.MethodPatch,
+		url:           "/v2/foo/blobs/uploads/1",
+		contentLength: -1,
+		truncated:     true,
+		code:          http.StatusBadRequest,
+	}, {
+		desc:          "lenient stream upload short",
+		lenient:       true,
+		method:        http.MethodPatch,
+		url:           "/v2/foo/blobs/uploads/1",
+		contentLength: 10,
+		code:          http.StatusAccepted,
+	}, {
+		desc:          "lenient stream upload truncated",
+		lenient:       true,
+		method:        http.MethodPatch,
+		url:           "/v2/foo/blobs/uploads/1",
+		contentLength: -1,
+		truncated:     true,
+		code:          http.StatusInternalServerError,
+	}, {
+		desc:          "monolithic upload short",
+		method:        http.MethodPost,
+		url:           "/v2/foo/blobs/uploads/?digest=" + digest,
+		contentLength: 10,
+		code:          http.StatusBadRequest,
+	}, {
+		desc:          "monolithic upload truncated",
+		method:        http.MethodPost,
+		url:           "/v2/foo/blobs/uploads/?digest=" + digest,
+		contentLength: -1,
+		truncated:     true,
+		code:          http.StatusBadRequest,
+	}, {
+		desc:          "upload put short",
+		method:        http.MethodPut,
+		url:           "/v2/foo/blobs/uploads/1?digest=" + digest,
+		contentLength: 10,
+		code:          http.StatusBadRequest,
+	}, {
+		desc:          "upload put",
+		method:        http.MethodPut,
+		url:           "/v2/foo/blobs/uploads/1?digest=" + digest,
+		contentLength: 3,
+		code:          http.StatusCreated,
+	}, {
+		desc:          "manifest put truncated",
+		method:        http.MethodPut,
+		url:           "/v2/foo/manifests/latest",
+		contentLength: -1,
+		truncated:     true,
+		code:          http.StatusInternalServerError,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := []registry.Option{registry.Logger(log.New(ioutil.Discard, "", 0))}
+			if tc.lenient {
+				opts = append(opts, registry.LenientUploads())
+			}
+			reg := registry.New(opts...)
+
+			var r io.Reader = strings.NewReader(body)
+			if tc.truncated {
+				r = &truncatedReader{r}
+			}
+			url := strings.Replace(tc.url, "/v2/foo/blobs/uploads/1", startUpload(t, reg), 1)
+			req := httptest.NewRequest(tc.method, url, r)
+			req.ContentLength = tc.contentLength
+			resp := httptest.NewRecorder()
+			reg.ServeHTTP(resp, req)
+
+			if resp.Code != tc.code {
+				t.Errorf("got status %d, want %d", resp.Code, tc.code)
+			}
+			if tc.code == http.StatusBadRequest && !strings.Contains(resp.Body.String(), "SIZE_INVALID") {
+				t.Errorf("got body %q, want SIZE_INVALID", resp.Body.String())
+			}
+		})
+	}
+}
+
+// startUpload starts a blob upload to foo and returns its location.
+func startUpload(t *testing.T, h http.Handler) string {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/v2/foo/blobs/uploads/", nil))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("POST /v2/foo/blobs/uploads/: got status %d, want %d", resp.Code, http.StatusAccepted)
+	}
+	return resp.Header().Get("Location")
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ManifestSizeLimit rejects manifests larger than n bytes with
+// MANIFEST_INVALID, instead of reading them into memory.
+func ManifestSizeLimit(n int64) Option {
+	return func(r *registry) {
+		r.manifests.sizeLimit = n
+	}
+}
+
+// BlobUploadLimit rejects blob uploads, across all of their chunks, that are
+// larger than n bytes with SIZE_INVALID.
+func BlobUploadLimit(n int64) Option {
+	return func(r *registry) {
+		r.blobs.uploadLimit = n
+	}
+}
+
+// errSizeLimit is returned by a limitedReader that has more to read than its
+// limit.
+var errSizeLimit = errors.New("size limit exceeded")
+
+// limit returns r, limited to n bytes if n is positive.
+func limit(r io.Reader, n int64) io.Reader {
+	if n <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, n: n}
+}
+
+// limitedReader is like io.LimitedReader, but fails with errSizeLimit rather
+// than stopping at the limit, so that it can't be mistaken for a short read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Only fail if there is actually more to read.
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, errSizeLimit
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func regErrManifestTooLarge(limit int64) *regError {
+	return &regError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    "MANIFEST_INVALID",
+		Message: fmt.Sprintf("manifest exceeds the maximum size of %d bytes", limit),
+	}
+}
+
+func regErrBlobTooLarge(limit int64) *regError {
+	return &regError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    "SIZE_INVALID",
+		Message: fmt.Sprintf("blob exceeds the maximum size of %d bytes", limit),
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestLimits(t *testing.T) {
+	const limit = 100
+	small := strings.Repeat("a", limit)
+	large := strings.Repeat("a", limit+1)
+
+	for _, tc := range []struct {
+		desc string
+		opt  registry.Option
+		// Requests are made in order, and only the last is expected to fail.
+		reqs []limitRequest
+		code int
+		err  string
+	}{{
+		desc: "manifest under limit",
+		opt:  registry.ManifestSizeLimit(limit),
+		reqs: []limitRequest{{http.MethodPut, "/v2/foo/manifests/latest", small, false}},
+		code: http.StatusCreated,
+	}, {
+		desc: "manifest over limit",
+		opt:  registry.ManifestSizeLimit(limit),
+		reqs: []limitRequest{{http.MethodPut, "/v2/foo/manifests/latest", large, false}},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "MANIFEST_INVALID",
+	}, {
+		desc: "streamed manifest over limit",
+		opt:  registry.ManifestSizeLimit(limit),
+		reqs: []limitRequest{{http.MethodPut, "/v2/foo/manifests/latest", large, true}},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "MANIFEST_INVALID",
+	}, {
+		desc: "monolithic blob under limit",
+		opt:  registry.BlobUploadLimit(limit),
+		reqs: []limitRequest{{http.MethodPost, "/v2/foo/blobs/uploads/?digest=sha256:" + sha256String(small), small, false}},
+		code: http.StatusCreated,
+	}, {
+		desc: "monolithic blob over limit",
+		opt:  registry.BlobUploadLimit(limit),
+		reqs: []limitRequest{{http.MethodPost, "/v2/foo/blobs/uploads/?digest=sha256:" + sha256String(large), large, false}},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "SIZE_INVALID",
+	}, {
+		desc: "streamed monolithic blob over limit",
+		opt:  registry.BlobUploadLimit(limit),
+		reqs: []limitRequest{{http.MethodPost, "/v2/foo/blobs/uploads/?digest=sha256:" + sha256String(large), large, true}},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "SIZE_INVALID",
+	}, {
+		desc: "stream upload over limit",
+		opt:  registry.BlobUploadLimit(limit),
+		reqs: []limitRequest{{http.MethodPatch, "/v2/foo/blobs/uploads/1", large, true}},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "SIZE_INVALID",
+	}, {
+		desc: "put upload over limit",
+		opt:  registry.BlobUploadLimit(limit),
+		reqs: []limitRequest{{http.MethodPut, "/v2/foo/blobs/uploads/1?digest=sha256:" + sha256String(large), large, true}},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "SIZE_INVALID",
+	}, {
+		desc: "stream then put over limit",
+		opt:  registry.BlobUploadLimit(limit),
+		reqs: []limitRequest{
+			{http.MethodPatch, "/v2/foo/blobs/uploads/1", small, false},
+			{http.MethodPut, "/v2/foo/blobs/uploads/1?digest=sha256:" + sha256String(small+"a"), "a", false},
+		},
+		code: http.StatusRequestEntityTooLarge,
+		err:  "SIZE_INVALID",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(tc.opt))
+			defer s.Close()
+
+			loc := startUpload(t, s.Config.Handler)
+			var resp *http.Response
+			for i, r := range tc.reqs {
+				r.path = strings.Replace(r.path, "/v2/foo/blobs/uploads/1", loc, 1)
+				var body io.Reader = strings.NewReader(r.body)
+				if r.stream {
+					// Hide the length, so that it is sent chunked.
+					body = ioutil.NopCloser(body)
+				}
+				req, err := http.NewRequest(r.method, s.URL+r.path, body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.method == http.MethodPatch && !r.stream {
+					req.Header.Set("Content-Range", "0-99")
+				}
+				resp, err = s.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				if i < len(tc.reqs)-1 && resp.StatusCode >= 300 {
+					t.Fatalf("%s %s: unexpected status %d", r.method, r.path, resp.StatusCode)
+				}
+			}
+
+			if resp.StatusCode != tc.code {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tc.code)
+			}
+			if tc.err != "" {
+				b, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(b), tc.err) {
+					t.Errorf("got body %q, want %s", b, tc.err)
+				}
+			}
+		})
+	}
+}
+
+type limitRequest struct {
+	method, path, body string
+	// stream sends the body without a Content-Length.
+	stream bool
+}
+
+func TestBlobUploadLimitClientError(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.BlobUploadLimit(1024)))
+	defer s.Close()
+
+	ref, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(4096, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = remote.Write(ref, img)
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("remote.Write() = %v, want *transport.Error", err)
+	}
+	if terr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want %d", terr.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	if len(terr.Errors) != 1 || terr.Errors[0].Code != transport.SizeInvalidErrorCode {
+		t.Errorf("got errors %v, want SIZE_INVALID", terr.Errors)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestIDHeader carries the ID of each request. If a client sets it, the
+// registry uses the client's ID; otherwise one is generated.
+const requestIDHeader = "X-Request-Id"
+
+// Level is the severity of a LogEntry.
+type Level int
+
+const (
+	// LevelDebug is for details that are only useful when debugging the
+	// registry.
+	LevelDebug Level = iota
+	// LevelInfo is for routine events, e.g. access log entries and
+	// completed mirroring.
+	LevelInfo
+	// LevelWarn is for problems that the registry carries on from, e.g.
+	// requests that fail with a 4xx status, or mismatched digests.
+	LevelWarn
+	// LevelError is for requests that fail with a 5xx status.
+	LevelError
+)
+
+// String implements fmt.Stringer.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "UNKNOWN"
+}
+
+// LogEntry is a structured record of a request to the registry, or of
+// something that happened while serving one.
+type LogEntry struct {
+	Level   Level
+	Message string
+
+	RequestID string
+	Method    string
+	URL       string
+
+	// Repo is the repository the request is for, if any.
+	Repo string
+	// Reference is the tag, digest or upload ID the request is for, if any.
+	Reference string
+
+	// Status is the HTTP status of the response, if one has been written.
+	Status int
+	// Code is the registry error code (e.g. MANIFEST_UNKNOWN) of a failed
+	// request.
+	Code string
+	// Duration is how long the request took to serve. It is only set for
+	// access log entries.
+	Duration time.Duration
+}
+
+// LogHandler receives structured log entries from the registry.
+type LogHandler interface {
+	// Access is called once for every request, after it has been served.
+	Access(e LogEntry)
+
+	// Log is called for failed requests and for other events of note while
+	// serving them, at the entry's Level.
+	Log(e LogEntry)
+}
+
+// StructuredLogger sets the handler that receives the registry's access and
+// error logs. It replaces any logger set with Logger.
+func StructuredLogger(h LogHandler) Option {
+	return func(r *registry) {
+		r.setLogger(h)
+	}
+}
+
+// stdLogger adapts a *log.Logger to LogHandler, for the Logger option.
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s *stdLogger) Access(e LogEntry) {
+	// Failed requests have already been logged by Log.
+	if e.Code != "" {
+		return
+	}
+	s.l.Printf("%s %s", e.Method, e.URL)
+}
+
+func (s *stdLogger) Log(e LogEntry) {
+	if e.Code != "" {
+		s.l.Printf("%s %s %d %s %s", e.Method, e.URL, e.Status, e.Code, e.Message)
+		return
+	}
+	s.l.Print(e.Message)
+}
+
+type requestIDKey struct{}
+
+// withRequestID returns req with its request ID attached to its context, and
+// the ID. The ID is taken from the request's X-Request-Id header if present.
+func withRequestID(req *http.Request) (*http.Request, string) {
+	id := req.Header.Get(requestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	return req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id)), id
+}
+
+// newRequestID returns a random request ID. It uses crypto/rand so that IDs
+// are unpredictable, and don't repeat between registries that start at the
+// same time.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on any supported platform.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// logEntry returns a LogEntry at the given level populated with the fields
+// that can be derived from req.
+func logEntry(req *http.Request, level Level, msg string) LogEntry {
+	id, _ := req.Context().Value(requestIDKey{}).(string)
+	repo, ref := repoAndReference(req)
+	return LogEntry{
+		Level:     level,
+		Message:   msg,
+		RequestID: id,
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		Repo:      repo,
+		Reference: ref,
+	}
+}
+
+// repoAndReference returns the repository that req is for and the tag, digest
+// or upload ID it refers to. Either may be empty.
+func repoAndReference(req *http.Request) (string, string) {
+	elem := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	// The repository name is followed by e.g. /blobs/<digest> or
+	// /blobs/uploads/<id>.
+	for i := len(elem) - 2; i > 1 && i >= len(elem)-3; i-- {
+		switch elem[i] {
+		case "blobs", "manifests", "tags", "referrers":
+		default:
+			continue
+		}
+		repo := strings.Join(elem[1:i], "/")
+		last := elem[len(elem)-1]
+		if elem[i] == "tags" || (elem[i] == "blobs" && last == "uploads") {
+			return repo, ""
+		}
+		return repo, last
+	}
+	return "", ""
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+type recordingLogger struct {
+	sync.Mutex
+	access, logs []registry.LogEntry
+}
+
+func (r *recordingLogger) Access(e registry.LogEntry) {
+	r.Lock()
+	defer r.Unlock()
+	r.access = append(r.access, e)
+}
+
+func (r *recordingLogger) Log(e registry.LogEntry) {
+	r.Lock()
+	defer r.Unlock()
+	r.logs = append(r.logs, e)
+}
+
+func TestStructuredLogger(t *testing.T) {
+	rl := &recordingLogger{}
+	s := httptest.NewServer(registry.New(registry.StructuredLogger(rl)))
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodGet, s.URL+"/v2/foo/bar/manifests/latest", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-Id", "my-request")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Request-Id"); got != "my-request" {
+		t.Errorf("X-Request-Id = %q, want %q", got, "my-request")
+	}
+
+	resp, err = http.Get(s.URL + "/v2/foo/tags/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	generated := resp.Header.Get("X-Request-Id")
+	if generated == "" {
+		t.Error("X-Request-Id was not generated")
+	}
+
+	if len(rl.logs) != 2 {
+		t.Fatalf("got %d log entries, want 2: %+v", len(rl.logs), rl.logs)
+	}
+	if e := rl.logs[0]; e.Level != registry.LevelWarn || e.Code != "NAME_UNKNOWN" || e.Status != http.StatusNotFound ||
+		e.RequestID != "my-request" || e.Repo != "foo/bar" || e.Reference != "latest" {
+		t.Errorf("unexpected log entry: %+v", e)
+	}
+
+	if len(rl.access) != 2 {
+		t.Fatalf("got %d access entries, want 2: %+v", len(rl.access), rl.access)
+	}
+	if e := rl.access[0]; e.Level != registry.LevelInfo || e.Method != http.MethodGet || e.Code != "NAME_UNKNOWN" ||
+		e.Status != http.StatusNotFound || e.RequestID != "my-request" || e.Repo != "foo/bar" || e.Reference != "latest" {
+		t.Errorf("unexpected access entry: %+v", e)
+	}
+	if e := rl.access[1]; e.RequestID != generated || e.Repo != "foo" || e.Reference != "" {
+		t.Errorf("unexpected access entry: %+v", e)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(&buf, "", 0))))
+	defer s.Close()
+
+	for _, path := range []string{"/v2/", "/v2/foo/manifests/latest"} {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	want := "GET /v2/\nGET /v2/foo/manifests/latest 404 NAME_UNKNOWN Unknown name\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got logs:\n%s\nwant:\n%s", got, want)
+	}
+}
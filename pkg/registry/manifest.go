@@ -16,17 +16,17 @@ package registry
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -41,16 +41,148 @@ type listTags struct {
 	Tags []string `json:"tags"`
 }
 
-type manifest struct {
-	contentType string
-	blob        []byte
+// Manifest is a manifest stored by a ManifestHandler.
+type Manifest struct {
+	// ContentType is the media type the manifest was pushed with.
+	ContentType string
+	// Blob is the contents of the manifest.
+	Blob []byte
+}
+
+// ManifestHandler represents a manifest storage backend. Use
+// WithManifestHandler to serve manifests from it.
+//
+// Every manifest is stored under its digest and, if it was pushed by tag,
+// under the tag too. The registry doesn't call a ManifestHandler
+// concurrently.
+type ManifestHandler interface {
+	// Get returns the manifest stored under ref, a tag or digest, in repo,
+	// or ErrNotFound if there isn't one.
+	Get(ctx context.Context, repo, ref string) (Manifest, error)
+
+	// Put stores m under ref, a tag or digest, in repo, creating repo if it
+	// doesn't exist.
+	Put(ctx context.Context, repo, ref string, m Manifest) error
+
+	// Delete removes ref, a tag or digest, from repo, or returns ErrNotFound
+	// if there isn't a manifest stored under it.
+	Delete(ctx context.Context, repo, ref string) error
+
+	// References returns the tags and digests that manifests are stored
+	// under in repo, or ErrNotFound if repo doesn't exist. A repository
+	// still exists once all of its manifests have been deleted.
+	References(ctx context.Context, repo string) ([]string, error)
+
+	// Repositories returns the names of all repositories.
+	Repositories(ctx context.Context) ([]string, error)
+}
+
+// memManifests is the default, in-memory, ManifestHandler.
+//
+// Each manifest is stored once per repository, by its sha256 digest. Tags
+// (and digests with other algorithms) point at that digest, so deleting a
+// manifest by digest also deletes the tags that refer to it.
+type memManifests struct {
+	// maps repo -> manifest digest -> manifest
+	m map[string]map[string]Manifest
+	// maps repo -> tag -> manifest digest
+	tags map[string]map[string]string
+}
+
+func newMemManifests() *memManifests {
+	return &memManifests{
+		m:    map[string]map[string]Manifest{},
+		tags: map[string]map[string]string{},
+	}
+}
+
+func (mm *memManifests) Get(_ context.Context, repo, ref string) (Manifest, error) {
+	if digest, ok := mm.tags[repo][ref]; ok {
+		ref = digest
+	}
+	mf, ok := mm.m[repo][ref]
+	if !ok {
+		return Manifest{}, ErrNotFound
+	}
+	return mf, nil
+}
+
+func (mm *memManifests) Put(_ context.Context, repo, ref string, mf Manifest) error {
+	if _, ok := mm.m[repo]; !ok {
+		mm.m[repo] = map[string]Manifest{}
+		mm.tags[repo] = map[string]string{}
+	}
+	digest := digestOf(mf.Blob)
+	mm.m[repo][digest] = mf
+	if ref != digest {
+		mm.tags[repo][ref] = digest
+	}
+	return nil
+}
+
+func (mm *memManifests) Delete(_ context.Context, repo, ref string) error {
+	if _, ok := mm.tags[repo][ref]; ok {
+		delete(mm.tags[repo], ref)
+		return nil
+	}
+	if _, ok := mm.m[repo][ref]; !ok {
+		return ErrNotFound
+	}
+	delete(mm.m[repo], ref)
+	for tag, digest := range mm.tags[repo] {
+		if digest == ref {
+			delete(mm.tags[repo], tag)
+		}
+	}
+	return nil
+}
+
+func (mm *memManifests) References(_ context.Context, repo string) ([]string, error) {
+	c, ok := mm.m[repo]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	refs := make([]string, 0, len(c)+len(mm.tags[repo]))
+	for ref := range c {
+		refs = append(refs, ref)
+	}
+	for ref := range mm.tags[repo] {
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func (mm *memManifests) Repositories(_ context.Context) ([]string, error) {
+	repos := make([]string, 0, len(mm.m))
+	for repo := range mm.m {
+		repos = append(repos, repo)
+	}
+	return repos, nil
 }
 
 type manifests struct {
-	// maps repo -> manifest tag/digest -> manifest
-	manifests map[string]map[string]manifest
-	lock      sync.Mutex
-	log       *log.Logger
+	manifestHandler ManifestHandler
+	lock            sync.Mutex
+	log             LogHandler
+
+	// resolvePlatforms enables the ?platform= extension, see ResolvePlatforms.
+	resolvePlatforms bool
+
+	// sizeLimit is the maximum size of a manifest, if positive.
+	sizeLimit int64
+
+	// emptyNotFound makes listing the tags of a repository without any tags
+	// fail with NAME_UNKNOWN, see EmptyReposNotFound.
+	emptyNotFound bool
+
+	// putDelay and putHook are applied before storing pushed manifests, see
+	// WithManifestPutDelay and WithManifestPutHook.
+	putDelay time.Duration
+	putHook  func(repo, ref string)
+
+	// requireContentType rejects manifests pushed without a Content-Type,
+	// see RequireManifestContentType.
+	requireContentType bool
 }
 
 func isManifest(req *http.Request) bool {
@@ -71,6 +203,15 @@ func isTags(req *http.Request) bool {
 	return elems[len(elems)-2] == "tags"
 }
 
+func isReferrers(req *http.Request) bool {
+	elems := strings.Split(req.URL.Path, "/")
+	elems = elems[1:]
+	if len(elems) < 4 {
+		return false
+	}
+	return elems[len(elems)-2] == "referrers"
+}
+
 func isCatalog(req *http.Request) bool {
 	elems := strings.Split(req.URL.Path, "/")
 	elems = elems[1:]
@@ -81,6 +222,73 @@ func isCatalog(req *http.Request) bool {
 	return elems[len(elems)-1] == "_catalog"
 }
 
+// resolvePlatform returns the manifest that mf resolves to for the platform in
+// req's ?platform= query parameter, if the extension is enabled and mf is an
+// index. Otherwise, mf is returned as-is.
+func (m *manifests) resolvePlatform(req *http.Request, repo string, mf Manifest) (Manifest, *regError) {
+	ps := req.URL.Query().Get("platform")
+	if !m.resolvePlatforms || ps == "" {
+		return mf, nil
+	}
+	want, err := v1.ParsePlatform(ps)
+	if err != nil {
+		return Manifest{}, &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "BAD_REQUEST",
+			Message: fmt.Sprintf("Invalid platform %q: %v", ps, err),
+		}
+	}
+
+	// Nested indexes are resolved until an image is found.
+	for types.MediaType(mf.ContentType).IsIndex() {
+		im, err := v1.ParseIndexManifest(bytes.NewReader(mf.Blob))
+		if err != nil {
+			return Manifest{}, regErrInternal(err)
+		}
+		found := false
+		for _, desc := range im.Manifests {
+			if desc.Platform == nil || !platformMatches(*want, *desc.Platform) {
+				continue
+			}
+			child, err := m.manifestHandler.Get(req.Context(), repo, desc.Digest.String())
+			if errors.Is(err, ErrNotFound) {
+				return Manifest{}, &regError{
+					Status:  http.StatusNotFound,
+					Code:    "MANIFEST_UNKNOWN",
+					Message: fmt.Sprintf("Manifest %s for platform %s not found", desc.Digest, ps),
+				}
+			} else if err != nil {
+				return Manifest{}, regErrInternal(err)
+			}
+			mf, found = child, true
+			break
+		}
+		if !found {
+			return Manifest{}, &regError{
+				Status:  http.StatusNotFound,
+				Code:    "MANIFEST_UNKNOWN",
+				Message: fmt.Sprintf("No manifest for platform %s", ps),
+			}
+		}
+	}
+	return mf, nil
+}
+
+// platformMatches returns true if got satisfies want. Fields of want that are
+// empty match anything.
+func platformMatches(want, got v1.Platform) bool {
+	if want.OS != got.OS || want.Architecture != got.Architecture {
+		return false
+	}
+	if want.Variant != "" && want.Variant != got.Variant {
+		return false
+	}
+	if want.OSVersion != "" && want.OSVersion != got.OSVersion {
+		return false
+	}
+	return true
+}
+
 // https://github.com/opencontainers/distribution-spec/blob/master/spec.md#pulling-an-image-manifest
 // https://github.com/opencontainers/distribution-spec/blob/master/spec.md#pushing-an-image
 func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regError {
@@ -89,82 +297,103 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 	target := elem[len(elem)-1]
 	repo := strings.Join(elem[1:len(elem)-2], "/")
 
+	alg, rerr := parseTarget(target)
+	if rerr != nil {
+		return rerr
+	}
+
+	ctx := req.Context()
+
 	switch req.Method {
 	case http.MethodGet:
 		m.lock.Lock()
 		defer m.lock.Unlock()
 
-		c, ok := m.manifests[repo]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
+		mf, rerr := m.get(ctx, repo, alg, target)
+		if rerr != nil {
+			return rerr
 		}
-		m, ok := c[target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+		served, rerr := m.resolvePlatform(req, repo, mf)
+		if rerr != nil {
+			return rerr
+		}
+		d := digestOf(served.Blob)
+		if alg != "" {
+			d = hashOf(alg, served.Blob)
 		}
-		rd := sha256.Sum256(m.blob)
-		d := "sha256:" + hex.EncodeToString(rd[:])
 		resp.Header().Set("Docker-Content-Digest", d)
-		resp.Header().Set("Content-Type", m.contentType)
-		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
+		resp.Header().Set("Content-Type", served.ContentType)
+		resp.Header().Set("Content-Length", fmt.Sprint(len(served.Blob)))
 		resp.WriteHeader(http.StatusOK)
-		io.Copy(resp, bytes.NewReader(m.blob))
+		io.Copy(resp, bytes.NewReader(served.Blob))
 		return nil
 
 	case http.MethodHead:
 		m.lock.Lock()
 		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
+
+		mf, rerr := m.get(ctx, repo, alg, target)
+		if rerr != nil {
+			return rerr
 		}
-		m, ok := m.manifests[repo][target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+		served, rerr := m.resolvePlatform(req, repo, mf)
+		if rerr != nil {
+			return rerr
+		}
+		d := digestOf(served.Blob)
+		if alg != "" {
+			d = hashOf(alg, served.Blob)
 		}
-		rd := sha256.Sum256(m.blob)
-		d := "sha256:" + hex.EncodeToString(rd[:])
 		resp.Header().Set("Docker-Content-Digest", d)
-		resp.Header().Set("Content-Type", m.contentType)
-		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
+		resp.Header().Set("Content-Type", served.ContentType)
+		resp.Header().Set("Content-Length", fmt.Sprint(len(served.Blob)))
 		resp.WriteHeader(http.StatusOK)
 		return nil
 
 	case http.MethodPut:
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			m.manifests[repo] = map[string]manifest{}
+		if m.sizeLimit > 0 && req.ContentLength > m.sizeLimit {
+			return regErrManifestTooLarge(m.sizeLimit)
 		}
 		b := &bytes.Buffer{}
-		io.Copy(b, req.Body)
-		rd := sha256.Sum256(b.Bytes())
-		digest := "sha256:" + hex.EncodeToString(rd[:])
-		mf := manifest{
-			blob:        b.Bytes(),
-			contentType: req.Header.Get("Content-Type"),
+		if _, err := io.Copy(b, limit(req.Body, m.sizeLimit)); errors.Is(err, errSizeLimit) {
+			return regErrManifestTooLarge(m.sizeLimit)
+		} else if err != nil {
+			return regErrInternal(err)
+		}
+		// Manifests pushed by digest must match it.
+		if alg != "" && hashOf(alg, b.Bytes()) != target {
+			m.log.Log(logEntry(req, LevelWarn, fmt.Sprintf("Digest mismatch for manifest %s", target)))
+			return regErrDigestMismatch
 		}
+		digest := digestOf(b.Bytes())
+		mf := Manifest{
+			Blob:        b.Bytes(),
+			ContentType: req.Header.Get("Content-Type"),
+		}
+		if mf.ContentType == "" {
+			if m.requireContentType {
+				return regErrMissingContentType
+			}
+			// Storing no media type would break pulls, so make our best guess,
+			// or serve it as opaque bytes if we can't tell what it is.
+			mf.ContentType = string(sniffMediaType(mf.Blob))
+			if mf.ContentType == "" {
+				mf.ContentType = "application/octet-stream"
+			}
+			m.log.Log(logEntry(req, LevelWarn, fmt.Sprintf("Manifest %s pushed without Content-Type, using %q", digest, mf.ContentType)))
+		}
+
+		if rerr := m.beforePut(req, repo, target); rerr != nil {
+			return rerr
+		}
+		m.lock.Lock()
+		defer m.lock.Unlock()
 
 		// If the manifest is a manifest list, check that the manifest
 		// list's constituent manifests are already uploaded.
 		// This isn't strictly required by the registry API, but some
 		// registries require this.
-		if types.MediaType(mf.contentType).IsIndex() {
+		if types.MediaType(mf.ContentType).IsIndex() {
 			im, err := v1.ParseIndexManifest(b)
 			if err != nil {
 				return &regError{
@@ -178,24 +407,32 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 					continue
 				}
 				if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
-					if _, found := m.manifests[repo][desc.Digest.String()]; !found {
+					if _, err := m.manifestHandler.Get(ctx, repo, desc.Digest.String()); errors.Is(err, ErrNotFound) {
 						return &regError{
 							Status:  http.StatusNotFound,
 							Code:    "MANIFEST_UNKNOWN",
 							Message: fmt.Sprintf("Sub-manifest %q not found", desc.Digest),
 						}
+					} else if err != nil {
+						return regErrInternal(err)
 					}
 				} else {
 					// TODO: Probably want to do an existence check for blobs.
-					m.log.Printf("TODO: Check blobs for %q", desc.Digest)
+					m.log.Log(logEntry(req, LevelDebug, fmt.Sprintf("TODO: Check blobs for %q", desc.Digest)))
 				}
 			}
 		}
 
 		// Allow future references by target (tag) and immutable digest.
 		// See https://docs.docker.com/engine/reference/commandline/pull/#pull-an-image-by-digest-immutable-identifier.
-		m.manifests[repo][target] = mf
-		m.manifests[repo][digest] = mf
+		for _, ref := range []string{target, digest} {
+			if err := m.manifestHandler.Put(ctx, repo, ref, mf); err != nil {
+				return regErrInternal(err)
+			}
+		}
+		if alg != "" {
+			digest = target
+		}
 		resp.Header().Set("Docker-Content-Digest", digest)
 		resp.WriteHeader(http.StatusCreated)
 		return nil
@@ -203,24 +440,11 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 	case http.MethodDelete:
 		m.lock.Lock()
 		defer m.lock.Unlock()
-		if _, ok := m.manifests[repo]; !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
-		}
-
-		_, ok := m.manifests[repo][target]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "MANIFEST_UNKNOWN",
-				Message: "Unknown manifest",
-			}
+		if err := m.manifestHandler.Delete(ctx, repo, target); errors.Is(err, ErrNotFound) {
+			return m.notFound(ctx, repo)
+		} else if err != nil {
+			return regErrInternal(err)
 		}
-
-		delete(m.manifests[repo], target)
 		resp.WriteHeader(http.StatusAccepted)
 		return nil
 
@@ -233,6 +457,31 @@ func (m *manifests) handle(resp http.ResponseWriter, req *http.Request) *regErro
 	}
 }
 
+// get returns the manifest stored under target in repo. If target is a digest
+// with an algorithm other than sha256, alg, manifests that were pushed by tag
+// or sha256 digest are found by hashing them.
+func (m *manifests) get(ctx context.Context, repo, alg, target string) (Manifest, *regError) {
+	mf, err := m.manifestHandler.Get(ctx, repo, target)
+	if errors.Is(err, ErrNotFound) && alg != "" && alg != "sha256" {
+		mf, err = m.findByDigest(ctx, repo, alg, target)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return Manifest{}, m.notFound(ctx, repo)
+	} else if err != nil {
+		return Manifest{}, regErrInternal(err)
+	}
+	return mf, nil
+}
+
+// notFound returns the error for a manifest that doesn't exist in repo, which
+// depends on whether repo exists.
+func (m *manifests) notFound(ctx context.Context, repo string) *regError {
+	if _, err := m.manifestHandler.References(ctx, repo); errors.Is(err, ErrNotFound) {
+		return regErrNameUnknown
+	}
+	return regErrManifestUnknown
+}
+
 func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *regError {
 	elem := strings.Split(req.URL.Path, "/")
 	elem = elem[1:]
@@ -242,21 +491,22 @@ func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *reg
 		m.lock.Lock()
 		defer m.lock.Unlock()
 
-		c, ok := m.manifests[repo]
-		if !ok {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "NAME_UNKNOWN",
-				Message: "Unknown name",
-			}
+		refs, err := m.manifestHandler.References(req.Context(), repo)
+		if errors.Is(err, ErrNotFound) {
+			return regErrNameUnknown
+		} else if err != nil {
+			return regErrInternal(err)
 		}
 
-		var tags []string
-		for tag := range c {
-			if !strings.Contains(tag, "sha256:") {
+		tags := []string{}
+		for _, tag := range refs {
+			if !isDigest(tag) {
 				tags = append(tags, tag)
 			}
 		}
+		if len(tags) == 0 && m.emptyNotFound {
+			return regErrNameUnknown
+		}
 		sort.Strings(tags)
 
 		// https://github.com/opencontainers/distribution-spec/blob/b505e9cc53ec499edbd9c1be32298388921bb705/detail.md#tags-paginated
@@ -314,16 +564,23 @@ func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *
 		m.lock.Lock()
 		defer m.lock.Unlock()
 
-		var repos []string
-		countRepos := 0
-		// TODO: implement pagination
-		for key := range m.manifests {
-			if countRepos >= n {
-				break
+		// The prefix query parameter is an extension, supported by some
+		// registries, that only lists repositories under the prefix.
+		prefix := query.Get("prefix")
+		all, err := m.manifestHandler.Repositories(req.Context())
+		if err != nil {
+			return regErrInternal(err)
+		}
+		repos := []string{}
+		for _, key := range all {
+			if strings.HasPrefix(key, prefix) {
+				repos = append(repos, key)
 			}
-			countRepos++
-
-			repos = append(repos, key)
+		}
+		sort.Strings(repos)
+		// TODO: implement pagination
+		if len(repos) > n {
+			repos = repos[:n]
 		}
 
 		repositoriesToList := catalog{
@@ -343,3 +600,96 @@ func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *
 		Message: "We don't understand your method + url",
 	}
 }
+
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers
+func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request) *regError {
+	if req.Method != http.MethodGet {
+		return &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "METHOD_UNKNOWN",
+			Message: "We don't understand your method + url",
+		}
+	}
+
+	elem := strings.Split(req.URL.Path, "/")
+	elem = elem[1:]
+	target := elem[len(elem)-1]
+	repo := strings.Join(elem[1:len(elem)-2], "/")
+
+	if _, err := v1.NewHash(target); err != nil {
+		return &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "DIGEST_INVALID",
+			Message: "referrers target must be a digest",
+		}
+	}
+	artifactType := req.URL.Query().Get("artifactType")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	refs, err := m.manifestHandler.References(req.Context(), repo)
+	if errors.Is(err, ErrNotFound) {
+		return regErrNameUnknown
+	} else if err != nil {
+		return regErrInternal(err)
+	}
+
+	im := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{},
+	}
+	for _, key := range refs {
+		// Every manifest is stored by digest, so skip tags to avoid duplicates.
+		h, err := v1.NewHash(key)
+		if err != nil {
+			continue
+		}
+		mf, err := m.manifestHandler.Get(req.Context(), repo, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return regErrInternal(err)
+		}
+		var referrer struct {
+			ArtifactType string            `json:"artifactType,omitempty"`
+			Config       v1.Descriptor     `json:"config"`
+			Subject      *v1.Descriptor    `json:"subject,omitempty"`
+			Annotations  map[string]string `json:"annotations,omitempty"`
+		}
+		if err := json.Unmarshal(mf.Blob, &referrer); err != nil {
+			continue
+		}
+		if referrer.Subject == nil || referrer.Subject.Digest.String() != target {
+			continue
+		}
+		at := referrer.ArtifactType
+		if at == "" {
+			at = string(referrer.Config.MediaType)
+		}
+		if artifactType != "" && at != artifactType {
+			continue
+		}
+		im.Manifests = append(im.Manifests, v1.Descriptor{
+			MediaType:    types.MediaType(mf.ContentType),
+			Size:         int64(len(mf.Blob)),
+			Digest:       h,
+			ArtifactType: at,
+			Annotations:  referrer.Annotations,
+		})
+	}
+	sort.Slice(im.Manifests, func(i, j int) bool {
+		return im.Manifests[i].Digest.String() < im.Manifests[j].Digest.String()
+	})
+
+	msg, _ := json.Marshal(&im)
+	if artifactType != "" {
+		resp.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	resp.Header().Set("Content-Type", string(types.OCIImageIndex))
+	resp.Header().Set("Content-Length", fmt.Sprint(len(msg)))
+	resp.WriteHeader(http.StatusOK)
+	io.Copy(resp, bytes.NewReader(msg))
+	return nil
+}
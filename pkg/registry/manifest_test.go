@@ -0,0 +1,88 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestSharedManifestTags(t *testing.T) {
+	reg := registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)))
+	digest := "sha256:" + sha256String("foo")
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		var req *http.Request
+		if body != "" {
+			req = httptest.NewRequest(method, url, strings.NewReader(body))
+		} else {
+			req = httptest.NewRequest(method, url, nil)
+		}
+		resp := httptest.NewRecorder()
+		reg.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Push the same manifest under a few tags, and another under one more.
+	for _, tag := range []string{"a", "b", "c"} {
+		if resp := do(http.MethodPut, "/v2/foo/manifests/"+tag, "foo"); resp.Code != http.StatusCreated {
+			t.Fatalf("PUT %s: got status %d", tag, resp.Code)
+		}
+	}
+	if resp := do(http.MethodPut, "/v2/foo/manifests/d", "bar"); resp.Code != http.StatusCreated {
+		t.Fatalf("PUT d: got status %d", resp.Code)
+	}
+
+	// Deleting a tag leaves the manifest and its other tags alone.
+	if resp := do(http.MethodDelete, "/v2/foo/manifests/a", ""); resp.Code != http.StatusAccepted {
+		t.Fatalf("DELETE a: got status %d", resp.Code)
+	}
+	for ref, want := range map[string]int{
+		"a":    http.StatusNotFound,
+		"b":    http.StatusOK,
+		digest: http.StatusOK,
+	} {
+		if resp := do(http.MethodGet, "/v2/foo/manifests/"+ref, ""); resp.Code != want {
+			t.Errorf("GET %s: got status %d, want %d", ref, resp.Code, want)
+		}
+	}
+
+	// Deleting the digest deletes the tags that point at it.
+	if resp := do(http.MethodDelete, "/v2/foo/manifests/"+digest, ""); resp.Code != http.StatusAccepted {
+		t.Fatalf("DELETE %s: got status %d", digest, resp.Code)
+	}
+	for ref, want := range map[string]int{
+		"b":    http.StatusNotFound,
+		"c":    http.StatusNotFound,
+		digest: http.StatusNotFound,
+		"d":    http.StatusOK,
+	} {
+		if resp := do(http.MethodGet, "/v2/foo/manifests/"+ref, ""); resp.Code != want {
+			t.Errorf("GET %s: got status %d, want %d", ref, resp.Code, want)
+		}
+	}
+
+	resp := do(http.MethodGet, "/v2/foo/tags/list", "")
+	if got, want := resp.Body.String(), `{"name":"foo","tags":["d"]}`; strings.TrimSpace(got) != want {
+		t.Errorf("tags: got %s, want %s", got, want)
+	}
+}
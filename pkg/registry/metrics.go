@@ -0,0 +1,221 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram. These match the Prometheus client defaults.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	method string
+	code   int
+}
+
+// metrics records counters about the requests a registry has served.
+type metrics struct {
+	lock sync.Mutex
+
+	requests      map[requestKey]int64
+	latencyCounts []int64 // one per latencyBuckets entry
+	latencySum    float64
+	latencyCount  int64
+	blobsServed   int64
+	blobsReceived int64
+}
+
+func (m *metrics) observe(method string, code int, d time.Duration, received, served int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.requests == nil {
+		m.requests = map[requestKey]int64{}
+		m.latencyCounts = make([]int64, len(latencyBuckets))
+	}
+	m.requests[requestKey{method, code}]++
+
+	secs := d.Seconds()
+	for i, le := range latencyBuckets {
+		if secs <= le {
+			m.latencyCounts[i]++
+		}
+	}
+	m.latencySum += secs
+	m.latencyCount++
+
+	m.blobsReceived += received
+	m.blobsServed += served
+}
+
+// Metrics returns a handler that serves metrics about h, which must have been
+// returned by New, in the Prometheus text exposition format.
+//
+// The metrics include request counts by method and status code, request
+// latencies, blob bytes served and received, and the size of stored content.
+func Metrics(h http.Handler) (http.Handler, error) {
+	r, ok := h.(*registry)
+	if !ok {
+		return nil, errors.New("registry.Metrics: handler was not created by registry.New")
+	}
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var buf bytes.Buffer
+		r.writeMetrics(&buf)
+		resp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		resp.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+		resp.WriteHeader(http.StatusOK)
+		io.Copy(resp, &buf)
+	}), nil
+}
+
+func (r *registry) writeMetrics(w io.Writer) {
+	m := &r.metrics
+	m.lock.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	fmt.Fprintln(w, "# HELP registry_http_requests_total Total number of HTTP requests handled.")
+	fmt.Fprintln(w, "# TYPE registry_http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "registry_http_requests_total{method=%q,code=\"%d\"} %d\n", k.method, k.code, m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP registry_http_request_duration_seconds Latency of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE registry_http_request_duration_seconds histogram")
+	for i, le := range latencyBuckets {
+		var count int64
+		if m.latencyCounts != nil {
+			count = m.latencyCounts[i]
+		}
+		fmt.Fprintf(w, "registry_http_request_duration_seconds_bucket{le=\"%g\"} %d\n", le, count)
+	}
+	fmt.Fprintf(w, "registry_http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.latencyCount)
+	fmt.Fprintf(w, "registry_http_request_duration_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(w, "registry_http_request_duration_seconds_count %d\n", m.latencyCount)
+
+	fmt.Fprintln(w, "# HELP registry_blob_bytes_served_total Total number of blob bytes served.")
+	fmt.Fprintln(w, "# TYPE registry_blob_bytes_served_total counter")
+	fmt.Fprintf(w, "registry_blob_bytes_served_total %d\n", m.blobsServed)
+	fmt.Fprintln(w, "# HELP registry_blob_bytes_received_total Total number of blob bytes received.")
+	fmt.Fprintln(w, "# TYPE registry_blob_bytes_received_total counter")
+	fmt.Fprintf(w, "registry_blob_bytes_received_total %d\n", m.blobsReceived)
+	m.lock.Unlock()
+
+	// Only the in-memory manifest handler knows how much it's storing.
+	if mm, ok := r.manifests.manifestHandler.(*memManifests); ok {
+		r.manifests.lock.Lock()
+		var repos, manifests, manifestBytes, tags int64
+		for repo, c := range mm.m {
+			repos++
+			for _, mf := range c {
+				manifests++
+				manifestBytes += int64(len(mf.Blob))
+			}
+			tags += int64(len(mm.tags[repo]))
+		}
+		r.manifests.lock.Unlock()
+
+		fmt.Fprintln(w, "# HELP registry_repositories Number of repositories.")
+		fmt.Fprintln(w, "# TYPE registry_repositories gauge")
+		fmt.Fprintf(w, "registry_repositories %d\n", repos)
+		fmt.Fprintln(w, "# HELP registry_storage_manifests Number of stored manifests.")
+		fmt.Fprintln(w, "# TYPE registry_storage_manifests gauge")
+		fmt.Fprintf(w, "registry_storage_manifests %d\n", manifests)
+		fmt.Fprintln(w, "# HELP registry_storage_tags Number of tags, which share the storage of the manifests they point to.")
+		fmt.Fprintln(w, "# TYPE registry_storage_tags gauge")
+		fmt.Fprintf(w, "registry_storage_tags %d\n", tags)
+		fmt.Fprintln(w, "# HELP registry_storage_manifest_bytes Total size of stored manifests.")
+		fmt.Fprintln(w, "# TYPE registry_storage_manifest_bytes gauge")
+		fmt.Fprintf(w, "registry_storage_manifest_bytes %d\n", manifestBytes)
+	}
+
+	// Only the in-memory blob handler knows how much it's storing.
+	if mh, ok := r.blobs.blobHandler.(*memHandler); ok {
+		mh.lock.Lock()
+		var blobBytes int64
+		for _, b := range mh.m {
+			blobBytes += int64(len(b))
+		}
+		blobs := len(mh.m)
+		mh.lock.Unlock()
+
+		fmt.Fprintln(w, "# HELP registry_storage_blobs Number of stored blobs.")
+		fmt.Fprintln(w, "# TYPE registry_storage_blobs gauge")
+		fmt.Fprintf(w, "registry_storage_blobs %d\n", blobs)
+		fmt.Fprintln(w, "# HELP registry_storage_blob_bytes Total size of stored blobs.")
+		fmt.Fprintln(w, "# TYPE registry_storage_blob_bytes gauge")
+		fmt.Fprintf(w, "registry_storage_blob_bytes %d\n", blobBytes)
+	}
+}
+
+// statusRecorder records the status code and number of bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.written += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, if the underlying ResponseWriter does, so
+// that wrapping it doesn't stop handlers from streaming responses.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// countingReadCloser counts the number of bytes read.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReadCloser) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	c.n += int64(n)
+	return n, err
+}
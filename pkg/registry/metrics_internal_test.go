@@ -0,0 +1,43 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	var rw http.ResponseWriter = &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	f, ok := rw.(http.Flusher)
+	if !ok {
+		t.Fatal("statusRecorder doesn't implement http.Flusher")
+	}
+	f.Flush()
+	if !w.Flushed {
+		t.Error("Flush() wasn't forwarded")
+	}
+
+	u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+	if !ok {
+		t.Fatal("statusRecorder doesn't implement Unwrap")
+	}
+	if u.Unwrap() != w {
+		t.Error("Unwrap() didn't return the underlying ResponseWriter")
+	}
+}
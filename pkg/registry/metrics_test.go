@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestMetrics(t *testing.T) {
+	h := registry.New()
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	mh, err := registry.Metrics(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := httptest.NewServer(mh)
+	defer ms.Close()
+
+	ref, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := remote.Layer(ref.Context().Digest(d.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrc, err := rl.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(rrc); err != nil {
+		t.Fatal(err)
+	}
+	size, err := layers[0].Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(ms.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+
+	for _, want := range []string{
+		`registry_http_requests_total{method="PUT",code="201"} 4`,
+		fmt.Sprintf("registry_blob_bytes_served_total %d", size),
+		"registry_repositories 1",
+		"registry_storage_manifests 1",
+		"registry_storage_tags 1",
+		"registry_storage_blobs 3",
+		"# TYPE registry_http_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+
+	if _, err := registry.Metrics(http.NotFoundHandler()); err == nil {
+		t.Error("Metrics: expected error for foreign handler")
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// MirrorStatusPath is where a registry created WithMirror serves the status of
+// its replications, as a JSON list of Replication.
+const MirrorStatusPath = "/v2/_mirror"
+
+// MirrorState is the state of a Replication.
+type MirrorState string
+
+const (
+	// MirrorPending replications are queued or in progress.
+	MirrorPending MirrorState = "pending"
+	// MirrorSucceeded replications have been written to their target.
+	MirrorSucceeded MirrorState = "succeeded"
+	// MirrorFailed replications couldn't be written to their target, even
+	// after retrying.
+	MirrorFailed MirrorState = "failed"
+)
+
+// Replication is the status of replicating a pushed manifest to a target.
+type Replication struct {
+	Repository string      `json:"repository"`
+	Reference  string      `json:"reference"`
+	Digest     string      `json:"digest"`
+	Target     string      `json:"target"`
+	State      MirrorState `json:"state"`
+	Error      string      `json:"error,omitempty"`
+	Updated    time.Time   `json:"updated"`
+
+	ctx       context.Context
+	blobs     *blobs
+	manifests *manifests
+	target    name.Registry
+}
+
+// WithMirror makes the registry replicate every manifest that is successfully
+// pushed to it, along with the manifests and blobs it refers to, to the same
+// repository and reference on each of targets.
+//
+// Replications are written with pkg/v1/remote using opts, which can
+// authenticate with the targets, e.g. with remote.WithAuthFromKeychain, share
+// a transport between replications with remote.WithTransport, and change how
+// failed requests are retried with remote.WithRetryBackoff. Options that take
+// a channel, like remote.WithProgress, can't be used, since each write closes
+// it. Blobs are streamed from the registry's storage to the targets.
+//
+// The manifest is copied by the digest it was pushed with, and then tagged,
+// so a tag that is pushed again while its replication is pending still
+// replicates what was pushed each time. Replications are performed
+// asynchronously, one at a time, in the order that manifests were pushed,
+// so a tag is always left pointing at the most recently pushed manifest.
+// Their status is served at MirrorStatusPath, and WaitForMirror waits for
+// them to finish.
+//
+// Replications have the values of the push's request context, but since they
+// outlive the request, they aren't canceled with it. Use remote.WithContext
+// to cancel them or give them a deadline.
+func WithMirror(targets []name.Registry, opts ...remote.Option) Option {
+	return func(r *registry) {
+		r.mirror = &mirror{
+			targets: targets,
+			opts:    opts,
+			status:  map[string]*Replication{},
+			log:     r.log,
+		}
+	}
+}
+
+// WaitForMirror blocks until all of the replications queued by h, which must
+// have been returned by New with WithMirror, have succeeded or failed.
+func WaitForMirror(h http.Handler) error {
+	r, ok := h.(*registry)
+	if !ok {
+		return errors.New("registry.WaitForMirror: handler was not created by registry.New")
+	}
+	if r.mirror == nil {
+		return errors.New("registry.WaitForMirror: registry was not created WithMirror")
+	}
+	r.mirror.wg.Wait()
+	return nil
+}
+
+type mirror struct {
+	targets []name.Registry
+	opts    []remote.Option
+	log     LogHandler
+
+	lock    sync.Mutex
+	queue   []*Replication
+	running bool
+	// maps target, repository and reference -> latest replication
+	status map[string]*Replication
+	wg     sync.WaitGroup
+}
+
+// serveStatus writes the latest replication of each pushed reference to each
+// target, sorted by when they were last updated.
+func (m *mirror) serveStatus(resp http.ResponseWriter) *regError {
+	m.lock.Lock()
+	out := make([]Replication, 0, len(m.status))
+	for _, r := range m.status {
+		out = append(out, *r)
+	}
+	m.lock.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Updated.Before(out[j].Updated)
+	})
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return regErrInternal(err)
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Content-Length", fmt.Sprint(len(b)))
+	resp.WriteHeader(http.StatusOK)
+	io.Copy(resp, bytes.NewReader(b))
+	return nil
+}
+
+// pushed queues the replication of the manifest that req successfully pushed
+// to blobs and manifests, with its digest in the response header h.
+func (m *mirror) pushed(req *http.Request, h http.Header, blobs *blobs, manifests *manifests) {
+	repo, ref := repoAndReference(req)
+	digest := h.Get("Docker-Content-Digest")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, t := range m.targets {
+		r := &Replication{
+			Repository: repo,
+			Reference:  ref,
+			Digest:     digest,
+			Target:     t.Name(),
+			State:      MirrorPending,
+			Updated:    time.Now(),
+			ctx:        detached{req.Context()},
+			blobs:      blobs,
+			manifests:  manifests,
+			target:     t,
+		}
+		m.status[r.Target+"/"+repo+"@"+ref] = r
+		m.queue = append(m.queue, r)
+		m.wg.Add(1)
+	}
+	if !m.running && len(m.queue) != 0 {
+		m.running = true
+		go m.run()
+	}
+}
+
+// run replicates queued manifests until the queue is empty.
+func (m *mirror) run() {
+	for {
+		m.lock.Lock()
+		if len(m.queue) == 0 {
+			m.running = false
+			m.lock.Unlock()
+			return
+		}
+		r := m.queue[0]
+		m.queue = m.queue[1:]
+		m.lock.Unlock()
+
+		m.replicate(r)
+		m.wg.Done()
+	}
+}
+
+func (m *mirror) replicate(r *Replication) {
+	err := m.copy(r)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	r.Updated = time.Now()
+	e := LogEntry{Repo: r.Repository, Reference: r.Digest}
+	if err != nil {
+		e.Level, e.Message = LevelWarn, fmt.Sprintf("Mirroring to %s failed: %v", r.Target, err)
+		m.log.Log(e)
+		r.State = MirrorFailed
+		r.Error = err.Error()
+		return
+	}
+	e.Level, e.Message = LevelInfo, fmt.Sprintf("Mirrored to %s", r.Target)
+	m.log.Log(e)
+	r.State = MirrorSucceeded
+}
+
+// copy writes the manifest with r's digest, and everything it refers to, to
+// r's target, and then tags it if it was pushed by tag.
+func (m *mirror) copy(r *Replication) error {
+	repo, err := name.NewRepository(r.target.RegistryStr() + "/" + r.Repository)
+	if err != nil {
+		return err
+	}
+	// Keep the target's options, e.g. name.Insecure.
+	repo.Registry = r.target
+	// Options given to WithMirror come last, so they can replace the context.
+	opts := append([]remote.Option{remote.WithContext(r.ctx)}, m.opts...)
+
+	mf, err := m.manifest(r, r.Digest)
+	if err != nil {
+		return err
+	}
+	if err := m.copyManifest(r, repo, r.Digest, mf, opts); err != nil {
+		return err
+	}
+	if r.Reference == r.Digest {
+		return nil
+	}
+	return remote.Put(repo.Tag(r.Reference), storedManifest(mf), opts...)
+}
+
+// manifest reads the manifest with digest from r's source.
+func (m *mirror) manifest(r *Replication, digest string) (Manifest, error) {
+	alg, rerr := parseTarget(digest)
+	if rerr != nil {
+		return Manifest{}, fmt.Errorf("invalid digest %q: %s", digest, rerr.Message)
+	}
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+	mf, rerr := r.manifests.get(r.ctx, r.Repository, alg, digest)
+	if rerr != nil {
+		return Manifest{}, fmt.Errorf("reading manifest %s: %s", digest, rerr.Message)
+	}
+	return mf, nil
+}
+
+// copyManifest writes the blobs and child manifests of mf, and then mf, by
+// digest to repo.
+func (m *mirror) copyManifest(r *Replication, repo name.Repository, digest string, mf Manifest, opts []remote.Option) error {
+	var children, blobs []v1.Descriptor
+	switch mt := types.MediaType(mf.ContentType); {
+	case mt.IsIndex():
+		im, err := v1.ParseIndexManifest(bytes.NewReader(mf.Blob))
+		if err != nil {
+			return fmt.Errorf("parsing index %s: %w", digest, err)
+		}
+		for _, desc := range im.Manifests {
+			if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
+				children = append(children, desc)
+			} else {
+				blobs = append(blobs, desc)
+			}
+		}
+	case mt.IsImage():
+		im, err := v1.ParseManifest(bytes.NewReader(mf.Blob))
+		if err != nil {
+			return fmt.Errorf("parsing manifest %s: %w", digest, err)
+		}
+		blobs = append([]v1.Descriptor{im.Config}, im.Layers...)
+	}
+
+	for _, desc := range blobs {
+		// Foreign layers are fetched from their URLs, not registries.
+		if !desc.MediaType.IsDistributable() {
+			continue
+		}
+		l, err := partial.CompressedToLayer(&storedBlob{r: r, desc: desc})
+		if err != nil {
+			return err
+		}
+		if err := remote.WriteLayer(repo, l, opts...); err != nil {
+			return fmt.Errorf("writing blob %s: %w", desc.Digest, err)
+		}
+	}
+	for _, desc := range children {
+		child, err := m.manifest(r, desc.Digest.String())
+		if err != nil {
+			return err
+		}
+		if err := m.copyManifest(r, repo, desc.Digest.String(), child, opts); err != nil {
+			return err
+		}
+	}
+	return remote.Put(repo.Digest(digest), storedManifest(mf), opts...)
+}
+
+// storedManifest is a Manifest from the registry's storage, which remote.Put
+// writes with the media type it was pushed with.
+type storedManifest Manifest
+
+func (s storedManifest) RawManifest() ([]byte, error) {
+	return s.Blob, nil
+}
+
+func (s storedManifest) MediaType() (types.MediaType, error) {
+	return types.MediaType(s.ContentType), nil
+}
+
+// storedBlob is a blob in the registry's storage, which is streamed from it
+// when it's written to a target.
+type storedBlob struct {
+	r    *Replication
+	desc v1.Descriptor
+}
+
+func (s *storedBlob) Digest() (v1.Hash, error) {
+	return s.desc.Digest, nil
+}
+
+func (s *storedBlob) Size() (int64, error) {
+	return s.desc.Size, nil
+}
+
+func (s *storedBlob) MediaType() (types.MediaType, error) {
+	return s.desc.MediaType, nil
+}
+
+func (s *storedBlob) Compressed() (io.ReadCloser, error) {
+	return s.r.blobs.blobHandler.Get(s.r.ctx, s.r.Repository, s.desc.Digest)
+}
+
+// detached has the values of the context it wraps, but is never canceled, so
+// a replication can outlive the request that queued it.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
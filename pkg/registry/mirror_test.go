@@ -0,0 +1,237 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func mirrorServer(t *testing.T, h http.Handler) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(h)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func mirrorRef(t *testing.T, s *httptest.Server, ref string) name.Reference {
+	t.Helper()
+	r, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/" + ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func mirrorTarget(t *testing.T, s *httptest.Server) name.Registry {
+	t.Helper()
+	r, err := name.NewRegistry(strings.TrimPrefix(s.URL, "http://"), name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func quietRegistry(opts ...registry.Option) http.Handler {
+	return registry.New(append([]registry.Option{registry.Logger(log.New(ioutil.Discard, "", 0))}, opts...)...)
+}
+
+func TestMirror(t *testing.T) {
+	targets := []*httptest.Server{mirrorServer(t, quietRegistry()), mirrorServer(t, quietRegistry())}
+	h := quietRegistry(registry.WithMirror([]name.Registry{mirrorTarget(t, targets[0]), mirrorTarget(t, targets[1])}))
+	src := mirrorServer(t, h)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(mirrorRef(t, src, "foo/image:latest"), img); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(mirrorRef(t, src, "foo/index:latest"), idx); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.WaitForMirror(h); err != nil {
+		t.Fatal(err)
+	}
+
+	imgDigest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		for repo, want := range map[string]string{"foo/image": imgDigest.String(), "foo/index": idxDigest.String()} {
+			ref := mirrorRef(t, target, repo+":latest")
+			desc, err := remote.Head(ref)
+			if err != nil {
+				t.Fatalf("Head(%s): %v", ref, err)
+			}
+			if got := desc.Digest.String(); got != want {
+				t.Errorf("%s: got %s, want %s", ref, got, want)
+			}
+		}
+		if _, err := remote.Index(mirrorRef(t, target, "foo/index@"+idxDigest.String())); err != nil {
+			t.Errorf("Index(): %v", err)
+		}
+	}
+
+	// The index's children are pushed by digest, and the index by tag, so
+	// there's a replication to each target for each of them and the image.
+	resp, err := http.Get(src.URL + registry.MirrorStatusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status []registry.Replication
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(status), 2*4; got != want {
+		t.Errorf("got %d replications, want %d", got, want)
+	}
+	for _, r := range status {
+		if r.State != registry.MirrorSucceeded {
+			t.Errorf("%s %s:%s: got %s (%q), want %s", r.Target, r.Repository, r.Reference, r.State, r.Error, registry.MirrorSucceeded)
+		}
+	}
+}
+
+func TestMirrorByDigest(t *testing.T) {
+	target := mirrorServer(t, quietRegistry())
+	h := quietRegistry(registry.WithMirror([]name.Registry{mirrorTarget(t, target)}))
+	src := mirrorServer(t, h)
+
+	// Pushing a tag again before the first push is replicated still
+	// replicates the first push's manifest.
+	var digests []string
+	for i := 0; i < 2; i++ {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(mirrorRef(t, src, "foo:latest"), img); err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, d.String())
+	}
+	if err := registry.WaitForMirror(h); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range digests {
+		if _, err := remote.Image(mirrorRef(t, target, "foo@"+d)); err != nil {
+			t.Errorf("Image(%s): %v", d, err)
+		}
+	}
+	desc, err := remote.Head(mirrorRef(t, target, "foo:latest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := desc.Digest.String(), digests[1]; got != want {
+		t.Errorf("latest: got %s, want %s", got, want)
+	}
+}
+
+func TestMirrorRetry(t *testing.T) {
+	var attempts int32
+	target := mirrorServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	backoff := remote.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+	h := quietRegistry(registry.WithMirror([]name.Registry{mirrorTarget(t, target)}, remote.WithRetryBackoff(backoff)))
+	src := mirrorServer(t, h)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(mirrorRef(t, src, "foo:latest"), img); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.WaitForMirror(h); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(src.URL + registry.MirrorStatusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status []registry.Replication
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 {
+		t.Fatalf("got %d replications, want 1", len(status))
+	}
+	if r := status[0]; r.State != registry.MirrorFailed || r.Error == "" {
+		t.Errorf("got %s (%q), want %s with an error", r.State, r.Error, registry.MirrorFailed)
+	}
+	if got := atomic.LoadInt32(&attempts); got < int32(backoff.Steps) {
+		t.Errorf("target got %d requests, want at least %d", got, backoff.Steps)
+	}
+}
+
+func TestMirrorContext(t *testing.T) {
+	target := mirrorServer(t, quietRegistry())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h := quietRegistry(registry.WithMirror([]name.Registry{mirrorTarget(t, target)}, remote.WithContext(ctx)))
+	src := mirrorServer(t, h)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(mirrorRef(t, src, "foo:latest"), img); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.WaitForMirror(h); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := remote.Head(mirrorRef(t, target, "foo:latest")); err == nil {
+		t.Error("replication with a canceled context wrote to the target")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+type clientCertAuth struct {
+	roots *x509.CertPool
+	allow func(cert *x509.Certificate) error
+}
+
+// ClientCertAuth requires every request to be made over TLS with a client
+// certificate that chains to roots and is valid for client authentication.
+// If authorize is not nil, it is then called with the client's leaf
+// certificate, and the request is denied if it returns an error.
+//
+// The server's tls.Config must ask clients for certificates, e.g. by setting
+// ClientAuth to tls.RequestClientCert, as NewTLSServer does. Certificates are
+// verified by the registry, so the server need not verify them itself.
+func ClientCertAuth(roots *x509.CertPool, authorize func(cert *x509.Certificate) error) Option {
+	return func(r *registry) {
+		r.clientCertAuth = &clientCertAuth{
+			roots: roots,
+			allow: authorize,
+		}
+	}
+}
+
+func (c *clientCertAuth) authorize(req *http.Request) *regError {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return &regError{
+			Status:  http.StatusUnauthorized,
+			Code:    "UNAUTHORIZED",
+			Message: "client certificate required",
+		}
+	}
+
+	leaf := req.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range req.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return &regError{
+			Status:  http.StatusUnauthorized,
+			Code:    "UNAUTHORIZED",
+			Message: fmt.Sprintf("invalid client certificate: %v", err),
+		}
+	}
+
+	if c.allow != nil {
+		if err := c.allow(leaf); err != nil {
+			return &regError{
+				Status:  http.StatusForbidden,
+				Code:    "DENIED",
+				Message: err.Error(),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestResolvePlatforms(t *testing.T) {
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+	var adds []mutate.IndexAddendum
+	digests := map[string]v1.Hash{}
+	for _, p := range platforms {
+		p := p
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests[p.String()] = d
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &p},
+		})
+	}
+	idx := mutate.AppendManifests(empty.Index, adds...)
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		opts     []registry.Option
+		platform string
+		code     int
+		want     v1.Hash
+	}{{
+		desc:     "arm64",
+		opts:     []registry.Option{registry.ResolvePlatforms()},
+		platform: "linux/arm64",
+		code:     http.StatusOK,
+		want:     digests["linux/arm64"],
+	}, {
+		desc:     "variant",
+		opts:     []registry.Option{registry.ResolvePlatforms()},
+		platform: "linux/arm/v7",
+		code:     http.StatusOK,
+		want:     digests["linux/arm/v7"],
+	}, {
+		desc: "no platform",
+		opts: []registry.Option{registry.ResolvePlatforms()},
+		code: http.StatusOK,
+		want: idxDigest,
+	}, {
+		desc:     "unknown platform",
+		opts:     []registry.Option{registry.ResolvePlatforms()},
+		platform: "windows/amd64",
+		code:     http.StatusNotFound,
+	}, {
+		desc:     "wrong variant",
+		opts:     []registry.Option{registry.ResolvePlatforms()},
+		platform: "linux/arm/v6",
+		code:     http.StatusNotFound,
+	}, {
+		desc:     "invalid platform",
+		opts:     []registry.Option{registry.ResolvePlatforms()},
+		platform: "linux/arm/v7/extra/bits",
+		code:     http.StatusBadRequest,
+	}, {
+		desc:     "disabled",
+		platform: "linux/arm64",
+		code:     http.StatusOK,
+		want:     idxDigest,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(tc.opts...))
+			defer s.Close()
+
+			ref, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo:latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := remote.WriteIndex(ref, idx); err != nil {
+				t.Fatal(err)
+			}
+
+			u := fmt.Sprintf("%s/v2/foo/manifests/latest", s.URL)
+			if tc.platform != "" {
+				u += "?platform=" + tc.platform
+			}
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				req, err := http.NewRequest(method, u, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tc.code {
+					t.Fatalf("%s: got status %d, want %d", method, resp.StatusCode, tc.code)
+				}
+				if tc.code != http.StatusOK {
+					continue
+				}
+				if got := resp.Header.Get("Docker-Content-Digest"); got != tc.want.String() {
+					t.Errorf("%s: got digest %s, want %s", method, got, tc.want)
+				}
+			}
+		})
+	}
+}
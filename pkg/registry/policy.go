@@ -0,0 +1,52 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+)
+
+// WithRepoNamePolicy rejects requests for repositories whose names don't
+// satisfy policy, e.g. to enforce organizational naming rules when the registry
+// fronts another one. If policy returns an error for a repository, pushes to
+// and pulls from it fail with 400 NAME_INVALID and the error's message. This
+// includes mounting blobs from it into another repository.
+func WithRepoNamePolicy(policy func(repo string) error) Option {
+	return func(r *registry) {
+		r.repoNamePolicy = policy
+	}
+}
+
+// checkRepoName applies the repository name policy to the repositories that
+// req reads from or writes to.
+func (r *registry) checkRepoName(req *http.Request) *regError {
+	repos := []string{}
+	if repo, _ := repoAndReference(req); repo != "" {
+		repos = append(repos, repo)
+	}
+	if from := req.URL.Query().Get("from"); from != "" && req.URL.Query().Get("mount") != "" {
+		repos = append(repos, from)
+	}
+	for _, repo := range repos {
+		if err := r.repoNamePolicy(repo); err != nil {
+			return &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "NAME_INVALID",
+				Message: err.Error(),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestRepoNamePolicy(t *testing.T) {
+	policy := func(repo string) error {
+		if !strings.HasPrefix(repo, "team/") {
+			return errors.New("repositories must be under team/")
+		}
+		return nil
+	}
+	reg := registry.New(registry.WithRepoNamePolicy(policy), registry.Logger(log.New(ioutil.Discard, "", 0)))
+
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/v2/", http.StatusOK},
+		{http.MethodGet, "/v2/_catalog", http.StatusOK},
+		{http.MethodGet, "/v2/team/app/manifests/latest", http.StatusNotFound},
+		{http.MethodGet, "/v2/other/app/manifests/latest", http.StatusBadRequest},
+		{http.MethodGet, "/v2/other/app/tags/list", http.StatusBadRequest},
+		{http.MethodPost, "/v2/team/app/blobs/uploads/", http.StatusAccepted},
+		{http.MethodPost, "/v2/other/app/blobs/uploads/", http.StatusBadRequest},
+		{http.MethodPost, "/v2/team/app/blobs/uploads/?mount=sha256:0000000000000000000000000000000000000000000000000000000000000000&from=other/app", http.StatusBadRequest},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			reg.ServeHTTP(resp, httptest.NewRequest(tc.method, tc.path, nil))
+			if resp.Code != tc.want {
+				t.Fatalf("got status %d, want %d: %s", resp.Code, tc.want, resp.Body.String())
+			}
+			if tc.want == http.StatusBadRequest {
+				body := resp.Body.String()
+				if !strings.Contains(body, "NAME_INVALID") || !strings.Contains(body, "must be under team/") {
+					t.Errorf("got body %q, want NAME_INVALID with the policy's message", body)
+				}
+			}
+		})
+	}
+}
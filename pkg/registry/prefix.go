@@ -0,0 +1,61 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PathPrefix serves the registry under prefix, e.g. "/registry" to serve
+// "/registry/v2/...", so that it can be registered with a mux alongside other
+// handlers. Requests outside the prefix fail with 404, and upload locations
+// sent to clients include the prefix.
+func PathPrefix(prefix string) Option {
+	return func(r *registry) {
+		prefix = strings.TrimRight(prefix, "/")
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		r.prefix = prefix
+		r.blobs.prefix = prefix
+	}
+}
+
+// stripPrefix returns a shallow copy of req without the path prefix, like
+// http.StripPrefix, or false if req isn't under the prefix.
+func (r *registry) stripPrefix(req *http.Request) (*http.Request, bool) {
+	if r.prefix == "" {
+		return req, true
+	}
+	p := strings.TrimPrefix(req.URL.Path, r.prefix)
+	if len(p) == len(req.URL.Path) || !strings.HasPrefix(p, "/") {
+		return req, false
+	}
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *req.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = ""
+	return r2, true
+}
+
+var regErrPrefix = &regError{
+	Status:  http.StatusNotFound,
+	Code:    "METHOD_UNKNOWN",
+	Message: "We don't understand your method + url",
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestPathPrefix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/registry/", registry.New(registry.PathPrefix("/registry/"), registry.Logger(log.New(ioutil.Discard, "", 0))))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {})
+
+	do := func(method, url string, body io.Reader, code int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, url, body)
+		if method == http.MethodPut && strings.Contains(url, "/manifests/") {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code {
+			t.Fatalf("%s %s: got status %d, want %d: %s", method, url, resp.Code, code, resp.Body.String())
+		}
+		return resp
+	}
+
+	do(http.MethodGet, "/registry/v2/", nil, http.StatusOK)
+	do(http.MethodGet, "/healthz", nil, http.StatusOK)
+
+	resp := do(http.MethodPost, "/registry/v2/foo/bar/blobs/uploads/", nil, http.StatusAccepted)
+	loc := resp.Header().Get("Location")
+	if want := "/registry/v2/foo/bar/blobs/uploads/"; !strings.HasPrefix(loc, want) {
+		t.Fatalf("got Location %q, want prefix %q", loc, want)
+	}
+	digest := "sha256:" + sha256String("foo")
+	do(http.MethodPut, loc+"?digest="+digest, strings.NewReader("foo"), http.StatusCreated)
+	resp = do(http.MethodGet, "/registry/v2/foo/bar/blobs/"+digest, nil, http.StatusOK)
+	if got := resp.Body.String(); got != "foo" {
+		t.Errorf("got blob %q, want %q", got, "foo")
+	}
+
+	do(http.MethodPut, "/registry/v2/foo/bar/manifests/latest", strings.NewReader("{}"), http.StatusCreated)
+	resp = do(http.MethodGet, "/registry/v2/foo/bar/tags/list", nil, http.StatusOK)
+	if got, want := resp.Body.String(), `"name":"foo/bar"`; !strings.Contains(got, want) {
+		t.Errorf("got tags %s, want %s", got, want)
+	}
+	resp = do(http.MethodGet, "/registry/v2/_catalog", nil, http.StatusOK)
+	if got, want := resp.Body.String(), `["foo/bar"]`; !strings.Contains(got, want) {
+		t.Errorf("got catalog %s, want %s", got, want)
+	}
+
+	// Requests that don't have the prefix aren't served, even if they're
+	// routed to the registry.
+	reg := registry.New(registry.PathPrefix("registry"), registry.Logger(log.New(ioutil.Discard, "", 0)))
+	for _, path := range []string{"/v2/", "/registryv2/", "/v2/foo/bar/manifests/latest"} {
+		resp := httptest.NewRecorder()
+		reg.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("GET %s: got status %d, want %d", path, resp.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the registry to rps requests per second, allowing
+// bursts of up to burst requests. Requests over the limit are rejected with
+// 429 TOOMANYREQUESTS and a Retry-After header saying how many seconds to wait,
+// to test how clients handle throttling.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(r *registry) {
+		r.rateLimiter = &rateLimiter{
+			rps:    rps,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+			now:    time.Now,
+		}
+	}
+}
+
+// rateLimiter is a token bucket, which holds up to burst tokens and is refilled
+// with rps tokens per second. Each request takes a token.
+type rateLimiter struct {
+	rps   float64
+	burst float64
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// now is time.Now, except in tests.
+	now func() time.Time
+}
+
+// allow takes a token, or returns how long until there will be one.
+func (l *rateLimiter) allow() (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rps)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	if l.rps <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - l.tokens) / l.rps * float64(time.Second))
+}
+
+// limit rejects the request if it's over the limit.
+func (l *rateLimiter) limit(resp http.ResponseWriter) *regError {
+	ok, wait := l.allow()
+	if ok {
+		return nil
+	}
+	// Retry-After is in whole seconds, so round up to avoid retrying early.
+	resp.Header().Set("Retry-After", fmt.Sprint(int64(math.Ceil(wait.Seconds()))))
+	return &regError{
+		Status:  http.StatusTooManyRequests,
+		Code:    "TOOMANYREQUESTS",
+		Message: "Rate limit exceeded",
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestRateLimit(t *testing.T) {
+	reg := New(WithRateLimit(0.5, 2), Logger(log.New(ioutil.Discard, "", 0)))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		resp := httptest.NewRecorder()
+		reg.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/v2/", nil))
+		if resp.Code != want {
+			t.Fatalf("request %d: got status %d, want %d", i, resp.Code, want)
+		}
+		if want != http.StatusTooManyRequests {
+			continue
+		}
+		if got := resp.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("got Retry-After %q, want 2", got)
+		}
+		if !strings.Contains(resp.Body.String(), "TOOMANYREQUESTS") {
+			t.Errorf("got body %q, want TOOMANYREQUESTS", resp.Body.String())
+		}
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	// The registry's clock only advances when it throttles a request, by as
+	// long as it asks the client to wait, so that every retry is allowed no
+	// matter how long it really took.
+	var lock sync.Mutex
+	now := time.Unix(0, 0)
+	throttled := 0
+	reg := New(WithRateLimit(1, 4), Logger(log.New(ioutil.Discard, "", 0))).(*registry)
+	reg.rateLimiter.last = now
+	reg.rateLimiter.now = func() time.Time {
+		lock.Lock()
+		defer lock.Unlock()
+		return now
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.ServeHTTP(w, r)
+		if w.Header().Get("Retry-After") == "" {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		throttled++
+		now = now.Add(time.Second)
+	}))
+	defer s.Close()
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Don't really wait as long as the registry asks.
+	if err := remote.Write(ref, img, remote.WithJobs(1), remote.WithMaxRetryAfter(time.Millisecond)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if throttled == 0 {
+		t.Error("no requests were throttled")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// redactedHeaders are never written to recordings.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Interaction is a recorded HTTP request and its response.
+type Interaction struct {
+	Method            string      `json:"method"`
+	Path              string      `json:"path"`
+	Query             string      `json:"query,omitempty"`
+	RequestHeader     http.Header `json:"requestHeader,omitempty"`
+	RequestBodyDigest string      `json:"requestBodyDigest,omitempty"`
+
+	Status             int         `json:"status"`
+	ResponseHeader     http.Header `json:"responseHeader,omitempty"`
+	ResponseBodyDigest string      `json:"responseBodyDigest,omitempty"`
+	ResponseBody       []byte      `json:"responseBody,omitempty"`
+}
+
+func (i *Interaction) key() string {
+	return i.Method + " " + i.Path + "?" + i.Query
+}
+
+type recorder struct {
+	inner http.RoundTripper
+
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+// NewRecorder returns a transport that sends requests with inner and writes
+// each request and its response to w, as a stream of JSON Interactions that
+// can be read back with ReadInteractions.
+//
+// This is intended for capturing interactions with real registries, to be
+// replayed in tests with Replay. Credentials and cookies are not recorded.
+func NewRecorder(inner http.RoundTripper, w io.Writer) http.RoundTripper {
+	return &recorder{
+		inner: inner,
+		enc:   json.NewEncoder(w),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := Interaction{
+		Method:        req.Method,
+		Path:          req.URL.Path,
+		Query:         req.URL.RawQuery,
+		RequestHeader: redact(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		i.RequestBodyDigest = digestOf(b)
+	}
+
+	resp, err := r.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	i.Status = resp.StatusCode
+	i.ResponseHeader = redact(resp.Header)
+	if len(b) != 0 {
+		i.ResponseBody = b
+		i.ResponseBodyDigest = digestOf(b)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.enc.Encode(i); err != nil {
+		return nil, fmt.Errorf("recording %s %s: %w", req.Method, req.URL, err)
+	}
+	return resp, nil
+}
+
+// ReadInteractions reads the Interactions written by NewRecorder from r.
+func ReadInteractions(r io.Reader) ([]Interaction, error) {
+	var is []Interaction
+	dec := json.NewDecoder(r)
+	for {
+		var i Interaction
+		if err := dec.Decode(&i); errors.Is(err, io.EOF) {
+			return is, nil
+		} else if err != nil {
+			return nil, err
+		}
+		is = append(is, i)
+	}
+}
+
+type replayer struct {
+	lock sync.Mutex
+	// maps method, path and query -> responses to serve in turn
+	recorded map[string][]Interaction
+}
+
+// Replay serves the given recorded responses, e.g. from ReadInteractions, to
+// requests with a matching method, path and query, in the order they were
+// recorded. Once a request's responses are exhausted, the last is repeated.
+// Requests with no recorded response are served by the registry as usual.
+//
+// Hosts are ignored when matching, but clients are still sent wherever
+// recorded responses point them, e.g. to a token server in a Bearer
+// challenge, so recordings are best made with anonymous access.
+func Replay(is []Interaction) Option {
+	return func(r *registry) {
+		rp := &replayer{recorded: map[string][]Interaction{}}
+		for _, i := range is {
+			rp.recorded[i.key()] = append(rp.recorded[i.key()], i)
+		}
+		r.replayer = rp
+	}
+}
+
+// next returns the next recorded response for req, if there is one.
+func (rp *replayer) next(req *http.Request) (Interaction, bool) {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	key := (&Interaction{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery}).key()
+	is := rp.recorded[key]
+	if len(is) == 0 {
+		return Interaction{}, false
+	}
+	i := is[0]
+	if len(is) > 1 {
+		rp.recorded[key] = is[1:]
+	}
+	return i, true
+}
+
+// serve writes the next recorded response for req, and reports whether there
+// was one.
+func (rp *replayer) serve(resp http.ResponseWriter, req *http.Request) bool {
+	i, ok := rp.next(req)
+	if !ok {
+		return false
+	}
+	for k, vs := range i.ResponseHeader {
+		for _, v := range vs {
+			resp.Header().Add(k, v)
+		}
+	}
+	if req.Method == http.MethodHead {
+		resp.WriteHeader(i.Status)
+		return true
+	}
+	resp.Header().Set("Content-Length", strconv.Itoa(len(i.ResponseBody)))
+	resp.WriteHeader(i.Status)
+	io.Copy(resp, bytes.NewReader(i.ResponseBody))
+	return true
+}
+
+func redact(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range redactedHeaders {
+		if _, ok := h[k]; ok {
+			h[k] = []string{"REDACTED"}
+		}
+	}
+	return h
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
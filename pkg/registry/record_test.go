@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestRecordReplay(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record pushing and pulling an image.
+	var buf bytes.Buffer
+	s := httptest.NewServer(registry.New())
+	ref, err := name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := remote.WithTransport(registry.NewRecorder(http.DefaultTransport, &buf))
+	auth := remote.WithAuth(&authn.Basic{Username: "user", Password: "hunter2"})
+	if err := remote.Write(ref, img, rec, auth); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := remote.Image(ref, rec, auth)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Fatalf("validate.Image: %v", err)
+	}
+	s.Close()
+
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "dXNlcjpodW50ZXIy") {
+		t.Error("recording contains credentials")
+	}
+
+	is, err := registry.ReadInteractions(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(is) == 0 {
+		t.Fatal("nothing was recorded")
+	}
+	for _, i := range is {
+		if i.RequestHeader.Get("Authorization") != "" && i.RequestHeader.Get("Authorization") != "REDACTED" {
+			t.Errorf("%s %s: Authorization not redacted", i.Method, i.Path)
+		}
+	}
+
+	// Replay the pull against an empty registry.
+	s = httptest.NewServer(registry.New(registry.Replay(is)))
+	defer s.Close()
+	ref, err = name.NewTag(strings.TrimPrefix(s.URL, "http://") + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = remote.Image(ref)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+	if d, err := got.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != want {
+		t.Errorf("Digest() = %s, want %s", d, want)
+	}
+
+	// Anything that wasn't recorded is served as usual.
+	if _, err := remote.Image(ref.Context().Tag("other")); err == nil {
+		t.Error("Image succeeded for unrecorded tag, wanted err")
+	}
+}
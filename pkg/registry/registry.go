@@ -27,28 +27,56 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 )
 
 type registry struct {
-	log       *log.Logger
-	blobs     blobs
-	manifests manifests
+	log            LogHandler
+	blobs          blobs
+	manifests      manifests
+	metrics        metrics
+	tokenAuth      *tokenAuth
+	clientCertAuth *clientCertAuth
+	replayer       *replayer
+	rateLimiter    *rateLimiter
+	repoNamePolicy func(repo string) error
+	mirror         *mirror
+
+	// prefix is the path the registry is served under, see PathPrefix.
+	prefix string
+
+	// images to store once all options have been applied, and the error
+	// storing them, which every request fails with
+	seeds   []seed
+	seedErr error
+
+	// virtualHostStorage is set by WithVirtualHosts, and virtualHosts is
+	// created from it once all options have been applied.
+	virtualHostStorage map[string]Storage
+	virtualHosts       map[string]*virtualHost
 }
 
 // https://docs.docker.com/registry/spec/api/#api-version-check
 // https://github.com/opencontainers/distribution-spec/blob/master/spec.md#api-version-check
 func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
+	blobs, manifests := r.storageFor(req)
 	if isBlob(req) {
-		return r.blobs.handle(resp, req)
+		return blobs.handle(resp, req)
 	}
 	if isManifest(req) {
-		return r.manifests.handle(resp, req)
+		return manifests.handle(resp, req)
 	}
 	if isTags(req) {
-		return r.manifests.handleTags(resp, req)
+		return manifests.handleTags(resp, req)
 	}
 	if isCatalog(req) {
-		return r.manifests.handleCatalog(resp, req)
+		return manifests.handleCatalog(resp, req)
+	}
+	if isReferrers(req) {
+		return manifests.handleReferrers(resp, req)
+	}
+	if r.mirror != nil && req.URL.Path == MirrorStatusPath && req.Method == http.MethodGet {
+		return r.mirror.serveStatus(resp)
 	}
 	resp.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
 	if req.URL.Path != "/v2/" && req.URL.Path != "/v2" {
@@ -62,33 +90,105 @@ func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
 	return nil
 }
 
-func (r *registry) root(resp http.ResponseWriter, req *http.Request) {
-	if rerr := r.v2(resp, req); rerr != nil {
-		r.log.Printf("%s %s %d %s %s", req.Method, req.URL, rerr.Status, rerr.Code, rerr.Message)
+// ServeHTTP implements http.Handler.
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	req, inPrefix := r.stripPrefix(req)
+	req, id := withRequestID(req)
+	w.Header().Set(requestIDHeader, id)
+	resp := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	blob := isBlob(req)
+	var body *countingReadCloser
+	if blob && req.Body != nil {
+		body = &countingReadCloser{ReadCloser: req.Body}
+		req.Body = body
+	}
+
+	var rerr *regError
+	defer func() {
+		var received, served int64
+		if blob {
+			if body != nil {
+				received = body.n
+			}
+			if req.Method == http.MethodGet && resp.status == http.StatusOK {
+				served = resp.written
+			}
+		}
+		duration := time.Since(start)
+		r.metrics.observe(req.Method, resp.status, duration, received, served)
+
+		e := logEntry(req, LevelInfo, "")
+		e.Status = resp.status
+		e.Duration = duration
+		if rerr != nil {
+			e.Code = rerr.Code
+			e.Message = rerr.Message
+		}
+		r.log.Access(e)
+	}()
+
+	if !inPrefix {
+		rerr = regErrPrefix
+	}
+	if rerr == nil && r.seedErr != nil {
+		rerr = regErrInternal(r.seedErr)
+	}
+	if rerr == nil && r.rateLimiter != nil {
+		rerr = r.rateLimiter.limit(resp)
+	}
+	if rerr == nil && r.clientCertAuth != nil {
+		rerr = r.clientCertAuth.authorize(req)
+	}
+	if rerr == nil && r.tokenAuth != nil {
+		rerr = r.tokenAuth.authorize(resp, req)
+	}
+	if rerr == nil && r.repoNamePolicy != nil {
+		rerr = r.checkRepoName(req)
+	}
+	if rerr == nil {
+		if r.replayer != nil && r.replayer.serve(resp, req) {
+			return
+		}
+		rerr = r.v2(resp, req)
+		if rerr == nil && r.mirror != nil && req.Method == http.MethodPut && isManifest(req) && resp.status == http.StatusCreated {
+			blobs, manifests := r.storageFor(req)
+			r.mirror.pushed(req, resp.Header(), blobs, manifests)
+		}
+	}
+	if rerr != nil {
+		level := LevelWarn
+		if rerr.Status >= http.StatusInternalServerError {
+			level = LevelError
+		}
+		e := logEntry(req, level, rerr.Message)
+		e.Status = rerr.Status
+		e.Code = rerr.Code
+		r.log.Log(e)
 		rerr.Write(resp)
-		return
 	}
-	r.log.Printf("%s %s", req.Method, req.URL)
 }
 
 // New returns a handler which implements the docker registry protocol.
-// It should be registered at the site root.
+// It should be registered at the site root, or under the prefix given to
+// PathPrefix.
 func New(opts ...Option) http.Handler {
 	r := &registry{
-		log: log.New(os.Stderr, "", log.LstdFlags),
 		blobs: blobs{
 			blobHandler: &memHandler{m: map[string][]byte{}},
 			uploads:     map[string][]byte{},
 		},
 		manifests: manifests{
-			manifests: map[string]map[string]manifest{},
-			log:       log.New(os.Stderr, "", log.LstdFlags),
+			manifestHandler: newMemManifests(),
 		},
 	}
+	r.setLogger(&stdLogger{log.New(os.Stderr, "", log.LstdFlags)})
 	for _, o := range opts {
 		o(r)
 	}
-	return http.HandlerFunc(r.root)
+	r.setupVirtualHosts()
+	r.seedErr = r.seedAll()
+	return r
 }
 
 // Option describes the available options
@@ -98,7 +198,69 @@ type Option func(r *registry)
 // Logger overrides the logger used to record requests to the registry.
 func Logger(l *log.Logger) Option {
 	return func(r *registry) {
-		r.log = l
-		r.manifests.log = l
+		r.setLogger(&stdLogger{l})
+	}
+}
+
+func (r *registry) setLogger(h LogHandler) {
+	r.log = h
+	r.blobs.log = h
+	r.manifests.log = h
+	if r.mirror != nil {
+		r.mirror.log = h
+	}
+}
+
+// WithBlobHandler stores blobs with h instead of in memory, e.g. to serve them
+// from object storage. See BlobHandler for the interfaces h may implement.
+func WithBlobHandler(h BlobHandler) Option {
+	return func(r *registry) {
+		r.blobs.blobHandler = h
+	}
+}
+
+// WithManifestHandler stores manifests with h instead of in memory, e.g. to
+// keep them in a database.
+func WithManifestHandler(h ManifestHandler) Option {
+	return func(r *registry) {
+		r.manifests.manifestHandler = h
+	}
+}
+
+// ResolvePlatforms enables an extension, supported by some registries, where
+// GET and HEAD requests for an index with a ?platform=os/arch[/variant] query
+// parameter are served the manifest for the matching platform instead.
+func ResolvePlatforms() Option {
+	return func(r *registry) {
+		r.manifests.resolvePlatforms = true
+	}
+}
+
+// EmptyReposNotFound makes listing the tags of a repository that exists but
+// has no tags, e.g. because its manifests were only pushed by digest or have
+// all been deleted, fail with 404 NAME_UNKNOWN, as it would for a repository
+// that doesn't exist. By default, an empty list of tags is returned.
+func EmptyReposNotFound() Option {
+	return func(r *registry) {
+		r.manifests.emptyNotFound = true
+	}
+}
+
+// ChunkMinLength advertises, in the OCI-Chunk-Min-Length header of responses
+// starting an upload, that chunks should be at least n bytes, to test that
+// clients uploading in chunks respect it. Smaller chunks are still accepted.
+func ChunkMinLength(n int64) Option {
+	return func(r *registry) {
+		r.blobs.chunkMinLength = n
+	}
+}
+
+// LenientUploads disables checking that the bodies of blob uploads match their
+// Content-Length, and stores truncated chunks rather than rejecting them with
+// SIZE_INVALID. This is useful for reproducing the behavior of registries
+// that don't check, e.g. when testing how clients handle corrupt uploads.
+func LenientUploads() Option {
+	return func(r *registry) {
+		r.blobs.lenient = true
 	}
 }
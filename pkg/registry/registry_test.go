@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sort"
 	"strings"
 	"testing"
 
@@ -46,6 +47,12 @@ const (
 }`
 )
 
+const (
+	subjectDigest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	sbomManifest  = `{"schemaVersion":2,"artifactType":"application/spdx+json","config":{"mediaType":"application/vnd.oci.empty.v1+json","size":2,"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"layers":[],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":3,"digest":"` + subjectDigest + `"}}`
+	sigManifest   = `{"schemaVersion":2,"config":{"mediaType":"application/vnd.dev.cosign.simplesigning.v1+json","size":2,"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"layers":[],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":3,"digest":"` + subjectDigest + `"}}`
+)
+
 func sha256String(s string) string {
 	h := sha256.Sum256([]byte(s))
 	return hex.EncodeToString(h[:])
@@ -120,6 +127,45 @@ func TestCalls(t *testing.T) {
 			URL:         "/v2/foo/blobs/sha256:asd",
 			Code:        http.StatusBadRequest,
 		},
+		{
+			Description: "GET referrers",
+			Manifests:   map[string]string{"foo/manifests/sbom": sbomManifest, "foo/manifests/sig": sigManifest},
+			Method:      "GET",
+			URL:         "/v2/foo/referrers/" + subjectDigest,
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Content-Type": "application/vnd.oci.image.index.v1+json"},
+			Want:        referrersIndex(sbomManifest, "application/spdx+json", sigManifest, "application/vnd.dev.cosign.simplesigning.v1+json"),
+		},
+		{
+			Description: "GET referrers filtered by artifactType",
+			Manifests:   map[string]string{"foo/manifests/sbom": sbomManifest, "foo/manifests/sig": sigManifest},
+			Method:      "GET",
+			URL:         "/v2/foo/referrers/" + subjectDigest + "?artifactType=application/spdx%2Bjson",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"OCI-Filters-Applied": "artifactType"},
+			Want:        referrersIndex(sbomManifest, "application/spdx+json"),
+		},
+		{
+			Description: "GET referrers no matches",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/referrers/" + subjectDigest,
+			Code:        http.StatusOK,
+			Want:        `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`,
+		},
+		{
+			Description: "GET referrers by tag",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/referrers/latest",
+			Code:        http.StatusBadRequest,
+		},
+		{
+			Description: "GET referrers unknown repo",
+			Method:      "GET",
+			URL:         "/v2/foo/referrers/" + subjectDigest,
+			Code:        http.StatusNotFound,
+		},
 		{
 			Description: "GET containerless blob",
 			Digests:     map[string]string{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae": "foo"},
@@ -138,6 +184,45 @@ func TestCalls(t *testing.T) {
 			Header:      map[string]string{"Docker-Content-Digest": "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
 			Want:        "foo",
 		},
+		{
+			Description:   "GET blob range",
+			Digests:       map[string]string{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae": "foo"},
+			Method:        "GET",
+			URL:           "/v2/foo/blobs/sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			RequestHeader: map[string]string{"Range": "bytes=1-1"},
+			Code:          http.StatusPartialContent,
+			Header:        map[string]string{"Content-Range": "bytes 1-1/3"},
+			Want:          "o",
+		},
+		{
+			Description:   "GET blob open-ended range",
+			Digests:       map[string]string{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae": "foo"},
+			Method:        "GET",
+			URL:           "/v2/foo/blobs/sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			RequestHeader: map[string]string{"Range": "bytes=1-"},
+			Code:          http.StatusPartialContent,
+			Header:        map[string]string{"Content-Range": "bytes 1-2/3"},
+			Want:          "oo",
+		},
+		{
+			Description:   "GET blob suffix range",
+			Digests:       map[string]string{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae": "foo"},
+			Method:        "GET",
+			URL:           "/v2/foo/blobs/sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			RequestHeader: map[string]string{"Range": "bytes=-2"},
+			Code:          http.StatusPartialContent,
+			Header:        map[string]string{"Content-Range": "bytes 1-2/3"},
+			Want:          "oo",
+		},
+		{
+			Description:   "GET blob range past end",
+			Digests:       map[string]string{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae": "foo"},
+			Method:        "GET",
+			URL:           "/v2/foo/blobs/sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			RequestHeader: map[string]string{"Range": "bytes=3-5"},
+			Code:          http.StatusRequestedRangeNotSatisfiable,
+			Header:        map[string]string{"Content-Range": "bytes */3"},
+		},
 		{
 			Description: "HEAD blob",
 			Digests:     map[string]string{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae": "foo"},
@@ -216,20 +301,32 @@ func TestCalls(t *testing.T) {
 			Description: "stream upload",
 			Method:      "PATCH",
 			URL:         "/v2/foo/blobs/uploads/1",
-			Code:        http.StatusNoContent,
+			Code:        http.StatusAccepted,
 			Body:        "foo",
+			BlobStream:  map[string]string{"1": ""},
 			Header: map[string]string{
 				"Range":    "0-2",
 				"Location": "/v2/foo/blobs/uploads/1",
 			},
 		},
 		{
-			Description: "stream duplicate upload",
+			Description: "stream subsequent upload",
 			Method:      "PATCH",
 			URL:         "/v2/foo/blobs/uploads/1",
-			Code:        http.StatusBadRequest,
+			Code:        http.StatusAccepted,
 			Body:        "foo",
 			BlobStream:  map[string]string{"1": "foo"},
+			Header: map[string]string{
+				"Range":    "0-5",
+				"Location": "/v2/foo/blobs/uploads/1",
+			},
+		},
+		{
+			Description: "stream unknown upload",
+			Method:      "PATCH",
+			URL:         "/v2/foo/blobs/uploads/bogus",
+			Code:        http.StatusNotFound,
+			Body:        "foo",
 		},
 		{
 			Description: "stream finish upload",
@@ -265,6 +362,64 @@ func TestCalls(t *testing.T) {
 			URL:         "/v2/foo/manifests/bar",
 			Code:        http.StatusNotFound,
 		},
+		{
+			Description: "get manifest by sha512 digest",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/manifests/sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7",
+			Code:        http.StatusOK,
+			Header:      map[string]string{"Docker-Content-Digest": "sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7"},
+			Want:        "foo",
+		},
+		{
+			Description: "get manifest by unsupported digest algorithm",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/manifests/md5:acbd18db4cc2f85cedef654fccc4a4d8",
+			Code:        http.StatusBadRequest,
+		},
+		{
+			Description: "get manifest by malformed digest",
+			Method:      "GET",
+			URL:         "/v2/foo/manifests/sha256:latest",
+			Code:        http.StatusBadRequest,
+		},
+		{
+			Description: "get manifest by invalid tag",
+			Method:      "GET",
+			URL:         "/v2/foo/manifests/.latest",
+			Code:        http.StatusBadRequest,
+		},
+		{
+			Description: "put manifest by sha512 digest",
+			Method:      "PUT",
+			URL:         "/v2/foo/manifests/sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7",
+			Code:        http.StatusCreated,
+			Header:      map[string]string{"Docker-Content-Digest": "sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7"},
+			Body:        "foo",
+		},
+		{
+			Description: "put manifest by mismatched digest",
+			Method:      "PUT",
+			URL:         "/v2/foo/manifests/sha256:" + sha256String("bar"),
+			Code:        http.StatusBadRequest,
+			Body:        "foo",
+		},
+		{
+			Description: "put manifest with a tag that looks like a digest",
+			Method:      "PUT",
+			URL:         "/v2/foo/manifests/sha256",
+			Code:        http.StatusCreated,
+			Body:        "foo",
+		},
+		{
+			Description: "list tags excludes digests of any algorithm",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo", "foo/manifests/sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7": "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/tags/list",
+			Code:        http.StatusOK,
+			Want:        `{"name":"foo","tags":["latest"]}`,
+		},
 		{
 			Description: "get manifest by tag",
 			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
@@ -336,8 +491,9 @@ func TestCalls(t *testing.T) {
 			Description:   "Chunk upload start",
 			Method:        "PATCH",
 			URL:           "/v2/foo/blobs/uploads/1",
+			BlobStream:    map[string]string{"1": ""},
 			RequestHeader: map[string]string{"Content-Range": "0-3"},
-			Code:          http.StatusNoContent,
+			Code:          http.StatusAccepted,
 			Body:          "foo",
 			Header: map[string]string{
 				"Range":    "0-2",
@@ -348,6 +504,7 @@ func TestCalls(t *testing.T) {
 			Description:   "Chunk upload bad content range",
 			Method:        "PATCH",
 			URL:           "/v2/foo/blobs/uploads/1",
+			BlobStream:    map[string]string{"1": ""},
 			RequestHeader: map[string]string{"Content-Range": "0-bar"},
 			Code:          http.StatusRequestedRangeNotSatisfiable,
 			Body:          "foo",
@@ -360,6 +517,10 @@ func TestCalls(t *testing.T) {
 			RequestHeader: map[string]string{"Content-Range": "2-5"},
 			Code:          http.StatusRequestedRangeNotSatisfiable,
 			Body:          "bar",
+			Header: map[string]string{
+				"Range":    "0-2",
+				"Location": "/v2/foo/blobs/uploads/1",
+			},
 		},
 		{
 			Description:   "Chunk upload after previous data",
@@ -367,7 +528,7 @@ func TestCalls(t *testing.T) {
 			URL:           "/v2/foo/blobs/uploads/1",
 			BlobStream:    map[string]string{"1": "foo"},
 			RequestHeader: map[string]string{"Content-Range": "3-6"},
-			Code:          http.StatusNoContent,
+			Code:          http.StatusAccepted,
 			Body:          "bar",
 			Header: map[string]string{
 				"Range":    "0-5",
@@ -437,6 +598,31 @@ func TestCalls(t *testing.T) {
 			Method:      "GET",
 			URL:         "/v2/_catalog?n=1000",
 			Code:        http.StatusOK,
+			Want:        `{"repositories":["bar","foo"]}`,
+		},
+		{
+			Description: "list repos with prefix",
+			Manifests:   map[string]string{"team/foo/manifests/latest": "foo", "team/bar/manifests/latest": "bar", "other/manifests/latest": "other"},
+			Method:      "GET",
+			URL:         "/v2/_catalog?prefix=team/",
+			Code:        http.StatusOK,
+			Want:        `{"repositories":["team/bar","team/foo"]}`,
+		},
+		{
+			Description: "list repos with unmatched prefix",
+			Manifests:   map[string]string{"foo/manifests/latest": "foo"},
+			Method:      "GET",
+			URL:         "/v2/_catalog?prefix=bar",
+			Code:        http.StatusOK,
+			Want:        `{"repositories":[]}`,
+		},
+		{
+			Description: "list tags of untagged repo",
+			Manifests:   map[string]string{"foo/manifests/sha256:" + sha256String("foo"): "foo"},
+			Method:      "GET",
+			URL:         "/v2/foo/tags/list",
+			Code:        http.StatusOK,
+			Want:        `{"name":"foo","tags":[]}`,
 		},
 	}
 
@@ -495,10 +681,24 @@ func TestCalls(t *testing.T) {
 				}
 			}
 
+			// Uploads get IDs from the registry, so start one for each
+			// BlobStream and point the test case's URLs at it.
+			uploads := map[string]string{}
 			for upload, contents := range tc.BlobStream {
-				u, err := url.Parse(fmt.Sprintf("%s/v2/foo/blobs/uploads/%s", s.URL, upload))
+				resp, err := s.Client().Post(s.URL+"/v2/foo/blobs/uploads/", "", nil)
 				if err != nil {
-					t.Fatalf("Error parsing %q: %v", s.URL+tc.URL, err)
+					t.Fatalf("Error starting upload: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusAccepted {
+					t.Fatalf("Error starting upload: %d", resp.StatusCode)
+				}
+				loc := resp.Header.Get("Location")
+				uploads["/v2/foo/blobs/uploads/"+upload] = loc
+
+				u, err := url.Parse(s.URL + loc)
+				if err != nil {
+					t.Fatalf("Error parsing %q: %v", s.URL+loc, err)
 				}
 				req := &http.Request{
 					Method: "PATCH",
@@ -506,18 +706,24 @@ func TestCalls(t *testing.T) {
 					Body:   ioutil.NopCloser(strings.NewReader(contents)),
 				}
 				t.Log(req.Method, req.URL)
-				resp, err := s.Client().Do(req)
+				resp, err = s.Client().Do(req)
 				if err != nil {
 					t.Fatalf("Error streaming blob: %v", err)
 				}
-				if resp.StatusCode != http.StatusNoContent {
+				if resp.StatusCode != http.StatusAccepted {
 					body, _ := ioutil.ReadAll(resp.Body)
 					t.Fatalf("Error streaming blob: %d %s", resp.StatusCode, body)
 				}
 
 			}
+			withUploads := func(s string) string {
+				for from, to := range uploads {
+					s = strings.Replace(s, from, to, 1)
+				}
+				return s
+			}
 
-			u, err := url.Parse(s.URL + tc.URL)
+			u, err := url.Parse(s.URL + withUploads(tc.URL))
 			if err != nil {
 				t.Fatalf("Error parsing %q: %v", s.URL+tc.URL, err)
 			}
@@ -545,6 +751,7 @@ func TestCalls(t *testing.T) {
 			}
 
 			for k, v := range tc.Header {
+				v = withUploads(v)
 				r := resp.Header.Get(k)
 				if r != v {
 					t.Errorf("Incorrect header %q received, got %q, want %q", k, r, v)
@@ -560,3 +767,23 @@ func TestCalls(t *testing.T) {
 		t.Run(tc.Description+" - custom log", testf)
 	}
 }
+
+// referrersIndex returns the expected referrers response for pairs of
+// manifests and artifact types, sorted by digest. The manifests are pushed
+// without a Content-Type, so their media type is sniffed.
+func referrersIndex(pairs ...string) string {
+	type desc struct {
+		digest, entry string
+	}
+	descs := []desc{}
+	for i := 0; i < len(pairs); i += 2 {
+		d := "sha256:" + sha256String(pairs[i])
+		descs = append(descs, desc{d, fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":%d,"digest":"%s","artifactType":"%s"}`, len(pairs[i]), d, pairs[i+1])})
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].digest < descs[j].digest })
+	entries := []string{}
+	for _, d := range descs {
+		entries = append(entries, d.entry)
+	}
+	return `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` + strings.Join(entries, ",") + "]}"
+}
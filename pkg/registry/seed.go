@@ -0,0 +1,213 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WithSeedImages preloads the registry with images, keyed by references of
+// the form repo[:tag] or repo@digest, e.g. "foo/bar:v1". References without a
+// tag or digest are tagged "latest".
+//
+// The images are stored after all other options have been applied, so they
+// are stored with any blob and manifest handlers that are configured. If
+// they can't be stored, every request fails with the error; use Seed to
+// handle it instead.
+func WithSeedImages(imgs map[string]v1.Image) Option {
+	return func(r *registry) {
+		for ref, img := range imgs {
+			r.seeds = append(r.seeds, seed{ref: ref, img: img})
+		}
+	}
+}
+
+type seed struct {
+	ref string
+	img v1.Image
+}
+
+// seedAll stores the images from WithSeedImages, in order of their
+// references.
+func (r *registry) seedAll() error {
+	sort.Slice(r.seeds, func(i, j int) bool { return r.seeds[i].ref < r.seeds[j].ref })
+	for _, s := range r.seeds {
+		if err := r.seedImage(context.Background(), s.ref, s.img); err != nil {
+			return err
+		}
+	}
+	r.seeds = nil
+	return nil
+}
+
+// Seed stores img in h, which must have been returned by New, under ref,
+// without going through HTTP. See WithSeedImages for the form of ref.
+func Seed(h http.Handler, ref string, img v1.Image) error {
+	r, ok := h.(*registry)
+	if !ok {
+		return errors.New("registry.Seed: handler was not created by registry.New")
+	}
+	return r.seedImage(context.Background(), ref, img)
+}
+
+// SeedIndex stores idx, and all of the images and indexes it refers to, in h,
+// which must have been returned by New, under ref, without going through
+// HTTP. See WithSeedImages for the form of ref.
+func SeedIndex(h http.Handler, ref string, idx v1.ImageIndex) error {
+	r, ok := h.(*registry)
+	if !ok {
+		return errors.New("registry.SeedIndex: handler was not created by registry.New")
+	}
+	return r.seedIndex(context.Background(), ref, idx)
+}
+
+// parseSeedRef splits ref into a repository and a tag or digest.
+func parseSeedRef(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i != -1 {
+		return ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+func (r *registry) seedImage(ctx context.Context, ref string, img v1.Image) error {
+	repo, target := parseSeedRef(ref)
+	bph, ok := r.blobs.blobHandler.(BlobPutHandler)
+	if !ok {
+		return fmt.Errorf("seeding %s: blob handler does not support writes", ref)
+	}
+
+	ls, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		mt, err := l.MediaType()
+		if err != nil {
+			return err
+		}
+		if !mt.IsDistributable() {
+			continue
+		}
+		h, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			return err
+		}
+		if err := bph.Put(ctx, repo, h, rc); err != nil {
+			return fmt.Errorf("seeding %s: layer %s: %w", ref, h, err)
+		}
+	}
+
+	h, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := bph.Put(ctx, repo, h, ioutil.NopCloser(bytes.NewReader(cfg))); err != nil {
+		return fmt.Errorf("seeding %s: config %s: %w", ref, h, err)
+	}
+
+	return r.seedManifest(ctx, ref, repo, target, img)
+}
+
+func (r *registry) seedIndex(ctx context.Context, ref string, idx v1.ImageIndex) error {
+	repo, target := parseSeedRef(ref)
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range im.Manifests {
+		child := repo + "@" + desc.Digest.String()
+		switch {
+		case desc.MediaType.IsIndex():
+			ii, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := r.seedIndex(ctx, child, ii); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := r.seedImage(ctx, child, img); err != nil {
+				return err
+			}
+		}
+	}
+	return r.seedManifest(ctx, ref, repo, target, idx)
+}
+
+// manifester is implemented by both v1.Image and v1.ImageIndex.
+type manifester interface {
+	RawManifest() ([]byte, error)
+	MediaType() (types.MediaType, error)
+}
+
+// seedManifest stores t's manifest under target and its digest in repo.
+func (r *registry) seedManifest(ctx context.Context, ref, repo, target string, t manifester) error {
+	raw, err := t.RawManifest()
+	if err != nil {
+		return err
+	}
+	mt, err := t.MediaType()
+	if err != nil {
+		return err
+	}
+	alg, rerr := parseTarget(target)
+	if rerr != nil {
+		return fmt.Errorf("seeding %s: %s", ref, rerr.Message)
+	}
+	if alg != "" {
+		if got := hashOf(alg, raw); got != target {
+			return fmt.Errorf("seeding %s: manifest digest is %s", ref, got)
+		}
+	}
+	digest := hashOf("sha256", raw)
+
+	mf := Manifest{
+		ContentType: string(mt),
+		Blob:        raw,
+	}
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+	for _, ref := range []string{target, digest} {
+		if err := r.manifests.manifestHandler.Put(ctx, repo, ref, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
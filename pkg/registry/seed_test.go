@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestSeed(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New(registry.WithSeedImages(map[string]v1.Image{
+		"foo/image":               img,
+		"foo/image:v1":            img,
+		"bar/image@" + d.String(): img,
+	}))
+	if err := registry.SeedIndex(reg, "foo/index:v1", idx); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Seed(reg, "foo/wrong@sha256:"+strings.Repeat("0", 64), img); err == nil {
+		t.Error("Seed() with the wrong digest should fail")
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha512.Sum512(raw)
+	d512 := "sha512:" + hex.EncodeToString(sum[:])
+	if err := registry.Seed(reg, "baz/image@"+d512, img); err != nil {
+		t.Errorf("Seed() with a sha512 digest: %v", err)
+	}
+
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	u := strings.TrimPrefix(s.URL, "http://")
+
+	for _, ref := range []string{"foo/image:latest", "foo/image:v1", "bar/image@" + d.String()} {
+		ref, err := name.ParseReference(u + "/" + ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := remote.Image(ref)
+		if err != nil {
+			t.Fatalf("Image(%s): %v", ref, err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image(%s) = %v", ref, err)
+		}
+	}
+
+	// name can't parse sha512 digests.
+	resp, err := http.Get(s.URL + "/v2/baz/image/manifests/" + d512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET baz/image@%s = %d, want %d", d512, resp.StatusCode, http.StatusOK)
+	}
+
+	ref, err := name.ParseReference(u + "/foo/index:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := remote.Index(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+}
+
+func TestSeedImagesError(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rather than panicking, the registry fails every request.
+	reg := registry.New(registry.WithSeedImages(map[string]v1.Image{
+		"foo/wrong@sha256:" + strings.Repeat("0", 64): img,
+	}))
+	s := httptest.NewServer(reg)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusInternalServerError; got != want {
+		t.Errorf("GET /v2/ = %d, want %d", got, want)
+	}
+}
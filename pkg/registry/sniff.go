@@ -0,0 +1,73 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// RequireManifestContentType rejects manifests pushed without a Content-Type
+// header with MANIFEST_INVALID. By default, their media type is sniffed from
+// their contents instead, and those that can't be sniffed are served as
+// application/octet-stream.
+func RequireManifestContentType() Option {
+	return func(r *registry) {
+		r.manifests.requireContentType = true
+	}
+}
+
+// regErrMissingContentType is returned for manifests pushed without a
+// Content-Type with RequireManifestContentType.
+var regErrMissingContentType = &regError{
+	Status:  http.StatusBadRequest,
+	Code:    "MANIFEST_INVALID",
+	Message: "manifest pushed without a Content-Type header",
+}
+
+// sniffMediaType guesses the media type of a manifest pushed without a
+// Content-Type, from its mediaType field or, failing that, its structure. It
+// returns "" if b doesn't look like a manifest at all.
+func sniffMediaType(b []byte) types.MediaType {
+	var m struct {
+		SchemaVersion int               `json:"schemaVersion"`
+		MediaType     types.MediaType   `json:"mediaType"`
+		Manifests     json.RawMessage   `json:"manifests"`
+		Config        json.RawMessage   `json:"config"`
+		FSLayers      json.RawMessage   `json:"fsLayers"`
+		Signatures    []json.RawMessage `json:"signatures"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return ""
+	}
+	switch {
+	case m.MediaType != "":
+		return m.MediaType
+	case m.SchemaVersion == 1 && m.FSLayers != nil:
+		if len(m.Signatures) != 0 {
+			return types.DockerManifestSchema1Signed
+		}
+		return types.DockerManifestSchema1
+	case m.SchemaVersion != 2:
+		return ""
+	case m.Manifests != nil:
+		return types.OCIImageIndex
+	case m.Config != nil:
+		return types.OCIManifestSchema1
+	}
+	return ""
+}
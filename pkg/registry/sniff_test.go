@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestSniffManifestContentType(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		body string
+		want types.MediaType
+	}{{
+		desc: "mediaType field",
+		body: `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{},"layers":[]}`,
+		want: types.DockerManifestSchema2,
+	}, {
+		desc: "oci manifest",
+		body: `{"schemaVersion":2,"config":{},"layers":[]}`,
+		want: types.OCIManifestSchema1,
+	}, {
+		desc: "oci index",
+		body: `{"schemaVersion":2,"manifests":[]}`,
+		want: types.OCIImageIndex,
+	}, {
+		desc: "schema 1",
+		body: `{"schemaVersion":1,"fsLayers":[],"signatures":[{}]}`,
+		want: types.DockerManifestSchema1Signed,
+	}, {
+		desc: "not a manifest",
+		body: `{"hello":"world"}`,
+		want: "application/octet-stream",
+	}, {
+		desc: "not json",
+		body: `hello`,
+		want: "application/octet-stream",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+			defer s.Close()
+
+			req, err := http.NewRequest(http.MethodPut, s.URL+"/v2/foo/manifests/latest", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := s.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("PUT: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+			}
+
+			resp, err = s.Client().Get(s.URL + "/v2/foo/manifests/latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := types.MediaType(resp.Header.Get("Content-Type")); got != tc.want {
+				t.Errorf("Content-Type = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequireManifestContentType(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.RequireManifestContentType(), registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+
+	body := `{"schemaVersion":2,"manifests":[]}`
+	req, err := http.NewRequest(http.MethodPut, s.URL+"/v2/foo/manifests/latest", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "MANIFEST_INVALID") {
+		t.Errorf("got body %q, want MANIFEST_INVALID", b)
+	}
+
+	// With a Content-Type, it's accepted.
+	req, err = http.NewRequest(http.MethodPut, s.URL+"/v2/foo/manifests/latest", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", string(types.OCIImageIndex))
+	resp, err = s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+}
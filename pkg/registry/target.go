@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+	tagRE = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+	// https://github.com/opencontainers/image-spec/blob/main/descriptor.md#digests
+	digestRE = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+)
+
+// digestAlgorithms are the algorithms that manifests can be referred to by.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// parseTarget returns the algorithm of target if it's a digest, or "" if it's
+// a tag. Since tags can't contain colons, anything with one is a digest.
+func parseTarget(target string) (string, *regError) {
+	i := strings.Index(target, ":")
+	if i < 0 {
+		if !tagRE.MatchString(target) {
+			return "", &regError{
+				Status:  http.StatusBadRequest,
+				Code:    "TAG_INVALID",
+				Message: fmt.Sprintf("invalid tag %q", target),
+			}
+		}
+		return "", nil
+	}
+
+	alg, encoded := target[:i], target[i+1:]
+	if !digestRE.MatchString(target) {
+		return "", regErrManifestDigestInvalid(fmt.Sprintf("invalid digest %q", target))
+	}
+	h, ok := digestAlgorithms[alg]
+	if !ok {
+		return "", regErrManifestDigestInvalid(fmt.Sprintf("unsupported digest algorithm %q", alg))
+	}
+	if len(encoded) != h().Size()*2 || strings.TrimLeft(encoded, "0123456789abcdef") != "" {
+		return "", regErrManifestDigestInvalid(fmt.Sprintf("invalid %s digest %q", alg, encoded))
+	}
+	return alg, nil
+}
+
+// isDigest returns whether ref, which manifests are stored under, is a digest.
+func isDigest(ref string) bool {
+	return strings.Contains(ref, ":")
+}
+
+// hashOf returns the digest of b using alg, which must be one of
+// digestAlgorithms.
+func hashOf(alg string, b []byte) string {
+	h := digestAlgorithms[alg]()
+	h.Write(b)
+	return alg + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// findByDigest returns the manifest in repo whose digest is target, for
+// digests with algorithms that manifests aren't stored under by default.
+func (m *manifests) findByDigest(ctx context.Context, repo, alg, target string) (Manifest, error) {
+	refs, err := m.manifestHandler.References(ctx, repo)
+	if err != nil {
+		return Manifest{}, err
+	}
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref, "sha256:") {
+			continue
+		}
+		mf, err := m.manifestHandler.Get(ctx, repo, ref)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return Manifest{}, err
+		}
+		if hashOf(alg, mf.Blob) == target {
+			return mf, nil
+		}
+	}
+	return Manifest{}, ErrNotFound
+}
+
+func regErrManifestDigestInvalid(msg string) *regError {
+	return &regError{
+		Status:  http.StatusBadRequest,
+		Code:    "DIGEST_INVALID",
+		Message: msg,
+	}
+}
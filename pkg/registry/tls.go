@@ -15,6 +15,8 @@
 package registry
 
 import (
+	"crypto/x509"
+	"net/http"
 	"net/http/httptest"
 
 	ggcrtest "github.com/google/go-containerregistry/internal/httptest"
@@ -27,3 +29,42 @@ import (
 func TLS(domain string) (*httptest.Server, error) {
 	return ggcrtest.NewTLSServer(domain, New())
 }
+
+// TLSServer is a registry served over TLS, with a certificate issued by a
+// generated CA.
+type TLSServer struct {
+	*httptest.Server
+
+	// CA is the PEM-encoded certificate of the generated CA, e.g. for
+	// writing to a file that other tools can be configured to trust.
+	CA []byte
+
+	// CertPool contains only the generated CA.
+	CertPool *x509.CertPool
+
+	// Transport trusts the generated CA and sends all requests to the
+	// server, whatever their host, so that images can be referenced using
+	// the domain the server was created for. It is also Client().Transport.
+	Transport *http.Transport
+}
+
+// NewTLSServer starts a registry created with New(opts...), served over TLS.
+//
+// The server's certificate is valid for domain, localhost, 127.0.0.1 and ::1,
+// and is issued by a CA generated for this server alone, so clients only
+// trust it if they are configured with CA, CertPool or Transport, or skip
+// verification. Callers should call Close when finished.
+func NewTLSServer(domain string, opts ...Option) (*TLSServer, error) {
+	s, ca, err := ggcrtest.NewTLSServerWithCA(domain, New(opts...))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	return &TLSServer{
+		Server:    s,
+		CA:        ca,
+		CertPool:  pool,
+		Transport: s.Client().Transport.(*http.Transport),
+	}, nil
+}
@@ -15,12 +15,16 @@
 package registry_test
 
 import (
+	"crypto/tls"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
 func TestTLS(t *testing.T) {
@@ -47,3 +51,56 @@ func TestTLS(t *testing.T) {
 		t.Fatalf("Unable to write image to remote: %s", err)
 	}
 }
+
+func TestNewTLSServer(t *testing.T) {
+	s, err := registry.NewTLSServer("registry.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Transport routes the domain to the server.
+	tag, err := name.NewTag("registry.example.com/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img, remote.WithTransport(s.Transport)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	local, err := name.NewTag(strings.TrimPrefix(s.URL, "https://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(tr http.RoundTripper) error {
+		resp, err := (&http.Client{Transport: tr}).Get(s.URL + "/v2/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// The CA is not trusted by default.
+	if err := get(&http.Transport{}); err == nil {
+		t.Error("Get succeeded without trusting the CA")
+	}
+	// Unless verification is skipped.
+	if err := get(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}); err != nil {
+		t.Errorf("Get with InsecureSkipVerify: %v", err)
+	}
+	// Or the CA is trusted.
+	if err := get(&http.Transport{TLSClientConfig: &tls.Config{RootCAs: s.CertPool}}); err != nil {
+		t.Errorf("Get with RootCAs: %v", err)
+	}
+
+	// The CA can be pinned for just this registry.
+	pin := transport.TLSPin{RootCAs: s.CertPool}
+	if _, err := remote.Image(local, remote.WithTLSPin(local.Context().Registry, pin)); err != nil {
+		t.Errorf("Image: %v", err)
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTokenExpiry = 5 * time.Minute
+
+// TokenServer is an http.Handler that issues bearer tokens following the
+// Docker token authentication specification, for use with TokenAuth.
+//
+// It supports both the GET flow, with optional basic auth, and the OAuth2
+// POST flow with password and refresh_token grants.
+//
+// https://docs.docker.com/registry/spec/auth/token/
+// https://docs.docker.com/registry/spec/auth/oauth/
+type TokenServer struct {
+	service string
+	key     []byte
+	expiry  time.Duration
+	users   map[string]string
+	access  func(user, typ, name string, actions []string) []string
+
+	// maps refresh token -> user
+	refresh map[string]string
+	lock    sync.Mutex
+}
+
+// TokenOption describes the available options
+// for creating the token server.
+type TokenOption func(ts *TokenServer)
+
+// TokenUser adds a user that may authenticate with the given password.
+//
+// If no users are added, tokens are issued to anonymous clients.
+func TokenUser(username, password string) TokenOption {
+	return func(ts *TokenServer) {
+		ts.users[username] = password
+	}
+}
+
+// TokenExpiry sets how long issued tokens are valid for. The default is five
+// minutes.
+func TokenExpiry(d time.Duration) TokenOption {
+	return func(ts *TokenServer) {
+		ts.expiry = d
+	}
+}
+
+// TokenAccess sets the function used to decide which of the requested actions
+// user is granted on the resource of type typ (e.g. "repository") with the
+// given name. Anonymous clients have an empty user.
+//
+// By default, every requested action is granted.
+func TokenAccess(f func(user, typ, name string, actions []string) []string) TokenOption {
+	return func(ts *TokenServer) {
+		ts.access = f
+	}
+}
+
+// NewTokenServer returns a TokenServer that issues tokens for service, which
+// should match the service passed to TokenAuth.
+func NewTokenServer(service string, opts ...TokenOption) *TokenServer {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		panic(err)
+	}
+	ts := &TokenServer{
+		service: service,
+		key:     key,
+		expiry:  defaultTokenExpiry,
+		users:   map[string]string{},
+		access: func(_, _, _ string, actions []string) []string {
+			return actions
+		},
+		refresh: map[string]string{},
+	}
+	for _, o := range opts {
+		o(ts)
+	}
+	return ts
+}
+
+// tokenAccess is a single access grant within a token.
+type tokenAccess struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+// tokenClaims are the JWT claims of an issued token.
+type tokenClaims struct {
+	Issuer    string        `json:"iss"`
+	Subject   string        `json:"sub"`
+	Audience  string        `json:"aud"`
+	ExpiresAt int64         `json:"exp"`
+	NotBefore int64         `json:"nbf"`
+	IssuedAt  int64         `json:"iat"`
+	Access    []tokenAccess `json:"access"`
+}
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IssuedAt     string `json:"issued_at"`
+}
+
+// ServeHTTP implements http.Handler.
+func (ts *TokenServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	var (
+		user, service string
+		scopes        []string
+		refresh       bool
+	)
+	switch req.Method {
+	case http.MethodGet:
+		u, p, ok := req.BasicAuth()
+		if ok || len(ts.users) != 0 {
+			if !ts.checkPassword(u, p) {
+				resp.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+				http.Error(resp, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			user = u
+		}
+		service = req.URL.Query().Get("service")
+		scopes = splitScopes(req.URL.Query()["scope"])
+		refresh = req.URL.Query().Get("offline_token") == "true"
+
+	case http.MethodPost:
+		if err := req.ParseForm(); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch req.PostForm.Get("grant_type") {
+		case "password":
+			u, p := req.PostForm.Get("username"), req.PostForm.Get("password")
+			if !ts.checkPassword(u, p) {
+				http.Error(resp, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			user = u
+			refresh = req.PostForm.Get("access_type") == "offline"
+		case "refresh_token":
+			ts.lock.Lock()
+			u, ok := ts.refresh[req.PostForm.Get("refresh_token")]
+			ts.lock.Unlock()
+			if !ok {
+				http.Error(resp, "invalid refresh token", http.StatusUnauthorized)
+				return
+			}
+			user = u
+			refresh = true
+		default:
+			http.Error(resp, "unsupported grant_type", http.StatusBadRequest)
+			return
+		}
+		service = req.PostForm.Get("service")
+		scopes = splitScopes(req.PostForm["scope"])
+
+	default:
+		http.Error(resp, "unsupported method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if service != "" && service != ts.service {
+		http.Error(resp, fmt.Sprintf("unknown service %q", service), http.StatusBadRequest)
+		return
+	}
+
+	var access []tokenAccess
+	for _, scope := range scopes {
+		// Scopes look like repository:foo/bar:pull,push. Names can contain
+		// colons (e.g. a registry port), so split from both ends.
+		first, last := strings.Index(scope, ":"), strings.LastIndex(scope, ":")
+		if first == -1 || first == last {
+			http.Error(resp, fmt.Sprintf("malformed scope %q", scope), http.StatusBadRequest)
+			return
+		}
+		typ, name := scope[:first], scope[first+1:last]
+		granted := ts.access(user, typ, name, strings.Split(scope[last+1:], ","))
+		if len(granted) != 0 {
+			access = append(access, tokenAccess{Type: typ, Name: name, Actions: granted})
+		}
+	}
+
+	now := time.Now()
+	token, err := ts.sign(tokenClaims{
+		Issuer:    ts.service,
+		Subject:   user,
+		Audience:  ts.service,
+		ExpiresAt: now.Add(ts.expiry).Unix(),
+		NotBefore: now.Add(-time.Minute).Unix(),
+		IssuedAt:  now.Unix(),
+		Access:    access,
+	})
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tr := tokenResponse{
+		Token:       token,
+		AccessToken: token,
+		ExpiresIn:   int64(ts.expiry.Seconds()),
+		IssuedAt:    now.UTC().Format(time.RFC3339),
+	}
+	if refresh && user != "" {
+		b := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tr.RefreshToken = hex.EncodeToString(b)
+		ts.lock.Lock()
+		ts.refresh[tr.RefreshToken] = user
+		ts.lock.Unlock()
+	}
+
+	msg, _ := json.Marshal(tr)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Content-Length", fmt.Sprint(len(msg)))
+	resp.WriteHeader(http.StatusOK)
+	io.Copy(resp, bytes.NewReader(msg))
+}
+
+// splitScopes returns the scopes in values, each of which may hold several,
+// separated by spaces, as clients that escalate their scopes send them.
+func splitScopes(values []string) []string {
+	var scopes []string
+	for _, v := range values {
+		scopes = append(scopes, strings.Fields(v)...)
+	}
+	return scopes
+}
+
+func (ts *TokenServer) checkPassword(user, password string) bool {
+	want, ok := ts.users[user]
+	return ok && subtle.ConstantTimeCompare([]byte(want), []byte(password)) == 1
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (ts *TokenServer) sign(claims tokenClaims) (string, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(b)
+	mac := hmac.New(sha256.New, ts.key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify checks the signature, audience and lifetime of token, and returns
+// its claims.
+func (ts *TokenServer) verify(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, errors.New("malformed token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, ts.key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	claims := &tokenClaims{}
+	if err := json.Unmarshal(b, claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	if claims.Audience != ts.service {
+		return nil, errors.New("token has the wrong audience")
+	}
+	now := time.Now().Unix()
+	if now >= claims.ExpiresAt {
+		return nil, errors.New("token has expired")
+	}
+	if now < claims.NotBefore {
+		return nil, errors.New("token is not yet valid")
+	}
+	return claims, nil
+}
+
+// tokenAuth authorizes registry requests using tokens from a TokenServer.
+type tokenAuth struct {
+	realm string
+	ts    *TokenServer
+}
+
+// TokenAuth requires every request to the registry to present a bearer token
+// issued by ts, which is served at realm, with access to the requested
+// repository. As with the distribution registry, pushing requires both pull
+// and push, and mounting a blob requires pull on the repository it's mounted
+// from. Requests without enough access get an insufficient_scope challenge
+// listing every scope they need, so clients can escalate and try again.
+func TokenAuth(realm string, ts *TokenServer) Option {
+	return func(r *registry) {
+		r.tokenAuth = &tokenAuth{realm: realm, ts: ts}
+	}
+}
+
+func (ta *tokenAuth) authorize(resp http.ResponseWriter, req *http.Request) *regError {
+	scopes := requestScopes(req)
+
+	challenge := func(msg, errCode string) *regError {
+		c := fmt.Sprintf("Bearer realm=%q,service=%q", ta.realm, ta.ts.service)
+		if len(scopes) != 0 {
+			ss := make([]string, 0, len(scopes))
+			for _, s := range scopes {
+				ss = append(ss, s.String())
+			}
+			c += fmt.Sprintf(",scope=%q", strings.Join(ss, " "))
+		}
+		if errCode != "" {
+			c += fmt.Sprintf(",error=%q", errCode)
+		}
+		resp.Header().Set("WWW-Authenticate", c)
+		return &regError{
+			Status:  http.StatusUnauthorized,
+			Code:    "UNAUTHORIZED",
+			Message: msg,
+		}
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return challenge("authentication required", "")
+	}
+	claims, err := ta.ts.verify(strings.TrimPrefix(auth, "Bearer "))
+	if err != nil {
+		return challenge(err.Error(), "invalid_token")
+	}
+
+	// Hitting /v2/ just requires a valid token. Otherwise, every action of
+	// every scope must have been granted, by any of the token's grants.
+	for _, s := range scopes {
+		granted := map[string]bool{}
+		for _, a := range claims.Access {
+			if a.Type != s.typ || a.Name != s.name {
+				continue
+			}
+			for _, got := range a.Actions {
+				granted[got] = true
+			}
+		}
+		for _, want := range s.actions {
+			if !granted[want] && !granted["*"] {
+				return challenge("insufficient scope", "insufficient_scope")
+			}
+		}
+	}
+	return nil
+}
+
+// scope is the access to a resource that a request requires.
+type scope struct {
+	typ, name string
+	actions   []string
+}
+
+// String returns s as it appears in challenges and token requests, e.g.
+// repository:foo/bar:pull,push.
+func (s scope) String() string {
+	return s.typ + ":" + s.name + ":" + strings.Join(s.actions, ",")
+}
+
+// requestScopes returns the access required by req, or nothing if no
+// particular access is required.
+func requestScopes(req *http.Request) []scope {
+	if isCatalog(req) {
+		return []scope{{typ: "registry", name: "catalog", actions: []string{"*"}}}
+	}
+
+	repo, _ := repoAndReference(req)
+	if repo == "" {
+		return nil
+	}
+	actions := []string{"pull"}
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		// As with the distribution registry, pushing requires pulling too.
+		actions = []string{"pull", "push"}
+	case http.MethodDelete:
+		actions = []string{"delete"}
+	}
+	upload := strings.Contains(req.URL.Path, "/blobs/uploads/")
+	if upload {
+		// Checking the status of, or canceling, an upload is part of pushing.
+		actions = []string{"pull", "push"}
+	}
+	scopes := []scope{{typ: "repository", name: repo, actions: actions}}
+
+	// Mounting a blob from another repository requires pulling from it.
+	q := req.URL.Query()
+	if upload && req.Method == http.MethodPost && q.Get("mount") != "" && q.Get("from") != "" && q.Get("from") != repo {
+		scopes = append(scopes, scope{typ: "repository", name: q.Get("from"), actions: []string{"pull"}})
+	}
+	return scopes
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func setupTokenAuth(t *testing.T, opts ...registry.TokenOption) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+	ts := registry.NewTokenServer("test-registry", opts...)
+	tokens := httptest.NewServer(ts)
+	t.Cleanup(tokens.Close)
+	reg := httptest.NewServer(registry.New(registry.TokenAuth(tokens.URL+"/token", ts)))
+	t.Cleanup(reg.Close)
+	return tokens, reg
+}
+
+func TestTokenAuth(t *testing.T) {
+	readonly := func(user, typ, name string, actions []string) []string {
+		if name == "readonly" {
+			return []string{"pull"}
+		}
+		return actions
+	}
+	_, reg := setupTokenAuth(t, registry.TokenUser("user", "pass"), registry.TokenAccess(readonly))
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(strings.TrimPrefix(reg.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := remote.WithAuth(&authn.Basic{Username: "user", Password: "pass"})
+
+	if err := remote.Write(ref, img, basic); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := remote.Image(ref, basic); err != nil {
+		t.Errorf("Image: %v", err)
+	}
+
+	// Wrong credentials.
+	if _, err := remote.Image(ref, remote.WithAuth(&authn.Basic{Username: "user", Password: "nope"})); err == nil {
+		t.Error("Image: expected error with bad credentials")
+	}
+	// No credentials.
+	if _, err := remote.Image(ref); err == nil {
+		t.Error("Image: expected error without credentials")
+	}
+
+	// Access is limited by TokenAccess.
+	ro, err := name.NewTag(ref.Context().RegistryStr() + "/readonly:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ro, img, basic); err == nil {
+		t.Error("Write: expected error pushing to readonly repo")
+	}
+}
+
+func TestTokenAuthOAuth(t *testing.T) {
+	tokens, reg := setupTokenAuth(t, registry.TokenUser("user", "pass"))
+
+	// Exchange a password for a refresh token.
+	resp, err := http.PostForm(tokens.URL+"/token", url.Values{
+		"grant_type":  {"password"},
+		"username":    {"user"},
+		"password":    {"pass"},
+		"service":     {"test-registry"},
+		"access_type": {"offline"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var tr struct {
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		t.Fatal(err)
+	}
+	if tr.RefreshToken == "" {
+		t.Fatal("no refresh_token in response")
+	}
+	if tr.ExpiresIn != 300 {
+		t.Errorf("expires_in = %d, want 300", tr.ExpiresIn)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(strings.TrimPrefix(reg.URL, "http://") + "/foo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := authn.FromConfig(authn.AuthConfig{IdentityToken: tr.RefreshToken})
+	if err := remote.Write(ref, img, remote.WithAuth(auth)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+func TestTokenAuthExpired(t *testing.T) {
+	tokens, reg := setupTokenAuth(t, registry.TokenExpiry(-time.Minute))
+
+	resp, err := http.Get(tokens.URL + "/token?service=test-registry&scope=repository:foo:pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var tr struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, reg.URL+"/v2/foo/tags/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tr.Token)
+	got, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Body.Close()
+	if got.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", got.StatusCode, http.StatusUnauthorized)
+	}
+	want := `Bearer realm="` + tokens.URL + `/token",service="test-registry",scope="repository:foo:pull",error="invalid_token"`
+	if wac := got.Header.Get("WWW-Authenticate"); wac != want {
+		t.Errorf("WWW-Authenticate = %q, want %q", wac, want)
+	}
+}
+
+func TestTokenAuthScopeEscalation(t *testing.T) {
+	private := func(_, _, name string, actions []string) []string {
+		if name == "private" {
+			return nil
+		}
+		return actions
+	}
+	_, reg := setupTokenAuth(t, registry.TokenAccess(private))
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewRepository(host + "/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteLayer(src, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	// The challenge lists everything a mount needs.
+	mount := func(from string) string {
+		return reg.URL + "/v2/dst/blobs/uploads/?mount=" + digest.String() + "&from=" + from
+	}
+	resp, err := http.Post(mount("src"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := `scope="repository:dst:pull,push repository:src:pull"`; !strings.Contains(resp.Header.Get("WWW-Authenticate"), want) {
+		t.Errorf("WWW-Authenticate = %q, want it to contain %s", resp.Header.Get("WWW-Authenticate"), want)
+	}
+
+	// Start with a token that can only pull from dst, so that the client has
+	// to escalate its scopes as it goes.
+	dst, err := name.NewRepository(host + "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := transport.NewWithContext(context.Background(), dst.Registry, authn.Anonymous, http.DefaultTransport, []string{dst.Scope(transport.PullScope)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: tr}
+
+	for _, tc := range []struct {
+		desc string
+		url  string
+		want int
+	}{
+		{"upload", reg.URL + "/v2/dst/blobs/uploads/", http.StatusAccepted},
+		// The registry doesn't mount blobs, so this starts an upload too.
+		{"mount", mount("src"), http.StatusAccepted},
+		{"mount from private", mount("private"), http.StatusUnauthorized},
+	} {
+		resp, err := client.Post(tc.url, "", nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: got status %d, want %d", tc.desc, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Storage is where a virtual host keeps its contents, see WithVirtualHosts.
+// Blobs and manifests are kept in memory if their handler is nil.
+type Storage struct {
+	Blobs     BlobHandler
+	Manifests ManifestHandler
+}
+
+// WithVirtualHosts serves a separate registry for each host in hosts, with its
+// own storage, e.g. so that one server can play both the source and the
+// destination registry in a test. Requests are routed by the server name the
+// client sent with TLS SNI, if any, or else by the Host header, with or
+// without its port. Requests for other hosts are served by the default
+// registry, which uses WithBlobHandler and WithManifestHandler as usual.
+//
+// Other options apply to every host, except that WithSeedImages, Seed and
+// Snapshot only involve the default registry's storage.
+func WithVirtualHosts(hosts map[string]Storage) Option {
+	return func(r *registry) {
+		r.virtualHostStorage = hosts
+	}
+}
+
+// virtualHost is the storage of one of the registries from WithVirtualHosts.
+type virtualHost struct {
+	blobs     *blobs
+	manifests *manifests
+}
+
+// setupVirtualHosts creates the storage for each virtual host, configured
+// like the default registry's.
+func (r *registry) setupVirtualHosts() {
+	if len(r.virtualHostStorage) == 0 {
+		return
+	}
+	r.virtualHosts = map[string]*virtualHost{}
+	for host, s := range r.virtualHostStorage {
+		bh, mh := s.Blobs, s.Manifests
+		if bh == nil {
+			bh = &memHandler{m: map[string][]byte{}}
+		}
+		if mh == nil {
+			mh = newMemManifests()
+		}
+		r.virtualHosts[strings.ToLower(host)] = &virtualHost{
+			blobs: &blobs{
+				blobHandler:    bh,
+				log:            r.blobs.log,
+				uploads:        map[string][]byte{},
+				uploadLimit:    r.blobs.uploadLimit,
+				lenient:        r.blobs.lenient,
+				chunkMinLength: r.blobs.chunkMinLength,
+				prefix:         r.blobs.prefix,
+			},
+			manifests: &manifests{
+				manifestHandler:    mh,
+				log:                r.manifests.log,
+				resolvePlatforms:   r.manifests.resolvePlatforms,
+				sizeLimit:          r.manifests.sizeLimit,
+				emptyNotFound:      r.manifests.emptyNotFound,
+				putDelay:           r.manifests.putDelay,
+				putHook:            r.manifests.putHook,
+				requireContentType: r.manifests.requireContentType,
+			},
+		}
+	}
+}
+
+// storageFor returns the blobs and manifests that req should be served from.
+func (r *registry) storageFor(req *http.Request) (*blobs, *manifests) {
+	if r.virtualHosts != nil {
+		host := req.Host
+		if req.TLS != nil && req.TLS.ServerName != "" {
+			host = req.TLS.ServerName
+		}
+		host = strings.ToLower(host)
+		vh, ok := r.virtualHosts[host]
+		if !ok {
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				vh, ok = r.virtualHosts[h]
+			}
+		}
+		if ok {
+			return vh.blobs, vh.manifests
+		}
+	}
+	return &r.blobs, &r.manifests
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestVirtualHosts(t *testing.T) {
+	s := httptest.NewServer(registry.New(
+		registry.WithVirtualHosts(map[string]registry.Storage{
+			"src.example.com": {},
+			"dst.example.com": {},
+		}),
+		registry.Logger(log.New(ioutil.Discard, "", 0)),
+	))
+	defer s.Close()
+	addr := strings.TrimPrefix(s.URL, "http://")
+
+	// Send requests for every host to the server, keeping their Host header.
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	parse := func(ref string) name.Reference {
+		t.Helper()
+		r, err := name.ParseReference(ref, name.Insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	src := parse("src.example.com:80/foo:latest")
+	dst := parse("dst.example.com/foo:latest")
+	def := parse(addr + "/foo:latest")
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(src, img, remote.WithTransport(tr)); err != nil {
+		t.Fatalf("Write(%s): %v", src, err)
+	}
+	for _, ref := range []name.Reference{dst, def} {
+		if _, err := remote.Head(ref, remote.WithTransport(tr)); err == nil {
+			t.Errorf("Head(%s): expected error, image was only pushed to %s", ref, src)
+		}
+	}
+
+	// Copy from one virtual host to the other.
+	got, err := remote.Image(src, remote.WithTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(dst, got, remote.WithTransport(tr)); err != nil {
+		t.Fatalf("Write(%s): %v", dst, err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(dst, remote.WithTransport(tr))
+	if err != nil {
+		t.Fatalf("Head(%s): %v", dst, err)
+	}
+	if desc.Digest != want {
+		t.Errorf("Head(%s) = %s, want %s", dst, desc.Digest, want)
+	}
+
+	for ref, want := range map[name.Reference][]string{
+		src: {"foo"},
+		dst: {"foo"},
+		def: nil,
+	} {
+		repos, err := remote.Catalog(context.Background(), ref.Context().Registry, remote.WithTransport(tr))
+		if err != nil {
+			t.Fatalf("Catalog(%s): %v", ref.Context().Registry, err)
+		}
+		if diff := cmp.Diff(want, repos); diff != "" {
+			t.Errorf("Catalog(%s) (-want +got) = %s", ref.Context().Registry, diff)
+		}
+	}
+}
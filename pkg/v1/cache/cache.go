@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -51,6 +52,11 @@ var ErrNotFound = errors.New("layer was not found")
 // Image returns a new Image which wraps the given Image, whose layers will be
 // pulled from the Cache if they are found, and written to the Cache as they
 // are read from the underlying Image.
+//
+// For example, pushing a remote.Image wrapped with Image using remote.Write
+// populates the cache with any layers that are uploaded, so that subsequent
+// pushes or copies of the same image, including by other tools sharing a
+// filesystem cache, don't need to download them from the source again.
 func Image(i v1.Image, c Cache) v1.Image {
 	return &image{
 		Image: i,
@@ -72,6 +78,16 @@ func (i *image) Layers() ([]v1.Layer, error) {
 	out := make([]v1.Layer, len(ls))
 	for idx, l := range ls {
 		out[idx] = &lazyLayer{inner: l, c: i.c}
+
+		// Keep layers of remote images mountable, so that remote.Write can
+		// still mount them from their source repository instead of reading
+		// them through the cache at all.
+		if ml, ok := l.(*remote.MountableLayer); ok {
+			out[idx] = &remote.MountableLayer{
+				Layer:     out[idx],
+				Reference: ml.Reference,
+			}
+		}
 	}
 	return out, nil
 }
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+type contentcache struct {
+	path string
+}
+
+// NewFilesystemContentCache returns a remote.Cache, for use with
+// remote.WithCache, backed by files in path.
+//
+// Entries are stored the same way as compressed layers are by
+// NewFilesystemCache, so the two can share a path, and layers cached by either
+// are found by both.
+func NewFilesystemContentCache(path string) remote.Cache {
+	return &contentcache{path}
+}
+
+func (c *contentcache) Get(h v1.Hash) (*v1.Descriptor, io.ReadCloser, error) {
+	f, err := os.Open(cachepath(c.path, h))
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%s: %w", h, remote.ErrCacheMiss)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	// Entries written by NewFilesystemCache don't have a media type.
+	mt, err := ioutil.ReadFile(mediatypepath(c.path, h))
+	if err != nil && !os.IsNotExist(err) {
+		f.Close()
+		return nil, nil, err
+	}
+	return &v1.Descriptor{
+		Digest:    h,
+		Size:      fi.Size(),
+		MediaType: types.MediaType(strings.TrimSpace(string(mt))),
+	}, f, nil
+}
+
+// Put writes to a temporary file that is only moved into place once r has
+// been read to the end without error, like layers in NewFilesystemCache.
+func (c *contentcache) Put(desc v1.Descriptor, r io.Reader) (err error) {
+	if err := os.MkdirAll(c.path, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.path, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Write the media type first, so the entry is never without it.
+	if desc.MediaType != "" {
+		if err := ioutil.WriteFile(mediatypepath(c.path, desc.Digest), []byte(desc.MediaType), 0600); err != nil {
+			return err
+		}
+	}
+	return os.Rename(f.Name(), cachepath(c.path, desc.Digest))
+}
+
+func mediatypepath(path string, h v1.Hash) string {
+	return cachepath(path, h) + ".mediatype"
+}
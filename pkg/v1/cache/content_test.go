@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("boom") }
+
+func TestFilesystemContentCache(t *testing.T) {
+	c := NewFilesystemContentCache(t.TempDir())
+
+	content := []byte(`{"schemaVersion":2}`)
+	h, size, err := v1.SHA256(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Get(h); !errors.Is(err, remote.ErrCacheMiss) {
+		t.Fatalf("Get() before Put = %v, want ErrCacheMiss", err)
+	}
+
+	// A failed Put stores nothing.
+	failed := io.MultiReader(bytes.NewReader(content[:5]), failingReader{})
+	if err := c.Put(v1.Descriptor{Digest: h, Size: -1, MediaType: types.OCIManifestSchema1}, failed); err == nil {
+		t.Fatal("Put() with failing reader succeeded, wanted err")
+	}
+	if _, _, err := c.Get(h); !errors.Is(err, remote.ErrCacheMiss) {
+		t.Fatalf("Get() after failed Put = %v, want ErrCacheMiss", err)
+	}
+
+	if err := c.Put(v1.Descriptor{Digest: h, Size: -1, MediaType: types.OCIManifestSchema1}, bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	desc, rc, err := c.Get(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+	if desc.Digest != h || desc.Size != size || desc.MediaType != types.OCIManifestSchema1 {
+		t.Errorf("Get() = %+v", desc)
+	}
+}
+
+func TestFilesystemContentCacheSharesLayers(t *testing.T) {
+	dir := t.TempDir()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/content:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	pulled, err := remote.Image(ref, remote.WithCache(NewFilesystemContentCache(dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(pulled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Layers pulled through the content cache are found by the layer cache.
+	ls, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc := NewFilesystemCache(dir)
+	for _, l := range ls {
+		d, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cl, err := lc.Get(d)
+		if err != nil {
+			t.Fatalf("layer cache Get(%s) = %v", d, err)
+		}
+		if got, err := cl.Digest(); err != nil || got != d {
+			t.Errorf("layer cache Get(%s).Digest() = %v, %v", d, got, err)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -58,50 +59,58 @@ type layer struct {
 	digest, diffID v1.Hash
 }
 
-func (l *layer) create(h v1.Hash) (io.WriteCloser, error) {
+// create returns a reader that writes the contents of rc to the cache entry
+// for h as they are read.
+//
+// Contents are written to a temporary file which is only moved into place once
+// rc has been read to the end, so that a layer which is only partially read,
+// e.g. by an interrupted or retried upload, never leaves a truncated entry
+// behind, and so that concurrent writers, possibly in other processes, don't
+// interfere with each other.
+func (l *layer) create(h v1.Hash, open func() (io.ReadCloser, error)) (io.ReadCloser, error) {
 	if err := os.MkdirAll(l.path, 0700); err != nil {
 		return nil, err
 	}
-	return os.Create(cachepath(l.path, h))
-}
-
-func (l *layer) Compressed() (io.ReadCloser, error) {
-	f, err := l.create(l.digest)
+	rc, err := open()
 	if err != nil {
 		return nil, err
 	}
-	rc, err := l.Layer.Compressed()
+	f, err := ioutil.TempFile(l.path, ".tmp-")
 	if err != nil {
+		rc.Close()
 		return nil, err
 	}
 	return &readcloser{
 		t:      io.TeeReader(rc, f),
 		closes: []func() error{rc.Close, f.Close},
+		tmp:    f.Name(),
+		path:   cachepath(l.path, h),
 	}, nil
 }
 
+func (l *layer) Compressed() (io.ReadCloser, error) {
+	return l.create(l.digest, l.Layer.Compressed)
+}
+
 func (l *layer) Uncompressed() (io.ReadCloser, error) {
-	f, err := l.create(l.diffID)
-	if err != nil {
-		return nil, err
-	}
-	rc, err := l.Layer.Uncompressed()
-	if err != nil {
-		return nil, err
-	}
-	return &readcloser{
-		t:      io.TeeReader(rc, f),
-		closes: []func() error{rc.Close, f.Close},
-	}, nil
+	return l.create(l.diffID, l.Layer.Uncompressed)
 }
 
 type readcloser struct {
 	t      io.Reader
 	closes []func() error
+
+	// tmp is moved to path on Close if t was read to the end.
+	tmp, path string
+	complete  bool
 }
 
 func (rc *readcloser) Read(b []byte) (int, error) {
-	return rc.t.Read(b)
+	n, err := rc.t.Read(b)
+	if errors.Is(err, io.EOF) {
+		rc.complete = true
+	}
+	return n, err
 }
 
 func (rc *readcloser) Close() error {
@@ -114,6 +123,12 @@ func (rc *readcloser) Close() error {
 			err = lastErr
 		}
 	}
+	if err == nil && rc.complete {
+		err = os.Rename(rc.tmp, rc.path)
+	}
+	if err != nil || !rc.complete {
+		os.Remove(rc.tmp)
+	}
 	return err
 }
 
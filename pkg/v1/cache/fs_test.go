@@ -18,11 +18,19 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -212,3 +220,110 @@ func TestErrUnexpectedEOF(t *testing.T) {
 		t.Errorf("os.Stat(%q): %v", p, err)
 	}
 }
+
+func TestPartialRead(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("random.Layer: %v", err)
+	}
+	c := NewFilesystemCache(dir)
+	cl, err := c.Put(l)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Abandon reading the layer part of the way through.
+	rc, err := cl.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := rc.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Nothing, not even a temporary file, should be left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Got %d cached files, want 0", len(files))
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	if _, err := c.Get(h); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(%q): %v", h, err)
+	}
+}
+
+func TestRemoteWriteThrough(t *testing.T) {
+	// Count blob fetches from the source registry.
+	var fetches int32
+	reg := registry.New()
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			atomic.AddInt32(&fetches, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer src.Close()
+
+	u, err := url.Parse(src.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/src:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewFilesystemCache(t.TempDir())
+	push := func() {
+		t.Helper()
+		pulled, err := remote.Image(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := httptest.NewServer(registry.New())
+		defer dst.Close()
+		u, err := url.Parse(dst.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dstRef, err := name.ParseReference(u.Host + "/dst:latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(dstRef, Image(pulled, c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	push()
+	layerFetches := atomic.LoadInt32(&fetches)
+	if layerFetches < 3 {
+		t.Fatalf("got %d blob fetches from source on first push, want at least 3", layerFetches)
+	}
+
+	// Pushing again should read the layers from the cache, and only fetch
+	// the config.
+	atomic.StoreInt32(&fetches, 0)
+	push()
+	if got := atomic.LoadInt32(&fetches); got != layerFetches-3 {
+		t.Errorf("got %d blob fetches from source on second push, want %d", got, layerFetches-3)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/internal/flock"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// GCResult describes the blobs that GC removed, or would have removed.
+type GCResult struct {
+	// Blobs are the unreferenced blobs, sorted.
+	Blobs []v1.Hash
+	// Bytes is the total size of Blobs.
+	Bytes int64
+}
+
+// maxSniffSize is the largest blob that GC reads to see whether it's a
+// manifest, when its media type doesn't say.
+const maxSniffSize = 4 << 20
+
+// GCOption is a functional option for GC.
+type GCOption func(*gcOptions)
+
+type gcOptions struct {
+	dryRun bool
+	minAge time.Duration
+}
+
+// GCDryRun makes GC report what it would remove without removing anything.
+func GCDryRun(o *gcOptions) {
+	o.dryRun = true
+}
+
+// GCMinAge makes GC leave blobs that were written less than d ago alone, even
+// if they aren't referenced.
+//
+// Registries, including pkg/registry's BlobLayout, upload an image's blobs
+// before the manifest that refers to them is added to index.json, so GC of a
+// layout that a registry is serving should set d longer than any push takes.
+func GCMinAge(d time.Duration) GCOption {
+	return func(o *gcOptions) {
+		o.minAge = d
+	}
+}
+
+// GC removes the blobs in the Path that aren't referenced, directly or
+// indirectly, by the manifests in its index.json, e.g. those left behind by
+// RemoveDescriptors or ReplaceImage. Manifests are followed to their configs,
+// layers, children and subjects. Blobs with media types that GC doesn't
+// recognize are read, if they're small enough, and followed if they turn out
+// to be manifests. Referenced blobs that are missing, such as
+// non-distributable layers, are ignored.
+//
+// Files in the blobs directory that aren't named after a digest, such as
+// partial writes, are left alone.
+//
+// GC holds the lock on index.json.lock that pkg/registry's BlobLayout takes
+// to update index.json, so a registry serving the layout can't add manifests
+// while GC runs, but see GCMinAge for the blobs it's uploading. The methods
+// of Path don't take that lock, so GC must not be run while they're writing
+// to the layout.
+func (l Path) GC(opts ...GCOption) (*GCResult, error) {
+	o := &gcOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	unlock, err := flock.Lock(l.path("index.json.lock"))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ii, err := l.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := map[v1.Hash]bool{}
+	for _, desc := range index.Manifests {
+		if err := l.mark(desc, reachable); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &GCResult{}
+	cutoff := time.Now().Add(-o.minAge)
+	algs, err := os.ReadDir(l.path("blobs"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return res, nil
+		}
+		return nil, err
+	}
+	for _, alg := range algs {
+		if !alg.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(l.path("blobs", alg.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			h, err := v1.NewHash(alg.Name() + ":" + e.Name())
+			if err != nil || e.IsDir() || reachable[h] {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			if o.minAge > 0 && info.ModTime().After(cutoff) {
+				continue
+			}
+			res.Blobs = append(res.Blobs, h)
+			res.Bytes += info.Size()
+		}
+	}
+	sort.Slice(res.Blobs, func(i, j int) bool {
+		return res.Blobs[i].String() < res.Blobs[j].String()
+	})
+
+	if o.dryRun {
+		return res, nil
+	}
+	for _, h := range res.Blobs {
+		if err := l.RemoveBlob(h); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// gcManifest has the fields of every kind of manifest that refer to blobs.
+type gcManifest struct {
+	Config    *v1.Descriptor  `json:"config,omitempty"`
+	Layers    []v1.Descriptor `json:"layers,omitempty"`
+	Manifests []v1.Descriptor `json:"manifests,omitempty"`
+	Subject   *v1.Descriptor  `json:"subject,omitempty"`
+	FSLayers  []struct {
+		BlobSum v1.Hash `json:"blobSum"`
+	} `json:"fsLayers,omitempty"`
+}
+
+// mark adds desc to reachable and, if it's a manifest, everything it refers to.
+func (l Path) mark(desc v1.Descriptor, reachable map[v1.Hash]bool) error {
+	if reachable[desc.Digest] {
+		return nil
+	}
+	reachable[desc.Digest] = true
+
+	if leafMediaTypes[desc.MediaType] {
+		return nil
+	}
+	known := desc.MediaType.IsIndex() || desc.MediaType.IsImage() ||
+		desc.MediaType == types.DockerManifestSchema1 || desc.MediaType == types.DockerManifestSchema1Signed
+	if !known {
+		// Descriptors of artifacts can have any media type, so look for
+		// anything that might be a manifest.
+		fi, err := os.Stat(l.path("blobs", desc.Digest.Algorithm, desc.Digest.Hex))
+		if err != nil || fi.Size() > maxSniffSize {
+			return nil
+		}
+	}
+
+	b, err := l.Bytes(desc.Digest)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var m gcManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		if !known {
+			return nil
+		}
+		return fmt.Errorf("parsing manifest %s: %w", desc.Digest, err)
+	}
+
+	var children []v1.Descriptor
+	if m.Config != nil {
+		children = append(children, *m.Config)
+	}
+	if m.Subject != nil {
+		children = append(children, *m.Subject)
+	}
+	children = append(children, m.Layers...)
+	children = append(children, m.Manifests...)
+	for _, fsl := range m.FSLayers {
+		children = append(children, v1.Descriptor{MediaType: types.DockerLayer, Digest: fsl.BlobSum})
+	}
+	for _, child := range children {
+		if err := l.mark(child, reachable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leafMediaTypes are the media types of blobs that don't refer to anything.
+var leafMediaTypes = map[types.MediaType]bool{
+	types.OCIConfigJSON:                  true,
+	types.OCILayer:                       true,
+	types.OCILayerZStd:                   true,
+	types.OCIRestrictedLayer:             true,
+	types.OCIRestrictedLayerZStd:         true,
+	types.OCIUncompressedLayer:           true,
+	types.OCIUncompressedRestrictedLayer: true,
+	types.DockerConfigJSON:               true,
+	types.DockerLayer:                    true,
+	types.DockerForeignLayer:             true,
+	types.DockerUncompressedLayer:        true,
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestGC(t *testing.T) {
+	l, err := Write(t.TempDir(), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendImage(kept); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendImage(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything in removed, and a stray blob, is garbage.
+	h, err := removed.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RemoveDescriptors(match.Digests(h)); err != nil {
+		t.Fatal(err)
+	}
+	stray := []byte("stray")
+	strayHash, _, err := v1.SHA256(bytes.NewReader(stray))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.WriteBlob(strayHash, io.NopCloser(bytes.NewReader(stray))); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &GCResult{Blobs: []v1.Hash{h, strayHash}, Bytes: int64(len(stray))}
+	m, err := removed.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Bytes += int64(len(m))
+	cfg, err := removed.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawCfg, err := removed.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Blobs = append(want.Blobs, cfg)
+	want.Bytes += int64(len(rawCfg))
+	layers, err := removed.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range layers {
+		d, err := layer.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := layer.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Blobs = append(want.Blobs, d)
+		want.Bytes += size
+	}
+	sort.Slice(want.Blobs, func(i, j int) bool {
+		return want.Blobs[i].String() < want.Blobs[j].String()
+	})
+
+	// A dry run reports the garbage without removing it.
+	got, err := l.GC(GCDryRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GC(GCDryRun) (-want +got) = %s", diff)
+	}
+	if _, err := l.Bytes(strayHash); err != nil {
+		t.Errorf("dry run removed a blob: %v", err)
+	}
+
+	got, err = l.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GC() (-want +got) = %s", diff)
+	}
+	for _, h := range want.Blobs {
+		if _, err := l.Bytes(h); !os.IsNotExist(err) {
+			t.Errorf("blob %s: got err %v, want it to be removed", h, err)
+		}
+	}
+
+	// What's left is intact, and there's nothing more to collect.
+	kh, err := kept.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := l.Image(kh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+	ih, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii, err := l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := ii.ImageIndex(ih)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(child); err != nil {
+		t.Errorf("validate.Index: %v", err)
+	}
+	if got, err := l.GC(); err != nil || len(got.Blobs) != 0 {
+		t.Errorf("second GC() = %v, %v, want nothing", got, err)
+	}
+}
+
+func TestGCArtifact(t *testing.T) {
+	l, err := Write(t.TempDir(), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeBlob := func(b []byte) v1.Descriptor {
+		t.Helper()
+		h, size, err := v1.SHA256(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.WriteBlob(h, io.NopCloser(bytes.NewReader(b))); err != nil {
+			t.Fatal(err)
+		}
+		return v1.Descriptor{MediaType: "application/vnd.example.thing", Size: size, Digest: h}
+	}
+
+	// An artifact whose media type GC doesn't know still keeps what its
+	// manifest refers to.
+	layer := writeBlob([]byte("layer"))
+	m, err := json.Marshal(v1.Manifest{SchemaVersion: 2, Config: writeBlob([]byte("{}")), Layers: []v1.Descriptor{layer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact := writeBlob(m)
+	artifact.MediaType = "application/vnd.example.artifact"
+	if err := l.AppendDescriptor(artifact); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := l.GC(); err != nil || len(got.Blobs) != 0 {
+		t.Errorf("GC() = %v, %v, want nothing", got, err)
+	}
+	if _, err := l.Bytes(layer.Digest); err != nil {
+		t.Errorf("artifact layer was removed: %v", err)
+	}
+}
+
+func TestGCMinAge(t *testing.T) {
+	l, err := Write(t.TempDir(), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stray := []byte("stray")
+	h, _, err := v1.SHA256(bytes.NewReader(stray))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.WriteBlob(h, io.NopCloser(bytes.NewReader(stray))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The blob is too new to collect, e.g. because the manifest that will
+	// refer to it hasn't been pushed yet.
+	if got, err := l.GC(GCMinAge(time.Hour)); err != nil || len(got.Blobs) != 0 {
+		t.Errorf("GC(GCMinAge) = %v, %v, want nothing", got, err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(l.path("blobs", h.Algorithm, h.Hex), old, old); err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.GC(GCMinAge(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]v1.Hash{h}, got.Blobs); diff != "" {
+		t.Errorf("GC(GCMinAge) (-want +got) = %s", diff)
+	}
+}
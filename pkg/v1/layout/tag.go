@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Tag names the manifest desc describes ref in the index.json of the Path,
+// using the "org.opencontainers.image.ref.name" annotation:
+// https://github.com/opencontainers/image-spec/blob/v1.0.1/annotations.md#pre-defined-annotation-keys
+//
+// Like a tag in a registry, ref names at most one manifest, so any other
+// manifest named ref is removed from index.json; the blobs it refers to are
+// left alone. An unnamed entry for desc's manifest is replaced with the named
+// one. The manifest itself should already have been written, e.g. with
+// WriteImage or WriteIndex.
+func (l Path) Tag(ref string, desc v1.Descriptor) error {
+	ii, err := l.ImageIndex()
+	if err != nil {
+		return err
+	}
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	manifests := make([]v1.Descriptor, 0, len(index.Manifests)+1)
+	for _, m := range index.Manifests {
+		name, named := m.Annotations[imagespec.AnnotationRefName]
+		if name == ref {
+			if m.Digest == desc.Digest {
+				// It's already tagged.
+				return nil
+			}
+			continue
+		}
+		if !named && m.Digest == desc.Digest {
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+
+	annotations := map[string]string{}
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	annotations[imagespec.AnnotationRefName] = ref
+	desc.Annotations = annotations
+	index.Manifests = append(manifests, desc)
+
+	rawIndex, err := json.MarshalIndent(index, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+}
+
+// ResolveTag returns the descriptor of the manifest that Tag named ref in the
+// index.json of the Path. If there isn't one, the error wraps os.ErrNotExist.
+func (l Path) ResolveTag(ref string) (*v1.Descriptor, error) {
+	ii, err := l.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range index.Manifests {
+		if m.Annotations[imagespec.AnnotationRefName] == ref {
+			m := m
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no manifest named %q: %w", l, ref, os.ErrNotExist)
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestTag(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "tag-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func() v1.Descriptor {
+		t.Helper()
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.WriteImage(img); err != nil {
+			t.Fatal(err)
+		}
+		desc, err := partial.Descriptor(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *desc
+	}
+	manifests := func() []v1.Descriptor {
+		t.Helper()
+		ii, err := l.ImageIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ii.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Manifests
+	}
+	resolve := func(ref string, want v1.Hash) {
+		t.Helper()
+		desc, err := l.ResolveTag(ref)
+		if err != nil {
+			t.Fatalf("ResolveTag(%q) = %v", ref, err)
+		}
+		if desc.Digest != want {
+			t.Errorf("ResolveTag(%q) = %s, want %s", ref, desc.Digest, want)
+		}
+		if got := desc.Annotations[imagespec.AnnotationRefName]; got != ref {
+			t.Errorf("ResolveTag(%q) annotation = %q", ref, got)
+		}
+	}
+
+	if _, err := l.ResolveTag("v1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ResolveTag() on an empty layout = %v, want os.ErrNotExist", err)
+	}
+
+	// An unnamed entry is replaced by the named one.
+	one := write()
+	if err := l.AppendDescriptor(one); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Tag("v1", one); err != nil {
+		t.Fatal(err)
+	}
+	resolve("v1", one.Digest)
+	if n := len(manifests()); n != 1 {
+		t.Errorf("got %d manifests, want 1", n)
+	}
+
+	// A manifest can have more than one name, and tagging twice is a no-op.
+	for i := 0; i < 2; i++ {
+		if err := l.Tag("example.com/app:latest", one); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolve("example.com/app:latest", one.Digest)
+	resolve("v1", one.Digest)
+	if n := len(manifests()); n != 2 {
+		t.Errorf("got %d manifests, want 2", n)
+	}
+
+	// Moving a tag drops the old entry, and keeps desc's other annotations.
+	two := write()
+	two.Annotations = map[string]string{"foo": "bar"}
+	if err := l.Tag("v1", two); err != nil {
+		t.Fatal(err)
+	}
+	resolve("v1", two.Digest)
+	resolve("example.com/app:latest", one.Digest)
+	if n := len(manifests()); n != 2 {
+		t.Errorf("got %d manifests, want 2", n)
+	}
+	desc, err := l.ResolveTag("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Annotations["foo"] != "bar" {
+		t.Errorf("annotations = %v, wanted foo=bar", desc.Annotations)
+	}
+	if _, ok := two.Annotations[imagespec.AnnotationRefName]; ok {
+		t.Errorf("Tag() modified the caller's annotations")
+	}
+
+	// Tagged manifests can be read back.
+	img, err := l.Image(two.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := img.Digest(); err != nil || d != two.Digest {
+		t.Errorf("Image() = %s, %v, want %s", d, err, two.Digest)
+	}
+}
@@ -15,6 +15,7 @@
 package v1
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -48,6 +49,36 @@ func TestGoodManifestWithHash(t *testing.T) {
 	}
 }
 
+func TestManifestArtifactTypeAndSubject(t *testing.T) {
+	in := `{"schemaVersion":2,"artifactType":"application/spdx+json","config":{"mediaType":"application/vnd.oci.empty.v1+json","size":2,"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"layers":[],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":3,"digest":"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae","artifactType":"application/vnd.example"}}`
+	m, err := ParseManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing manifest: %v", err)
+	}
+	if got, want := m.ArtifactType, "application/spdx+json"; got != want {
+		t.Errorf("ParseManifest().ArtifactType; got %v, want %v", got, want)
+	}
+	if m.Subject == nil {
+		t.Fatal("ParseManifest().Subject; got nil")
+	}
+	if got, want := m.Subject.ArtifactType, "application/vnd.example"; got != want {
+		t.Errorf("ParseManifest().Subject.ArtifactType; got %v, want %v", got, want)
+	}
+
+	// Fields should survive a round trip.
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != in {
+		t.Errorf("json.Marshal(); got %s, want %s", got, in)
+	}
+
+	if diff := cmp.Diff(m, m.DeepCopy()); diff != "" {
+		t.Errorf("DeepCopy(); (-want +got) %s", diff)
+	}
+}
+
 func TestManifestWithBadHash(t *testing.T) {
 	bad, err := ParseManifest(strings.NewReader(`{
   "config": {
@@ -0,0 +1,179 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"fmt"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// EstargzOption configures Estargz.
+type EstargzOption func(*estargzOptions)
+
+type estargzOptions struct {
+	prioritize []string
+	missing    *[]string
+	layerOpts  []tarball.LayerOption
+}
+
+// EstargzPrioritizedFiles puts files at the front of each layer that has
+// them, so that they're fetched first by lazy pullers. They're typically the
+// files the image's entrypoint reads on startup.
+func EstargzPrioritizedFiles(files ...string) EstargzOption {
+	return func(o *estargzOptions) {
+		o.prioritize = append(o.prioritize, files...)
+	}
+}
+
+// EstargzMissingFiles sets missing to the prioritized files that aren't in
+// any layer. Without it, Estargz returns an error if any are missing.
+func EstargzMissingFiles(missing *[]string) EstargzOption {
+	return func(o *estargzOptions) {
+		o.missing = missing
+	}
+}
+
+// EstargzLayerOptions passes opts along when converting each layer, e.g. to
+// set its compression level.
+func EstargzLayerOptions(opts ...tarball.LayerOption) EstargzOption {
+	return func(o *estargzOptions) {
+		o.layerOpts = append(o.layerOpts, opts...)
+	}
+}
+
+// Estargz returns an image with the layers of base rewritten as eStargz, a
+// seekable form of gzip that lets lazy pullers such as the containerd
+// stargz-snapshotter start containers before the whole image is fetched.
+//
+// Converting a layer changes its uncompressed contents, so the diff_ids of the
+// config are recomputed. Everything else, including the history, annotations
+// and media types, is kept. Non-distributable layers are left as they are.
+func Estargz(base v1.Image, opts ...EstargzOption) (v1.Image, error) {
+	o := &estargzOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	m, err := base.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+	layers, err := base.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("getting layers: %w", err)
+	}
+	if len(layers) != len(m.Layers) {
+		return nil, fmt.Errorf("manifest has %d layers, got %d", len(m.Layers), len(layers))
+	}
+
+	layerOpts := []tarball.LayerOption{tarball.WithEstargz}
+	if m.MediaType == types.OCIManifestSchema1 {
+		layerOpts = append(layerOpts, tarball.WithMediaType(types.OCILayer))
+	}
+	layerOpts = append(layerOpts, o.layerOpts...)
+
+	// A prioritized file is only missing if no layer has it.
+	missing := o.prioritize
+
+	adds := make([]Addendum, 0, len(layers))
+	for i, layer := range layers {
+		desc := m.Layers[i]
+		if !desc.MediaType.IsDistributable() {
+			adds = append(adds, Addendum{
+				Layer:       layer,
+				Annotations: desc.Annotations,
+				URLs:        desc.URLs,
+				MediaType:   desc.MediaType,
+			})
+			continue
+		}
+
+		missingFromLayer := []string{}
+		lopts := append([]tarball.LayerOption{
+			tarball.WithEstargzOptions(
+				estargz.WithPrioritizedFiles(o.prioritize),
+				estargz.WithAllowPrioritizeNotFound(&missingFromLayer),
+			),
+		}, layerOpts...)
+		olayer, err := tarball.LayerFromOpener(layer.Uncompressed, lopts...)
+		if err != nil {
+			return nil, fmt.Errorf("converting layer %s: %w", desc.Digest, err)
+		}
+		notFound := map[string]bool{}
+		for _, f := range missingFromLayer {
+			notFound[f] = true
+		}
+		var stillMissing []string
+		for _, f := range missing {
+			if notFound[f] {
+				stillMissing = append(stillMissing, f)
+			}
+		}
+		missing = stillMissing
+		adds = append(adds, Addendum{Layer: olayer})
+	}
+
+	if o.missing != nil {
+		*o.missing = append([]string{}, missing...)
+	} else if len(missing) != 0 {
+		return nil, fmt.Errorf("prioritized files are missing from every layer: %v", missing)
+	}
+
+	// Append recomputes the diff_ids, but adds a history entry for each
+	// layer, so put the original history back afterwards.
+	ocf := cf.DeepCopy()
+	ocf.RootFS.DiffIDs = []v1.Hash{}
+	ocf.History = []v1.History{}
+	img, err := ConfigFile(empty.Image, ocf)
+	if err != nil {
+		return nil, fmt.Errorf("mutating config: %w", err)
+	}
+	if m.MediaType != "" {
+		img = MediaType(img, m.MediaType)
+	}
+	if m.Config.MediaType != "" {
+		img = ConfigMediaType(img, m.Config.MediaType)
+	}
+	img, err = Append(img, adds...)
+	if err != nil {
+		return nil, fmt.Errorf("appending layers: %w", err)
+	}
+	ocf, err = img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+	ocf = ocf.DeepCopy()
+	ocf.History = cf.DeepCopy().History
+	img, err = ConfigFile(img, ocf)
+	if err != nil {
+		return nil, fmt.Errorf("restoring history: %w", err)
+	}
+
+	// Retain any annotations from the original image.
+	if len(m.Annotations) != 0 {
+		img = Annotations(img, m.Annotations).(v1.Image)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// requireEstargz skips the test if the estargz package can't build layers
+// with this version of Go, e.g. because compress/gzip writes a footer of a
+// different size than it expects, which it panics on.
+func requireEstargz(t *testing.T) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "probe", Mode: 0644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("estargz doesn't work with this version of Go: %v", r)
+		}
+	}()
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob.Close()
+}
+
+func TestEstargz(t *testing.T) {
+	requireEstargz(t)
+
+	bottom, err := mutate.LayerFromFS(fstest.MapFS{
+		"bin/app":  {Data: []byte("app")},
+		"etc/conf": {Data: []byte("conf")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := mutate.LayerFromFS(fstest.MapFS{
+		"data.txt": {Data: []byte("data")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	base = mutate.ConfigMediaType(base, types.OCIConfigJSON)
+	base, err = mutate.Append(base,
+		mutate.Addendum{Layer: bottom, History: v1.History{CreatedBy: "bottom"}},
+		mutate.Addendum{History: v1.History{CreatedBy: "ENV A=B", EmptyLayer: true}},
+		mutate.Addendum{Layer: top, History: v1.History{CreatedBy: "top"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base = mutate.Annotations(base, map[string]string{"foo": "bar"}).(v1.Image)
+
+	// Every prioritized file has to be in some layer, unless the caller asks
+	// which aren't.
+	if _, err := mutate.Estargz(base, mutate.EstargzPrioritizedFiles("bin/app", "nope")); err == nil {
+		t.Error("Estargz() with a missing file = nil, wanted error")
+	}
+	var missing []string
+	img, err := mutate.Estargz(base,
+		mutate.EstargzPrioritizedFiles("bin/app", "nope", "data.txt"),
+		mutate.EstargzMissingFiles(&missing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"nope"}, missing); diff != "" {
+		t.Errorf("missing (-want +got) = %s", diff)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Fatalf("validate.Image: %v", err)
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.MediaType, types.OCIManifestSchema1; got != want {
+		t.Errorf("MediaType = %s, want %s", got, want)
+	}
+	if got, want := m.Config.MediaType, types.OCIConfigJSON; got != want {
+		t.Errorf("Config.MediaType = %s, want %s", got, want)
+	}
+	if diff := cmp.Diff(map[string]string{"foo": "bar"}, m.Annotations); diff != "" {
+		t.Errorf("Annotations (-want +got) = %s", diff)
+	}
+	if got, want := len(m.Layers), 2; got != want {
+		t.Fatalf("len(Layers) = %d, want %d", got, want)
+	}
+	for _, l := range m.Layers {
+		if got, want := l.MediaType, types.OCILayer; got != want {
+			t.Errorf("layer MediaType = %s, want %s", got, want)
+		}
+		if _, ok := l.Annotations[estargz.TOCJSONDigestAnnotation]; !ok {
+			t.Errorf("layer %s is missing the %s annotation", l.Digest, estargz.TOCJSONDigestAnnotation)
+		}
+	}
+
+	// The history is kept, and the diff_ids are those of the new layers.
+	bcf, err := base.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(bcf.History, cf.History); diff != "" {
+		t.Errorf("History (-want +got) = %s", diff)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diffIDs []v1.Hash
+	for _, l := range layers {
+		h, err := l.DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffIDs = append(diffIDs, h)
+	}
+	if diff := cmp.Diff(diffIDs, cf.RootFS.DiffIDs); diff != "" {
+		t.Errorf("DiffIDs (-want +got) = %s", diff)
+	}
+	if cmp.Equal(bcf.RootFS.DiffIDs, cf.RootFS.DiffIDs) {
+		t.Error("DiffIDs are unchanged, wanted those of the eStargz layers")
+	}
+}
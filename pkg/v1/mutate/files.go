@@ -0,0 +1,170 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// File is a regular file to add to an image with AddFiles.
+type File struct {
+	Contents []byte
+
+	// Mode is the file's permission bits. The default is 0644.
+	Mode int64
+
+	// ModTime is the file's modification time. The default is the Unix epoch.
+	ModTime time.Time
+
+	// UID and GID own the file. The default is 0 (root) for both.
+	UID, GID int
+}
+
+// DeletePaths returns an image with a new layer on top of base that deletes
+// each of paths, which may be files or directories, using whiteouts. The
+// existing layers are left as they are, so the deleted contents are still in
+// the image's blobs, but not in its filesystem.
+func DeletePaths(base v1.Image, paths ...string) (v1.Image, error) {
+	if len(paths) == 0 {
+		return base, nil
+	}
+	var hdrs []*tar.Header
+	for _, p := range paths {
+		p, err := cleanPath(p)
+		if err != nil {
+			return nil, err
+		}
+		dir, file := path.Split(p)
+		hdrs = append(hdrs, &tar.Header{
+			Name:     path.Join(dir, whiteoutPrefix+file),
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			ModTime:  time.Unix(0, 0),
+		})
+	}
+	return appendTarLayer(base, hdrs, nil, fmt.Sprintf("mutate.DeletePaths %s", strings.Join(paths, " ")))
+}
+
+// AddFiles returns an image with a new layer on top of base that contains
+// files, keyed by their path in the filesystem. Files that exist in base are
+// replaced. Parent directories aren't added, so any that are missing from
+// base are created by the runtime.
+func AddFiles(base v1.Image, files map[string]File) (v1.Image, error) {
+	if len(files) == 0 {
+		return base, nil
+	}
+	// Sort the paths so that the layer is reproducible.
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var (
+		hdrs     []*tar.Header
+		contents [][]byte
+	)
+	for _, p := range paths {
+		f := files[p]
+		name, err := cleanPath(p)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(path.Base(name), whiteoutPrefix) {
+			return nil, fmt.Errorf("can't add %q: name starts with %q", p, whiteoutPrefix)
+		}
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		modTime := f.ModTime
+		if modTime.IsZero() {
+			modTime = time.Unix(0, 0)
+		}
+		hdrs = append(hdrs, &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     mode,
+			Size:     int64(len(f.Contents)),
+			ModTime:  modTime,
+			Uid:      f.UID,
+			Gid:      f.GID,
+		})
+		contents = append(contents, f.Contents)
+	}
+	return appendTarLayer(base, hdrs, contents, fmt.Sprintf("mutate.AddFiles %s", strings.Join(paths, " ")))
+}
+
+// cleanPath returns p relative to the root of the filesystem, as it appears
+// in a layer.
+func cleanPath(p string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if clean == "" {
+		return "", fmt.Errorf("invalid path %q", p)
+	}
+	return clean, nil
+}
+
+// appendTarLayer appends a layer made of hdrs, with the corresponding
+// contents, to base. The layer's media type matches base's manifest.
+func appendTarLayer(base v1.Image, hdrs []*tar.Header, contents [][]byte, createdBy string) (v1.Image, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i, hdr := range hdrs {
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if i < len(contents) {
+			if _, err := tw.Write(contents[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	b := buf.Bytes()
+
+	m, err := base.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var layerOpts []tarball.LayerOption
+	if m.MediaType == types.OCIManifestSchema1 {
+		layerOpts = append(layerOpts, tarball.WithMediaType(types.OCILayer))
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}, layerOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating layer: %w", err)
+	}
+	return Append(base, Addendum{
+		Layer:   layer,
+		History: v1.History{CreatedBy: createdBy},
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestDeletePathsAndAddFiles(t *testing.T) {
+	layer, err := mutate.LayerFromFS(fstest.MapFS{
+		"etc/secret":     {Data: []byte("hunter2")},
+		"etc/motd":       {Data: []byte("hello")},
+		"var/cache/a":    {Data: []byte("a")},
+		"var/cache/b/c":  {Data: []byte("c")},
+		"usr/bin/binary": {Data: []byte("binary"), Mode: 0755},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	base, err = mutate.AppendLayers(base, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := mutate.DeletePaths(base, "/etc/secret", "var/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.AddFiles(img, map[string]mutate.File{
+		"/etc/ssl/certs/ca.crt": {Contents: []byte("cert")},
+		"etc/motd":              {Contents: []byte("goodbye")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Fatalf("validate.Image: %v", err)
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Layers), 3; got != want {
+		t.Fatalf("len(Layers) = %d, want %d", got, want)
+	}
+	for _, l := range m.Layers[1:] {
+		if got, want := l.MediaType, types.OCILayer; got != want {
+			t.Errorf("layer MediaType = %s, want %s", got, want)
+		}
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cf.History[1].CreatedBy, "mutate.DeletePaths /etc/secret var/cache"; got != want {
+		t.Errorf("CreatedBy = %q, want %q", got, want)
+	}
+
+	got := map[string]string{}
+	rc := mutate.Extract(img)
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[hdr.Name] = string(b)
+	}
+	want := map[string]string{
+		"etc/motd":             "goodbye",
+		"etc/ssl/certs/ca.crt": "cert",
+		"usr/bin/binary":       "binary",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("contents (-want +got): %s", diff)
+	}
+}
+
+func TestAddFilesWhiteout(t *testing.T) {
+	if _, err := mutate.AddFiles(empty.Image, map[string]mutate.File{"etc/.wh.foo": {}}); err == nil {
+		t.Error("AddFiles() = nil, wanted error for a whiteout")
+	}
+}
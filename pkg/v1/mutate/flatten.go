@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// FlattenOption configures Flatten.
+type FlattenOption func(*flattenOptions)
+
+type flattenOptions struct {
+	createdBy string
+	layer     v1.Layer
+	layerOpts []tarball.LayerOption
+}
+
+// FlattenCreatedBy sets the CreatedBy of the flattened layer's history entry.
+// The default is "flatten <digest>", where digest is the original image's.
+func FlattenCreatedBy(createdBy string) FlattenOption {
+	return func(o *flattenOptions) {
+		o.createdBy = createdBy
+	}
+}
+
+// FlattenLayer uses layer as the flattened layer instead of computing it.
+// The layer must contain the contents of Extract for the original image, and
+// its media type should suit the original image's manifest.
+//
+// This is useful to avoid extracting the image more than once, e.g. by
+// passing a stream.Layer that has already been uploaded.
+func FlattenLayer(layer v1.Layer) FlattenOption {
+	return func(o *flattenOptions) {
+		o.layer = layer
+	}
+}
+
+// FlattenLayerOptions passes opts along when computing the flattened layer,
+// e.g. to set its compression. It has no effect with FlattenLayer.
+func FlattenLayerOptions(opts ...tarball.LayerOption) FlattenOption {
+	return func(o *flattenOptions) {
+		o.layerOpts = append(o.layerOpts, opts...)
+	}
+}
+
+// Flatten returns an image with the config and annotations of base and a
+// single layer containing its flattened filesystem, as returned by Extract.
+// Whiteouts in base are applied, so deleted files are absent rather than
+// whited out.
+//
+// The diff_ids and history of the config are replaced by those of the new
+// layer. The original history is kept, as JSON, in the new entry's comment.
+func Flatten(base v1.Image, opts ...FlattenOption) (v1.Image, error) {
+	o := &flattenOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	digest, err := base.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("getting digest: %w", err)
+	}
+	m, err := base.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+	cf = cf.DeepCopy()
+
+	oldHistory, err := json.Marshal(cf.History)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling history: %w", err)
+	}
+
+	layer := o.layer
+	if layer == nil {
+		layerOpts := o.layerOpts
+		if m.MediaType == types.OCIManifestSchema1 {
+			layerOpts = append([]tarball.LayerOption{tarball.WithMediaType(types.OCILayer)}, layerOpts...)
+		}
+		layer, err = tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return Extract(base), nil
+		}, layerOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating flattened layer: %w", err)
+		}
+	}
+
+	createdBy := o.createdBy
+	if createdBy == "" {
+		createdBy = fmt.Sprintf("flatten %s", digest)
+	}
+
+	// Clear layer-specific config file information.
+	cf.RootFS.DiffIDs = []v1.Hash{}
+	cf.History = []v1.History{}
+
+	img, err := ConfigFile(empty.Image, cf)
+	if err != nil {
+		return nil, fmt.Errorf("mutating config: %w", err)
+	}
+	if m.MediaType != "" {
+		img = MediaType(img, m.MediaType)
+	}
+	if m.Config.MediaType != "" {
+		img = ConfigMediaType(img, m.Config.MediaType)
+	}
+
+	img, err = Append(img, Addendum{
+		Layer: layer,
+		History: v1.History{
+			Created:   cf.Created,
+			CreatedBy: createdBy,
+			Comment:   string(oldHistory),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("appending layer: %w", err)
+	}
+
+	// Retain any annotations from the original image.
+	if len(m.Annotations) != 0 {
+		img = Annotations(img, m.Annotations).(v1.Image)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestFlatten(t *testing.T) {
+	bottom, err := mutate.LayerFromFS(fstest.MapFS{
+		"a.txt": {Data: []byte("a")},
+		"b.txt": {Data: []byte("b")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := mutate.LayerFromFS(fstest.MapFS{
+		".wh.a.txt": {},
+		"c.txt":     {Data: []byte("c")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	base = mutate.ConfigMediaType(base, types.OCIConfigJSON)
+	base, err = mutate.Append(base,
+		mutate.Addendum{Layer: bottom, History: v1.History{CreatedBy: "bottom"}},
+		mutate.Addendum{Layer: top, History: v1.History{CreatedBy: "top"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err = mutate.Config(base, v1.Config{Env: []string{"A=B"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base = mutate.Annotations(base, map[string]string{"foo": "bar"}).(v1.Image)
+
+	flat, err := mutate.Flatten(base, mutate.FlattenCreatedBy("squash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(flat); err != nil {
+		t.Fatalf("validate.Image: %v", err)
+	}
+
+	m, err := flat.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.MediaType, types.OCIManifestSchema1; got != want {
+		t.Errorf("MediaType = %s, want %s", got, want)
+	}
+	if got, want := len(m.Layers), 1; got != want {
+		t.Fatalf("len(Layers) = %d, want %d", got, want)
+	}
+	if got, want := m.Layers[0].MediaType, types.OCILayer; got != want {
+		t.Errorf("layer MediaType = %s, want %s", got, want)
+	}
+	if diff := cmp.Diff(map[string]string{"foo": "bar"}, m.Annotations); diff != "" {
+		t.Errorf("Annotations (-want +got): %s", diff)
+	}
+
+	cf, err := flat.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"A=B"}, cf.Config.Env); diff != "" {
+		t.Errorf("Env (-want +got): %s", diff)
+	}
+	if got, want := len(cf.RootFS.DiffIDs), 1; got != want {
+		t.Errorf("len(DiffIDs) = %d, want %d", got, want)
+	}
+	if got, want := len(cf.History), 1; got != want {
+		t.Fatalf("len(History) = %d, want %d", got, want)
+	}
+	if got, want := cf.History[0].CreatedBy, "squash"; got != want {
+		t.Errorf("CreatedBy = %q, want %q", got, want)
+	}
+	if !strings.Contains(cf.History[0].Comment, `"bottom"`) || !strings.Contains(cf.History[0].Comment, `"top"`) {
+		t.Errorf("Comment = %q, want original history", cf.History[0].Comment)
+	}
+
+	layers, err := flat.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got := map[string]string{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[hdr.Name] = string(b)
+	}
+	want := map[string]string{"b.txt": "b", "c.txt": "c"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("flattened contents (-want +got): %s", diff)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// FSOption is a functional option for LayerFromFS.
+type FSOption func(*fsOptions)
+
+type fsOptions struct {
+	uid, gid  int
+	modTime   time.Time
+	prefix    string
+	layerOpts []tarball.LayerOption
+}
+
+// WithOwner sets the uid and gid of every entry in the layer.
+//
+// The default is 0 (root) for both.
+func WithOwner(uid, gid int) FSOption {
+	return func(o *fsOptions) {
+		o.uid, o.gid = uid, gid
+	}
+}
+
+// WithModTime sets the modification time of every entry in the layer.
+//
+// The default is the Unix epoch.
+func WithModTime(t time.Time) FSOption {
+	return func(o *fsOptions) {
+		o.modTime = t
+	}
+}
+
+// WithPrefix places the contents of the filesystem under dir in the layer,
+// e.g. "/app". Entries for dir and its parents are included.
+func WithPrefix(dir string) FSOption {
+	return func(o *fsOptions) {
+		o.prefix = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	}
+}
+
+// WithLayerOptions passes the given options through to
+// tarball.LayerFromOpener, e.g. to set the media type of the layer.
+func WithLayerOptions(opts ...tarball.LayerOption) FSOption {
+	return func(o *fsOptions) {
+		o.layerOpts = append(o.layerOpts, opts...)
+	}
+}
+
+// LayerFromFS returns a layer containing the files and directories of fsys.
+//
+// The layer is reproducible: entries are written in lexical order, and
+// ownership and modification times are normalized (see WithOwner and
+// WithModTime), so the same tree always produces the same digest. Only
+// permission bits are kept from each entry's mode. Only regular files and
+// directories are supported.
+func LayerFromFS(fsys fs.FS, opts ...FSOption) (v1.Layer, error) {
+	o := &fsOptions{
+		modTime: time.Unix(0, 0),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	w := new(bytes.Buffer)
+	tw := tar.NewWriter(w)
+
+	if o.prefix != "" {
+		var parent string
+		for _, elem := range strings.Split(o.prefix, "/") {
+			parent = path.Join(parent, elem)
+			if err := tw.WriteHeader(o.header(parent+"/", tar.TypeDir, 0755, 0)); err != nil {
+				return nil, fmt.Errorf("writing tar header: %w", err)
+			}
+		}
+	}
+
+	// fs.WalkDir visits entries in lexical order.
+	if err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := path.Join(o.prefix, p)
+		mode := int64(info.Mode().Perm())
+
+		switch {
+		case info.IsDir():
+			return tw.WriteHeader(o.header(name+"/", tar.TypeDir, mode, 0))
+		case info.Mode().IsRegular():
+			f, err := fsys.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := tw.WriteHeader(o.header(name, tar.TypeReg, mode, info.Size())); err != nil {
+				return err
+			}
+			_, err = io.CopyN(tw, f, info.Size())
+			return err
+		default:
+			return fmt.Errorf("%s: unsupported file type %s", p, info.Mode().Type())
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("writing layer: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	b := w.Bytes()
+	opener := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	layer, err := tarball.LayerFromOpener(opener, o.layerOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating layer: %w", err)
+	}
+	return layer, nil
+}
+
+func (o *fsOptions) header(name string, typ byte, mode, size int64) *tar.Header {
+	return &tar.Header{
+		Name:     name,
+		Typeflag: typ,
+		Mode:     mode,
+		Size:     size,
+		Uid:      o.uid,
+		Gid:      o.gid,
+		ModTime:  o.modTime,
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestLayerFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bin/app":         {Data: []byte("binary"), Mode: 0755, ModTime: time.Now()},
+		"etc/app/config":  {Data: []byte("config"), Mode: 0644, ModTime: time.Now()},
+		"etc/app/secrets": {Data: []byte("shh"), Mode: 0600},
+	}
+
+	l, err := mutate.LayerFromFS(fsys, mutate.WithPrefix("/opt/app/"), mutate.WithOwner(65532, 65532))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Layer(l); err != nil {
+		t.Fatalf("validate.Layer: %v", err)
+	}
+
+	type entry struct {
+		Name     string
+		Mode     int64
+		Uid, Gid int
+		ModTime  int64
+		Content  string
+	}
+	rc, err := l.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var got []entry
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, entry{hdr.Name, hdr.Mode, hdr.Uid, hdr.Gid, hdr.ModTime.Unix(), string(b)})
+	}
+	want := []entry{
+		{"opt/", 0755, 65532, 65532, 0, ""},
+		{"opt/app/", 0755, 65532, 65532, 0, ""},
+		{"opt/app/bin/", 0555, 65532, 65532, 0, ""},
+		{"opt/app/bin/app", 0755, 65532, 65532, 0, "binary"},
+		{"opt/app/etc/", 0555, 65532, 65532, 0, ""},
+		{"opt/app/etc/app/", 0555, 65532, 65532, 0, ""},
+		{"opt/app/etc/app/config", 0644, 65532, 65532, 0, "config"},
+		{"opt/app/etc/app/secrets", 0600, 65532, 65532, 0, "shh"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries (-want +got): %s", diff)
+	}
+}
+
+func TestLayerFromFSReproducible(t *testing.T) {
+	a := fstest.MapFS{
+		"a": {Data: []byte("a"), ModTime: time.Now()},
+		"b": {Data: []byte("b"), ModTime: time.Now().Add(-time.Hour)},
+	}
+	b := fstest.MapFS{
+		"b": {Data: []byte("b")},
+		"a": {Data: []byte("a")},
+	}
+	digest := func(fsys fs.FS, opts ...mutate.FSOption) string {
+		t.Helper()
+		l, err := mutate.LayerFromFS(fsys, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.String()
+	}
+
+	if da, db := digest(a), digest(b); da != db {
+		t.Errorf("digests differ for equivalent trees: %s != %s", da, db)
+	}
+	if da, db := digest(a), digest(a, mutate.WithModTime(time.Unix(1, 0))); da == db {
+		t.Errorf("WithModTime did not change digest %s", da)
+	}
+
+	l, err := mutate.LayerFromFS(a, mutate.WithLayerOptions(tarball.WithMediaType(types.OCILayer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt, err := l.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if mt != types.OCILayer {
+		t.Errorf("MediaType() = %s, want %s", mt, types.OCILayer)
+	}
+}
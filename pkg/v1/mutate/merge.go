@@ -0,0 +1,241 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// MergePolicy decides whose value Merge keeps when both images set one.
+type MergePolicy int
+
+const (
+	// PreferBase keeps the base image's value.
+	PreferBase MergePolicy = iota
+	// PreferOverlay keeps the overlay image's value.
+	PreferOverlay
+)
+
+// MergeOption configures how Merge combines the configs of two images.
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	env    MergePolicy
+	labels MergePolicy
+	config MergePolicy
+}
+
+// MergeEnv sets whose value to keep for environment variables that both
+// images set. Variables that only one image sets are always kept. The default
+// is PreferOverlay.
+func MergeEnv(p MergePolicy) MergeOption {
+	return func(o *mergeOptions) {
+		o.env = p
+	}
+}
+
+// MergeLabels sets whose value to keep for labels that both images set.
+// Labels that only one image sets are always kept. The default is
+// PreferOverlay.
+func MergeLabels(p MergePolicy) MergeOption {
+	return func(o *mergeOptions) {
+		o.labels = p
+	}
+}
+
+// MergeConfig sets whose value to keep for the rest of the config that
+// describes how to run the image: the Entrypoint and Cmd, which are kept
+// together, WorkingDir, User, StopSignal, Healthcheck and Shell. Values that
+// only one image sets are kept. ExposedPorts and Volumes are always combined.
+// The default is PreferBase, so the merged image runs like the base image.
+func MergeConfig(p MergePolicy) MergeOption {
+	return func(o *mergeOptions) {
+		o.config = p
+	}
+}
+
+// Merge returns an image with the layers of base followed by the layers of
+// overlay, e.g. to add the files of a tool image to a base image. The merged
+// image's config is base's, combined with overlay's according to opts, and
+// its history is base's followed by overlay's.
+//
+// The images must be for the same platform.
+func Merge(base, overlay v1.Image, opts ...MergeOption) (v1.Image, error) {
+	o := &mergeOptions{
+		env:    PreferOverlay,
+		labels: PreferOverlay,
+		config: PreferBase,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	baseConfig, err := base.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config for base: %w", err)
+	}
+	overlayConfig, err := overlay.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config for overlay: %w", err)
+	}
+	if err := samePlatform(baseConfig, overlayConfig); err != nil {
+		return nil, err
+	}
+
+	overlayLayers, err := overlay.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layers for overlay: %w", err)
+	}
+	merged, err := Append(base, createAddendums(0, 0, overlayConfig.History, overlayLayers)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append overlay: %w", err)
+	}
+
+	mergedConfig, err := merged.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("could not get config for merged image: %w", err)
+	}
+	mergedConfig = mergedConfig.DeepCopy()
+	mergedConfig.Config = mergeConfig(baseConfig.Config, overlayConfig.Config, o)
+
+	return ConfigFile(merged, mergedConfig)
+}
+
+// samePlatform returns an error if the images have conflicting platforms.
+func samePlatform(base, overlay *v1.ConfigFile) error {
+	for _, f := range []struct {
+		field, base, overlay string
+	}{
+		{"os", base.OS, overlay.OS},
+		{"architecture", base.Architecture, overlay.Architecture},
+		{"variant", base.Variant, overlay.Variant},
+	} {
+		if f.base != "" && f.overlay != "" && f.base != f.overlay {
+			return fmt.Errorf("cannot merge images with different %s: %q and %q", f.field, f.base, f.overlay)
+		}
+	}
+	return nil
+}
+
+func mergeConfig(base, overlay v1.Config, o *mergeOptions) v1.Config {
+	base, overlay = *base.DeepCopy(), *overlay.DeepCopy()
+	cfg := base
+
+	cfg.Env = mergeEnv(base.Env, overlay.Env, o.env)
+	cfg.Labels = mergeMaps(base.Labels, overlay.Labels, o.labels)
+	cfg.ExposedPorts = mergeSets(base.ExposedPorts, overlay.ExposedPorts)
+	cfg.Volumes = mergeSets(base.Volumes, overlay.Volumes)
+
+	// The preferred image's values win, but fill in whatever it doesn't set
+	// from the other image.
+	preferred, other := base, overlay
+	if o.config == PreferOverlay {
+		preferred, other = overlay, base
+	}
+	// Entrypoint and Cmd only make sense together.
+	if len(preferred.Entrypoint) != 0 || len(preferred.Cmd) != 0 {
+		cfg.Entrypoint, cfg.Cmd, cfg.ArgsEscaped = preferred.Entrypoint, preferred.Cmd, preferred.ArgsEscaped
+	} else {
+		cfg.Entrypoint, cfg.Cmd, cfg.ArgsEscaped = other.Entrypoint, other.Cmd, other.ArgsEscaped
+	}
+	cfg.WorkingDir = preferString(preferred.WorkingDir, other.WorkingDir)
+	cfg.User = preferString(preferred.User, other.User)
+	cfg.StopSignal = preferString(preferred.StopSignal, other.StopSignal)
+	cfg.Healthcheck = preferred.Healthcheck
+	if cfg.Healthcheck == nil {
+		cfg.Healthcheck = other.Healthcheck
+	}
+	cfg.Shell = preferred.Shell
+	if len(cfg.Shell) == 0 {
+		cfg.Shell = other.Shell
+	}
+	return cfg
+}
+
+func preferString(preferred, other string) string {
+	if preferred != "" {
+		return preferred
+	}
+	return other
+}
+
+// mergeEnv combines two lists of KEY=value environment variables, keeping
+// base's order and adding overlay's new variables after it.
+func mergeEnv(base, overlay []string, p MergePolicy) []string {
+	if len(overlay) == 0 {
+		return base
+	}
+	key := func(kv string) string {
+		if i := strings.Index(kv, "="); i >= 0 {
+			return kv[:i]
+		}
+		return kv
+	}
+
+	overlayByKey := map[string]string{}
+	for _, kv := range overlay {
+		overlayByKey[key(kv)] = kv
+	}
+	env := []string{}
+	seen := map[string]bool{}
+	for _, kv := range base {
+		k := key(kv)
+		seen[k] = true
+		if okv, ok := overlayByKey[k]; ok && p == PreferOverlay {
+			kv = okv
+		}
+		env = append(env, kv)
+	}
+	for _, kv := range overlay {
+		if k := key(kv); !seen[k] {
+			seen[k] = true
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+func mergeMaps(base, overlay map[string]string, p MergePolicy) map[string]string {
+	if len(base) == 0 && len(overlay) == 0 {
+		return base
+	}
+	m := map[string]string{}
+	for k, v := range base {
+		m[k] = v
+	}
+	for k, v := range overlay {
+		if _, ok := m[k]; !ok || p == PreferOverlay {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+func mergeSets(base, overlay map[string]struct{}) map[string]struct{} {
+	if len(overlay) == 0 {
+		return base
+	}
+	m := map[string]struct{}{}
+	for k := range base {
+		m[k] = struct{}{}
+	}
+	for k := range overlay {
+		m[k] = struct{}{}
+	}
+	return m
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// mergeImage returns a random image with cfg and a history entry for each
+// layer, plus an empty layer's entry.
+func mergeImage(t *testing.T, prefix string, cfg v1.Config) v1.Image {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS, cf.Architecture = "linux", "amd64"
+	cf.Config = cfg
+	cf.History = []v1.History{
+		{CreatedBy: prefix + " layer 0"},
+		{CreatedBy: prefix + " ENV", EmptyLayer: true},
+		{CreatedBy: prefix + " layer 1"},
+	}
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestMerge(t *testing.T) {
+	base := mergeImage(t, "base", v1.Config{
+		Env:        []string{"PATH=/usr/bin", "LANG=C"},
+		Labels:     map[string]string{"owner": "base", "tier": "runtime"},
+		Entrypoint: []string{"/server"},
+		WorkingDir: "/srv",
+	})
+	overlay := mergeImage(t, "overlay", v1.Config{
+		Env:        []string{"PATH=/tools/bin", "TOOL=1"},
+		Labels:     map[string]string{"owner": "tools"},
+		Cmd:        []string{"tool", "--help"},
+		User:       "tool",
+		WorkingDir: "/tools",
+	})
+
+	for _, tc := range []struct {
+		name string
+		opts []mutate.MergeOption
+		want v1.Config
+	}{{
+		name: "defaults",
+		want: v1.Config{
+			Env:        []string{"PATH=/tools/bin", "LANG=C", "TOOL=1"},
+			Labels:     map[string]string{"owner": "tools", "tier": "runtime"},
+			Entrypoint: []string{"/server"},
+			WorkingDir: "/srv",
+			User:       "tool",
+		},
+	}, {
+		name: "prefer base env and labels",
+		opts: []mutate.MergeOption{mutate.MergeEnv(mutate.PreferBase), mutate.MergeLabels(mutate.PreferBase)},
+		want: v1.Config{
+			Env:        []string{"PATH=/usr/bin", "LANG=C", "TOOL=1"},
+			Labels:     map[string]string{"owner": "base", "tier": "runtime"},
+			Entrypoint: []string{"/server"},
+			WorkingDir: "/srv",
+			User:       "tool",
+		},
+	}, {
+		name: "prefer overlay config",
+		opts: []mutate.MergeOption{mutate.MergeConfig(mutate.PreferOverlay)},
+		want: v1.Config{
+			Env:        []string{"PATH=/tools/bin", "LANG=C", "TOOL=1"},
+			Labels:     map[string]string{"owner": "tools", "tier": "runtime"},
+			Cmd:        []string{"tool", "--help"},
+			WorkingDir: "/tools",
+			User:       "tool",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, err := mutate.Merge(base, overlay, tc.opts...)
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			if err := validate.Image(merged); err != nil {
+				t.Errorf("validate.Image: %v", err)
+			}
+
+			want := append(layerDigests(t, base), layerDigests(t, overlay)...)
+			if diff := cmp.Diff(want, layerDigests(t, merged)); diff != "" {
+				t.Errorf("layers (-want +got) = %s", diff)
+			}
+
+			cf, err := merged.ConfigFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, cf.Config); diff != "" {
+				t.Errorf("config (-want +got) = %s", diff)
+			}
+			gotHistory := []string{}
+			for _, h := range cf.History {
+				gotHistory = append(gotHistory, h.CreatedBy)
+			}
+			wantHistory := []string{"base layer 0", "base ENV", "base layer 1", "overlay layer 0", "overlay ENV", "overlay layer 1"}
+			if diff := cmp.Diff(wantHistory, gotHistory); diff != "" {
+				t.Errorf("history (-want +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeDifferentPlatforms(t *testing.T) {
+	base := mergeImage(t, "base", v1.Config{})
+	overlay := mergeImage(t, "overlay", v1.Config{})
+	cf, err := overlay.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.Architecture = "arm64"
+	overlay, err = mutate.ConfigFile(overlay, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mutate.Merge(base, overlay); err == nil {
+		t.Error("Merge of amd64 and arm64 images: expected error")
+	}
+}
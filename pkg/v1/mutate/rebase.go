@@ -134,9 +134,10 @@ func createAddendums(startHistory, startLayer int, history []v1.History, layers
 		}
 	}
 	// In the event history was malformed or non-existent, append the remaining layers.
+	// Like layerIndex above, startLayer counts layers from one.
 	for i := layerIndex; i < len(layers); i++ {
-		if i >= startLayer {
-			adds = append(adds, Addendum{Layer: layers[layerIndex]})
+		if i+1 >= startLayer {
+			adds = append(adds, Addendum{Layer: layers[i]})
 		}
 	}
 
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
@@ -177,3 +178,58 @@ func TestRebase(t *testing.T) {
 		t.Errorf("ConfigFile property OSVersion mismatch, got %q, want %q", rebasedConfig.OSVersion, newBaseConfig.OSVersion)
 	}
 }
+
+// withoutHistory returns img with the history removed from its config, as in
+// images built by tools that don't record it.
+func withoutHistory(t *testing.T, img v1.Image) v1.Image {
+	t.Helper()
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	cf = cf.DeepCopy()
+	cf.History = nil
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatalf("mutate.ConfigFile: %v", err)
+	}
+	return img
+}
+
+// TestRebaseWithoutHistory tests that every layer is kept when rebasing images
+// without any history to say which layers they have.
+func TestRebaseWithoutHistory(t *testing.T) {
+	oldBase, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (oldBase): %v", err)
+	}
+	oldBase = withoutHistory(t, oldBase)
+	top, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (top): %v", err)
+	}
+	topLayers, err := top.Layers()
+	if err != nil {
+		t.Fatalf("top.Layers: %v", err)
+	}
+	orig, err := mutate.AppendLayers(oldBase, topLayers...)
+	if err != nil {
+		t.Fatalf("AppendLayers: %v", err)
+	}
+	orig = withoutHistory(t, orig)
+	newBase, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatalf("random.Image (newBase): %v", err)
+	}
+	newBase = withoutHistory(t, newBase)
+
+	rebased, err := mutate.Rebase(orig, oldBase, newBase)
+	if err != nil {
+		t.Fatalf("Rebase: %v", err)
+	}
+
+	want := append(layerDigests(t, newBase), layerDigests(t, top)...)
+	if diff := cmp.Diff(want, layerDigests(t, rebased)); diff != "" {
+		t.Errorf("rebased layers (-want +got) = %s", diff)
+	}
+}
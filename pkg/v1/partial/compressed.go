@@ -19,6 +19,7 @@ import (
 
 	"github.com/google/go-containerregistry/internal/and"
 	"github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/internal/zstd"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -52,8 +53,8 @@ func (cle *compressedLayerExtender) Uncompressed() (io.ReadCloser, error) {
 	}
 
 	// Often, the "compressed" bytes are not actually gzip-compressed.
-	// Peek at the first two bytes to determine whether or not it's correct to
-	// wrap this with gzip.UnzipReadCloser.
+	// Peek at the first few bytes to determine whether or not it's correct to
+	// wrap this with gzip.UnzipReadCloser or zstd.UnzipReadCloser.
 	gzipped, pr, err := gzip.Peek(rc)
 	if err != nil {
 		return nil, err
@@ -62,12 +63,19 @@ func (cle *compressedLayerExtender) Uncompressed() (io.ReadCloser, error) {
 		Reader:    pr,
 		CloseFunc: rc.Close,
 	}
-
-	if !gzipped {
-		return prc, nil
+	if gzipped {
+		return gzip.UnzipReadCloser(prc)
 	}
 
-	return gzip.UnzipReadCloser(prc)
+	zstdCompressed, zpr, err := zstd.Peek(pr)
+	if err != nil {
+		return nil, err
+	}
+	prc.Reader = zpr
+	if zstdCompressed {
+		return zstd.UnzipReadCloser(prc)
+	}
+	return prc, nil
 }
 
 // DiffID implements v1.Layer
@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// TotalSize returns the compressed size of an image: its manifest plus every
+// distinct blob it references, as recorded in the manifest's descriptors.
+// Layer contents are never fetched.
+func TotalSize(i WithRawManifest) (int64, error) {
+	return totalSize(i, map[v1.Hash]bool{})
+}
+
+// IndexTotalSize returns the compressed size of an index: its manifest plus
+// the total size of every child, counting blobs shared between children only
+// once. Child manifests are fetched, but layer contents are not.
+func IndexTotalSize(idx v1.ImageIndex) (int64, error) {
+	return indexTotalSize(idx, map[v1.Hash]bool{})
+}
+
+func totalSize(i WithRawManifest, seen map[v1.Hash]bool) (int64, error) {
+	total, err := Size(i)
+	if err != nil {
+		return 0, err
+	}
+	m, err := Manifest(i)
+	if err != nil {
+		return 0, err
+	}
+	for _, desc := range append([]v1.Descriptor{m.Config}, m.Layers...) {
+		if seen[desc.Digest] {
+			continue
+		}
+		seen[desc.Digest] = true
+		total += desc.Size
+	}
+	return total, nil
+}
+
+func indexTotalSize(idx v1.ImageIndex, seen map[v1.Hash]bool) (int64, error) {
+	total, err := idx.Size()
+	if err != nil {
+		return 0, err
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return 0, err
+	}
+	for _, desc := range m.Manifests {
+		if seen[desc.Digest] {
+			continue
+		}
+		seen[desc.Digest] = true
+
+		var size int64
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return 0, err
+			}
+			size, err = indexTotalSize(child, seen)
+			if err != nil {
+				return 0, err
+			}
+		case desc.MediaType.IsImage():
+			child, err := idx.Image(desc.Digest)
+			if err != nil {
+				return 0, err
+			}
+			size, err = totalSize(child, seen)
+			if err != nil {
+				return 0, err
+			}
+		default:
+			size = desc.Size
+		}
+		total += size
+	}
+	return total, nil
+}
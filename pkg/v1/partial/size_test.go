@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// manifestSize sums the sizes recorded in img's manifest by hand.
+func manifestSize(t *testing.T, img v1.Image) int64 {
+	t.Helper()
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := img.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size += m.Config.Size
+	for _, l := range m.Layers {
+		size += l.Size
+	}
+	return size
+}
+
+func TestTotalSize(t *testing.T) {
+	img, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := manifestSize(t, img)
+
+	got, err := partial.TotalSize(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("TotalSize() = %d, want %d", got, want)
+	}
+
+	// Appending an existing layer again shouldn't count it twice.
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, err := mutate.AppendLayers(img, layers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := layers[0].Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = partial.TotalSize(dup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := manifestSize(t, dup) - size; got != want {
+		t.Errorf("TotalSize(dup) = %d, want %d", got, want)
+	}
+}
+
+func TestIndexTotalSize(t *testing.T) {
+	idx, err := random.Index(100, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := idx.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range m.Manifests {
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += manifestSize(t, img)
+	}
+
+	got, err := partial.IndexTotalSize(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("IndexTotalSize() = %d, want %d", got, want)
+	}
+
+	// Nesting the index, alongside one of its own images, should only add
+	// the size of the outer index manifest.
+	img, err := idx.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: idx},
+		mutate.IndexAddendum{Add: img},
+	)
+	outerSize, err := outer.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = partial.IndexTotalSize(outer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := want + outerSize; got != want {
+		t.Errorf("IndexTotalSize(outer) = %d, want %d", got, want)
+	}
+}
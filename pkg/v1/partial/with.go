@@ -383,6 +383,24 @@ func Exists(l v1.Layer) (bool, error) {
 	return true, nil
 }
 
+type withRangeReader interface {
+	RangeReader(off, n int64) (io.ReadCloser, error)
+}
+
+// RangeReader returns a reader for n bytes of l's compressed contents,
+// starting at off, or fewer if they end first. This lets callers read part of
+// a large layer, e.g. a single file, without reading all of it.
+//
+// It returns an error if l doesn't support reading ranges. Layers read from a
+// registry do, using Range requests. Since only part of the layer is read, it
+// isn't verified against the layer's digest.
+func RangeReader(l v1.Layer, off, n int64) (io.ReadCloser, error) {
+	if wrr, ok := unwrap(l).(withRangeReader); ok {
+		return wrr.RangeReader(off, n)
+	}
+	return nil, fmt.Errorf("reading ranges of %T is not supported", l)
+}
+
 // Recursively unwrap our wrappers so that we can check for the original implementation.
 // We might want to expose this?
 func unwrap(i interface{}) interface{} {
@@ -0,0 +1,219 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// referrersTagAttempts is how many times PushArtifact tries to update the
+// referrers tag when other pushes keep moving it.
+const referrersTagAttempts = 3
+
+// PushArtifact pushes an OCI artifact of type artifactType that refers to
+// subject: an image manifest whose layers are blobs and whose config is the
+// empty JSON object. It returns the artifact's descriptor.
+//
+// If ref is a tag, the artifact is tagged with it. If ref is a digest, it must
+// be the artifact's.
+//
+// If the registry doesn't say that it processed the subject, the artifact is
+// also added to the index tagged sha256-<hex> after the subject's digest, as
+// the referrers tag schema describes, like any other manifest with a subject
+// written by this package:
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#referrers-tag-schema
+func PushArtifact(ref name.Reference, artifactType string, subject v1.Descriptor, blobs []v1.Layer, options ...Option) (desc *v1.Descriptor, rerr error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	img, err := newArtifact(artifactType, subject, blobs)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	h, size, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	desc = &v1.Descriptor{
+		MediaType:    types.OCIManifestSchema1,
+		Digest:       h,
+		Size:         size,
+		ArtifactType: artifactType,
+	}
+
+	if d, ok := ref.(name.Digest); ok && d.DigestStr() != h.String() {
+		return nil, fmt.Errorf("artifact digest %s does not match %s", h, ref)
+	}
+
+	var p *progress
+	if o.updates != nil {
+		p = &progress{updates: o.updates}
+		p.lastUpdate = &v1.Update{}
+		p.lastUpdate.Total, err = countImage(img, o.allowNondistributableArtifacts)
+		if err != nil {
+			return nil, err
+		}
+		defer close(o.updates)
+		defer func() { _ = p.err(rerr) }()
+	}
+	if o.events != nil {
+		defer close(o.events)
+	}
+	if err := makeSizeLimits(ref.Context().Registry, o).check(ref.Context().Registry, img, o.allowNondistributableArtifacts); err != nil {
+		return nil, err
+	}
+
+	// Converting the manifest would drop its subject, so don't.
+	if err := writeImage(o.context, ref, img, o, p, nil, nil); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// artifact implements partial.CompressedImageCore for PushArtifact.
+type artifact struct {
+	manifest []byte
+	blobs    map[v1.Hash]v1.Layer
+}
+
+var emptyJSON = []byte("{}")
+
+func newArtifact(artifactType string, subject v1.Descriptor, blobs []v1.Layer) (v1.Image, error) {
+	empty := static.NewLayer(emptyJSON, types.OCIEmptyJSON)
+	config, err := partial.Descriptor(empty)
+	if err != nil {
+		return nil, err
+	}
+	m := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		ArtifactType:  artifactType,
+		Config:        *config,
+		Subject:       &subject,
+	}
+	a := &artifact{blobs: map[v1.Hash]v1.Layer{config.Digest: empty}}
+	if len(blobs) == 0 {
+		// Manifests should have at least one layer, so use the empty one.
+		blobs = []v1.Layer{empty}
+	}
+	for _, b := range blobs {
+		d, err := partial.Descriptor(b)
+		if err != nil {
+			return nil, err
+		}
+		m.Layers = append(m.Layers, *d)
+		a.blobs[d.Digest] = b
+	}
+	if a.manifest, err = json.Marshal(m); err != nil {
+		return nil, err
+	}
+	return partial.CompressedToImage(a)
+}
+
+// RawConfigFile implements partial.CompressedImageCore.
+func (a *artifact) RawConfigFile() ([]byte, error) {
+	return emptyJSON, nil
+}
+
+// MediaType implements partial.CompressedImageCore.
+func (a *artifact) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+// RawManifest implements partial.CompressedImageCore.
+func (a *artifact) RawManifest() ([]byte, error) {
+	return a.manifest, nil
+}
+
+// LayerByDigest implements partial.CompressedImageCore.
+func (a *artifact) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	l, ok := a.blobs[h]
+	if !ok {
+		return nil, fmt.Errorf("artifact has no blob %s", h)
+	}
+	return l, nil
+}
+
+// addReferrer adds desc to the index tagged with the referrers tag schema for
+// subject in w's repository. The tag is only moved if nothing else has moved
+// it since it was read, so that concurrent pushes don't drop each other's
+// referrers.
+func (w *writer) addReferrer(ctx context.Context, subject v1.Hash, desc v1.Descriptor) error {
+	tag := w.repo.Tag(strings.Replace(subject.String(), ":", "-", 1))
+	// Don't count the referrers tag as progress, or convert it.
+	rw := *w
+	rw.progress = nil
+	rw.conv = nil
+	f := fetcher{Ref: tag, Client: w.client, context: ctx}
+
+	for attempt := 1; ; attempt++ {
+		index := &v1.IndexManifest{SchemaVersion: 2, MediaType: types.OCIImageIndex}
+		var current v1.Hash
+		raw, d, err := f.fetchManifest(tag, []types.MediaType{types.OCIImageIndex})
+		switch {
+		case IsNotFound(err):
+		case err != nil:
+			return err
+		default:
+			current = d.Digest
+			if index, err = v1.ParseIndexManifest(bytes.NewReader(raw)); err != nil {
+				return err
+			}
+		}
+		for _, m := range index.Manifests {
+			if m.Digest == desc.Digest {
+				return nil
+			}
+		}
+		index.Manifests = append(index.Manifests, desc)
+
+		b, err := json.Marshal(index)
+		if err != nil {
+			return err
+		}
+		h, size, err := v1.SHA256(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		rw.expected = &current
+		err = rw.commitManifest(ctx, &Descriptor{
+			Descriptor: v1.Descriptor{MediaType: types.OCIImageIndex, Digest: h, Size: size},
+			Manifest:   b,
+		}, tag)
+		var conflict *TagConflictError
+		if errors.As(err, &conflict) && attempt < referrersTagAttempts {
+			logs.Warn.Printf("%v, retrying", err)
+			continue
+		}
+		return err
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// pushSubject pushes a random image to repo and returns its descriptor.
+func pushSubject(t *testing.T, repo name.Repository) v1.Descriptor {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(repo.Tag("latest"), img); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *desc
+}
+
+func TestPushArtifact(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/artifact", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := pushSubject(t, repo)
+
+	sig := static.NewLayer([]byte("signature"), "application/vnd.example.signature")
+	rec := &CapabilityRecorder{}
+	desc, err := PushArtifact(repo.Tag("sig"), "application/vnd.example.sig", subject, []v1.Layer{sig}, WithCapabilityRecorder(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Capabilities(repo.Registry).Subject; got != CapabilityUnsupported {
+		t.Errorf("Subject capability = %v, want %v", got, CapabilityUnsupported)
+	}
+
+	got, err := Get(repo.Digest(desc.Digest.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := v1.ParseManifest(strings.NewReader(string(got.Manifest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ArtifactType != "application/vnd.example.sig" {
+		t.Errorf("ArtifactType = %q", m.ArtifactType)
+	}
+	if m.Subject == nil || m.Subject.Digest != subject.Digest {
+		t.Errorf("Subject = %v, want %s", m.Subject, subject.Digest)
+	}
+	if m.Config.MediaType != types.OCIEmptyJSON || m.Config.Size != 2 {
+		t.Errorf("Config = %v, want the empty descriptor", m.Config)
+	}
+	if len(m.Layers) != 1 || m.Layers[0].MediaType != "application/vnd.example.signature" {
+		t.Errorf("Layers = %v", m.Layers)
+	}
+	if _, err := Get(repo.Tag("sig")); err != nil {
+		t.Errorf("artifact wasn't tagged: %v", err)
+	}
+
+	// The registry doesn't process subjects, so the referrers tag lists the
+	// artifact.
+	referrers := func() []v1.Descriptor {
+		t.Helper()
+		tag := repo.Tag(strings.Replace(subject.Digest.String(), ":", "-", 1))
+		idx, err := Index(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := idx.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return im.Manifests
+	}
+	if rs := referrers(); len(rs) != 1 || rs[0].Digest != desc.Digest || rs[0].ArtifactType != desc.ArtifactType {
+		t.Errorf("referrers = %v, want %v", rs, desc)
+	}
+
+	// Another artifact is added to the same tag, without any blobs.
+	other, err := PushArtifact(repo.Tag("sbom"), "application/vnd.example.sbom", subject, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs := referrers(); len(rs) != 2 || rs[1].Digest != other.Digest {
+		t.Errorf("referrers = %v, want 2 ending with %v", rs, other)
+	}
+	img, err := Image(repo.Digest(other.Digest.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	om, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(om.Layers) != 1 || om.Layers[0].MediaType != types.OCIEmptyJSON {
+		t.Errorf("Layers = %v, want the empty descriptor", om.Layers)
+	}
+
+	// Pushing an artifact again doesn't list it twice.
+	if _, err := PushArtifact(repo.Digest(desc.Digest.String()), "application/vnd.example.sig", subject, []v1.Layer{sig}); err != nil {
+		t.Fatal(err)
+	}
+	if rs := referrers(); len(rs) != 2 {
+		t.Errorf("referrers = %v, want 2", rs)
+	}
+
+	// A digest has to match.
+	if _, err := PushArtifact(repo.Digest(subject.Digest.String()), "application/vnd.example.sig", subject, []v1.Layer{sig}); err == nil {
+		t.Error("PushArtifact() with mismatched digest = nil, wanted error")
+	}
+}
+
+func TestPushArtifactSubjectSupported(t *testing.T) {
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") {
+			w.Header().Set("OCI-Subject", "sha256:whatever")
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/artifact", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := pushSubject(t, repo)
+
+	if _, err := PushArtifact(repo.Tag("sig"), "application/vnd.example.sig", subject, nil); err != nil {
+		t.Fatal(err)
+	}
+	tag := repo.Tag(strings.Replace(subject.Digest.String(), ":", "-", 1))
+	if _, err := Head(tag); !IsNotFound(err) {
+		t.Errorf("Head(%s) = %v, wanted not found", tag, err)
+	}
+}
+
+func TestPutSubjectReferrersTag(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/referrer", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := pushSubject(t, repo)
+
+	// Any manifest with a subject is added to the referrers tag, not just
+	// those pushed with PushArtifact.
+	sig := static.NewLayer([]byte("signature"), "application/vnd.example.signature")
+	img, err := newArtifact("", subject, []v1.Layer{sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.ConfigMediaType(img, "application/vnd.example.config")
+	img = mutate.Annotations(img, map[string]string{"foo": "bar"}).(v1.Image)
+	if err := Write(repo.Tag("sig"), img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := repo.Tag(strings.Replace(subject.Digest.String(), ":", "-", 1))
+	idx, err := Index(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 1 {
+		t.Fatalf("referrers = %v, want 1", im.Manifests)
+	}
+	got := im.Manifests[0]
+	if got.Digest != d {
+		t.Errorf("referrer digest = %s, want %s", got.Digest, d)
+	}
+	// Without an artifactType, the config's media type is used.
+	if got.ArtifactType != "application/vnd.example.config" {
+		t.Errorf("referrer ArtifactType = %q, want the config media type", got.ArtifactType)
+	}
+	if got.Annotations["foo"] != "bar" {
+		t.Errorf("referrer Annotations = %v, want foo=bar", got.Annotations)
+	}
+}
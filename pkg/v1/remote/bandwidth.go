@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter paces reads from any number of streams so that, together,
+// they don't exceed a fixed number of bytes per second.
+type bandwidthLimiter struct {
+	bytesPerSec int64
+
+	mu sync.Mutex
+	// next is when the bytes read so far will have been paid for.
+	next time.Time
+}
+
+// chunk is the most a single Read may transfer before waiting, so that
+// streams are paced smoothly (about 10 chunks per second) rather than in
+// large bursts.
+func (l *bandwidthLimiter) chunk() int {
+	if n := l.bytesPerSec / 10; n > 1 {
+		return int(n)
+	}
+	return 1
+}
+
+// wait blocks until n more bytes fit within the limit, or ctx is done.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		// Idle time doesn't accumulate into a burst.
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(n) * time.Second / time.Duration(l.bytesPerSec))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reader wraps rc so that reading from it counts against the limit, if l is
+// non-nil.
+func (l *bandwidthLimiter) reader(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+	return &limitedReader{rc: rc, limiter: l, ctx: ctx}
+}
+
+type limitedReader struct {
+	rc      io.ReadCloser
+	limiter *bandwidthLimiter
+	ctx     context.Context
+}
+
+func (r *limitedReader) Read(b []byte) (int, error) {
+	if c := r.limiter.chunk(); len(b) > c {
+		b = b[:c]
+	}
+	n, err := r.rc.Read(b)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *limitedReader) Close() error { return r.rc.Close() }
@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	const (
+		size  = 20000
+		limit = 100000
+		// The transfer should take size/limit = 200ms, allow some slack.
+		atLeast = 150 * time.Millisecond
+	)
+	l, err := random.Layer(size, types.OCIUncompressedLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := l.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/bandwidth", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := WriteLayer(repo, l, WithBandwidthLimit(limit)); err != nil {
+		t.Fatalf("WriteLayer: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < atLeast {
+		t.Errorf("WriteLayer took %v, want at least %v", elapsed, atLeast)
+	}
+
+	rl, err := Layer(repo.Digest(d.String()), WithBandwidthLimit(limit))
+	if err != nil {
+		t.Fatalf("Layer: %v", err)
+	}
+	start = time.Now()
+	rc, err := rl.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	n, err := io.Copy(ioutil.Discard, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Errorf("read %d bytes, want %d", n, want)
+	}
+	if elapsed := time.Since(start); elapsed < atLeast {
+		t.Errorf("reading layer took %v, want at least %v", elapsed, atLeast)
+	}
+}
+
+func TestBandwidthLimitInvalid(t *testing.T) {
+	if _, err := makeOptions(name.MustParseReference("example.com/foo").Context(), WithBandwidthLimit(0)); err == nil {
+		t.Error("expected error for zero bandwidth limit")
+	}
+}
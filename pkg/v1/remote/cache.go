@@ -0,0 +1,226 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Cache is a content-addressable store of manifests and blobs. See WithCache.
+type Cache interface {
+	// Get returns the descriptor and contents of what was stored with
+	// digest h, or an error that Is ErrCacheMiss if nothing was.
+	Get(h v1.Hash) (*v1.Descriptor, io.ReadCloser, error)
+
+	// Put stores the contents read from r with desc.Digest and
+	// desc.MediaType. Nothing must be stored if reading r fails, which is
+	// how the caller signals that the contents were incomplete or didn't
+	// match desc.Digest. desc.Size is -1 if it isn't known in advance.
+	Put(desc v1.Descriptor, r io.Reader) error
+}
+
+// ErrCacheMiss is returned by a Cache's Get when nothing was stored with a
+// digest.
+var ErrCacheMiss = errors.New("not found in cache")
+
+// errCacheIncomplete aborts a Put for a response that wasn't read to the end.
+var errCacheIncomplete = errors.New("response was not read to the end")
+
+// cacheTransport serves GET and HEAD requests for manifests and blobs by
+// digest from cache, and populates it with the responses to those it can't.
+// Everything else, including range requests, goes straight to inner.
+type cacheTransport struct {
+	cache Cache
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h, ok := cacheable(req)
+	if !ok {
+		return t.inner.RoundTrip(req)
+	}
+
+	desc, rc, err := t.cache.Get(h)
+	if err == nil {
+		logs.Debug.Printf("Serving %s from cache", h)
+		return cachedResponse(req, desc, rc), nil
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		logs.Warn.Printf("Reading %s from cache: %v", h, err)
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	resp.Body = t.populate(v1.Descriptor{
+		Digest:    h,
+		Size:      resp.ContentLength,
+		MediaType: types.MediaType(resp.Header.Get("Content-Type")),
+	}, resp.Body)
+	return resp, nil
+}
+
+// cacheable returns the digest req is for, if it's a plain GET or HEAD for a
+// manifest or blob by digest.
+func cacheable(req *http.Request) (v1.Hash, bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return v1.Hash{}, false
+	}
+	if req.Header.Get("Range") != "" {
+		return v1.Hash{}, false
+	}
+	parts := strings.Split(req.URL.Path, "/")
+	if len(parts) < 5 || parts[1] != "v2" {
+		return v1.Hash{}, false
+	}
+	if resource := parts[len(parts)-2]; resource != "manifests" && resource != "blobs" {
+		return v1.Hash{}, false
+	}
+	h, err := v1.NewHash(parts[len(parts)-1])
+	if err != nil || h.Algorithm != "sha256" {
+		return v1.Hash{}, false
+	}
+	return h, true
+}
+
+func cachedResponse(req *http.Request, desc *v1.Descriptor, rc io.ReadCloser) *http.Response {
+	mt := desc.MediaType
+	if mt == "" {
+		mt = "application/octet-stream"
+	}
+	header := http.Header{}
+	header.Set("Content-Type", string(mt))
+	header.Set("Content-Length", strconv.FormatInt(desc.Size, 10))
+	header.Set("Docker-Content-Digest", desc.Digest.String())
+
+	if req.Method == http.MethodHead {
+		rc.Close()
+		rc = http.NoBody
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: desc.Size,
+		Body:          rc,
+		Request:       req,
+	}
+}
+
+// populate returns a ReadCloser that reads body and concurrently Puts what it
+// reads into the cache. The Put is aborted unless body is read to the end and
+// matches desc.Digest.
+func (t *cacheTransport) populate(desc v1.Descriptor, body io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := t.cache.Put(desc, pr)
+		if err != nil && !errors.Is(err, errCacheIncomplete) {
+			logs.Warn.Printf("Writing %s to cache: %v", desc.Digest, err)
+		}
+		// Unblock any writes that Put didn't consume.
+		pr.CloseWithError(errCacheIncomplete)
+	}()
+	return &cachingReader{
+		body:   body,
+		want:   desc.Digest,
+		hasher: sha256.New(),
+		pw:     pw,
+		done:   done,
+	}
+}
+
+type cachingReader struct {
+	body   io.ReadCloser
+	want   v1.Hash
+	hasher hash.Hash
+	once   sync.Once
+
+	// pw is nil once writing to the cache has failed or finished.
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+func (r *cachingReader) Read(b []byte) (int, error) {
+	n, err := r.body.Read(b)
+	if r.pw != nil && n > 0 {
+		r.hasher.Write(b[:n])
+		if _, werr := r.pw.Write(b[:n]); werr != nil {
+			r.pw = nil
+		}
+	}
+	if r.pw != nil && errors.Is(err, io.EOF) {
+		if got := hex.EncodeToString(r.hasher.Sum(nil)); got == r.want.Hex {
+			r.pw.Close()
+		} else {
+			r.pw.CloseWithError(fmt.Errorf("digest mismatch: got sha256:%s, want %s", got, r.want))
+		}
+		r.pw = nil
+	}
+	return n, err
+}
+
+// Close waits for the cache to be populated, so that subsequent requests
+// for the same digest are hits.
+func (r *cachingReader) Close() error {
+	err := r.body.Close()
+	r.once.Do(func() {
+		if r.pw != nil {
+			r.pw.CloseWithError(errCacheIncomplete)
+			r.pw = nil
+		}
+		<-r.done
+	})
+	return err
+}
+
+// lazyTransport defers creating a transport, which for registries means the
+// ping and token exchange, until the first request that needs it.
+type lazyTransport struct {
+	once sync.Once
+	new  func() (http.RoundTripper, error)
+	rt   http.RoundTripper
+	err  error
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *lazyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.once.Do(func() {
+		t.rt, t.err = t.new()
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.rt.RoundTrip(req)
+}
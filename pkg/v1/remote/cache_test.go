@@ -0,0 +1,257 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+type memCache struct {
+	sync.Mutex
+	descs    map[v1.Hash]v1.Descriptor
+	contents map[v1.Hash][]byte
+}
+
+func newMemCache() *memCache {
+	return &memCache{
+		descs:    map[v1.Hash]v1.Descriptor{},
+		contents: map[v1.Hash][]byte{},
+	}
+}
+
+func (c *memCache) Get(h v1.Hash) (*v1.Descriptor, io.ReadCloser, error) {
+	c.Lock()
+	defer c.Unlock()
+	b, ok := c.contents[h]
+	if !ok {
+		return nil, nil, ErrCacheMiss
+	}
+	desc := c.descs[h]
+	desc.Size = int64(len(b))
+	return &desc, ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (c *memCache) Put(desc v1.Descriptor, r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.descs[desc.Digest] = desc
+	c.contents[desc.Digest] = b
+	return nil
+}
+
+func TestWithCache(t *testing.T) {
+	var requests int32
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(u.Host + "/test/cache:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := tag.Context().Digest(d.String())
+
+	c := newMemCache()
+	pull := func() {
+		t.Helper()
+		got, err := Image(ref, WithCache(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Fatalf("validate.Image() = %v", err)
+		}
+		if _, err := Head(ref, WithCache(c)); err != nil {
+			t.Fatalf("Head() = %v", err)
+		}
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	pull()
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Fatal("first pull didn't talk to the registry")
+	}
+	// The manifest, config and 3 layers.
+	if got, want := len(c.contents), 5; got != want {
+		t.Errorf("got %d cache entries, want %d", got, want)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	pull()
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Errorf("second pull made %d requests, want 0", got)
+	}
+
+	// Tags still go to the registry, but blobs don't.
+	atomic.StoreInt32(&requests, 0)
+	got, err := Image(tag, WithCache(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Fatalf("validate.Image() = %v", err)
+	}
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Error("pull by tag didn't talk to the registry")
+	}
+}
+
+func TestWithCacheBadContent(t *testing.T) {
+	layer, err := random.Layer(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.Write([]byte("not the blob you're looking for"))
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigest(fmt.Sprintf("%s/test/cache@%s", u.Host, d))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newMemCache()
+	l, err := Layer(ref, WithCache(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err == nil {
+		t.Error("reading mismatched blob succeeded, wanted err")
+	}
+	rc.Close()
+	if len(c.contents) != 0 {
+		t.Errorf("mismatched blob was cached")
+	}
+}
+
+func TestWithCachePartialRead(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := random.Layer(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLayer(repo, layer); err != nil {
+		t.Fatal(err)
+	}
+	d, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newMemCache()
+	l, err := Layer(repo.Digest(d.String()), WithCache(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, rc, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.contents) != 0 {
+		t.Errorf("partially read blob was cached")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	h := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	for _, tc := range []struct {
+		method, path, rng string
+		want              bool
+	}{
+		{http.MethodGet, "/v2/foo/bar/manifests/" + h, "", true},
+		{http.MethodHead, "/v2/foo/blobs/" + h, "", true},
+		{http.MethodGet, "/v2/foo/blobs/" + h, "bytes=0-10", false},
+		{http.MethodPut, "/v2/foo/manifests/" + h, "", false},
+		{http.MethodGet, "/v2/foo/manifests/latest", "", false},
+		{http.MethodGet, "/v2/foo/tags/list", "", false},
+		{http.MethodGet, "/v2/foo/referrers/" + h, "", false},
+	} {
+		req, err := http.NewRequest(tc.method, "https://example.com"+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.rng != "" {
+			req.Header.Set("Range", tc.rng)
+		}
+		if _, got := cacheable(req); got != tc.want {
+			t.Errorf("cacheable(%s %s, %q) = %t, want %t", tc.method, tc.path, tc.rng, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Capability is whether a registry supports a feature, as far as is known.
+type Capability int
+
+const (
+	// CapabilityUnknown means the registry hasn't been seen to support the
+	// feature, or not to.
+	CapabilityUnknown Capability = iota
+	// CapabilitySupported means the registry supports the feature.
+	CapabilitySupported
+	// CapabilityUnsupported means the registry doesn't support the feature.
+	CapabilityUnsupported
+)
+
+func (c Capability) String() string {
+	switch c {
+	case CapabilitySupported:
+		return "supported"
+	case CapabilityUnsupported:
+		return "unsupported"
+	default:
+		return "unknown"
+	}
+}
+
+// Capabilities are the optional features of the distribution spec that a
+// registry supports.
+type Capabilities struct {
+	// Subject is whether the registry processes the subject of manifests,
+	// which it advertises with the OCI-Subject header when they're pushed.
+	Subject Capability
+	// Referrers is whether the registry serves the referrers API.
+	Referrers Capability
+	// Delete is whether the registry allows manifests to be deleted.
+	Delete Capability
+	// TagDelete is whether the registry allows tags to be deleted without
+	// deleting the manifests they point to, see Untag.
+	TagDelete Capability
+	// BlobDelete is whether the registry allows blobs to be deleted.
+	BlobDelete Capability
+}
+
+// CapabilityRecorder records what registries are seen to support as
+// operations that are passed it with WithCapabilityRecorder talk to them, so
+// that callers can choose between e.g. the referrers API and the tag schema
+// fallback without probing the registry each time. Operations also use what
+// it has recorded to skip requests that are known to fail.
+//
+// The zero value is ready to use, and it's safe for concurrent use.
+type CapabilityRecorder struct {
+	mu         sync.Mutex
+	registries map[string]Capabilities
+}
+
+// Capabilities returns what's known about what reg supports.
+func (r *CapabilityRecorder) Capabilities(reg name.Registry) Capabilities {
+	if r == nil {
+		return Capabilities{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.registries[reg.RegistryStr()]
+}
+
+// record updates what's known about reg with f.
+func (r *CapabilityRecorder) record(reg name.Registry, f func(*Capabilities)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.registries == nil {
+		r.registries = map[string]Capabilities{}
+	}
+	c := r.registries[reg.RegistryStr()]
+	f(&c)
+	r.registries[reg.RegistryStr()] = c
+}
+
+// WithCapabilityRecorder records what registries support in r.
+func WithCapabilityRecorder(r *CapabilityRecorder) Option {
+	return func(o *options) error {
+		o.capabilities = r
+		return nil
+	}
+}
+
+// manifestSubject returns the digest of the subject of the manifest raw, if
+// it has one.
+func manifestSubject(raw []byte) (v1.Hash, bool) {
+	var m struct {
+		Subject *v1.Descriptor `json:"subject"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil || m.Subject == nil {
+		return v1.Hash{}, false
+	}
+	return m.Subject.Digest, true
+}
+
+// referrerDescriptor returns the descriptor of the manifest raw, described
+// by desc, as it appears in a list of referrers: with its artifact type and
+// annotations.
+func referrerDescriptor(raw []byte, desc v1.Descriptor) v1.Descriptor {
+	var m struct {
+		ArtifactType string `json:"artifactType"`
+		Config       struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	d := v1.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return d
+	}
+	// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers
+	d.ArtifactType = m.ArtifactType
+	if d.ArtifactType == "" && desc.MediaType == types.OCIManifestSchema1 {
+		d.ArtifactType = m.Config.MediaType
+	}
+	d.Annotations = m.Annotations
+	return d
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestCapabilityRecorder(t *testing.T) {
+	reg := NewRegistry()
+	referrers := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/referrers/"):
+			referrers++
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/"):
+			w.Header().Set("OCI-Subject", "sha256:"+strings.Repeat("a", 64))
+			reg.ServeHTTP(w, r)
+		default:
+			reg.ServeHTTP(w, r)
+		}
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/capabilities", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &CapabilityRecorder{}
+	if got, want := rec.Capabilities(ref.Context().Registry), (Capabilities{}); got != want {
+		t.Errorf("Capabilities() = %+v, want %+v", got, want)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img, WithCapabilityRecorder(rec)); err != nil {
+		t.Fatal(err)
+	}
+	// Pushing a manifest without a subject says nothing about support for it.
+	if got := rec.Capabilities(ref.Context().Registry).Subject; got != CapabilityUnknown {
+		t.Errorf("Subject = %v, want %v", got, CapabilityUnknown)
+	}
+
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config:        m.Config,
+		Layers:        []v1.Descriptor{},
+		Subject:       &v1.Descriptor{MediaType: types.DockerManifestSchema2, Digest: d, Size: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigDigest, _, err := v1.SHA256(bytes.NewReader(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Put(ref.Context().Digest(sigDigest.String()), rawTaggable(sig), WithCapabilityRecorder(rec)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first Graph asks for referrers.
+	for i := 0; i < 2; i++ {
+		if _, err := Graph(ref, WithReferrers, WithCapabilityRecorder(rec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if referrers != 1 {
+		t.Errorf("got %d referrers requests, want 1", referrers)
+	}
+
+	if err := Delete(ref, WithCapabilityRecorder(rec)); err == nil {
+		t.Error("Delete() succeeded, want error")
+	}
+
+	want := Capabilities{
+		Subject:   CapabilitySupported,
+		Referrers: CapabilityUnsupported,
+		Delete:    CapabilityUnsupported,
+	}
+	if got := rec.Capabilities(ref.Context().Registry); got != want {
+		t.Errorf("Capabilities() = %+v, want %+v", got, want)
+	}
+}
@@ -80,7 +80,47 @@ func Catalog(ctx context.Context, target name.Registry, options ...Option) ([]st
 	if err != nil {
 		return nil, err
 	}
+	c, err := newCatalogger(target, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// WithContext overrides the ctx passed directly.
+	if o.context != context.Background() {
+		ctx = o.context
+	}
+
+	var repoList []string
+	for c.HasNext() {
+		page, err := c.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		repoList = append(repoList, page...)
+	}
+	return repoList, nil
+}
 
+// Catalogger pages through the repositories on a registry, fetching each page
+// only when it is asked for. See NewCatalogger.
+type Catalogger struct {
+	pager pager
+}
+
+// NewCatalogger returns a Catalogger for the repositories on target. Unlike
+// Catalog, which fetches every repository before returning any of them, each
+// call to Next fetches and returns a single page of repositories, so
+// registries with very many repositories can be processed incrementally. The
+// size of pages can be set with WithPageSize.
+func NewCatalogger(target name.Registry, options ...Option) (*Catalogger, error) {
+	o, err := makeOptions(target, options...)
+	if err != nil {
+		return nil, err
+	}
+	return newCatalogger(target, o)
+}
+
+func newCatalogger(target name.Registry, o *options) (*Catalogger, error) {
 	scopes := []string{target.Scope(transport.PullScope)}
 	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes)
 	if err != nil {
@@ -97,58 +137,25 @@ func Catalog(ctx context.Context, target name.Registry, options ...Option) ([]st
 		uri.RawQuery = fmt.Sprintf("n=%d", o.pageSize)
 	}
 
-	client := http.Client{Transport: tr}
-
-	// WithContext overrides the ctx passed directly.
-	if o.context != context.Background() {
-		ctx = o.context
-	}
-
-	var (
-		parsed   catalog
-		repoList []string
-	)
-
-	// get responses until there is no next page
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		req, err := http.NewRequest("GET", uri.String(), nil)
-		if err != nil {
-			return nil, err
-		}
-		req = req.WithContext(ctx)
-
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := transport.CheckError(resp, http.StatusOK); err != nil {
-			return nil, err
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-			return nil, err
-		}
-		if err := resp.Body.Close(); err != nil {
-			return nil, err
-		}
+	return &Catalogger{
+		pager: pager{
+			client: &http.Client{Transport: tr},
+			next:   uri,
+		},
+	}, nil
+}
 
-		repoList = append(repoList, parsed.Repos...)
+// HasNext returns true if there are more pages of repositories to fetch.
+func (c *Catalogger) HasNext() bool {
+	return c.pager.next != nil
+}
 
-		uri, err = getNextPageURL(resp)
-		if err != nil {
-			return nil, err
-		}
-		// no next page
-		if uri == nil {
-			break
-		}
+// Next fetches the next page of repositories, or returns io.EOF if there are
+// no more.
+func (c *Catalogger) Next(ctx context.Context) ([]string, error) {
+	var parsed catalog
+	if err := c.pager.fetch(ctx, &parsed); err != nil {
+		return nil, err
 	}
-	return repoList, nil
+	return parsed.Repos, nil
 }
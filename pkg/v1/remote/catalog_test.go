@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -153,6 +154,63 @@ func TestCatalog(t *testing.T) {
 	}
 }
 
+func TestCatalogger(t *testing.T) {
+	pageTwo := "/v2/_catalog_two"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/_catalog":
+			requests++
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, pageTwo))
+			w.Write([]byte(`{"repositories":["test/one","test/two"]}`))
+		case pageTwo:
+			requests++
+			w.Write([]byte(`{"repositories":["test/three"]}`))
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	reg, err := name.NewRegistry(u.Host)
+	if err != nil {
+		t.Fatalf("name.NewRegistry(%v) = %v", u.Host, err)
+	}
+
+	c, err := NewCatalogger(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i, want := range [][]string{{"test/one", "test/two"}, {"test/three"}} {
+		if !c.HasNext() {
+			t.Fatalf("page %d: HasNext() = false", i)
+		}
+		if requests != i {
+			t.Errorf("page %d: made %d requests before Next, want %d", i, requests, i)
+		}
+		got, err := c.Next(ctx)
+		if err != nil {
+			t.Fatalf("page %d: Next() = %v", i, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("page %d: Next() wrong repos (-want +got) = %s", i, diff)
+		}
+	}
+	if c.HasNext() {
+		t.Error("HasNext() = true after last page")
+	}
+	if _, err := c.Next(ctx); err != io.EOF {
+		t.Errorf("Next() after last page = %v, want io.EOF", err)
+	}
+}
+
 func TestCancelledCatalog(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
@@ -0,0 +1,70 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TagConflictError is returned when pushing with WithExpectedDigest to a tag
+// that doesn't point at the expected manifest.
+type TagConflictError struct {
+	Tag name.Tag
+
+	// Expected and Actual are the zero Hash if the tag is expected not to
+	// exist, or doesn't.
+	Expected v1.Hash
+	Actual   v1.Hash
+}
+
+// Error implements error.
+func (e *TagConflictError) Error() string {
+	describe := func(h v1.Hash) string {
+		if h == (v1.Hash{}) {
+			return "no manifest"
+		}
+		return h.String()
+	}
+	return fmt.Sprintf("%s points at %s, expected %s", e.Tag, describe(e.Actual), describe(e.Expected))
+}
+
+// checkExpectedDigest returns a *TagConflictError unless tag points at the
+// manifest expected by WithExpectedDigest.
+func (w *writer) checkExpectedDigest(ctx context.Context, tag name.Tag) error {
+	acceptable := []types.MediaType{
+		types.DockerManifestSchema1,
+		types.DockerManifestSchema1Signed,
+	}
+	acceptable = append(acceptable, acceptableImageMediaTypes...)
+	acceptable = append(acceptable, acceptableIndexMediaTypes...)
+
+	f := fetcher{Ref: tag, Client: w.client, context: ctx}
+	var actual v1.Hash
+	desc, err := f.headManifest(tag, acceptable)
+	if err == nil {
+		actual = desc.Digest
+	} else if !IsNotFound(err) {
+		return err
+	}
+	if actual != *w.expected {
+		return &TagConflictError{Tag: tag, Expected: *w.expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestExpectedDigest(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/test/conditional:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	one, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneDigest, err := one.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twoDigest, err := two.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantConflict := func(t *testing.T, err error, expected, actual v1.Hash) {
+		t.Helper()
+		var cerr *TagConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, want *TagConflictError", err)
+		}
+		if cerr.Expected != expected || cerr.Actual != actual {
+			t.Errorf("got expected %s, actual %s; want %s, %s", cerr.Expected, cerr.Actual, expected, actual)
+		}
+	}
+	wantTag := func(t *testing.T, want v1.Hash) {
+		t.Helper()
+		desc, err := Head(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Digest != want {
+			t.Errorf("tag points at %s, want %s", desc.Digest, want)
+		}
+	}
+
+	// The zero Hash only pushes new tags.
+	if err := Write(tag, one, WithExpectedDigest(v1.Hash{})); err != nil {
+		t.Fatalf("Write new tag: %v", err)
+	}
+	wantTag(t, oneDigest)
+	wantConflict(t, Write(tag, two, WithExpectedDigest(v1.Hash{})), v1.Hash{}, oneDigest)
+	wantTag(t, oneDigest)
+
+	// Swap one for two.
+	if err := Write(tag, two, WithExpectedDigest(oneDigest)); err != nil {
+		t.Fatalf("Write expecting current digest: %v", err)
+	}
+	wantTag(t, twoDigest)
+
+	// A stale expectation fails, even just tagging.
+	wantConflict(t, Tag(tag, one, WithExpectedDigest(oneDigest)), oneDigest, twoDigest)
+	wantTag(t, twoDigest)
+
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantConflict(t, WriteIndex(tag, idx, WithExpectedDigest(oneDigest)), oneDigest, twoDigest)
+	if err := WriteIndex(tag, idx, WithExpectedDigest(twoDigest)); err != nil {
+		t.Fatalf("WriteIndex expecting current digest: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ManifestConversion controls what happens when a registry rejects a manifest
+// being pushed because of its media type, as some older registries do with
+// OCI manifests.
+type ManifestConversion int
+
+const (
+	// PushAsIs never converts manifests, and returns the registry's error.
+	// This is the default.
+	PushAsIs ManifestConversion = iota
+
+	// ConvertToOCI retries rejected Docker schema 2 manifests and manifest
+	// lists as OCI manifests and indexes.
+	ConvertToOCI
+
+	// ConvertToDocker retries rejected OCI manifests and indexes as Docker
+	// schema 2 manifests and manifest lists. Annotations, subjects and
+	// artifact types, which Docker manifests can't express, are dropped.
+	ConvertToDocker
+)
+
+var ociToDocker = map[types.MediaType]types.MediaType{
+	types.OCIManifestSchema1:   types.DockerManifestSchema2,
+	types.OCIImageIndex:        types.DockerManifestList,
+	types.OCIConfigJSON:        types.DockerConfigJSON,
+	types.OCILayer:             types.DockerLayer,
+	types.OCIUncompressedLayer: types.DockerUncompressedLayer,
+	types.OCIRestrictedLayer:   types.DockerForeignLayer,
+}
+
+var dockerToOCI = map[types.MediaType]types.MediaType{}
+
+func init() {
+	for oci, docker := range ociToDocker {
+		dockerToOCI[docker] = oci
+	}
+}
+
+// converter converts manifests rejected by a registry, and remembers what it
+// converted so that indexes can refer to the converted manifests.
+type converter struct {
+	mediaTypes map[types.MediaType]types.MediaType
+
+	lock sync.Mutex
+	// maps original manifest digest -> converted manifest
+	converted map[v1.Hash]v1.Descriptor
+	// the digests of the children of indexes being written, which are pushed
+	// by digest, and can be pushed by their converted digest instead
+	children map[v1.Hash]bool
+}
+
+// newConverter returns a converter for c, or nil for PushAsIs.
+func newConverter(c ManifestConversion) *converter {
+	var mts map[types.MediaType]types.MediaType
+	switch c {
+	case ConvertToOCI:
+		mts = dockerToOCI
+	case ConvertToDocker:
+		mts = ociToDocker
+	default:
+		return nil
+	}
+	return &converter{
+		mediaTypes: mts,
+		converted:  map[v1.Hash]v1.Descriptor{},
+		children:   map[v1.Hash]bool{},
+	}
+}
+
+// rejected returns true if err indicates that the registry doesn't accept a
+// manifest's media type.
+func rejected(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusUnsupportedMediaType {
+		return true
+	}
+	for _, d := range terr.Errors {
+		switch d.Code {
+		case transport.ManifestInvalidErrorCode, transport.UnsupportedErrorCode:
+			return true
+		}
+	}
+	return false
+}
+
+// hasConvertedChildren returns true if raw is an index that refers to any
+// manifests that have been converted.
+func (c *converter) hasConvertedChildren(raw []byte, mt types.MediaType) bool {
+	if !mt.IsIndex() {
+		return false
+	}
+	index, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, desc := range index.Manifests {
+		if _, ok := c.converted[desc.Digest]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// convert returns raw converted to the other media type family, or false if
+// it can't be converted.
+func (c *converter) convert(raw []byte, mt types.MediaType) (*convertedManifest, bool, error) {
+	newMT, ok := c.target(mt)
+	if !ok || (newMT == mt && !mt.IsIndex()) {
+		// Indexes may still need to refer to converted children.
+		return nil, false, nil
+	}
+	toDocker := newMT == types.DockerManifestSchema2 || newMT == types.DockerManifestList
+
+	var v interface{}
+	switch {
+	case mt.IsImage():
+		m, err := v1.ParseManifest(bytes.NewReader(raw))
+		if err != nil {
+			return nil, false, err
+		}
+		m.MediaType = newMT
+		if m.Config.MediaType, ok = c.target(m.Config.MediaType); !ok {
+			// e.g. an artifact with a custom config type.
+			return nil, false, nil
+		}
+		for i, l := range m.Layers {
+			if m.Layers[i].MediaType, ok = c.target(l.MediaType); !ok {
+				return nil, false, nil
+			}
+		}
+		if toDocker {
+			m.ArtifactType = ""
+			m.Subject = nil
+			m.Annotations = nil
+		}
+		v = m
+	case mt.IsIndex():
+		index, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+		if err != nil {
+			return nil, false, err
+		}
+		index.MediaType = newMT
+		c.lock.Lock()
+		for i, desc := range index.Manifests {
+			if converted, ok := c.converted[desc.Digest]; ok {
+				index.Manifests[i].MediaType = converted.MediaType
+				index.Manifests[i].Size = converted.Size
+				index.Manifests[i].Digest = converted.Digest
+			}
+		}
+		c.lock.Unlock()
+		if toDocker {
+			index.Subject = nil
+			index.Annotations = nil
+		}
+		v = index
+	default:
+		return nil, false, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false, err
+	}
+	return &convertedManifest{raw: b, mediaType: newMT}, true, nil
+}
+
+// target returns the media type that mt converts to, which is mt itself if it
+// is already of the right family, or false if there is none.
+func (c *converter) target(mt types.MediaType) (types.MediaType, bool) {
+	if to, ok := c.mediaTypes[mt]; ok {
+		return to, true
+	}
+	for _, to := range c.mediaTypes {
+		if mt == to {
+			return mt, true
+		}
+	}
+	return "", false
+}
+
+// record remembers that the manifest with digest orig was pushed as desc.
+func (c *converter) record(orig v1.Hash, desc v1.Descriptor) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.converted[orig] = desc
+}
+
+// addChild remembers that h is the child of an index being written.
+func (c *converter) addChild(h v1.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.children[h] = true
+}
+
+// isChild returns true if h is the child of an index being written.
+func (c *converter) isChild(h v1.Hash) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.children[h]
+}
+
+// convertedManifest is a Taggable holding a converted manifest.
+type convertedManifest struct {
+	raw       []byte
+	mediaType types.MediaType
+}
+
+func (m *convertedManifest) RawManifest() ([]byte, error) {
+	return m.raw, nil
+}
+
+func (m *convertedManifest) MediaType() (types.MediaType, error) {
+	return m.mediaType, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// rejectingRegistry returns a registry that rejects manifests whose media type
+// contains reject.
+func rejectingRegistry(t *testing.T, reject string) string {
+	t.Helper()
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") && strings.Contains(r.Header.Get("Content-Type"), reject) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func ociImage(t *testing.T) v1.Image {
+	t.Helper()
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	return mutate.ConfigMediaType(img, types.OCIConfigJSON)
+}
+
+func TestWriteManifestConversion(t *testing.T) {
+	host := rejectingRegistry(t, "vnd.oci")
+	img := ociImage(t)
+	ref, err := name.ParseReference(host + "/repo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Write(ref, img); err == nil {
+		t.Fatal("Write succeeded without conversion, wanted err")
+	}
+	if err := Write(ref, img, WithManifestConversion(ConvertToDocker)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	got, err := Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+	m, err := got.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaType != types.DockerManifestSchema2 || m.Config.MediaType != types.DockerConfigJSON {
+		t.Errorf("got media types %s, %s, want Docker", m.MediaType, m.Config.MediaType)
+	}
+
+	// The blobs are the same.
+	wantLayers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range m.Layers {
+		d, err := wantLayers[i].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Digest != d {
+			t.Errorf("layer %d: digest %s, want %s", i, l.Digest, d)
+		}
+	}
+}
+
+func TestWriteManifestConversionRejected(t *testing.T) {
+	host := rejectingRegistry(t, "vnd.oci")
+	img := ociImage(t)
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := mutate.MediaType(img, "application/vnd.oci.custom.manifest.v1+json")
+
+	for _, tc := range []struct {
+		name string
+		ref  string
+		img  v1.Image
+	}{{
+		// Converting would push it to a different digest.
+		name: "pinned",
+		ref:  host + "/repo@" + d.String(),
+		img:  img,
+	}, {
+		// There's nothing to convert it to.
+		name: "unconvertible",
+		ref:  host + "/repo:custom",
+		img:  custom,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := name.ParseReference(tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Write(ref, tc.img, WithManifestConversion(ConvertToDocker))
+			var terr *transport.Error
+			if !errors.As(err, &terr) || terr.Errors[0].Code != transport.ManifestInvalidErrorCode {
+				t.Errorf("Write() = %v, wanted the registry's MANIFEST_INVALID", err)
+			}
+		})
+	}
+}
+
+func TestWriteIndexManifestConversion(t *testing.T) {
+	host := rejectingRegistry(t, "vnd.oci")
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+		mutate.IndexAddendum{Add: ociImage(t), Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: ociImage(t), Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+	ref, err := name.ParseReference(host + "/repo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteIndex(ref, idx, WithManifestConversion(ConvertToDocker)); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+
+	got, err := Index(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index: %v", err)
+	}
+	im, err := got.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if im.MediaType != types.DockerManifestList {
+		t.Errorf("index media type %s, want %s", im.MediaType, types.DockerManifestList)
+	}
+	for _, desc := range im.Manifests {
+		if desc.MediaType != types.DockerManifestSchema2 || desc.Platform == nil {
+			t.Errorf("unexpected child: %+v", desc)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -25,21 +26,40 @@ import (
 
 // Delete removes the specified image reference from the remote registry.
 func Delete(ref name.Reference, options ...Option) error {
-	o, err := makeOptions(ref.Context(), options...)
+	return deleteResource(ref.Context(), "manifests", ref.Identifier(), func(c *Capabilities) *Capability { return &c.Delete }, options...)
+}
+
+// Untag removes tag from the remote registry, leaving the manifest it points to
+// in place, on registries that support deleting tags. Some registries instead
+// reject the request, or delete the manifest too, as with Delete.
+func Untag(tag name.Tag, options ...Option) error {
+	return deleteResource(tag.Context(), "manifests", tag.Identifier(), func(c *Capabilities) *Capability { return &c.TagDelete }, options...)
+}
+
+// DeleteBlob removes the blob that ref refers to from its repository. See
+// Layer for what ref means here.
+func DeleteBlob(ref name.Digest, options ...Option) error {
+	return deleteResource(ref.Context(), "blobs", ref.Identifier(), func(c *Capabilities) *Capability { return &c.BlobDelete }, options...)
+}
+
+// deleteResource deletes /v2/<repo>/<resource>/<identifier>, recording whether
+// the registry supports it in the capability that field returns.
+func deleteResource(repo name.Repository, resource, identifier string, field func(*Capabilities) *Capability, options ...Option) error {
+	o, err := makeOptions(repo, options...)
 	if err != nil {
 		return err
 	}
-	scopes := []string{ref.Scope(transport.DeleteScope)}
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	scopes := []string{repo.Scope(transport.DeleteScope)}
+	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
 	if err != nil {
 		return err
 	}
 	c := &http.Client{Transport: tr}
 
 	u := url.URL{
-		Scheme: ref.Context().Registry.Scheme(),
-		Host:   ref.Context().RegistryStr(),
-		Path:   fmt.Sprintf("/v2/%s/manifests/%s", ref.Context().RepositoryStr(), ref.Identifier()),
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/%s/%s", repo.RepositoryStr(), resource, identifier),
 	}
 
 	req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
@@ -53,5 +73,13 @@ func Delete(ref name.Reference, options ...Option) error {
 	}
 	defer resp.Body.Close()
 
-	return transport.CheckError(resp, http.StatusOK, http.StatusAccepted)
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusAccepted); err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && (terr.StatusCode == http.StatusMethodNotAllowed || terr.StatusCode == http.StatusNotImplemented) {
+			o.capabilities.record(repo.Registry, func(c *Capabilities) { *field(c) = CapabilityUnsupported })
+		}
+		return err
+	}
+	o.capabilities.record(repo.Registry, func(c *Capabilities) { *field(c) = CapabilitySupported })
+	return nil
 }
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 )
 
 func TestDelete(t *testing.T) {
@@ -87,3 +88,94 @@ func TestDeleteBadStatus(t *testing.T) {
 		t.Error("Delete() = nil; wanted error")
 	}
 }
+
+func TestUntagAndDeleteBlob(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", s.URL, err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/untag/test:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &CapabilityRecorder{}
+	if err := Untag(tag, WithCapabilityRecorder(cr)); err != nil {
+		t.Fatalf("Untag() = %v", err)
+	}
+	if _, err := Head(tag); err == nil {
+		t.Error("Head(tag) after Untag: expected error")
+	}
+	if _, err := Head(tag.Context().Digest(d.String())); err != nil {
+		t.Errorf("Head(digest) after Untag = %v", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ld, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteBlob(tag.Context().Digest(ld.String()), WithCapabilityRecorder(cr)); err != nil {
+		t.Fatalf("DeleteBlob() = %v", err)
+	}
+	resp, err := http.Head(fmt.Sprintf("%s/v2/untag/test/blobs/%s", s.URL, ld))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("HEAD blob after DeleteBlob: got status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	want := Capabilities{TagDelete: CapabilitySupported, BlobDelete: CapabilitySupported}
+	if got := cr.Capabilities(tag.Context().Registry); got != want {
+		t.Errorf("Capabilities() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDeleteBlobUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		default:
+			if r.Method != http.MethodDelete {
+				t.Errorf("Method; got %v, want %v", r.Method, http.MethodDelete)
+			}
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	ref, err := name.NewDigest(fmt.Sprintf("%s/write/time@sha256:%064d", u.Host, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &CapabilityRecorder{}
+	if err := DeleteBlob(ref, WithCapabilityRecorder(cr)); err == nil {
+		t.Error("DeleteBlob(): expected error")
+	}
+	if got, want := cr.Capabilities(ref.Context().Registry).BlobDelete, CapabilityUnsupported; got != want {
+		t.Errorf("BlobDelete = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// maxDerivedTransports bounds derivedTransports.
+const maxDerivedTransports = 32
+
+// derivedTransports holds the transports that options build from the
+// caller's, e.g. the clones that WithTLSPin and WithMaxIdleConnsPerHost
+// configure, so that calls with the same transport and options share a
+// connection pool instead of each opening their own connections.
+//
+// Callers that create a new transport for every call would otherwise grow it
+// forever, so it only keeps the most recently used, and closes the idle
+// connections of those it evicts.
+var derivedTransports = newTransportCache(maxDerivedTransports)
+
+type transportCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // of *derivedTransport, most recently used first
+	entries map[interface{}]*list.Element
+}
+
+type derivedTransport struct {
+	key interface{}
+	t   http.RoundTripper
+}
+
+func newTransportCache(max int) *transportCache {
+	return &transportCache{
+		max:     max,
+		order:   list.New(),
+		entries: map[interface{}]*list.Element{},
+	}
+}
+
+// get returns the transport for key, which must be comparable, calling derive
+// to build it if it isn't cached.
+func (c *transportCache) get(key interface{}, derive func() http.RoundTripper) http.RoundTripper {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*derivedTransport).t
+	}
+
+	t := derive()
+	c.entries[key] = c.order.PushFront(&derivedTransport{key: key, t: t})
+	for c.order.Len() > c.max {
+		e := c.order.Back()
+		c.order.Remove(e)
+		dt := e.Value.(*derivedTransport)
+		delete(c.entries, dt.key)
+		// Connections in use are left alone, and time out once they're
+		// idle.
+		if ci, ok := dt.t.(interface{ CloseIdleConnections() }); ok {
+			ci.CloseIdleConnections()
+		}
+	}
+	return t
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+type closeCounter struct {
+	http.RoundTripper
+	closed int
+}
+
+func (c *closeCounter) CloseIdleConnections() {
+	c.closed++
+}
+
+func TestTransportCache(t *testing.T) {
+	c := newTransportCache(2)
+	derived := map[int]*closeCounter{}
+	get := func(key int) http.RoundTripper {
+		return c.get(key, func() http.RoundTripper {
+			derived[key] = &closeCounter{}
+			return derived[key]
+		})
+	}
+
+	one := get(1)
+	if got := get(1); got != one {
+		t.Error("get() derived a new transport for the same key")
+	}
+	get(2)
+	get(1) // 2 is now the least recently used.
+	get(3)
+	if c.order.Len() != 2 {
+		t.Errorf("cache has %d transports, want 2", c.order.Len())
+	}
+	if derived[2].closed != 1 || derived[1].closed != 0 {
+		t.Errorf("closed (1, 2) = (%d, %d), want (0, 1)", derived[1].closed, derived[2].closed)
+	}
+	if got := get(1); got != one {
+		t.Error("get() evicted the most recently used transport")
+	}
+	if get(2) == derived[1] {
+		t.Error("get() returned the wrong transport")
+	}
+}
+
+func TestTLSPinShared(t *testing.T) {
+	reg := name.MustParseReference("registry.example.com/foo").Context()
+	base := DefaultTransport.(*http.Transport).Clone()
+	pin := transport.TLSPin{RootCAs: x509.NewCertPool()}
+
+	key := func(pin transport.TLSPin) pinnedKey {
+		return pinnedKey{base: base, pins: pinsKey(map[string]transport.TLSPin{reg.RegistryStr(): pin})}
+	}
+	cached := func(k pinnedKey) http.RoundTripper {
+		derivedTransports.mu.Lock()
+		defer derivedTransports.mu.Unlock()
+		if e, ok := derivedTransports.entries[k]; ok {
+			return e.Value.(*derivedTransport).t
+		}
+		return nil
+	}
+
+	if _, err := makeOptions(reg, WithTransport(base), WithTLSPin(reg.Registry, pin)); err != nil {
+		t.Fatal(err)
+	}
+	first := cached(key(pin))
+	if first == nil {
+		t.Fatal("the pinned transport wasn't cached")
+	}
+	if _, err := makeOptions(reg, WithTransport(base), WithTLSPin(reg.Registry, pin)); err != nil {
+		t.Fatal(err)
+	}
+	if cached(key(pin)) != first {
+		t.Error("the pinned transport was rebuilt for the same transport and pins")
+	}
+
+	// Different pins get a different transport.
+	other := transport.TLSPin{RootCAs: x509.NewCertPool()}
+	if key(other) == key(pin) {
+		t.Error("different RootCAs have the same key")
+	}
+	if key(transport.TLSPin{RootCAs: pin.RootCAs, SPKIHashes: []string{"a"}}) == key(pin) {
+		t.Error("different SPKIHashes have the same key")
+	}
+}
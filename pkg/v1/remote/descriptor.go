@@ -17,6 +17,7 @@ package remote
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -32,6 +33,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
 )
 
 // ErrSchema1 indicates that we received a schema1 manifest from the registry.
@@ -91,25 +93,73 @@ func Get(ref name.Reference, options ...Option) (*Descriptor, error) {
 // Note that the server response will not have a body, so any errors encountered
 // should be retried with Get to get more details.
 func Head(ref name.Reference, options ...Option) (*v1.Descriptor, error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.headManifest(ref, headAcceptable())
+}
+
+// headAcceptable returns the media types that Head accepts.
+func headAcceptable() []types.MediaType {
 	acceptable := []types.MediaType{
 		// Just to look at them.
 		types.DockerManifestSchema1,
 		types.DockerManifestSchema1Signed,
 	}
 	acceptable = append(acceptable, acceptableImageMediaTypes...)
-	acceptable = append(acceptable, acceptableIndexMediaTypes...)
+	return append(acceptable, acceptableIndexMediaTypes...)
+}
 
-	o, err := makeOptions(ref.Context(), options...)
+// HeadTags is like Head for each of tags in repo, but authenticates once for
+// all of them and makes up to jobs requests at once, see WithJobs.
+//
+// The descriptors are returned in the same order as tags. Tags that don't
+// exist, e.g. because they were deleted since they were listed, have nil
+// descriptors.
+func HeadTags(repo name.Repository, tags []string, options ...Option) ([]*v1.Descriptor, error) {
+	descs := make([]*v1.Descriptor, len(tags))
+	if len(tags) == 0 {
+		return descs, nil
+	}
+	o, err := makeOptions(repo, options...)
 	if err != nil {
 		return nil, err
 	}
-
-	f, err := makeFetcher(ref, o)
+	f, err := makeFetcher(repo.Tag(tags[0]), o)
 	if err != nil {
 		return nil, err
 	}
 
-	return f.headManifest(ref, acceptable)
+	acceptable := headAcceptable()
+	g, ctx := errgroup.WithContext(o.context)
+	g.SetLimit(o.jobs)
+	for i, tag := range tags {
+		i, ref := i, repo.Tag(tag)
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			desc, err := f.headManifest(ref, acceptable)
+			if IsNotFound(err) {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("resolving %s: %w", ref, err)
+			}
+			descs[i] = desc
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return descs, nil
 }
 
 // Handle options and fetch the manifest with the acceptable MediaTypes in the
@@ -212,20 +262,45 @@ func (d *Descriptor) remoteIndex() *remoteIndex {
 
 // fetcher implements methods for reading from a registry.
 type fetcher struct {
-	Ref     name.Reference
-	Client  *http.Client
-	context context.Context
+	Ref             name.Reference
+	Client          *http.Client
+	context         context.Context
+	capabilities    *CapabilityRecorder
+	progress        *progress
+	bandwidth       *bandwidthLimiter
+	strict          bool
+	headFallback    bool
+	variantFallback bool
 }
 
 func makeFetcher(ref name.Reference, o *options) (*fetcher, error) {
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, []string{ref.Scope(transport.PullScope)})
-	if err != nil {
-		return nil, err
+	newTransport := func() (http.RoundTripper, error) {
+		return transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, []string{ref.Scope(transport.PullScope)})
+	}
+	var tr http.RoundTripper
+	if o.cache != nil {
+		// Don't talk to the registry at all until something isn't cached.
+		tr = &cacheTransport{cache: o.cache, inner: &lazyTransport{new: newTransport}}
+	} else {
+		var err error
+		if tr, err = newTransport(); err != nil {
+			return nil, err
+		}
+	}
+	var p *progress
+	if o.readUpdates != nil {
+		p = &progress{updates: o.readUpdates, lastUpdate: &v1.Update{}}
 	}
 	return &fetcher{
-		Ref:     ref,
-		Client:  &http.Client{Transport: tr},
-		context: o.context,
+		Ref:             ref,
+		Client:          &http.Client{Transport: tr},
+		context:         o.context,
+		capabilities:    o.capabilities,
+		progress:        p,
+		bandwidth:       o.bandwidth,
+		strict:          o.strictDigests,
+		headFallback:    o.headFallback,
+		variantFallback: o.variantFallback,
 	}, nil
 }
 
@@ -272,16 +347,21 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 
 	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
 	contentDigest, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
-	if err == nil && mediaType == types.DockerManifestSchema1Signed {
+	if f.strict {
+		// Trust nothing but the manifest itself.
+		if err := f.verifyManifest(ref, digest, size, contentDigest); err != nil {
+			return nil, nil, err
+		}
+	} else if err == nil && mediaType == types.DockerManifestSchema1Signed {
 		// If we can parse the digest from the header, and it's a signed schema 1
 		// manifest, let's use that for the digest to appease older registries.
 		digest = contentDigest
 	}
 
 	// Validate the digest matches what we asked for, if pulling by digest.
-	if dgst, ok := ref.(name.Digest); ok {
-		if digest.String() != dgst.DigestStr() {
-			return nil, nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
+	if dgst, ok := pinnedDigest(ref); ok {
+		if digest.String() != dgst {
+			return nil, nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst, f.Ref)
 		}
 	}
 	// Do nothing for tags; I give up.
@@ -303,6 +383,24 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 }
 
 func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType) (*v1.Descriptor, error) {
+	if f.strict {
+		// The digest of a HEAD response is only a header, so fetch the
+		// manifest to verify it.
+		_, desc, err := f.fetchManifest(ref, acceptable)
+		return desc, err
+	}
+
+	desc, err := f.headOnly(ref, acceptable)
+	if err != nil && f.shouldFallBack(err) {
+		logs.Debug.Printf("HEAD %s failed, falling back to GET: %v", ref, err)
+		_, desc, err = f.fetchManifest(ref, acceptable)
+	}
+	return desc, err
+}
+
+// headOnly resolves ref to a descriptor with a HEAD request, relying on the
+// registry to report the digest, size and media type in headers.
+func (f *fetcher) headOnly(ref name.Reference, acceptable []types.MediaType) (*v1.Descriptor, error) {
 	u := f.url("manifests", ref.Identifier())
 	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
 	if err != nil {
@@ -326,18 +424,18 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 
 	mth := resp.Header.Get("Content-Type")
 	if mth == "" {
-		return nil, fmt.Errorf("HEAD %s: response did not include Content-Type header", u.String())
+		return nil, &missingHeaderError{url: u.String(), header: "Content-Type"}
 	}
 	mediaType := types.MediaType(mth)
 
 	size := resp.ContentLength
 	if size == -1 {
-		return nil, fmt.Errorf("GET %s: response did not include Content-Length header", u.String())
+		return nil, &missingHeaderError{url: u.String(), header: "Content-Length"}
 	}
 
 	dh := resp.Header.Get("Docker-Content-Digest")
 	if dh == "" {
-		return nil, fmt.Errorf("HEAD %s: response did not include Docker-Content-Digest header", u.String())
+		return nil, &missingHeaderError{url: u.String(), header: "Docker-Content-Digest"}
 	}
 	digest, err := v1.NewHash(dh)
 	if err != nil {
@@ -345,9 +443,9 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 	}
 
 	// Validate the digest matches what we asked for, if pulling by digest.
-	if dgst, ok := ref.(name.Digest); ok {
-		if digest.String() != dgst.DigestStr() {
-			return nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
+	if dgst, ok := pinnedDigest(ref); ok {
+		if digest.String() != dgst {
+			return nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst, f.Ref)
 		}
 	}
 
@@ -359,21 +457,69 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 	}, nil
 }
 
+// shouldFallBack returns whether a failed HEAD for a manifest should be retried
+// as a GET. Registries that don't allow or implement HEAD always are; those that
+// claim the manifest doesn't exist, or leave out headers, only if the caller
+// asked for WithHeadFallback.
+func (f *fetcher) shouldFallBack(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return true
+		case http.StatusNotFound:
+			return f.headFallback
+		}
+		return false
+	}
+	var merr *missingHeaderError
+	return f.headFallback && errors.As(err, &merr)
+}
+
+// missingHeaderError is returned when a HEAD response for a manifest doesn't
+// include a header needed to describe it.
+type missingHeaderError struct {
+	url    string
+	header string
+}
+
+func (e *missingHeaderError) Error() string {
+	return fmt.Sprintf("HEAD %s: response did not include %s header", e.url, e.header)
+}
+
+// pinnedDigest returns the digest ref refers to, if it has one.
+func pinnedDigest(ref name.Reference) (string, bool) {
+	switch r := ref.(type) {
+	case name.Digest:
+		return r.DigestStr(), true
+	case name.DigestTag:
+		return r.DigestStr(), true
+	}
+	return "", false
+}
+
 func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.ReadCloser, error) {
+	rc, _, err := f.fetchSizedBlob(ctx, size, h)
+	return rc, err
+}
+
+// fetchSizedBlob is like fetchBlob, but also returns the size of the blob, or
+// verify.SizeUnknown if neither the caller nor the registry knew it.
+func (f *fetcher) fetchSizedBlob(ctx context.Context, size int64, h v1.Hash) (io.ReadCloser, int64, error) {
 	u := f.url("blobs", h.String())
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	resp, err := f.Client.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, redact.Error(err)
+		return nil, 0, redact.Error(err)
 	}
 
 	if err := transport.CheckError(resp, http.StatusOK); err != nil {
 		resp.Body.Close()
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Do whatever we can.
@@ -382,12 +528,28 @@ func (f *fetcher) fetchBlob(ctx context.Context, size int64, h v1.Hash) (io.Read
 	if hsize := resp.ContentLength; hsize != -1 {
 		if size == verify.SizeUnknown {
 			size = hsize
+			// Nothing has accounted for this blob yet, so count it now.
+			if f.progress != nil {
+				f.progress.total(size)
+			}
 		} else if hsize != size {
-			return nil, fmt.Errorf("GET %s: Content-Length header %d does not match expected size %d", u.String(), hsize, size)
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("GET %s: Content-Length header %d does not match expected size %d", u.String(), hsize, size)
 		}
 	}
 
-	return verify.ReadCloser(resp.Body, size, h)
+	rc, err := verify.ReadCloser(f.bandwidth.reader(ctx, resp.Body), size, h)
+	if err != nil {
+		return nil, 0, err
+	}
+	if f.strict {
+		if rc, err = verifiedBlob(rc); err != nil {
+			return nil, 0, err
+		}
+	}
+	// Count progress outside of verification, so a blob that fails it is
+	// taken back.
+	return f.progress.reader(rc), size, nil
 }
 
 func (f *fetcher) headBlob(h v1.Hash) (*http.Response, error) {
@@ -15,6 +15,7 @@
 package remote
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -219,6 +220,75 @@ func TestHead_MissingHeaders(t *testing.T) {
 	}
 }
 
+// TestHeadFallback tests that HEAD requests registries mishandle are retried
+// as GETs when appropriate.
+func TestHeadFallback(t *testing.T) {
+	notAllowed := "not-allowed"
+	notFound := "not-found"
+	noDigest := "no-digest"
+	mediaType := types.OCIManifestSchema1
+	manifest := []byte(`{"schemaVersion":2}`)
+	want, _, err := v1.SHA256(bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", string(mediaType))
+			w.Write(manifest)
+			return
+		}
+		switch {
+		case strings.Contains(r.URL.Path, notAllowed):
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case strings.Contains(r.URL.Path, notFound):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(r.URL.Path, noDigest):
+			w.Header().Set("Content-Type", string(mediaType))
+			w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	for _, tc := range []struct {
+		repo     string
+		fallback bool
+		wantErr  bool
+	}{
+		{repo: notAllowed, fallback: false, wantErr: false},
+		{repo: notFound, fallback: false, wantErr: true},
+		{repo: noDigest, fallback: false, wantErr: true},
+		{repo: notAllowed, fallback: true, wantErr: false},
+		{repo: notFound, fallback: true, wantErr: false},
+		{repo: noDigest, fallback: true, wantErr: false},
+	} {
+		tag := mustNewTag(t, fmt.Sprintf("%s/%s:latest", u.Host, tc.repo))
+		desc, err := Head(tag, WithHeadFallback(tc.fallback))
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Head(%q, fallback=%t): expected error, got nil", tag, tc.fallback)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Head(%q, fallback=%t) = %v", tag, tc.fallback, err)
+			continue
+		}
+		if desc.Digest != want || desc.Size != int64(len(manifest)) || desc.MediaType != mediaType {
+			t.Errorf("Head(%q, fallback=%t) = %+v, wanted digest %s", tag, tc.fallback, desc, want)
+		}
+	}
+}
+
 // TestRedactFetchBlob tests that a request to fetchBlob that gets redirected
 // to a URL that contains sensitive information has that information redacted
 // if the subsequent request fails.
@@ -257,3 +327,52 @@ func (errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	return nil, fmt.Errorf("error reaching %s", req.URL.String())
 }
+
+func TestHeadTags(t *testing.T) {
+	expectedRepo := "foo/bar"
+	digest := func(tag string) string {
+		return "sha256:" + strings.Repeat(tag[:1], 64)
+	}
+	pings := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			pings++
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		tag := strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/v2/%s/manifests/", expectedRepo))
+		if tag == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+		w.Header().Set("Content-Length", "10")
+		w.Header().Set("Docker-Content-Digest", digest(tag))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	repo := mustNewTag(t, fmt.Sprintf("%s/%s:latest", u.Host, expectedRepo)).Context()
+
+	tags := []string{"a", "gone", "b"}
+	descs, err := HeadTags(repo, tags, WithJobs(1))
+	if err != nil {
+		t.Fatalf("HeadTags() = %v", err)
+	}
+	var got []string
+	for _, desc := range descs {
+		if desc == nil {
+			got = append(got, "")
+			continue
+		}
+		got = append(got, desc.Digest.String())
+	}
+	if diff := cmp.Diff([]string{digest("a"), "", digest("b")}, got); diff != "" {
+		t.Errorf("HeadTags() (-want +got) = %s", diff)
+	}
+	if pings != 1 {
+		t.Errorf("pinged the registry %d times, want 1", pings)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/internal/redact"
+	"github.com/google/go-containerregistry/internal/verify"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DownloadLayer writes the compressed contents of the blob that ref refers to
+// to the file at path, without going through v1.Layer. See Layer for what ref
+// means here.
+//
+// The blob is written to a temporary file next to path, which is renamed to
+// path only once the blob's size and digest have been verified, so path is
+// never left with partial or corrupt contents. Any existing file at path is
+// replaced. See WithPreallocate for extending the temporary file to the
+// blob's size before writing it.
+func DownloadLayer(ref name.Digest, path string, options ...Option) (rerr error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return err
+	}
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return err
+	}
+	h, err := v1.NewHash(ref.Identifier())
+	if err != nil {
+		return err
+	}
+
+	// We don't want to log binary layers -- this can break terminals.
+	ctx := redact.NewContext(o.context, "omitting binary blobs from logs")
+	rc, size, err := f.fetchSizedBlob(ctx, verify.SizeUnknown, h)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if o.preallocate && size > 0 {
+		if err := tmp.Truncate(size); err != nil {
+			return err
+		}
+	}
+	// The verifying reader returns an error from its final Read if the size
+	// or digest don't match, which fails the copy.
+	if _, err := io.Copy(tmp, rc); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
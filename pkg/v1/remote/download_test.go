@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestDownloadLayer(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve the layer normally, or with a byte flipped when corrupt is set.
+	var corrupt int32
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&corrupt) == 0 || r.Method != http.MethodGet || !strings.Contains(r.URL.Path, "/blobs/") {
+			reg.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		reg.ServeHTTP(rec, r)
+		b := rec.Body.Bytes()
+		b[0] ^= 0xff
+		w.WriteHeader(rec.Code)
+		w.Write(b)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigest(fmt.Sprintf("%s/some/path@%s", u.Host, digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLayer(ref.Context(), layer); err != nil {
+		t.Fatalf("failed to WriteLayer: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "layer.tar.gz")
+	if err := ioutil.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DownloadLayer(ref, path); err != nil {
+		t.Fatalf("DownloadLayer: %v", err)
+	}
+	if got, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, want) {
+		t.Errorf("downloaded %d bytes that don't match the layer's %d", len(got), len(want))
+	}
+
+	prealloc := filepath.Join(dir, "prealloc.tar.gz")
+	if err := DownloadLayer(ref, prealloc, WithPreallocate(true)); err != nil {
+		t.Fatalf("DownloadLayer(WithPreallocate): %v", err)
+	}
+	if got, err := ioutil.ReadFile(prealloc); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, want) {
+		t.Errorf("preallocated download of %d bytes doesn't match the layer's %d", len(got), len(want))
+	}
+
+	// A corrupt download fails and leaves what was there before alone.
+	atomic.StoreInt32(&corrupt, 1)
+	other := filepath.Join(dir, "other.tar.gz")
+	if err := DownloadLayer(ref, other); err == nil {
+		t.Error("DownloadLayer of corrupt blob: expected error")
+	}
+	if err := DownloadLayer(ref, path, WithPreallocate(true)); err == nil {
+		t.Error("DownloadLayer of corrupt blob: expected error")
+	}
+	if got, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, want) {
+		t.Error("failed DownloadLayer changed the existing file")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		names := []string{}
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("got files %v, want only %s and %s", names, filepath.Base(path), filepath.Base(prealloc))
+	}
+}
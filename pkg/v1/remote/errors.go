@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// IsNotFound reports whether err is a registry error saying that a repository,
+// manifest or blob doesn't exist: NAME_UNKNOWN, MANIFEST_UNKNOWN or
+// BLOB_UNKNOWN, or a 404 response without any error codes.
+func IsNotFound(err error) bool {
+	return hasErrorCode(err, []int{http.StatusNotFound},
+		transport.NameUnknownErrorCode,
+		transport.ManifestUnknownErrorCode,
+		transport.BlobUnknownErrorCode)
+}
+
+// IsRateLimited reports whether err is a registry error saying that too many
+// requests have been made: TOOMANYREQUESTS, or a 429 response without any
+// error codes.
+func IsRateLimited(err error) bool {
+	return hasErrorCode(err, []int{http.StatusTooManyRequests},
+		transport.TooManyRequestsErrorCode)
+}
+
+// IsAuthError reports whether err is a registry error saying that the request
+// wasn't authenticated or wasn't allowed: UNAUTHORIZED or DENIED, or a 401 or
+// 403 response without any error codes.
+func IsAuthError(err error) bool {
+	return hasErrorCode(err, []int{http.StatusUnauthorized, http.StatusForbidden},
+		transport.UnauthorizedErrorCode,
+		transport.DeniedErrorCode)
+}
+
+// HasErrorCode reports whether err is a registry error that includes any of
+// codes.
+func HasErrorCode(err error, codes ...transport.ErrorCode) bool {
+	return hasErrorCode(err, nil, codes...)
+}
+
+// hasErrorCode reports whether err is a *transport.Error that includes any of
+// codes or, if it doesn't include any codes at all, has any of statuses.
+func hasErrorCode(err error, statuses []int, codes ...transport.ErrorCode) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if len(terr.Errors) == 0 {
+		for _, status := range statuses {
+			if terr.StatusCode == status {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range terr.Errors {
+		for _, code := range codes {
+			if d.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestErrorHelpers(t *testing.T) {
+	for _, tc := range []struct {
+		desc                    string
+		status                  int
+		body                    string
+		notFound, limited, auth bool
+	}{{
+		desc:     "MANIFEST_UNKNOWN",
+		status:   http.StatusNotFound,
+		body:     `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"nope"}]}`,
+		notFound: true,
+	}, {
+		desc:     "NAME_UNKNOWN",
+		status:   http.StatusNotFound,
+		body:     `{"errors":[{"code":"NAME_UNKNOWN","message":"nope"}]}`,
+		notFound: true,
+	}, {
+		desc:     "404 without codes",
+		status:   http.StatusNotFound,
+		notFound: true,
+	}, {
+		desc:    "TOOMANYREQUESTS",
+		status:  http.StatusTooManyRequests,
+		body:    `{"errors":[{"code":"TOOMANYREQUESTS","message":"slow down"}]}`,
+		limited: true,
+	}, {
+		desc:    "429 without codes",
+		status:  http.StatusTooManyRequests,
+		limited: true,
+	}, {
+		desc:   "DENIED",
+		status: http.StatusForbidden,
+		body:   `{"errors":[{"code":"DENIED","message":"go away"}]}`,
+		auth:   true,
+	}, {
+		desc:   "403 without codes",
+		status: http.StatusForbidden,
+		auth:   true,
+	}, {
+		desc:   "UNAUTHORIZED",
+		status: http.StatusUnauthorized,
+		body:   `{"errors":[{"code":"UNAUTHORIZED","message":"who are you"}]}`,
+		auth:   true,
+	}, {
+		desc:   "codes win over status",
+		status: http.StatusNotFound,
+		body:   `{"errors":[{"code":"DENIED","message":"go away"}]}`,
+		auth:   true,
+	}, {
+		desc:   "other error",
+		status: http.StatusInternalServerError,
+		body:   `{"errors":[{"code":"UNKNOWN","message":"oops"}]}`,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v2/" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := name.ParseReference(u.Host + "/repo:tag")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = remote.Get(ref, remote.WithRetryBackoff(remote.Backoff{Duration: time.Millisecond, Steps: 1}))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			wrapped := fmt.Errorf("wrapped: %w", err)
+			if got := remote.IsNotFound(wrapped); got != tc.notFound {
+				t.Errorf("IsNotFound(%v) = %t, want %t", err, got, tc.notFound)
+			}
+			if got := remote.IsRateLimited(wrapped); got != tc.limited {
+				t.Errorf("IsRateLimited(%v) = %t, want %t", err, got, tc.limited)
+			}
+			if got := remote.IsAuthError(wrapped); got != tc.auth {
+				t.Errorf("IsAuthError(%v) = %t, want %t", err, got, tc.auth)
+			}
+		})
+	}
+}
+
+func TestErrorHelpersRegistry(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/missing:tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = remote.Get(ref)
+	if !remote.IsNotFound(err) {
+		t.Errorf("IsNotFound(%v) = false, want true", err)
+	}
+	if !remote.HasErrorCode(err, transport.NameUnknownErrorCode) {
+		t.Errorf("HasErrorCode(%v, NAME_UNKNOWN) = false, want true", err)
+	}
+	if remote.HasErrorCode(err, transport.DeniedErrorCode) {
+		t.Errorf("HasErrorCode(%v, DENIED) = true, want false", err)
+	}
+}
+
+func TestErrorHelpersNonRegistryError(t *testing.T) {
+	err := fmt.Errorf("not found")
+	if remote.IsNotFound(err) || remote.IsRateLimited(err) || remote.IsAuthError(err) || remote.HasErrorCode(err, transport.NameUnknownErrorCode) {
+		t.Errorf("helpers matched a non-registry error")
+	}
+	if remote.IsNotFound(nil) {
+		t.Errorf("IsNotFound(nil) = true")
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		sent = r.Header.Get("X-Correlation-Id")
+		w.Header().Set("X-Amzn-RequestId", "registry-id")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"nope"}]}`)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/repo:tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = remote.Get(ref, remote.WithRequestID("X-Correlation-Id", func() string { return "client-id" }))
+	if sent != "client-id" {
+		t.Errorf("sent request id %q, want %q", sent, "client-id")
+	}
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("Get() = %v, wanted *transport.Error", err)
+	}
+	if terr.RequestID != "registry-id" {
+		t.Errorf("RequestID = %q, want %q", terr.RequestID, "registry-id")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// EventKind is the kind of an Event.
+type EventKind string
+
+const (
+	// BlobExisting is sent when a blob is skipped because it already exists.
+	BlobExisting EventKind = "blob-existing"
+	// BlobMounted is sent when a blob is mounted from another repository.
+	BlobMounted EventKind = "blob-mounted"
+	// BlobPushed is sent when a blob has been uploaded.
+	BlobPushed EventKind = "blob-pushed"
+	// ManifestExisting is sent when a child of an index is skipped because
+	// it already exists.
+	ManifestExisting EventKind = "manifest-existing"
+	// ManifestPushed is sent when a manifest has been PUT by digest.
+	ManifestPushed EventKind = "manifest-pushed"
+	// TagUpdated is sent when a manifest has been PUT to a tag.
+	TagUpdated EventKind = "tag-updated"
+	// ChildComplete is sent when a child of an index being written by
+	// WriteIndex, and everything it refers to, exists in the registry.
+	ChildComplete EventKind = "child-complete"
+)
+
+// Event describes a step in writing an image or index, see WithEvents.
+type Event struct {
+	Kind EventKind
+
+	// Ref is the blob or manifest the event is about, by digest, or the tag
+	// that was updated.
+	Ref       name.Reference
+	Digest    v1.Hash
+	MediaType types.MediaType
+	Size      int64
+
+	// Child and Children are set for ChildComplete events: Child of Children
+	// manifests in the index have been written, counting from 1.
+	Child    int
+	Children int
+}
+
+// event sends e, if WithEvents is used.
+func (w *writer) event(e Event) {
+	if w.events == nil {
+		return
+	}
+	w.events <- e
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// countEvents drains c, which must be closed, and counts events by kind.
+func countEvents(t *testing.T, c <-chan Event) (map[EventKind]int, []Event) {
+	t.Helper()
+	counts := map[EventKind]int{}
+	var events []Event
+	for e := range c {
+		counts[e.Kind]++
+		events = append(events, e)
+	}
+	return counts, events
+}
+
+func TestWriteIndex_Events(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/events", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := make(chan Event, 100)
+	if err := WriteIndex(ref, idx, WithEvents(c)); err != nil {
+		t.Fatal(err)
+	}
+	counts, events := countEvents(t, c)
+	// Each image has two layers and a config blob.
+	want := map[EventKind]int{BlobPushed: 9, ManifestPushed: 3, ChildComplete: 3, TagUpdated: 1}
+	if diff := cmp.Diff(want, counts); diff != "" {
+		t.Errorf("events (-want +got): %s", diff)
+	}
+	var child int
+	for _, e := range events {
+		if e.Kind != ChildComplete {
+			continue
+		}
+		child++
+		if e.Child != child || e.Children != 3 {
+			t.Errorf("got child %d of %d, want %d of 3", e.Child, e.Children, child)
+		}
+	}
+	if last := events[len(events)-1]; last.Kind != TagUpdated || last.Digest != d || last.Ref.String() != ref.String() {
+		t.Errorf("last event: got %s %s %s, want %s %s %s", last.Kind, last.Ref, last.Digest, TagUpdated, ref, d)
+	}
+
+	// Writing it again finds the children.
+	c = make(chan Event, 100)
+	if err := WriteIndex(ref, idx, WithEvents(c)); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ = countEvents(t, c)
+	want = map[EventKind]int{ManifestExisting: 3, ChildComplete: 3, TagUpdated: 1}
+	if diff := cmp.Diff(want, counts); diff != "" {
+		t.Errorf("events (-want +got): %s", diff)
+	}
+}
+
+func TestMultiWrite_Events(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.ParseReference(fmt.Sprintf("%s/test/events:tag", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dig := tag.Context().Digest(d.String())
+
+	c := make(chan Event, 100)
+	if err := MultiWrite(map[name.Reference]Taggable{tag: img, dig: img}, WithEvents(c)); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ := countEvents(t, c)
+	want := map[EventKind]int{BlobPushed: 3, ManifestPushed: 1, TagUpdated: 1}
+	if diff := cmp.Diff(want, counts); diff != "" {
+		t.Errorf("events (-want +got): %s", diff)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/internal/redact"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// EdgeKind describes how one node of an ArtifactGraph refers to another.
+type EdgeKind string
+
+const (
+	// EdgeManifest is from an index to one of its manifests.
+	EdgeManifest EdgeKind = "manifest"
+	// EdgeConfig is from a manifest to its config.
+	EdgeConfig EdgeKind = "config"
+	// EdgeLayer is from a manifest to one of its layers.
+	EdgeLayer EdgeKind = "layer"
+	// EdgeSubject is from a manifest to its subject, e.g. from a signature to
+	// the image it signs.
+	EdgeSubject EdgeKind = "subject"
+)
+
+// GraphNode is a manifest or blob in an ArtifactGraph.
+type GraphNode struct {
+	Digest    v1.Hash         `json:"digest"`
+	MediaType types.MediaType `json:"mediaType"`
+	Size      int64           `json:"size"`
+	// Platform is set for manifests that an index gives a platform for.
+	Platform *v1.Platform `json:"platform,omitempty"`
+	// ArtifactType is set for referrers that have one.
+	ArtifactType string `json:"artifactType,omitempty"`
+}
+
+// GraphEdge is a reference from one node of an ArtifactGraph to another.
+type GraphEdge struct {
+	From v1.Hash  `json:"from"`
+	To   v1.Hash  `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// ArtifactGraph is the DAG of manifests and blobs reachable from Root.
+type ArtifactGraph struct {
+	Root  v1.Hash     `json:"root"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Graph returns the graph of the manifests and blobs that ref refers to,
+// transitively. With WithReferrers, it also includes the manifests that
+// refer to any manifest in the graph, e.g. signatures and attestations, and
+// everything they refer to.
+func Graph(ref name.Reference, options ...Option) (*ArtifactGraph, error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &grapher{
+		fetcher:   f,
+		repo:      ref.Context(),
+		referrers: o.referrers,
+		nodes:     map[v1.Hash]int{},
+		visited:   map[v1.Hash]bool{},
+	}
+	root, err := g.visit(ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Root = root
+	return &g.graph, nil
+}
+
+// WithReferrers makes Graph include the referrers of each manifest, using
+// the OCI referrers API. Registries that don't support it are treated as if
+// there are no referrers.
+func WithReferrers(o *options) error {
+	o.referrers = true
+	return nil
+}
+
+type grapher struct {
+	fetcher   *fetcher
+	repo      name.Repository
+	referrers bool
+
+	graph ArtifactGraph
+	// nodes indexes graph.Nodes by digest.
+	nodes map[v1.Hash]int
+	// visited is the set of manifests that have been fetched.
+	visited map[v1.Hash]bool
+}
+
+// add adds a node for desc, if it isn't already in the graph.
+func (g *grapher) add(desc v1.Descriptor) {
+	if i, ok := g.nodes[desc.Digest]; ok {
+		// The same manifest may be referred to with and without a platform.
+		if n := &g.graph.Nodes[i]; n.Platform == nil {
+			n.Platform = desc.Platform
+		}
+		return
+	}
+	g.nodes[desc.Digest] = len(g.graph.Nodes)
+	g.graph.Nodes = append(g.graph.Nodes, GraphNode{
+		Digest:       desc.Digest,
+		MediaType:    desc.MediaType,
+		Size:         desc.Size,
+		Platform:     desc.Platform,
+		ArtifactType: desc.ArtifactType,
+	})
+}
+
+func (g *grapher) edge(from, to v1.Hash, kind EdgeKind) {
+	g.graph.Edges = append(g.graph.Edges, GraphEdge{From: from, To: to, Kind: kind})
+}
+
+// visit adds the manifest ref to the graph, with everything it refers to. If
+// desc is non-nil, it's the descriptor ref was found with.
+func (g *grapher) visit(ref name.Reference, desc *v1.Descriptor) (v1.Hash, error) {
+	if desc != nil && g.visited[desc.Digest] {
+		g.add(*desc)
+		return desc.Digest, nil
+	}
+	acceptable := []types.MediaType{
+		types.DockerManifestSchema1,
+		types.DockerManifestSchema1Signed,
+	}
+	acceptable = append(acceptable, acceptableImageMediaTypes...)
+	acceptable = append(acceptable, acceptableIndexMediaTypes...)
+	if desc != nil {
+		acceptable = append(acceptable, desc.MediaType)
+	}
+	raw, got, err := g.fetcher.fetchManifest(ref, acceptable)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if desc != nil {
+		got.Platform = desc.Platform
+		got.ArtifactType = desc.ArtifactType
+	}
+	g.add(*got)
+	if g.visited[got.Digest] {
+		return got.Digest, nil
+	}
+	g.visited[got.Digest] = true
+
+	var m struct {
+		Config    *v1.Descriptor  `json:"config"`
+		Layers    []v1.Descriptor `json:"layers"`
+		Manifests []v1.Descriptor `json:"manifests"`
+		Subject   *v1.Descriptor  `json:"subject"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return v1.Hash{}, fmt.Errorf("parsing manifest %s: %w", got.Digest, err)
+	}
+
+	if m.Config != nil {
+		g.add(*m.Config)
+		g.edge(got.Digest, m.Config.Digest, EdgeConfig)
+	}
+	for _, l := range m.Layers {
+		g.add(l)
+		g.edge(got.Digest, l.Digest, EdgeLayer)
+	}
+	for _, child := range m.Manifests {
+		child := child
+		if _, err := g.visit(g.repo.Digest(child.Digest.String()), &child); err != nil {
+			return v1.Hash{}, err
+		}
+		g.edge(got.Digest, child.Digest, EdgeManifest)
+	}
+	if m.Subject != nil {
+		// The subject may not exist, so don't fetch it.
+		g.add(*m.Subject)
+		g.edge(got.Digest, m.Subject.Digest, EdgeSubject)
+	}
+
+	if !g.referrers {
+		return got.Digest, nil
+	}
+	im, err := g.fetcher.fetchReferrers(got.Digest)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	for _, r := range im.Manifests {
+		r := r
+		// The referrer's own subject edge points back here.
+		if _, err := g.visit(g.repo.Digest(r.Digest.String()), &r); err != nil {
+			return v1.Hash{}, err
+		}
+	}
+	return got.Digest, nil
+}
+
+// fetchReferrers returns the index of the manifests whose subject is h, or an
+// empty index if the registry doesn't support the referrers API.
+func (f *fetcher) fetchReferrers(h v1.Hash) (*v1.IndexManifest, error) {
+	reg := f.Ref.Context().Registry
+	if f.capabilities.Capabilities(reg).Referrers == CapabilityUnsupported {
+		return &v1.IndexManifest{}, nil
+	}
+	u := f.url("referrers", h.String())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(types.OCIImageIndex))
+
+	resp, err := f.Client.Do(req.WithContext(f.context))
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusNotFound); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		f.capabilities.record(reg, func(c *Capabilities) { c.Referrers = CapabilityUnsupported })
+		return &v1.IndexManifest{}, nil
+	}
+	f.capabilities.record(reg, func(c *Capabilities) { c.Referrers = CapabilitySupported })
+	return v1.ParseIndexManifest(resp.Body)
+}
+
+// DOT writes the graph to w in the Graphviz DOT language, e.g. to render it
+// with `dot -Tsvg`.
+func (g *ArtifactGraph) DOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph artifact {"); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		label := fmt.Sprintf("%s\n%s\n%d bytes", n.MediaType, n.Digest, n.Size)
+		if n.Platform != nil {
+			label += "\n" + n.Platform.String()
+		}
+		if n.ArtifactType != "" {
+			label += "\n" + n.ArtifactType
+		}
+		shape := "box"
+		if n.Digest == g.Root {
+			shape = "doubleoctagon"
+		} else if n.MediaType.IsIndex() || n.MediaType.IsImage() {
+			shape = "octagon"
+		}
+		if _, err := fmt.Fprintf(w, "  %q [label=%q, shape=%s];\n", n.Digest.String(), label, shape); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		style := "solid"
+		if e.Kind == EdgeSubject {
+			style = "dashed"
+		}
+		if _, err := fmt.Fprintf(w, "  %q -> %q [label=%q, style=%s];\n", e.From.String(), e.To.String(), e.Kind, style); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+type rawTaggable []byte
+
+func (r rawTaggable) RawManifest() ([]byte, error) {
+	return r, nil
+}
+
+func (r rawTaggable) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+func TestGraph(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/graph", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	root, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign the first image.
+	subject := im.Manifests[0]
+	sig, err := json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config: v1.Descriptor{
+			MediaType: "application/vnd.example.signature",
+			Digest:    v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)},
+			Size:      2,
+		},
+		Layers:  []v1.Descriptor{},
+		Subject: &subject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigDigest, _, err := v1.SHA256(bytes.NewReader(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Put(ref.Context().Digest(sigDigest.String()), rawTaggable(sig)); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := func(g *ArtifactGraph) map[EdgeKind]int {
+		counts := map[EdgeKind]int{}
+		for _, e := range g.Edges {
+			counts[e.Kind]++
+		}
+		return counts
+	}
+
+	// The index, 2 images, and each of their configs and 2 layers.
+	g, err := Graph(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Root != root {
+		t.Errorf("Root = %s, want %s", g.Root, root)
+	}
+	if got, want := len(g.Nodes), 9; got != want {
+		t.Errorf("got %d nodes, want %d", got, want)
+	}
+	if got, want := kinds(g), map[EdgeKind]int{EdgeManifest: 2, EdgeConfig: 2, EdgeLayer: 4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got edges %v, want %v", got, want)
+	}
+
+	// With the signature and its config.
+	g, err = Graph(ref, WithReferrers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(g.Nodes), 11; got != want {
+		t.Errorf("got %d nodes, want %d", got, want)
+	}
+	if got, want := kinds(g), map[EdgeKind]int{EdgeManifest: 2, EdgeConfig: 3, EdgeLayer: 4, EdgeSubject: 1}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got edges %v, want %v", got, want)
+	}
+	want := GraphEdge{From: sigDigest, To: subject.Digest, Kind: EdgeSubject}
+	found := false
+	for _, e := range g.Edges {
+		if e == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no edge %v", want)
+	}
+
+	var dot bytes.Buffer
+	if err := g.DOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"digraph artifact {",
+		fmt.Sprintf("%q -> %q [label=\"subject\", style=dashed];", sigDigest, subject.Digest),
+		fmt.Sprintf("%q [label=", root),
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot.String())
+		}
+	}
+}
@@ -15,7 +15,6 @@
 package remote
 
 import (
-	"bytes"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -45,6 +44,7 @@ type remoteImage struct {
 	config       []byte
 	mediaType    types.MediaType
 	descriptor   *v1.Descriptor
+	totalOnce    sync.Once // Counts the blobs in the manifest towards progress once
 }
 
 var _ partial.CompressedImageCore = (*remoteImage)(nil)
@@ -100,6 +100,7 @@ func (r *remoteImage) RawConfigFile() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	r.countProgress(m)
 
 	if m.Config.Data != nil {
 		if err := verify.Descriptor(m.Config); err != nil {
@@ -122,6 +123,24 @@ func (r *remoteImage) RawConfigFile() ([]byte, error) {
 	return r.config, nil
 }
 
+// countProgress adds the sizes of the blobs referenced by m to the progress
+// total the first time this image's blobs are read, if WithProgress is used.
+func (r *remoteImage) countProgress(m *v1.Manifest) {
+	if r.progress == nil {
+		return
+	}
+	r.totalOnce.Do(func() {
+		var total int64
+		if m.Config.Data == nil {
+			total += m.Config.Size
+		}
+		for _, l := range m.Layers {
+			total += l.Size
+		}
+		r.progress.total(total)
+	})
+}
+
 // Descriptor retains the original descriptor from an index manifest.
 // See partial.Descriptor.
 func (r *remoteImage) Descriptor() (*v1.Descriptor, error) {
@@ -152,8 +171,16 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 		return nil, err
 	}
 
+	if rl.ri.progress != nil {
+		m, err := rl.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		rl.ri.countProgress(m)
+	}
+
 	if d.Data != nil {
-		return verify.ReadCloser(ioutil.NopCloser(bytes.NewReader(d.Data)), d.Size, d.Digest)
+		return inlineData(*d, rl.ri.progress)
 	}
 
 	// We don't want to log binary layers -- this can break terminals.
@@ -190,7 +217,11 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 			continue
 		}
 
-		return verify.ReadCloser(resp.Body, d.Size, rl.digest)
+		rc, err := verify.ReadCloser(rl.ri.bandwidth.reader(ctx, resp.Body), d.Size, rl.digest)
+		if err != nil {
+			return nil, err
+		}
+		return rl.ri.progress.reader(rc), nil
 	}
 
 	return nil, lastErr
@@ -32,11 +32,11 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -533,7 +533,7 @@ func TestValidate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	reg, err := registry.TLS("gcr.io")
+	reg, err := NewTLSRegistry("gcr.io")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -552,6 +552,131 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestTLSPin(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("registry.internal/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := NewTLSRegistry("registry.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Close()
+	tr := WithTransport(reg.Client().Transport)
+
+	good := transport.TLSPin{SPKIHashes: []string{transport.SPKIHash(reg.Certificate())}}
+	if err := Write(tag, img, tr, WithTLSPin(tag.Context().Registry, good)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	bad := transport.TLSPin{SPKIHashes: []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}}
+	if _, err := Image(tag, tr, WithTLSPin(tag.Context().Registry, bad)); err == nil {
+		t.Error("Image succeeded with mismatched pin, wanted err")
+	}
+
+	// Pins for other registries don't apply.
+	other, err := name.NewRegistry("gcr.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Image(tag, tr, WithTLSPin(other, bad)); err != nil {
+		t.Errorf("Image: %v", err)
+	}
+
+	if _, err := Image(tag, WithTransport(http.NewFileTransport(http.Dir("."))), WithTLSPin(tag.Context().Registry, good)); err == nil {
+		t.Error("Image succeeded with non-*http.Transport, wanted err")
+	}
+}
+
+type hostKeychain map[string]authn.Authenticator
+
+func (k hostKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k[r.RegistryStr()]; ok {
+		return auth, nil
+	}
+	return authn.Anonymous, nil
+}
+
+func basicAuth(user string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != "secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", user))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestRedirectBasicAuth(t *testing.T) {
+	backend := NewRegistry()
+
+	// An artifact proxy with its own credentials, that serves blobs.
+	proxy := httptest.NewServer(basicAuth("proxy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Authorization")
+		backend.ServeHTTP(w, r)
+	})))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A registry that redirects blob downloads to the proxy.
+	reg := httptest.NewServer(basicAuth("registry", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/sha256:") {
+			http.Redirect(w, r, proxy.URL+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	})))
+	defer reg.Close()
+	regURL, err := url.Parse(reg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kc := hostKeychain{
+		regURL.Host:   &authn.Basic{Username: "registry", Password: "secret"},
+		proxyURL.Host: &authn.Basic{Username: "proxy", Password: "secret"},
+	}
+	tag, err := name.NewTag(regURL.Host + "/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(tag, img, WithAuthFromKeychain(kc)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	pulled, err := Image(tag, WithAuthFromKeychain(kc))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(pulled); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+
+	// With only the registry's credentials, its credentials must not be sent
+	// to the proxy.
+	kc = hostKeychain{regURL.Host: kc[regURL.Host]}
+	pulled, err = Image(tag, WithAuthFromKeychain(kc))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if err := validate.Image(pulled); err == nil {
+		t.Error("validate.Image succeeded without proxy credentials, wanted err")
+	}
+}
+
 func TestPullingForeignLayer(t *testing.T) {
 	// For that sweet, sweet coverage in options.
 	var b bytes.Buffer
@@ -661,7 +786,7 @@ func TestPullingForeignLayer(t *testing.T) {
 
 	// Set up a fake registry and write what we pulled to it.
 	// This ensures we get coverage for the remoteLayer.MediaType path.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err = url.Parse(s.URL)
 	if err != nil {
@@ -739,3 +864,49 @@ func TestData(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDataCorrupt(t *testing.T) {
+	img := randomImage(t)
+	manifest, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Inline data that doesn't match the layer's digest.
+	manifest.Layers[0].Data = bytes.Repeat([]byte{'x'}, int(manifest.Layers[0].Size))
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/test/manifests/latest":
+			w.Write(rawManifest)
+		default:
+			t.Errorf("Unexpected path: %v", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	ref, err := newReference(u.Host, "test", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rmt, err := Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := rmt.LayerByDigest(manifest.Layers[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Compressed(); err == nil {
+		t.Error("Compressed() with corrupt Data: expected error")
+	}
+}
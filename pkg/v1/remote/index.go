@@ -131,9 +131,11 @@ func (r *remoteIndex) Layer(h v1.Hash) (v1.Layer, error) {
 	}
 	for _, childDesc := range index.Manifests {
 		if h == childDesc.Digest {
+			childDesc := childDesc
 			l, err := partial.CompressedToLayer(&remoteLayer{
 				fetcher: r.fetcher,
 				digest:  h,
+				desc:    &childDesc,
 			})
 			if err != nil {
 				return nil, err
@@ -191,30 +193,38 @@ func (r *remoteIndex) imageByPlatform(platform v1.Platform) (v1.Image, error) {
 	return desc.Image()
 }
 
-// This naively matches the first manifest with matching platform attributes.
-//
-// We should probably use this instead:
-//	 github.com/containerd/containerd/platforms
-//
-// But first we'd need to migrate to:
-//   github.com/opencontainers/image-spec/specs-go/v1
+// childByPlatform returns the child that most closely matches platform. See
+// WithPlatform for how platforms are matched.
 func (r *remoteIndex) childByPlatform(platform v1.Platform) (*Descriptor, error) {
 	index, err := r.IndexManifest()
 	if err != nil {
 		return nil, err
 	}
-	for _, childDesc := range index.Manifests {
+	required := normalizePlatform(platform)
+	var (
+		best  *v1.Descriptor
+		bestP v1.Platform
+	)
+	for i, childDesc := range index.Manifests {
 		// If platform is missing from child descriptor, assume it's amd64/linux.
 		p := defaultPlatform
 		if childDesc.Platform != nil {
 			p = *childDesc.Platform
 		}
+		p = normalizePlatform(p)
 
-		if matchesPlatform(p, platform) {
-			return r.childDescriptor(childDesc, platform)
+		if !platformMatches(p, required, r.variantFallback) {
+			continue
 		}
+		// Ties go to whichever comes first.
+		if best == nil || closerPlatform(p, bestP, required, r.variantFallback) {
+			best, bestP = &index.Manifests[i], p
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no child with platform %+v in index %s", platform, r.Ref)
 	}
-	return nil, fmt.Errorf("no child with platform %+v in index %s", platform, r.Ref)
+	return r.childDescriptor(*best, platform)
 }
 
 func (r *remoteIndex) childByHash(h v1.Hash) (*Descriptor, error) {
@@ -250,9 +260,15 @@ func (r *remoteIndex) childDescriptor(child v1.Descriptor, platform v1.Platform)
 	}
 	return &Descriptor{
 		fetcher: fetcher{
-			Ref:     ref,
-			Client:  r.Client,
-			context: r.context,
+			Ref:             ref,
+			Client:          r.Client,
+			context:         r.context,
+			capabilities:    r.capabilities,
+			progress:        r.progress,
+			bandwidth:       r.bandwidth,
+			strict:          r.strict,
+			headFallback:    r.headFallback,
+			variantFallback: r.variantFallback,
 		},
 		Manifest:   manifest,
 		Descriptor: child,
@@ -262,20 +278,27 @@ func (r *remoteIndex) childDescriptor(child v1.Descriptor, platform v1.Platform)
 
 // matchesPlatform checks if the given platform matches the required platforms.
 // The given platform matches the required platform if
-// - architecture and OS are identical.
-// - OS version and variant are identical if provided.
+// - architecture and OS are identical, once normalized.
+// - variant is identical if provided, once normalized.
+// - OS version is identical if provided, or for Windows, has the same build.
 // - features and OS features of the required platform are subsets of those of the given platform.
 func matchesPlatform(given, required v1.Platform) bool {
+	return platformMatches(normalizePlatform(given), normalizePlatform(required), false)
+}
+
+// platformMatches is matchesPlatform for normalized platforms, optionally
+// allowing older arm variants.
+func platformMatches(given, required v1.Platform, variantFallback bool) bool {
 	// Required fields that must be identical.
 	if given.Architecture != required.Architecture || given.OS != required.OS {
 		return false
 	}
 
-	// Optional fields that may be empty, but must be identical if provided.
-	if required.OSVersion != "" && given.OSVersion != required.OSVersion {
+	// Optional fields that may be empty, but must be compatible if provided.
+	if !osVersionMatches(given, required) {
 		return false
 	}
-	if required.Variant != "" && given.Variant != required.Variant {
+	if variantDistance(given, required, variantFallback) < 0 {
 		return false
 	}
 
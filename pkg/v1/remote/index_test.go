@@ -17,7 +17,9 @@ package remote
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -502,3 +504,74 @@ func TestMatchesPlatform(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexLayerData(t *testing.T) {
+	blob := []byte("not an image")
+	h, size, err := v1.SHA256(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := types.MediaType("application/vnd.example.blob")
+	rawManifest, err := json.Marshal(v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests: []v1.Descriptor{{
+			MediaType: mt,
+			Size:      size,
+			Digest:    h,
+			Data:      blob,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/test/manifests/latest":
+			w.Header().Set("Content-Type", string(types.OCIImageIndex))
+			w.Write(rawManifest)
+		default:
+			// The blob should be served from Data.
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	ref, err := newReference(u.Host, "test", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Index(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := idx.(*remoteIndex).Layer(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.Size(); err != nil || got != size {
+		t.Errorf("Size() = %d, %v; want %d", got, err, size)
+	}
+	if got, err := l.MediaType(); err != nil || got != mt {
+		t.Errorf("MediaType() = %s, %v; want %s", got, err, mt)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Compressed() = %q, want %q", got, blob)
+	}
+}
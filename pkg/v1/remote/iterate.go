@@ -0,0 +1,280 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/internal/verify"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// IndexIterator reads the descriptors in the manifests of an index one at a
+// time, as they're downloaded, so that huge indexes can be processed without
+// holding them in memory. It's returned by IterateIndex.
+//
+// Like sql.Rows, call Next to advance to each descriptor, and check Err once
+// Next returns false:
+//
+//	it, err := remote.IterateIndex(ref)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		desc := it.Descriptor()
+//		...
+//	}
+//	return it.Err()
+type IndexIterator struct {
+	f      *fetcher
+	ref    name.Reference
+	body   *countingReader
+	dec    *json.Decoder
+	hasher hash.Hash
+	alg    string
+	header v1.Hash
+
+	// inManifests is set while dec is inside the manifests array.
+	inManifests bool
+	desc        v1.Descriptor
+	err         error
+	done        bool
+}
+
+// IterateIndex fetches the index at ref and returns an IndexIterator over the
+// descriptors in its manifests. It's an alternative to Index for indexes with
+// so many manifests, such as per-commit attestations, that parsing them all at
+// once takes too much memory.
+//
+// The index is verified against ref's digest, if it has one, once it has all
+// been read, so Err reports a mismatch after the last descriptor. With
+// WithDigestVerification, an index fetched by tag is also verified against the
+// Docker-Content-Digest header, if any. The digest is computed with the
+// algorithm of ref's digest, or else of the header's.
+//
+// Indexes larger than the registry is known to accept for a manifest are
+// rejected, so that a misbehaving registry can't stream one forever.
+func IterateIndex(ref name.Reference, options ...Option) (*IndexIterator, error) {
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, err
+	}
+
+	u := f.url("manifests", ref.Identifier())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	accept := []string{}
+	for _, mt := range acceptableIndexMediaTypes {
+		accept = append(accept, string(mt))
+	}
+	req.Header.Set("Accept", strings.Join(accept, ","))
+
+	resp, err := f.Client.Do(req.WithContext(f.context))
+	if err != nil {
+		return nil, err
+	}
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if mt := types.MediaType(resp.Header.Get("Content-Type")); mt.IsImage() {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected media type for IterateIndex(): %s", mt)
+	}
+
+	body := &countingReader{ReadCloser: resp.Body, limit: makeSizeLimits(ref.Context().Registry, o).manifest, ref: ref}
+	if body.limit > 0 && resp.ContentLength > body.limit {
+		resp.Body.Close()
+		return nil, body.tooLarge(resp.ContentLength)
+	}
+	it := &IndexIterator{
+		f:    f,
+		ref:  ref,
+		body: body,
+		alg:  "sha256",
+	}
+	it.header, _ = v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
+	if dgst, ok := pinnedDigest(ref); ok {
+		h, err := v1.NewHash(dgst)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		it.alg = h.Algorithm
+	} else if it.header != (v1.Hash{}) {
+		it.alg = it.header.Algorithm
+	}
+	if it.hasher, err = v1.Hasher(it.alg); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	it.dec = json.NewDecoder(io.TeeReader(it.body, it.hasher))
+	if err := it.expectDelim('{'); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// Next advances to the next descriptor, returning false when there are no
+// more or an error occurred, which Err returns.
+func (it *IndexIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if err := it.next(); err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+	return !it.done
+}
+
+func (it *IndexIterator) next() error {
+	for {
+		if it.inManifests {
+			if it.dec.More() {
+				it.desc = v1.Descriptor{}
+				if err := it.dec.Decode(&it.desc); err != nil {
+					return fmt.Errorf("decoding manifests: %w", err)
+				}
+				return nil
+			}
+			if err := it.expectDelim(']'); err != nil {
+				return err
+			}
+			it.inManifests = false
+		}
+
+		if !it.dec.More() {
+			// That's everything.
+			if err := it.expectDelim('}'); err != nil {
+				return err
+			}
+			it.done = true
+			return it.verify()
+		}
+		tok, err := it.dec.Token()
+		if err != nil {
+			return fmt.Errorf("decoding index: %w", err)
+		}
+		if key, _ := tok.(string); key == "manifests" {
+			tok, err := it.dec.Token()
+			if err != nil {
+				return fmt.Errorf("decoding manifests: %w", err)
+			}
+			if tok == nil {
+				continue
+			}
+			if d, ok := tok.(json.Delim); !ok || d != '[' {
+				return fmt.Errorf("decoding manifests: got %v, want an array", tok)
+			}
+			it.inManifests = true
+			continue
+		}
+		// Skip the values of other fields.
+		var skip json.RawMessage
+		if err := it.dec.Decode(&skip); err != nil {
+			return fmt.Errorf("decoding index: %w", err)
+		}
+	}
+}
+
+func (it *IndexIterator) expectDelim(want json.Delim) error {
+	tok, err := it.dec.Token()
+	if err != nil {
+		return fmt.Errorf("decoding index: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("decoding index: got %v, want %v", tok, want)
+	}
+	return nil
+}
+
+// verify checks the digest of the index, once it has all been decoded.
+func (it *IndexIterator) verify() error {
+	// Everything the decoder has read has been hashed, so hash whatever is
+	// left of the body too, e.g. a trailing newline.
+	if _, err := io.Copy(it.hasher, it.body); err != nil {
+		return err
+	}
+	got := v1.Hash{Algorithm: it.alg, Hex: hex.EncodeToString(it.hasher.Sum(nil))}
+	if it.f.strict {
+		return it.f.verifyManifest(it.ref, got, it.body.n, it.header)
+	}
+	if dgst, ok := pinnedDigest(it.ref); ok && dgst != got.String() {
+		want, err := v1.NewHash(dgst)
+		if err != nil {
+			return err
+		}
+		return verify.NewError(got, want, it.body.n)
+	}
+	return nil
+}
+
+// Descriptor returns the current descriptor.
+func (it *IndexIterator) Descriptor() v1.Descriptor {
+	return it.desc
+}
+
+// Err returns the error, if any, that stopped Next.
+func (it *IndexIterator) Err() error {
+	return it.err
+}
+
+// Close closes the connection to the registry. It's safe to call more than
+// once, including after reading every descriptor.
+func (it *IndexIterator) Close() error {
+	it.done = true
+	return it.body.Close()
+}
+
+// countingReader counts the bytes read from it, and fails once it has read
+// more than limit, if limit is positive.
+type countingReader struct {
+	io.ReadCloser
+	n     int64
+	limit int64
+	ref   name.Reference
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	if c.limit > 0 && c.n > c.limit {
+		return 0, c.tooLarge(c.n)
+	}
+	return n, err
+}
+
+func (c *countingReader) tooLarge(size int64) error {
+	return fmt.Errorf("index %s is %d bytes, larger than the %d bytes accepted by %s for a manifest", c.ref, size, c.limit, c.ref.Context().Registry)
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/internal/verify"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func iterate(ref name.Reference, options ...Option) ([]v1.Descriptor, error) {
+	it, err := IterateIndex(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var descs []v1.Descriptor
+	for it.Next() {
+		descs = append(descs, it.Descriptor())
+	}
+	return descs, it.Err()
+}
+
+func TestIterateIndex(t *testing.T) {
+	// A wrong digest is served the index at the tag.
+	wrong := "sha256:" + strings.Repeat("a", 64)
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = strings.Replace(r.URL.Path, "/manifests/"+wrong, "/manifests/latest", 1)
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/iterate", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := repo.Tag("latest")
+	if err := WriteIndex(tag, idx); err != nil {
+		t.Fatal(err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []name.Reference{tag, repo.Digest(h.String())} {
+		for _, strict := range []bool{false, true} {
+			got, err := iterate(ref, WithDigestVerification(strict))
+			if err != nil {
+				t.Fatalf("iterate(%s, %t): %v", ref, strict, err)
+			}
+			if diff := cmp.Diff(m.Manifests, got); diff != "" {
+				t.Errorf("iterate(%s, %t) (-want +got) = %s", ref, strict, diff)
+			}
+		}
+	}
+
+	// The mismatch is reported once everything has been read.
+	got, err := iterate(repo.Digest(wrong))
+	var verr verify.Error
+	if !errors.As(err, &verr) {
+		t.Errorf("iterate(wrong digest) = %v, wanted verify.Error", err)
+	}
+	if len(got) != len(m.Manifests) {
+		t.Errorf("iterate(wrong digest) got %d descriptors, want %d", len(got), len(m.Manifests))
+	}
+
+	// Images aren't indexes.
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgRef := repo.Tag("image")
+	if err := Write(imgRef, img); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := IterateIndex(imgRef); err == nil {
+		t.Error("IterateIndex(image) = nil, wanted error")
+	}
+}
+
+func TestIterateIndexLimit(t *testing.T) {
+	ref := name.MustParseReference("index.docker.io/test/iterate")
+	body := &countingReader{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat(" ", 100))), limit: 10, ref: ref}
+	if _, err := ioutil.ReadAll(body); err == nil || !strings.Contains(err.Error(), "larger than the 10 bytes") {
+		t.Errorf("reading past the limit = %v, wanted error", err)
+	}
+
+	body = &countingReader{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat(" ", 10))), limit: 10, ref: ref}
+	if _, err := ioutil.ReadAll(body); err != nil {
+		t.Errorf("reading up to the limit = %v", err)
+	}
+
+	// Docker Hub's limit is applied to indexes it serves.
+	if got := makeSizeLimits(ref.Context().Registry, &options{}).manifest; got != 4<<20 {
+		t.Errorf("manifest limit = %d, want %d", got, 4<<20)
+	}
+}
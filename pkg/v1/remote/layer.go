@@ -15,7 +15,9 @@
 package remote
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/internal/verify"
@@ -29,10 +31,20 @@ import (
 type remoteLayer struct {
 	fetcher
 	digest v1.Hash
+
+	// desc is the descriptor that referred to this layer, if we have one.
+	desc *v1.Descriptor
 }
 
 // Compressed implements partial.CompressedLayer
 func (rl *remoteLayer) Compressed() (io.ReadCloser, error) {
+	if rl.desc != nil && rl.desc.Data != nil {
+		if rl.progress != nil {
+			rl.progress.total(rl.desc.Size)
+		}
+		return inlineData(*rl.desc, rl.progress)
+	}
+
 	// We don't want to log binary layers -- this can break terminals.
 	ctx := redact.NewContext(rl.context, "omitting binary blobs from logs")
 	return rl.fetchBlob(ctx, verify.SizeUnknown, rl.digest)
@@ -40,6 +52,9 @@ func (rl *remoteLayer) Compressed() (io.ReadCloser, error) {
 
 // Compressed implements partial.CompressedLayer
 func (rl *remoteLayer) Size() (int64, error) {
+	if rl.desc != nil {
+		return rl.desc.Size, nil
+	}
 	resp, err := rl.headBlob(rl.digest)
 	if err != nil {
 		return -1, err
@@ -55,9 +70,21 @@ func (rl *remoteLayer) Digest() (v1.Hash, error) {
 
 // MediaType implements v1.Layer
 func (rl *remoteLayer) MediaType() (types.MediaType, error) {
+	if rl.desc != nil && rl.desc.MediaType != "" {
+		return rl.desc.MediaType, nil
+	}
 	return types.DockerLayer, nil
 }
 
+// inlineData returns the contents of d.Data, once they have been verified
+// against d's digest and size, instead of fetching the blob they describe.
+func inlineData(d v1.Descriptor, p *progress) (io.ReadCloser, error) {
+	if err := verify.Descriptor(d); err != nil {
+		return nil, err
+	}
+	return p.reader(ioutil.NopCloser(bytes.NewReader(d.Data))), nil
+}
+
 // See partial.Exists.
 func (rl *remoteLayer) Exists() (bool, error) {
 	return rl.blobExists(rl.digest)
@@ -16,13 +16,15 @@ package remote
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/internal/compare"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -43,7 +45,7 @@ func TestRemoteLayer(t *testing.T) {
 
 	// Set up a fake registry and write what we pulled to it.
 	// This ensures we get coverage for the remoteLayer.MediaType path.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	t.Log(s.URL)
 	u, err := url.Parse(s.URL)
@@ -101,7 +103,7 @@ func TestRemoteLayerDescriptor(t *testing.T) {
 
 	// Set up a fake registry and write what we pulled to it.
 	// This ensures we get coverage for the remoteLayer.MediaType path.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -146,3 +148,57 @@ func TestRemoteLayerDescriptor(t *testing.T) {
 		t.Errorf("Exists() = %t != %t", got, want)
 	}
 }
+
+func TestRemoteLayerBlobDecompression(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A registry that claims its already-gzipped blobs are gzipped on the
+	// fly whenever it's allowed to, so they're decompressed by mistake.
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/sha256:") && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := name.NewDigest(fmt.Sprintf("%s/some/path@%s", u.Host, digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLayer(dst.Context(), layer); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		enabled bool
+		wantErr bool
+	}{{true, true}, {false, false}} {
+		t.Run(fmt.Sprintf("enabled=%t", tc.enabled), func(t *testing.T) {
+			got, err := Layer(dst, WithBlobDecompression(tc.enabled))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := got.Compressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			_, err = io.Copy(io.Discard, rc)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("reading layer: got err %v, wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -45,6 +46,42 @@ func List(repo name.Repository, options ...Option) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	l, err := newLister(repo, o)
+	if err != nil {
+		return nil, err
+	}
+
+	tagList := []string{}
+	for l.HasNext() {
+		page, err := l.Next(o.context)
+		if err != nil {
+			return nil, err
+		}
+		tagList = append(tagList, page...)
+	}
+
+	return tagList, nil
+}
+
+// Lister pages through the tags of a repository, fetching each page only when
+// it is asked for. See NewLister.
+type Lister struct {
+	pager pager
+}
+
+// NewLister returns a Lister for the tags of repo. Unlike List, which fetches
+// every tag before returning any of them, each call to Next fetches and
+// returns a single page of tags, so repositories with very many tags can be
+// processed incrementally. The size of pages can be set with WithPageSize.
+func NewLister(repo name.Repository, options ...Option) (*Lister, error) {
+	o, err := makeOptions(repo, options...)
+	if err != nil {
+		return nil, err
+	}
+	return newLister(repo, o)
+}
+
+func newLister(repo name.Repository, o *options) (*Lister, error) {
 	scopes := []string{repo.Scope(transport.PullScope)}
 	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
 	if err != nil {
@@ -61,53 +98,73 @@ func List(repo name.Repository, options ...Option) ([]string, error) {
 		uri.RawQuery = fmt.Sprintf("n=%d", o.pageSize)
 	}
 
-	client := http.Client{Transport: tr}
-	tagList := []string{}
-	parsed := tags{}
-
-	// get responses until there is no next page
-	for {
-		select {
-		case <-o.context.Done():
-			return nil, o.context.Err()
-		default:
-		}
+	return &Lister{
+		pager: pager{
+			client: &http.Client{Transport: tr},
+			next:   uri,
+		},
+	}, nil
+}
 
-		req, err := http.NewRequestWithContext(o.context, "GET", uri.String(), nil)
-		if err != nil {
-			return nil, err
-		}
+// HasNext returns true if there are more pages of tags to fetch.
+func (l *Lister) HasNext() bool {
+	return l.pager.next != nil
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
+// Next fetches the next page of tags, or returns io.EOF if there are no more.
+func (l *Lister) Next(ctx context.Context) ([]string, error) {
+	var parsed tags
+	if err := l.pager.fetch(ctx, &parsed); err != nil {
+		return nil, err
+	}
+	return parsed.Tags, nil
+}
 
-		if err := transport.CheckError(resp, http.StatusOK); err != nil {
-			return nil, err
-		}
+// pager follows the Link headers of a paginated API from page to page.
+type pager struct {
+	client *http.Client
 
-		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-			return nil, err
-		}
+	// next is the URL of the next page, or nil after the last page.
+	next *url.URL
+}
 
-		if err := resp.Body.Close(); err != nil {
-			return nil, err
-		}
+// fetch decodes the next page into v.
+func (p *pager) fetch(ctx context.Context, v interface{}) error {
+	if p.next == nil {
+		return io.EOF
+	}
 
-		tagList = append(tagList, parsed.Tags...)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
 
-		uri, err = getNextPageURL(resp)
-		if err != nil {
-			return nil, err
-		}
-		// no next page
-		if uri == nil {
-			break
-		}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.next.String(), nil)
+	if err != nil {
+		return err
 	}
 
-	return tagList, nil
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return err
+	}
+
+	next, err := getNextPageURL(resp)
+	if err != nil {
+		return err
+	}
+	p.next = next
+	return nil
 }
 
 // getNextPageURL checks if there is a Link header in a http.Response which
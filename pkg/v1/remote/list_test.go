@@ -17,6 +17,7 @@ package remote
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -86,6 +87,71 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestLister(t *testing.T) {
+	repoName := "ubuntu"
+	tagsPath := fmt.Sprintf("/v2/%s/tags/list", repoName)
+	pages := map[string]string{
+		"":  `{"tags":["a","b"]}`,
+		"b": `{"tags":["c"]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case tagsPath:
+			requests++
+			if got, want := r.URL.Query().Get("n"), "2"; got != want {
+				t.Errorf("n = %q, want %q", got, want)
+			}
+			last := r.URL.Query().Get("last")
+			if last == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?n=2&last=b>; rel="next"`, tagsPath))
+			}
+			w.Write([]byte(pages[last]))
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s", u.Host, repoName), name.WeakValidation)
+	if err != nil {
+		t.Fatalf("name.NewRepository(%v) = %v", repoName, err)
+	}
+
+	l, err := NewLister(repo, WithPageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i, want := range [][]string{{"a", "b"}, {"c"}} {
+		if !l.HasNext() {
+			t.Fatalf("page %d: HasNext() = false", i)
+		}
+		if requests != i {
+			t.Errorf("page %d: made %d requests before Next, want %d", i, requests, i)
+		}
+		got, err := l.Next(ctx)
+		if err != nil {
+			t.Fatalf("page %d: Next() = %v", i, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("page %d: Next() wrong tags (-want +got) = %s", i, diff)
+		}
+	}
+	if l.HasNext() {
+		t.Error("HasNext() = true after last page")
+	}
+	if _, err := l.Next(ctx); err != io.EOF {
+		t.Errorf("Next() after last page = %v, want io.EOF", err)
+	}
+}
+
 func TestCancelledList(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
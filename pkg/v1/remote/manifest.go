@@ -0,0 +1,66 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"github.com/google/go-containerregistry/internal/retry"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// GetManifestBytes returns the exact bytes of the manifest ref refers to, as
+// served by the registry, and a descriptor of them. Unlike Get, nothing else
+// is set up to turn the manifest into a v1.Image or v1.ImageIndex, and an
+// index is never resolved to an image for WithPlatform.
+//
+// The descriptor's digest and size are computed from the bytes; its media
+// type is the response's Content-Type. If ref is a digest, the bytes must
+// match it. Otherwise, the Docker-Content-Digest header is only checked with
+// WithDigestVerification, and, for signed schema 1 manifests, is used as the
+// digest unless WithDigestVerification is set.
+//
+// Failures to fetch or read the manifest are retried according to
+// WithRetryBackoff and WithRetryPredicate.
+func GetManifestBytes(ref name.Reference, options ...Option) ([]byte, *v1.Descriptor, error) {
+	acceptable := []types.MediaType{
+		// Just to look at them.
+		types.DockerManifestSchema1,
+		types.DockerManifestSchema1Signed,
+	}
+	acceptable = append(acceptable, acceptableImageMediaTypes...)
+	acceptable = append(acceptable, acceptableIndexMediaTypes...)
+
+	o, err := makeOptions(ref.Context(), options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		b    []byte
+		desc *v1.Descriptor
+	)
+	if err := retry.Retry(func() error {
+		b, desc, err = f.fetchManifest(ref, acceptable)
+		return err
+	}, o.retryPredicate, o.retryBackoff); err != nil {
+		return nil, nil, err
+	}
+	return b, desc, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestGetManifestBytes(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	digest, size, err := v1.SHA256(bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut the first response short, so that reading it fails.
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasPrefix(r.URL.Path, "/v2/foo/manifests/"):
+			gets++
+			w.Header().Set("Content-Type", string(types.OCIImageIndex))
+			w.Header().Set("Content-Length", fmt.Sprint(len(manifest)))
+			if gets == 1 {
+				w.Write(manifest[:10])
+				return
+			}
+			w.Write(manifest)
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backoff := WithRetryBackoff(Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3})
+
+	tag := mustNewTag(t, u.Host+"/foo:latest")
+	b, desc, err := GetManifestBytes(tag, backoff)
+	if err != nil {
+		t.Fatalf("GetManifestBytes(%s) = %v", tag, err)
+	}
+	if !bytes.Equal(b, manifest) {
+		t.Errorf("GetManifestBytes() = %s, want %s", b, manifest)
+	}
+	if want := (v1.Descriptor{MediaType: types.OCIImageIndex, Size: size, Digest: digest}); desc.Digest != want.Digest || desc.Size != want.Size || desc.MediaType != want.MediaType {
+		t.Errorf("GetManifestBytes() descriptor = %+v, want %+v", desc, want)
+	}
+	if gets != 2 {
+		t.Errorf("got %d GETs, want 2", gets)
+	}
+
+	// Pulling by the wrong digest fails.
+	bogus, err := name.NewDigest(u.Host + "/foo@sha256:" + strings.Repeat("0", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GetManifestBytes(bogus, backoff); err == nil {
+		t.Errorf("GetManifestBytes(%s) = nil, want digest mismatch", bogus)
+	}
+}
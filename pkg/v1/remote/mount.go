@@ -15,6 +15,8 @@
 package remote
 
 import (
+	"io"
+
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -39,6 +41,35 @@ func (ml *MountableLayer) Exists() (bool, error) {
 	return partial.Exists(ml.Layer)
 }
 
+// RangeReader reads part of the layer, if the wrapped layer supports it.
+// See partial.RangeReader.
+func (ml *MountableLayer) RangeReader(off, n int64) (io.ReadCloser, error) {
+	return partial.RangeReader(ml.Layer, off, n)
+}
+
+// MountableImage returns img with its layers and config wrapped as
+// MountableLayers of ref, so that Write tries to mount them from ref's
+// repository before uploading them, and asks for permission to pull from it
+// when authenticating.
+//
+// Images returned by Image already behave this way. MountableImage is for
+// images that weren't, such as a base image loaded from a tarball or layout
+// that is known to be in ref, so that tools appending to it mount its layers
+// rather than uploading them again:
+//
+//	base = remote.MountableImage(base, baseRef)
+//	img, err := mutate.AppendLayers(base, layer)
+//	...
+//	err = remote.Write(dst, img)
+//
+// To hint repositories for all blobs instead, see WithMountFrom.
+func MountableImage(img v1.Image, ref name.Reference) v1.Image {
+	return &mountableImage{
+		Image:     img,
+		Reference: ref,
+	}
+}
+
 // mountableImage wraps the v1.Layer references returned by the embedded v1.Image
 // in MountableLayer's so that remote.Write might attempt to mount them from their
 // source repository.
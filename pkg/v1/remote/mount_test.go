@@ -15,10 +15,17 @@
 package remote
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
 
@@ -53,3 +60,130 @@ func TestMountableImage(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteMountableImage(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		mounts  []string
+		uploads []string
+	)
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The registry stores blobs across repositories, so hide them
+		// from app to make Write mount or upload them.
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/app/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/v2/app/blobs/uploads") {
+			mu.Lock()
+			defer mu.Unlock()
+			// The registry doesn't implement mounting, but base has
+			// all of the blobs it could be asked to mount.
+			if m := r.URL.Query().Get("mount"); m != "" && r.URL.Query().Get("from") == "base" {
+				mounts = append(mounts, m)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			if d := r.URL.Query().Get("digest"); d != "" {
+				uploads = append(uploads, d)
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseRef, err := name.ParseReference(u.Host + "/base:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := name.ParseReference(u.Host + "/app:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(baseRef, base); err != nil {
+		t.Fatal(err)
+	}
+
+	// Append to the base image we have locally, which knows nothing of
+	// baseRef until we tell it.
+	layer, err := random.Layer(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(MountableImage(base, baseRef), layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(dst, img); err != nil {
+		t.Fatal(err)
+	}
+
+	ls, err := base.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounted := map[string]bool{}
+	for _, m := range mounts {
+		mounted[m] = true
+	}
+	for _, l := range ls {
+		d, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !mounted[d.String()] {
+			t.Errorf("base layer %s wasn't mounted", d)
+		}
+	}
+
+	// Only the new layer and config should have been uploaded.
+	ld, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{ld.String(): true, cd.String(): true}
+	if len(uploads) != len(want) {
+		t.Errorf("uploaded %v, want only %v", uploads, want)
+	}
+	for _, d := range uploads {
+		if !want[d] {
+			t.Errorf("unexpectedly uploaded %s", d)
+		}
+	}
+}
+
+func TestMountableImageScopes(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseRef, err := name.ParseReference("registry.example.com/base:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := name.NewRepository("registry.example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = MountableImage(img, baseRef)
+
+	// The config alone is enough to ask to pull from base.
+	scopes := scopesForUploadingImage(dst, mountableConfig(img))
+	want := []string{dst.Scope(transport.PushScope), baseRef.Scope(transport.PullScope)}
+	if len(scopes) != len(want) || scopes[0] != want[0] || scopes[1] != want[1] {
+		t.Errorf("scopes = %v, want %v", scopes, want)
+	}
+}
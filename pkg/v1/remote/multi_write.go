@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -51,12 +52,20 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 		return err
 	}
 
-	// Collect unique blobs (layers and config blobs).
-	blobs := map[v1.Hash]v1.Layer{}
+	// Collect unique blobs (layers and config blobs). Walk the refs in a
+	// stable order, so that the same layers are uploaded in the same order
+	// each time.
+	refs := make([]name.Reference, 0, len(m))
+	for ref := range m {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].String() < refs[j].String() })
+	blobs := &blobSet{seen: map[v1.Hash]bool{}}
 	newManifests := []map[name.Reference]Taggable{}
 	// Separate originally requested images and indexes, so we can push images first.
 	images, indexes := map[name.Reference]Taggable{}, map[name.Reference]Taggable{}
-	for ref, i := range m {
+	for _, ref := range refs {
+		i := m[ref]
 		if img, ok := i.(v1.Image); ok {
 			images[ref] = i
 			if err := addImageBlobs(img, blobs, o.allowNondistributableArtifacts); err != nil {
@@ -77,21 +86,27 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 
 	// Determine if any of the layers are Mountable, because if so we need
 	// to request Pull scope too.
-	ls := []v1.Layer{}
-	for _, l := range blobs {
-		ls = append(ls, l)
-	}
-	scopes := scopesForUploadingImage(repo, ls)
+	scopes := scopesForUploadingImage(repo, blobs.layers, o.mountFrom...)
 	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
 	if err != nil {
 		return err
 	}
 	w := writer{
-		repo:      repo,
-		client:    &http.Client{Transport: tr},
-		context:   o.context,
-		backoff:   o.retryBackoff,
-		predicate: o.retryPredicate,
+		repo:         repo,
+		client:       &http.Client{Transport: tr},
+		context:      o.context,
+		events:       o.events,
+		backoff:      o.retryBackoff,
+		predicate:    o.retryPredicate,
+		resumable:    o.resumableUploads,
+		bandwidth:    o.bandwidth,
+		mountFrom:    o.mountFrom,
+		capabilities: o.capabilities,
+		conv:         newConverter(o.manifestConversion),
+	}
+
+	if o.events != nil {
+		defer close(o.events)
 	}
 
 	// Collect the total size of blobs and manifests we're about to write.
@@ -100,7 +115,7 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 		w.progress.lastUpdate = &v1.Update{}
 		defer close(o.updates)
 		defer func() { _ = w.progress.err(rerr) }()
-		for _, b := range blobs {
+		for _, b := range blobs.layers {
 			size, err := b.Size()
 			if err != nil {
 				return err
@@ -134,6 +149,14 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 		}
 	}
 
+	// Check everything fits before uploading any of it.
+	limits := makeSizeLimits(repo.Registry, o)
+	for _, ref := range refs {
+		if err := limits.check(repo.Registry, m[ref], o.allowNondistributableArtifacts); err != nil {
+			return err
+		}
+	}
+
 	// Upload individual blobs and collect any errors.
 	blobChan := make(chan v1.Layer, 2*o.jobs)
 	ctx := o.context
@@ -151,7 +174,7 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 	}
 	g.Go(func() error {
 		defer close(blobChan)
-		for _, b := range blobs {
+		for _, b := range blobs.layers {
 			select {
 			case blobChan <- b:
 			case <-gctx.Done():
@@ -184,12 +207,17 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 				return nil
 			})
 		}
-		go func() {
+		g.Go(func() error {
+			defer close(taskChan)
 			for ref, i := range m {
-				taskChan <- task{i, ref}
+				select {
+				case taskChan <- task{i, ref}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
-			close(taskChan)
-		}()
+			return nil
+		})
 		return g.Wait()
 	}
 	// Push originally requested image manifests. These have no
@@ -211,7 +239,7 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) (rerr error) {
 
 // addIndexBlobs adds blobs to the set of blobs we intend to upload, and
 // returns the latest copy of the ordered collection of manifests to upload.
-func addIndexBlobs(idx v1.ImageIndex, blobs map[v1.Hash]v1.Layer, repo name.Repository, newManifests []map[name.Reference]Taggable, lvl int, allowNondistributableArtifacts bool) ([]map[name.Reference]Taggable, error) {
+func addIndexBlobs(idx v1.ImageIndex, blobs *blobSet, repo name.Repository, newManifests []map[name.Reference]Taggable, lvl int, allowNondistributableArtifacts bool) ([]map[name.Reference]Taggable, error) {
 	if lvl > len(newManifests)-1 {
 		newManifests = append(newManifests, map[name.Reference]Taggable{})
 	}
@@ -263,7 +291,7 @@ func addIndexBlobs(idx v1.ImageIndex, blobs map[v1.Hash]v1.Layer, repo name.Repo
 	return newManifests, nil
 }
 
-func addLayerBlob(l v1.Layer, blobs map[v1.Hash]v1.Layer, allowNondistributableArtifacts bool) error {
+func addLayerBlob(l v1.Layer, blobs *blobSet, allowNondistributableArtifacts bool) error {
 	// Ignore foreign layers.
 	mt, err := l.MediaType()
 	if err != nil {
@@ -276,13 +304,28 @@ func addLayerBlob(l v1.Layer, blobs map[v1.Hash]v1.Layer, allowNondistributableA
 			return err
 		}
 
-		blobs[d] = l
+		blobs.add(d, l)
 	}
 
 	return nil
 }
 
-func addImageBlobs(img v1.Image, blobs map[v1.Hash]v1.Layer, allowNondistributableArtifacts bool) error {
+// blobSet is the set of blobs to upload, in the order they were added.
+type blobSet struct {
+	layers []v1.Layer
+	seen   map[v1.Hash]bool
+}
+
+// add adds l, unless a layer with the same digest has already been added.
+func (b *blobSet) add(h v1.Hash, l v1.Layer) {
+	if b.seen[h] {
+		return
+	}
+	b.seen[h] = true
+	b.layers = append(b.layers, l)
+}
+
+func addImageBlobs(img v1.Image, blobs *blobSet, allowNondistributableArtifacts bool) error {
 	ls, err := img.Layers()
 	if err != nil {
 		return err
@@ -17,8 +17,6 @@ package remote
 import (
 	"context"
 	"io"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -27,7 +25,6 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
@@ -75,7 +72,7 @@ func TestMultiWrite(t *testing.T) {
 	)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -132,7 +129,7 @@ func TestMultiWriteWithNondistributableLayer(t *testing.T) {
 	}
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -164,7 +161,7 @@ func TestMultiWrite_Retry(t *testing.T) {
 
 	t.Run("retry http error 500", func(t *testing.T) {
 		// Set up a fake registry.
-		handler := registry.New()
+		handler := NewRegistry()
 
 		numOfInternalServerErrors := 0
 		registryThatFailsOnFirstUpload := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
@@ -193,7 +190,7 @@ func TestMultiWrite_Retry(t *testing.T) {
 
 	t.Run("do not retry http error 401", func(t *testing.T) {
 		// Set up a fake registry.
-		handler := registry.New()
+		handler := NewRegistry()
 
 		numOf401HttpErrors := 0
 		registryThatFailsOnFirstUpload := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
@@ -266,7 +263,7 @@ func TestMultiWrite_Retry(t *testing.T) {
 	t.Run("do not add UserAgent if transport.Wrapper is used", func(t *testing.T) {
 		expectedNotUsedUserAgent := "TEST_USER_AGENT"
 
-		handler := registry.New()
+		handler := NewRegistry()
 
 		registryThatAssertsUserAgentIsCorrect := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
 			if strings.Contains(request.Header.Get("User-Agent"), expectedNotUsedUserAgent) {
@@ -311,8 +308,7 @@ func TestMultiWrite_Deep(t *testing.T) {
 	}
 
 	// Set up a fake registry (with NOP logger to avoid spamming test logs).
-	nopLog := log.New(ioutil.Discard, "", 0)
-	s := httptest.NewServer(registry.New(registry.Logger(nopLog)))
+	s := httptest.NewServer(NewQuietRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
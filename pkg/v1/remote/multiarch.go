@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WriteMultiArch pushes an index of images to ref, keyed by the platforms
+// they're for, e.g. "linux/amd64" or "linux/arm64/v8" (see v1.ParsePlatform).
+// Like WriteIndex, the images are pushed before the index, so the index is
+// only pushed once everything it refers to is in place.
+//
+// The index's descriptors are given the platforms of their keys, filled out
+// with the variant and OS version from the images' config files. It is an
+// error for an image's config file to be for a different OS or architecture
+// than its key. The index is a Docker manifest list if all the images are
+// Docker images, and an OCI image index otherwise.
+//
+// It returns the index that was pushed.
+func WriteMultiArch(ref name.Reference, images map[string]v1.Image, options ...Option) (v1.ImageIndex, error) {
+	if len(images) == 0 {
+		return nil, errors.New("no images to push")
+	}
+
+	// Sort the platforms, so the same images always make the same index.
+	keys := make([]string, 0, len(images))
+	for k := range images {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var (
+		adds      []mutate.IndexAddendum
+		platforms []v1.Platform
+		allDocker = true
+	)
+	for _, k := range keys {
+		img := images[k]
+		p, err := imagePlatform(k, img)
+		if err != nil {
+			return nil, err
+		}
+		for _, seen := range platforms {
+			if seen.Equals(*p) {
+				return nil, fmt.Errorf("more than one image for platform %s", p)
+			}
+		}
+		platforms = append(platforms, *p)
+
+		mt, err := img.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		if mt != types.DockerManifestSchema2 {
+			allDocker = false
+		}
+		adds = append(adds, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				MediaType: mt,
+				Platform:  p,
+			},
+		})
+	}
+
+	mt := types.OCIImageIndex
+	if allDocker {
+		mt = types.DockerManifestList
+	}
+	idx := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, adds...), mt)
+	if err := WriteIndex(ref, idx, options...); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// imagePlatform returns the platform that key describes, filled out from img's
+// config file, or an error if they disagree.
+func imagePlatform(key string, img v1.Image) (*v1.Platform, error) {
+	p, err := v1.ParsePlatform(key)
+	if err != nil {
+		return nil, err
+	}
+	if p.OS == "" || p.Architecture == "" {
+		return nil, fmt.Errorf("platform %q must have an OS and architecture", key)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading config file of %s image: %w", key, err)
+	}
+	if (cf.OS != "" && cf.OS != p.OS) || (cf.Architecture != "" && cf.Architecture != p.Architecture) {
+		return nil, fmt.Errorf("%s image is for %s/%s", key, cf.OS, cf.Architecture)
+	}
+	if p.Variant == "" {
+		p.Variant = cf.Variant
+	}
+	if p.OSVersion == "" {
+		p.OSVersion = cf.OSVersion
+	}
+	return p, nil
+}
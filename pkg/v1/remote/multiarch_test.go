@@ -0,0 +1,155 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// platformImage returns a random image whose config file is for os/arch.
+func platformImage(t *testing.T, os, arch, variant string) v1.Image {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS, cf.Architecture, cf.Variant = os, arch, variant
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestWriteMultiArch(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(fmt.Sprintf("%s/test/multiarch:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amd64 := platformImage(t, "linux", "amd64", "")
+	arm64 := platformImage(t, "linux", "arm64", "v8")
+	idx, err := WriteMultiArch(ref, map[string]v1.Image{
+		"linux/arm64": arm64,
+		"linux/amd64": amd64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Index(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := got.Digest(); err != nil || d != want {
+		t.Errorf("pushed index %s, %v, want %s", d, err, want)
+	}
+	m, err := got.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaType != types.DockerManifestList {
+		t.Errorf("MediaType = %s, want %s", m.MediaType, types.DockerManifestList)
+	}
+	if len(m.Manifests) != 2 {
+		t.Fatalf("got %d manifests, want 2", len(m.Manifests))
+	}
+	for i, want := range []struct {
+		img      v1.Image
+		platform v1.Platform
+	}{
+		{amd64, v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{arm64, v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+	} {
+		d := m.Manifests[i]
+		h, err := want.img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Digest != h {
+			t.Errorf("manifest %d = %s, want %s", i, d.Digest, h)
+		}
+		if d.Platform == nil || !d.Platform.Equals(want.platform) {
+			t.Errorf("manifest %d platform = %v, want %v", i, d.Platform, want.platform)
+		}
+		// Children are pushed too.
+		if _, err := Image(ref.Context().Digest(h.String())); err != nil {
+			t.Errorf("child %d: %v", i, err)
+		}
+	}
+
+	// With any OCI images, the index is an OCI image index.
+	oci := mutate.MediaType(platformImage(t, "linux", "s390x", ""), types.OCIManifestSchema1)
+	idx, err = WriteMultiArch(ref, map[string]v1.Image{"linux/amd64": amd64, "linux/s390x": oci})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt, err := idx.MediaType(); err != nil || mt != types.OCIImageIndex {
+		t.Errorf("MediaType() = %s, %v, want %s", mt, err, types.OCIImageIndex)
+	}
+}
+
+func TestWriteMultiArchErrors(t *testing.T) {
+	ref, err := name.NewTag("example.com/test/multiarch:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd64 := platformImage(t, "linux", "amd64", "")
+	for _, tc := range []struct {
+		desc   string
+		images map[string]v1.Image
+	}{{
+		desc: "no images",
+	}, {
+		desc:   "wrong architecture",
+		images: map[string]v1.Image{"linux/arm64": amd64},
+	}, {
+		desc:   "no architecture",
+		images: map[string]v1.Image{"linux": amd64},
+	}, {
+		desc:   "duplicate platforms",
+		images: map[string]v1.Image{"linux/amd64": amd64, "linux/amd64:": amd64},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := WriteMultiArch(ref, tc.images); err == nil {
+				t.Error("WriteMultiArch() = nil, wanted error")
+			}
+		})
+	}
+}
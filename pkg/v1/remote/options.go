@@ -17,15 +17,19 @@ package remote
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/google/go-containerregistry/internal/retry"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
@@ -43,9 +47,30 @@ type options struct {
 	userAgent                      string
 	allowNondistributableArtifacts bool
 	updates                        chan<- v1.Update
+	readUpdates                    chan<- v1.Update
+	events                         chan<- Event
 	pageSize                       int
 	retryBackoff                   Backoff
 	retryPredicate                 retry.Predicate
+	maxRetryAfter                  time.Duration
+	bandwidth                      *bandwidthLimiter
+	maxLayerSize                   int64
+	mountFrom                      []name.Repository
+	expectedDigest                 *v1.Hash
+	tlsPins                        map[string]transport.TLSPin
+	manifestConversion             ManifestConversion
+	referrers                      bool
+	resumableUploads               bool
+	capabilities                   *CapabilityRecorder
+	requestIDHeader                string
+	requestID                      func() string
+	tuning                         transportTuning
+	strictDigests                  bool
+	headFallback                   bool
+	cache                          Cache
+	variantFallback                bool
+	acceptEncoding                 transport.AcceptEncoding
+	preallocate                    bool
 }
 
 var defaultPlatform = v1.Platform{
@@ -102,13 +127,14 @@ var DefaultTransport http.RoundTripper = &http.Transport{
 
 func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 	o := &options{
-		transport:      DefaultTransport,
-		platform:       defaultPlatform,
-		context:        context.Background(),
-		jobs:           defaultJobs,
-		pageSize:       defaultPageSize,
-		retryPredicate: defaultRetryPredicate,
-		retryBackoff:   defaultRetryBackoff,
+		transport:       DefaultTransport,
+		platform:        defaultPlatform,
+		variantFallback: true,
+		context:         context.Background(),
+		jobs:            defaultJobs,
+		pageSize:        defaultPageSize,
+		retryPredicate:  defaultRetryPredicate,
+		retryBackoff:    defaultRetryBackoff,
 	}
 
 	for _, option := range opts {
@@ -132,9 +158,40 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		o.auth = authn.Anonymous
 	}
 
+	if !o.tuning.isZero() {
+		if t, ok := o.transport.(*http.Transport); ok {
+			o.transport = tuned(t, o.tuning)
+		} else {
+			logs.Warn.Printf("ignoring WithMaxIdleConnsPerHost, WithTLSHandshakeTimeout and WithHTTP2, which only apply to an *http.Transport, not %T", o.transport)
+		}
+	}
+
+	if len(o.tlsPins) != 0 {
+		t, ok := o.transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("WithTLSPin requires an *http.Transport, got %T", o.transport)
+		}
+		pins := o.tlsPins
+		o.transport = derivedTransports.get(pinnedKey{base: t, pins: pinsKey(pins)}, func() http.RoundTripper {
+			return transport.NewPinned(t, pins)
+		})
+	}
+
 	// transport.Wrapper is a signal that consumers are opt-ing into providing their own transport without any additional wrapping.
 	// This is to allow consumers full control over the transports logic, such as providing retry logic.
 	if _, ok := o.transport.(*transport.Wrapper); !ok {
+		// Wrap the transport in something that sets Accept-Encoding before
+		// anything else, so redirects and retries get it too.
+		if o.acceptEncoding != nil {
+			o.transport = transport.NewAcceptEncoding(o.transport, o.acceptEncoding)
+		}
+
+		// Wrap the transport in something that authenticates with other
+		// hosts the registry redirects us to, using their own credentials.
+		if o.keychain != nil {
+			o.transport = transport.NewRedirectAuth(o.transport, target.RegistryStr(), o.keychain)
+		}
+
 		// Wrap the transport in something that logs requests and responses.
 		// It's expensive to generate the dumps, so skip it if we're writing
 		// to nothing.
@@ -142,8 +199,19 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 			o.transport = transport.NewLogger(o.transport)
 		}
 
-		// Wrap the transport in something that can retry network flakes.
-		o.transport = transport.NewRetry(o.transport)
+		// Wrap the transport in something that can retry network flakes,
+		// and requests that the registry asks us to slow down for.
+		retryOpts := []transport.Option{transport.WithRetryBackoff(o.retryBackoff)}
+		if o.maxRetryAfter != 0 {
+			retryOpts = append(retryOpts, transport.WithMaxRetryAfter(o.maxRetryAfter))
+		}
+		o.transport = transport.NewRetry(o.transport, retryOpts...)
+
+		// Wrap the transport in something that tags requests with an ID
+		// outside of the retries, so that every attempt shares it.
+		if o.requestIDHeader != "" {
+			o.transport = transport.NewRequestID(o.transport, o.requestIDHeader, o.requestID)
+		}
 
 		// Wrap this last to prevent transport.New from double-wrapping.
 		if o.userAgent != "" {
@@ -195,6 +263,12 @@ func WithAuthFromKeychain(keys authn.Keychain) Option {
 // WithPlatform is a functional option for overriding the default platform
 // that Image and Descriptor.Image use for resolving an index to an image.
 //
+// Platforms are matched following the OCI conventions: aliases like aarch64
+// and arm64/v8 are equivalent to arm64, a variant or OS version is only
+// required if p has one, and Windows OS versions match any revision of the
+// same build, preferring an exact match and then the newest revision. Older
+// arm variants satisfy newer ones, see WithPlatformVariantFallback.
+//
 // The default platform is amd64/linux.
 func WithPlatform(p v1.Platform) Option {
 	return func(o *options) error {
@@ -203,6 +277,19 @@ func WithPlatform(p v1.Platform) Option {
 	}
 }
 
+// WithPlatformVariantFallback sets whether an index with no image for the
+// variant of a 32-bit arm platform, e.g. arm/v7, resolves to one for an older
+// variant, e.g. arm/v6, which runs on the same hardware. The closest variant
+// is preferred.
+//
+// The default is to fall back.
+func WithPlatformVariantFallback(fallback bool) Option {
+	return func(o *options) error {
+		o.variantFallback = fallback
+		return nil
+	}
+}
+
 // WithContext is a functional option for setting the context in http requests
 // performed by a given function. Note that this context is used for _all_
 // http requests, not just the initial volley. E.g., for remote.Image, the
@@ -221,6 +308,9 @@ func WithContext(ctx context.Context) Option {
 // operations performed by a given function. Note that not all remote
 // operations support parallelism.
 //
+// Write, WriteIndex and MultiWrite upload up to jobs blobs at once, and
+// WriteIndex and MultiWrite also push up to jobs manifests at once.
+//
 // The default value is 4.
 func WithJobs(jobs int) Option {
 	return func(o *options) error {
@@ -243,6 +333,25 @@ func WithUserAgent(ua string) Option {
 	}
 }
 
+// WithRequestID sets header on every HTTP request to an ID returned by id, so
+// that requests can be correlated with the registry's logs, e.g. when filing a
+// ticket with a registry vendor. If header is empty, X-Request-Id is used. If
+// id is nil, a random ID is generated for each request.
+//
+// Regardless of this option, errors returned by the registry are
+// *transport.Error values with the registry's request ID, if any, in their
+// RequestID field.
+func WithRequestID(header string, id func() string) Option {
+	return func(o *options) error {
+		if header == "" {
+			header = transport.DefaultRequestIDHeader
+		}
+		o.requestIDHeader = header
+		o.requestID = id
+		return nil
+	}
+}
+
 // WithNondistributable includes non-distributable (foreign) layers
 // when writing images, see:
 // https://github.com/opencontainers/image-spec/blob/master/layer.md#non-distributable-layers
@@ -254,6 +363,7 @@ func WithNondistributable(o *options) error {
 }
 
 // WithProgress takes a channel that will receive progress updates as bytes are written.
+// It has no effect on reads, see WithReadProgress.
 //
 // Sending updates to an unbuffered channel will block writes, so callers
 // should provide a buffered channel to avoid potential deadlocks.
@@ -264,6 +374,39 @@ func WithProgress(updates chan<- v1.Update) Option {
 	}
 }
 
+// WithReadProgress takes a channel that will receive progress updates as
+// blobs are read by the Image, Index or Layer returned by this package, with
+// totals computed from the manifests of the images that are read. Only bytes
+// that are read successfully count: if reading a blob fails, e.g. because it
+// doesn't match its digest, what was counted for it is taken back.
+//
+// This is separate from WithProgress so that the same options can be used to
+// read from one registry and write to another without mixing their updates.
+// Reads are lazy, so the channel is never closed. Sending updates to an
+// unbuffered channel will block reads, so callers should provide a buffered
+// channel or receive from it concurrently.
+func WithReadProgress(updates chan<- v1.Update) Option {
+	return func(o *options) error {
+		o.readUpdates = updates
+		return nil
+	}
+}
+
+// WithEvents takes a channel that will receive an Event for each blob and
+// manifest as it is written, and for each child of an index that has been
+// completely written. Unlike WithProgress, this describes what was written
+// rather than how many bytes, e.g. to show which images in an index are done.
+//
+// The channel is closed when the write returns. Sending events to an
+// unbuffered channel will block writes, so callers should provide a buffered
+// channel or receive from it concurrently.
+func WithEvents(events chan<- Event) Option {
+	return func(o *options) error {
+		o.events = events
+		return nil
+	}
+}
+
 // WithPageSize sets the given size as the value of parameter 'n' in the request.
 //
 // To omit the `n` parameter entirely, use WithPageSize(0).
@@ -276,6 +419,11 @@ func WithPageSize(size int) Option {
 }
 
 // WithRetryBackoff sets the httpBackoff for retry HTTP operations.
+//
+// This is also how long to wait before retrying a 503 response that doesn't
+// have a Retry-After header. 429 and 503 responses that do are retried after
+// the requested delay, up to the limit set by WithMaxRetryAfter, and 429
+// responses that don't are not retried.
 func WithRetryBackoff(backoff Backoff) Option {
 	return func(o *options) error {
 		o.retryBackoff = backoff
@@ -283,6 +431,77 @@ func WithRetryBackoff(backoff Backoff) Option {
 	}
 }
 
+// WithMaxRetryAfter sets the longest time to wait before retrying a 429 or
+// 503 response, regardless of the registry's Retry-After header.
+//
+// The default is one minute. Requests are never retried after their context
+// would have expired.
+func WithMaxRetryAfter(max time.Duration) Option {
+	return func(o *options) error {
+		o.maxRetryAfter = max
+		return nil
+	}
+}
+
+// WithBandwidthLimit limits the rate at which blobs are uploaded and
+// downloaded to bytesPerSec, shared between all the blobs transferred
+// concurrently by operations given this option.
+//
+// Reusing the same Option across several calls (e.g. for a batch of copies)
+// shares one limit between all of them. Manifests are not limited.
+func WithBandwidthLimit(bytesPerSec int64) Option {
+	l := &bandwidthLimiter{bytesPerSec: bytesPerSec}
+	return func(o *options) error {
+		if bytesPerSec <= 0 {
+			return fmt.Errorf("invalid bandwidth limit %d: must be positive", bytesPerSec)
+		}
+		o.bandwidth = l
+		return nil
+	}
+}
+
+// WithMaxLayerSize rejects pushes of any layer larger than max bytes with a
+// *SizeLimitError, before anything is uploaded.
+//
+// Without this option, the documented limits of some popular registries are
+// checked. A max of zero or less disables checking layer sizes entirely.
+func WithMaxLayerSize(max int64) Option {
+	return func(o *options) error {
+		o.maxLayerSize = max
+		if max <= 0 {
+			o.maxLayerSize = -1
+		}
+		return nil
+	}
+}
+
+// WithMountFrom hints repositories that may already have the blobs being
+// pushed, such as a mirror of a base image in the same registry.
+//
+// Before uploading a blob, the registry is asked to mount it from each of
+// these repositories in turn, after the source of any MountableLayer. The
+// registry uploads the blob as usual if none of them have it.
+func WithMountFrom(repos ...name.Repository) Option {
+	return func(o *options) error {
+		o.mountFrom = append(o.mountFrom, repos...)
+		return nil
+	}
+}
+
+// WithExpectedDigest makes Write, WriteIndex, Put and Tag only overwrite a tag
+// if it currently points at the manifest with digest h, or, if h is the zero
+// Hash, only push the tag if it doesn't exist yet. Otherwise they fail with a
+// *TagConflictError, without pushing the manifest.
+//
+// The tag is checked with a HEAD request just before its manifest is pushed,
+// so concurrent pushes may still race in between.
+func WithExpectedDigest(h v1.Hash) Option {
+	return func(o *options) error {
+		o.expectedDigest = &h
+		return nil
+	}
+}
+
 // WithRetryPredicate sets the predicate for retry HTTP operations.
 func WithRetryPredicate(predicate retry.Predicate) Option {
 	return func(o *options) error {
@@ -290,3 +509,209 @@ func WithRetryPredicate(predicate retry.Predicate) Option {
 		return nil
 	}
 }
+
+// WithResumableUploads sets whether blob uploads that fail partway, e.g.
+// because the connection was reset, are resumed from where the registry says
+// they left off when they're retried, rather than restarted. Streaming layers
+// can't be resumed, and uploads are restarted when the registry's status
+// doesn't say how much it has received.
+//
+// The default is false.
+func WithResumableUploads(enabled bool) Option {
+	return func(o *options) error {
+		o.resumableUploads = enabled
+		return nil
+	}
+}
+
+// pinnedKey identifies the transport that WithTLSPin builds from base.
+type pinnedKey struct {
+	base *http.Transport
+	pins string
+}
+
+// pinsKey returns a string that identifies pins, with each pool of RootCAs
+// identified by its address.
+func pinsKey(pins map[string]transport.TLSPin) string {
+	hosts := make([]string, 0, len(pins))
+	for host := range pins {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	var sb strings.Builder
+	for _, host := range hosts {
+		pin := pins[host]
+		fmt.Fprintf(&sb, "%s %p %s\n", host, pin.RootCAs, strings.Join(pin.SPKIHashes, ","))
+	}
+	return sb.String()
+}
+
+// WithTLSPin pins the identity of reg, verifying TLS connections to it with
+// the given custom root CAs and/or public key hashes. Connections to other
+// hosts are unaffected. It may be passed once per registry.
+//
+// This requires the transport to be an *http.Transport, as DefaultTransport is,
+// since that's where TLS is configured. The pinned transport is built from a
+// clone of it, which is shared by calls with the same transport and pins.
+func WithTLSPin(reg name.Registry, pin transport.TLSPin) Option {
+	return func(o *options) error {
+		if o.tlsPins == nil {
+			o.tlsPins = map[string]transport.TLSPin{}
+		}
+		o.tlsPins[reg.RegistryStr()] = pin
+		return nil
+	}
+}
+
+// WithMaxIdleConnsPerHost sets how many idle connections to each registry
+// are kept open for reuse. The default of http.DefaultMaxIdleConnsPerHost is
+// lower than the number of concurrent requests that e.g. Write makes with its
+// default WithJobs, so connections are closed and reopened as they go idle;
+// copy services should set it to at least their concurrency.
+//
+// Calls with the same transport and settings share a copy of the transport
+// with these settings, and so share its connection pool. Only the most
+// recently used copies are kept, so transports passed to WithTransport
+// alongside this should be reused between calls too. This only applies to an
+// *http.Transport, as DefaultTransport is; other transports are left alone.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return errors.New("max idle connections per host must be greater than zero")
+		}
+		o.tuning.maxIdleConnsPerHost = n
+		return nil
+	}
+}
+
+// WithTLSHandshakeTimeout sets how long to wait for TLS handshakes with
+// registries. The default is DefaultTransport's 10 seconds.
+//
+// Like WithMaxIdleConnsPerHost, this only applies to an *http.Transport.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("TLS handshake timeout must be greater than zero")
+		}
+		o.tuning.tlsHandshakeTimeout = d
+		return nil
+	}
+}
+
+// WithHTTP2 sets whether to use HTTP/2 with registries that support it. The
+// default, as with DefaultTransport, is to use it.
+//
+// Like WithMaxIdleConnsPerHost, this only applies to an *http.Transport.
+func WithHTTP2(enabled bool) Option {
+	return func(o *options) error {
+		o.tuning.http2 = &enabled
+		return nil
+	}
+}
+
+// WithDigestVerification sets whether to verify everything that's fetched
+// against its digest before returning any of it. With strict verification:
+//
+//   - blobs are read in full, into temporary files, and verified before
+//     their contents are returned, rather than as they are read;
+//   - manifests fetched by tag are checked against the Docker-Content-Digest
+//     header, if any, and then pinned to the digest of their contents;
+//   - Head fetches manifests rather than trusting the digest in the headers
+//     of a HEAD response; and
+//   - the digests of signed schema 1 manifests, which registries compute
+//     differently, aren't trusted either.
+//
+// Content that doesn't match its digest causes a VerificationError.
+//
+// The default is to verify blobs as they're read, and to trust what
+// registries say the digests of manifests are.
+func WithDigestVerification(strict bool) Option {
+	return func(o *options) error {
+		o.strictDigests = strict
+		return nil
+	}
+}
+
+// WithCache sets a Cache that manifests and blobs are fetched from by digest
+// before going to the registry, and that anything fetched by digest is stored
+// in. Only content that matches its digest is stored.
+//
+// Fetching something that's entirely cached, such as an image by digest that
+// has been pulled before, doesn't talk to the registry at all. Fetching by tag
+// still resolves the tag with the registry.
+func WithCache(c Cache) Option {
+	return func(o *options) error {
+		o.cache = c
+		return nil
+	}
+}
+
+// WithHeadFallback sets whether Head, and anything else that resolves a
+// manifest with a HEAD request, retries as a GET when the HEAD returns 404 or
+// leaves out the Content-Type, Content-Length or Docker-Content-Digest headers.
+// The GET's body is only used to compute the descriptor, and is discarded.
+//
+// This is for registries and proxies that mishandle HEAD for manifests. A HEAD
+// that fails with 405 or 501 is always retried as a GET.
+func WithHeadFallback(fallback bool) Option {
+	return func(o *options) error {
+		o.headFallback = fallback
+		return nil
+	}
+}
+
+// WithManifestConversion sets what to do when a registry rejects a manifest
+// because of its media type. When converting, the blobs already pushed are
+// reused, but the manifest's digest changes.
+//
+// The default is PushAsIs.
+func WithManifestConversion(c ManifestConversion) Option {
+	return func(o *options) error {
+		o.manifestConversion = c
+		return nil
+	}
+}
+
+// WithBlobDecompression sets whether getting blobs lets the transport ask for
+// gzip and transparently decompress the response, as an http.Transport does by
+// default. Disabling it asks for blobs with an Accept-Encoding of
+// transport.Identity, for registries and proxies that compress blobs again on
+// the fly or misreport their sizes when doing so, which breaks digest
+// verification. Blobs that come back encoded anyway are an error.
+//
+// This is shorthand for WithAcceptEncoding(transport.BlobGets(transport.Identity)).
+// The default is enabled.
+func WithBlobDecompression(enabled bool) Option {
+	return func(o *options) error {
+		if enabled {
+			o.acceptEncoding = nil
+		} else {
+			o.acceptEncoding = transport.BlobGets(transport.Identity)
+		}
+		return nil
+	}
+}
+
+// WithAcceptEncoding sets the Accept-Encoding header of each request to what
+// encoding returns for it, if anything, see transport.NewAcceptEncoding.
+// Responses to requests with the header set are returned as they're sent,
+// without being transparently decompressed.
+func WithAcceptEncoding(encoding transport.AcceptEncoding) Option {
+	return func(o *options) error {
+		o.acceptEncoding = encoding
+		return nil
+	}
+}
+
+// WithPreallocate sets whether DownloadLayer extends the file it writes to the
+// blob's size, when the registry reports it, before writing the blob. This
+// lets some filesystems lay the file out contiguously, but on most it only
+// makes a sparse file, which doesn't reserve any space.
+//
+// The default is disabled.
+func WithPreallocate(preallocate bool) Option {
+	return func(o *options) error {
+		o.preallocate = preallocate
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"strconv"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// normalizePlatform returns p with common aliases for its architecture and
+// variant replaced by their canonical forms, following the conventions of
+// containerd's platforms package, e.g. aarch64 and arm64/v8 are both arm64.
+//
+// An empty variant is left empty, since it matches any variant when required.
+func normalizePlatform(p v1.Platform) v1.Platform {
+	switch p.Architecture {
+	case "i386":
+		p.Architecture = "386"
+	case "x86_64", "x86-64":
+		p.Architecture = "amd64"
+	case "aarch64":
+		p.Architecture = "arm64"
+	case "armhf":
+		p.Architecture = "arm"
+		p.Variant = "v7"
+	case "armel":
+		p.Architecture = "arm"
+		p.Variant = "v6"
+	}
+	switch p.Architecture {
+	case "amd64":
+		if p.Variant == "v1" {
+			p.Variant = ""
+		}
+	case "arm64":
+		if p.Variant == "8" || p.Variant == "v8" {
+			p.Variant = ""
+		}
+	case "arm":
+		if _, err := strconv.Atoi(p.Variant); err == nil {
+			p.Variant = "v" + p.Variant
+		}
+	}
+	return p
+}
+
+// armVariant returns the version of an arm variant like v7, or -1.
+func armVariant(p v1.Platform) int {
+	if p.Architecture != "arm" || !strings.HasPrefix(p.Variant, "v") {
+		return -1
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(p.Variant, "v"))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// variantDistance returns how many versions older than the required variant
+// the given one is, 0 if they're the same, or -1 if given doesn't satisfy
+// required. Only 32-bit arm variants are ordered, and only with fallback, so
+// that e.g. arm/v7 is satisfied by arm/v6 and arm/v5.
+func variantDistance(given, required v1.Platform, fallback bool) int {
+	if required.Variant == "" || given.Variant == required.Variant {
+		return 0
+	}
+	if !fallback {
+		return -1
+	}
+	g, r := armVariant(given), armVariant(required)
+	if g < 0 || r < 0 || g > r {
+		return -1
+	}
+	return r - g
+}
+
+// osVersionMatches returns whether given's OS version satisfies required's.
+// Windows versions are compatible if their major, minor and build numbers
+// match, whatever their revision.
+func osVersionMatches(given, required v1.Platform) bool {
+	if required.OSVersion == "" || given.OSVersion == required.OSVersion {
+		return true
+	}
+	if required.OS != "windows" {
+		return false
+	}
+	build := windowsBuild(required.OSVersion)
+	return build != "" && windowsBuild(given.OSVersion) == build
+}
+
+// windowsBuild returns the major.minor.build prefix of a Windows OS version,
+// or "" if it doesn't have one.
+func windowsBuild(v string) string {
+	parts := strings.SplitN(v, ".", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.Join(parts[:3], ".")
+}
+
+// windowsRevision returns the revision of a Windows OS version, or -1.
+func windowsRevision(v string) int {
+	parts := strings.SplitN(v, ".", 4)
+	if len(parts) < 4 {
+		return -1
+	}
+	n, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// closerPlatform returns whether a is a closer match to required than b,
+// given that both satisfy it: a closer variant wins, then an exact OS
+// version, then a newer Windows revision.
+func closerPlatform(a, b, required v1.Platform, fallback bool) bool {
+	if da, db := variantDistance(a, required, fallback), variantDistance(b, required, fallback); da != db {
+		return da < db
+	}
+	if required.OSVersion == "" {
+		return false
+	}
+	if ea, eb := a.OSVersion == required.OSVersion, b.OSVersion == required.OSVersion; ea != eb {
+		return ea
+	}
+	return windowsRevision(a.OSVersion) > windowsRevision(b.OSVersion)
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestNormalizePlatform(t *testing.T) {
+	for _, tc := range []struct {
+		in, want v1.Platform
+	}{
+		{v1.Platform{OS: "linux", Architecture: "aarch64"}, v1.Platform{OS: "linux", Architecture: "arm64"}},
+		{v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, v1.Platform{OS: "linux", Architecture: "arm64"}},
+		{v1.Platform{OS: "linux", Architecture: "arm64", Variant: "8"}, v1.Platform{OS: "linux", Architecture: "arm64"}},
+		{v1.Platform{OS: "linux", Architecture: "x86_64"}, v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{v1.Platform{OS: "linux", Architecture: "i386"}, v1.Platform{OS: "linux", Architecture: "386"}},
+		{v1.Platform{OS: "linux", Architecture: "armhf"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{v1.Platform{OS: "linux", Architecture: "armel"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{v1.Platform{OS: "linux", Architecture: "arm", Variant: "7"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{v1.Platform{OS: "linux", Architecture: "arm"}, v1.Platform{OS: "linux", Architecture: "arm"}},
+	} {
+		if got := normalizePlatform(tc.in); got.String() != tc.want.String() {
+			t.Errorf("normalizePlatform(%s) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	for _, tc := range []struct {
+		desc            string
+		given, required v1.Platform
+		fallback, want  bool
+	}{{
+		desc:     "arm64/v8 is arm64",
+		given:    v1.Platform{OS: "linux", Architecture: "arm64"},
+		required: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		want:     true,
+	}, {
+		desc:     "older arm variant with fallback",
+		given:    v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		required: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		fallback: true,
+		want:     true,
+	}, {
+		desc:     "older arm variant without fallback",
+		given:    v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		required: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		want:     false,
+	}, {
+		desc:     "newer arm variant",
+		given:    v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		required: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		fallback: true,
+		want:     false,
+	}, {
+		desc:     "windows revision",
+		given:    v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1999"},
+		required: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1000"},
+		want:     true,
+	}, {
+		desc:     "windows build only",
+		given:    v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1999"},
+		required: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763"},
+		want:     true,
+	}, {
+		desc:     "windows different build",
+		given:    v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.1999"},
+		required: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1999"},
+		want:     false,
+	}, {
+		desc:     "linux os version is exact",
+		given:    v1.Platform{OS: "linux", Architecture: "amd64", OSVersion: "10.0.17763.1999"},
+		required: v1.Platform{OS: "linux", Architecture: "amd64", OSVersion: "10.0.17763"},
+		want:     false,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := platformMatches(normalizePlatform(tc.given), normalizePlatform(tc.required), tc.fallback)
+			if got != tc.want {
+				t.Errorf("platformMatches(%s, %s, %t) = %t, want %t", tc.given, tc.required, tc.fallback, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestImagePlatformSelection(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/platforms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	platforms := map[string]v1.Platform{
+		"arm64":   {OS: "linux", Architecture: "arm64", Variant: "v8"},
+		"armv5":   {OS: "linux", Architecture: "arm", Variant: "v5"},
+		"armv6":   {OS: "linux", Architecture: "arm", Variant: "v6"},
+		"win1000": {OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1000"},
+		"win2000": {OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2000"},
+		"win1500": {OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1500"},
+	}
+	digests := map[string]v1.Hash{}
+	idx := v1.ImageIndex(empty.Index)
+	for _, k := range []string{"arm64", "armv5", "armv6", "win1000", "win2000", "win1500"} {
+		p := platforms[k]
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digests[k], err = img.Digest(); err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &p},
+		})
+	}
+	if err := WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		platform v1.Platform
+		opts     []Option
+		want     string
+	}{{
+		desc:     "aarch64",
+		platform: v1.Platform{OS: "linux", Architecture: "aarch64"},
+		want:     "arm64",
+	}, {
+		desc:     "closest older arm variant",
+		platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		want:     "armv6",
+	}, {
+		desc:     "exact arm variant",
+		platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v5"},
+		want:     "armv5",
+	}, {
+		desc:     "no variant fallback",
+		platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		opts:     []Option{WithPlatformVariantFallback(false)},
+	}, {
+		desc:     "exact windows version",
+		platform: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1500"},
+		want:     "win1500",
+	}, {
+		desc:     "newest windows revision",
+		platform: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.42"},
+		want:     "win2000",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			img, err := Image(ref, append(tc.opts, WithPlatform(tc.platform))...)
+			if tc.want == "" {
+				if err == nil {
+					t.Error("Image() succeeded, wanted err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := img.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != digests[tc.want] {
+				t.Errorf("Image() resolved to %s, want %s (%s)", got, tc.want, digests[tc.want])
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// transportTuning holds the connection settings of WithMaxIdleConnsPerHost,
+// WithTLSHandshakeTimeout and WithHTTP2. Zero values leave the transport's
+// settings alone.
+type transportTuning struct {
+	maxIdleConnsPerHost int
+	tlsHandshakeTimeout time.Duration
+	http2               *bool
+}
+
+func (tt transportTuning) isZero() bool {
+	return tt == transportTuning{}
+}
+
+type tunedKey struct {
+	base                *http.Transport
+	maxIdleConnsPerHost int
+	tlsHandshakeTimeout time.Duration
+	// http2 is 0 if unset, 1 if disabled and 2 if enabled.
+	http2 int
+}
+
+// tuned returns a copy of base with tt's settings applied. Copies are shared
+// between calls with the same base and settings, through derivedTransports,
+// so that they share a connection pool instead of each opening their own
+// connections.
+func tuned(base *http.Transport, tt transportTuning) *http.Transport {
+	key := tunedKey{
+		base:                base,
+		maxIdleConnsPerHost: tt.maxIdleConnsPerHost,
+		tlsHandshakeTimeout: tt.tlsHandshakeTimeout,
+	}
+	if tt.http2 != nil {
+		key.http2 = 1
+		if *tt.http2 {
+			key.http2 = 2
+		}
+	}
+
+	return derivedTransports.get(key, func() http.RoundTripper {
+		t := base.Clone()
+		if tt.maxIdleConnsPerHost != 0 {
+			t.MaxIdleConnsPerHost = tt.maxIdleConnsPerHost
+			if t.MaxIdleConns != 0 && t.MaxIdleConns < tt.maxIdleConnsPerHost {
+				t.MaxIdleConns = tt.maxIdleConnsPerHost
+			}
+		}
+		if tt.tlsHandshakeTimeout != 0 {
+			t.TLSHandshakeTimeout = tt.tlsHandshakeTimeout
+		}
+		if tt.http2 != nil {
+			t.ForceAttemptHTTP2 = *tt.http2
+			if !*tt.http2 {
+				// A non-nil, empty TLSNextProto disables HTTP/2.
+				t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			}
+		}
+		return t
+	}).(*http.Transport)
+}
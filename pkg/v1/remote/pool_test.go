@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestTunedTransport(t *testing.T) {
+	base := DefaultTransport.(*http.Transport)
+	off := false
+	tt := transportTuning{
+		maxIdleConnsPerHost: 32,
+		tlsHandshakeTimeout: time.Second,
+		http2:               &off,
+	}
+	got := tuned(base, tt)
+	if got == base {
+		t.Fatal("tuned() modified the base transport")
+	}
+	if got.MaxIdleConnsPerHost != 32 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 32", got.MaxIdleConnsPerHost)
+	}
+	if got.TLSHandshakeTimeout != time.Second {
+		t.Errorf("TLSHandshakeTimeout = %v, want 1s", got.TLSHandshakeTimeout)
+	}
+	if got.ForceAttemptHTTP2 || got.TLSNextProto == nil {
+		t.Errorf("HTTP/2 wasn't disabled")
+	}
+	if base.MaxIdleConnsPerHost != 0 || !base.ForceAttemptHTTP2 {
+		t.Errorf("DefaultTransport was modified")
+	}
+
+	// The same settings share a transport, even with a different pointer.
+	off2 := false
+	if again := tuned(base, transportTuning{maxIdleConnsPerHost: 32, tlsHandshakeTimeout: time.Second, http2: &off2}); again != got {
+		t.Errorf("tuned() with the same settings returned a different transport")
+	}
+	on := true
+	if other := tuned(base, transportTuning{maxIdleConnsPerHost: 32, tlsHandshakeTimeout: time.Second, http2: &on}); other == got {
+		t.Errorf("tuned() with different settings returned the same transport")
+	} else if !other.ForceAttemptHTTP2 {
+		t.Errorf("HTTP/2 wasn't enabled")
+	}
+}
+
+func TestTuningOptions(t *testing.T) {
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(fmt.Sprintf("%s/test/pool:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []Option{WithMaxIdleConnsPerHost(16), WithTLSHandshakeTimeout(time.Second), WithHTTP2(false)}
+	if err := Write(ref, img, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Image(ref, opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		opts []Option
+	}{{
+		desc: "zero idle connections",
+		opts: []Option{WithMaxIdleConnsPerHost(0)},
+	}, {
+		desc: "zero timeout",
+		opts: []Option{WithTLSHandshakeTimeout(0)},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := makeOptions(ref.Context(), tc.opts...); err == nil {
+				t.Error("makeOptions() = nil, wanted error")
+			}
+		})
+	}
+
+	// Other transports can't be tuned, so they're used as they are.
+	if _, err := Image(ref, WithTransport(roundTripperFunc(http.DefaultTransport.RoundTrip)), WithHTTP2(false)); err != nil {
+		t.Errorf("Image() with a custom transport: %v", err)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -53,17 +53,35 @@ type progressReader struct {
 
 	count    *int64 // number of bytes this reader has read, to support resetting on retry.
 	progress *progress
+
+	// undoOnError takes back what the reader has counted if reading fails,
+	// for reads that aren't retried.
+	undoOnError bool
 }
 
 func (r *progressReader) Read(b []byte) (int, error) {
 	n, err := r.rc.Read(b)
-	if err != nil {
-		return n, err
+	if n > 0 {
+		atomic.AddInt64(r.count, int64(n))
+		// TODO: warn/debug log if sending takes too long, or if sending is blocked while context is canceled.
+		r.progress.complete(int64(n))
+	}
+	if err != nil && err != io.EOF && r.undoOnError {
+		if count := atomic.SwapInt64(r.count, 0); count != 0 {
+			r.progress.complete(-count)
+		}
+	}
+	return n, err
+}
+
+// reader wraps rc, which is being read from a registry, so that reading from
+// it reports progress, if p is non-nil.
+func (p *progress) reader(rc io.ReadCloser) io.ReadCloser {
+	if p == nil {
+		return rc
 	}
-	atomic.AddInt64(r.count, int64(n))
-	// TODO: warn/debug log if sending takes too long, or if sending is blocked while context is canceled.
-	r.progress.complete(int64(n))
-	return n, nil
+	var count int64
+	return &progressReader{rc: rc, progress: p, count: &count, undoOnError: true}
 }
 
 func (r *progressReader) Close() error { return r.rc.Close() }
@@ -17,6 +17,8 @@ package remote
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -26,7 +28,6 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
@@ -42,7 +43,7 @@ func TestWriteLayer_Progress(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -73,7 +74,7 @@ func TestWriteLayer_Progress_Exists(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -105,7 +106,7 @@ func TestWrite_Progress(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -144,7 +145,7 @@ func TestWrite_Progress_DedupeLayers(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -173,7 +174,7 @@ func TestWriteIndex_Progress(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -202,7 +203,7 @@ func TestMultiWrite_Progress(t *testing.T) {
 	c := make(chan v1.Update, 1000)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -237,7 +238,7 @@ func TestMultiWrite_Progress_Retry(t *testing.T) {
 	c := make(chan v1.Update, 1000)
 
 	// Set up a fake registry.
-	handler := registry.New()
+	handler := NewRegistry()
 	numOfInternalServerErrors := 0
 	var mu sync.Mutex
 	registryThatFailsOnFirstUpload := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
@@ -287,7 +288,7 @@ func TestWriteLayer_Progress_Retry(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	handler := registry.New()
+	handler := NewRegistry()
 
 	numOfInternalServerErrors := 0
 	registryThatFailsOnFirstUpload := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
@@ -344,7 +345,7 @@ func TestWriteLayer_Progress_Error(t *testing.T) {
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	handler := registry.New()
+	handler := NewRegistry()
 	registryThatAlwaysFails := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
 		if request.Method == http.MethodPatch && strings.Contains(request.URL.Path, "blobs/uploads") {
 			responseWriter.WriteHeader(403)
@@ -407,7 +408,7 @@ func TestWrite_Progress_WithNonDistributableLayer_AndIncludeNonDistributableLaye
 	c := make(chan v1.Update, 200)
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -461,3 +462,159 @@ func checkUpdates(updates <-chan v1.Update) error {
 
 	return nil
 }
+
+func TestImage_Progress(t *testing.T) {
+	img, err := random.Image(10000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set up a fake registry.
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/progress/pull", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	c := make(chan v1.Update, 200)
+	rmt, err := Image(ref, WithReadProgress(c))
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if _, err := rmt.RawConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := rmt.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range layers {
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reads never close the channel, since they are lazy.
+	close(c)
+	if err := checkUpdates(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayer_Progress(t *testing.T) {
+	l, err := random.Layer(100000, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set up a fake registry.
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/progress/pull", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLayer(repo, l); err != nil {
+		t.Fatalf("WriteLayer: %v", err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := make(chan v1.Update, 200)
+	rmt, err := Layer(repo.Digest(h.String()), WithReadProgress(c))
+	if err != nil {
+		t.Fatalf("Layer: %v", err)
+	}
+	rc, err := rmt.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	close(c)
+	if err := checkUpdates(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayer_ReadProgress_Corrupt(t *testing.T) {
+	l, err := random.Layer(10000, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := l.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve a blob of the right size, but with the wrong contents.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(size))
+		w.Write(make([]byte, size))
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigest(fmt.Sprintf("%s/test/progress/corrupt@%s", u.Host, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := make(chan v1.Update, 200)
+	rmt, err := Layer(ref, WithReadProgress(c))
+	if err != nil {
+		t.Fatalf("Layer: %v", err)
+	}
+	rc, err := rmt.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err == nil {
+		t.Fatal("reading a corrupt blob succeeded")
+	}
+	rc.Close()
+
+	close(c)
+	var last v1.Update
+	for u := range c {
+		last = u
+	}
+	if last.Complete != 0 {
+		t.Errorf("Complete = %d after a failed read, want 0", last.Complete)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/go-containerregistry/internal/and"
+	"github.com/google/go-containerregistry/internal/redact"
+	"github.com/google/go-containerregistry/internal/verify"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// fetchBlobRange returns a reader for n bytes of the blob h, starting at off,
+// or fewer if the blob ends first. Only that range is fetched from registries
+// that support Range requests; others send the whole blob, which is skipped
+// through to off.
+//
+// Since only part of the blob is read, it can't be verified against h, unless
+// verification is strict, in which case the whole blob is fetched and verified
+// first.
+func (f *fetcher) fetchBlobRange(ctx context.Context, h v1.Hash, off, n int64) (io.ReadCloser, error) {
+	if off < 0 || n < 0 {
+		return nil, fmt.Errorf("invalid range: %d bytes at offset %d", n, off)
+	}
+	if n == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if f.strict {
+		rc, err := f.fetchBlob(ctx, verify.SizeUnknown, h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(ioutil.Discard, rc, off); err != nil && err != io.EOF {
+			rc.Close()
+			return nil, err
+		}
+		return &and.ReadCloser{Reader: io.LimitReader(rc, n), CloseFunc: rc.Close}, nil
+	}
+
+	u := f.url("blobs", h.String())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+
+	resp, err := f.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The range starts past the end of the blob.
+		resp.Body.Close()
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusPartialContent); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != off {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: unexpected Content-Range %q for range starting at %d", u.String(), resp.Header.Get("Content-Range"), off)
+		}
+	} else if _, err := io.CopyN(ioutil.Discard, resp.Body, off); err != nil && err != io.EOF {
+		// The registry ignored the Range header, so skip to it.
+		resp.Body.Close()
+		return nil, err
+	}
+
+	body := f.bandwidth.reader(ctx, resp.Body)
+	return &and.ReadCloser{Reader: io.LimitReader(body, n), CloseFunc: body.Close}, nil
+}
+
+// dataRange returns n bytes of data, starting at off, or fewer if it ends
+// first.
+func dataRange(data []byte, off, n int64) (io.ReadCloser, error) {
+	if off < 0 || n < 0 {
+		return nil, fmt.Errorf("invalid range: %d bytes at offset %d", n, off)
+	}
+	if off > int64(len(data)) {
+		off = int64(len(data))
+	}
+	if end := int64(len(data)); n > end-off {
+		n = end - off
+	}
+	return ioutil.NopCloser(bytes.NewReader(data[off : off+n])), nil
+}
+
+// verifiedDataRange is like dataRange for d.Data, which is verified against d
+// first if strict.
+func verifiedDataRange(d v1.Descriptor, strict bool, off, n int64) (io.ReadCloser, error) {
+	if strict {
+		if err := verify.Descriptor(d); err != nil {
+			return nil, err
+		}
+	}
+	return dataRange(d.Data, off, n)
+}
+
+// RangeReader returns a reader for n bytes of the compressed contents of the
+// layer, starting at off. See partial.RangeReader.
+func (rl *remoteLayer) RangeReader(off, n int64) (io.ReadCloser, error) {
+	if rl.desc != nil && rl.desc.Data != nil {
+		return verifiedDataRange(*rl.desc, rl.strict, off, n)
+	}
+	ctx := redact.NewContext(rl.context, "omitting binary blobs from logs")
+	return rl.fetchBlobRange(ctx, rl.digest, off, n)
+}
+
+// RangeReader returns a reader for n bytes of the compressed contents of the
+// layer, starting at off. See partial.RangeReader.
+func (rl *remoteImageLayer) RangeReader(off, n int64) (io.ReadCloser, error) {
+	d, err := partial.BlobDescriptor(rl, rl.digest)
+	if err != nil {
+		return nil, err
+	}
+	if d.Data != nil {
+		return verifiedDataRange(*d, rl.ri.strict, off, n)
+	}
+	ctx := redact.NewContext(rl.ri.context, "omitting binary blobs from logs")
+	return rl.ri.fetchBlobRange(ctx, rl.digest, off, n)
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+type rangeReader interface {
+	RangeReader(off, n int64) (io.ReadCloser, error)
+}
+
+func TestLayerRangeReader(t *testing.T) {
+	for _, ignoreRange := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignoreRange=%t", ignoreRange), func(t *testing.T) {
+			var served int64
+			reg := NewRegistry()
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ignoreRange {
+					r.Header.Del("Range")
+				}
+				if r.Method == http.MethodGet && r.Header.Get("Range") != "" && r.URL.Path != "/v2/" {
+					served++
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := name.ParseReference(fmt.Sprintf("%s/test/range", u.Host))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := random.Image(4096, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Write(ref, img); err != nil {
+				t.Fatal(err)
+			}
+			ls, err := img.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := ls[0].Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := ls[0].Compressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := int64(len(want))
+
+			l, err := Layer(ref.Context().Digest(d.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr, ok := l.(rangeReader)
+			if !ok {
+				t.Fatalf("%T does not implement RangeReader", l)
+			}
+
+			rimg, err := Image(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rls, err := rimg.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tc := range []struct {
+				off, n int64
+			}{
+				{0, 10},
+				{100, 200},
+				{size - 5, 5},
+				{size - 5, 100}, // past the end
+				{size + 1, 10},  // entirely past the end
+				{10, 0},
+			} {
+				wantRange := []byte{}
+				if tc.off < size {
+					end := tc.off + tc.n
+					if end > size {
+						end = size
+					}
+					wantRange = want[tc.off:end]
+				}
+				for name, read := range map[string]func(off, n int64) (io.ReadCloser, error){
+					"Layer": rr.RangeReader,
+					"Image": func(off, n int64) (io.ReadCloser, error) { return partial.RangeReader(rls[0], off, n) },
+				} {
+					rc, err := read(tc.off, tc.n)
+					if err != nil {
+						t.Fatalf("%s RangeReader(%d, %d): %v", name, tc.off, tc.n, err)
+					}
+					got, err := ioutil.ReadAll(rc)
+					if err != nil {
+						t.Fatal(err)
+					}
+					rc.Close()
+					if !bytes.Equal(got, wantRange) {
+						t.Errorf("%s RangeReader(%d, %d) = %d bytes, want %d", name, tc.off, tc.n, len(got), len(wantRange))
+					}
+				}
+			}
+			if ignoreRange && served != 0 {
+				t.Errorf("served %d range requests, want none", served)
+			}
+			if !ignoreRange && served == 0 {
+				t.Error("served no range requests")
+			}
+		})
+	}
+}
+
+func TestRangeReaderUnsupported(t *testing.T) {
+	l, err := random.Layer(100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partial.RangeReader(l, 0, 10); err == nil {
+		t.Error("expected error reading a range of a random layer")
+	}
+	ml := &MountableLayer{Layer: l}
+	if _, err := ml.RangeReader(0, 10); err == nil {
+		t.Error("expected error reading a range of a wrapped random layer")
+	}
+}
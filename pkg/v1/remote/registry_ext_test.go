@@ -0,0 +1,34 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func init() {
+	remote.NewRegistry = func() http.Handler {
+		return registry.New()
+	}
+	remote.NewQuietRegistry = func() http.Handler {
+		return registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)))
+	}
+	remote.NewTLSRegistry = registry.TLS
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// pkg/registry writes to mirrors with this package, so tests in this package
+// can't import it. Instead, registry_ext_test.go, which is in the remote_test
+// package, sets these to its constructors before the tests run.
+var (
+	// NewRegistry is registry.New().
+	NewRegistry func() http.Handler
+	// NewQuietRegistry is registry.New(), without logging.
+	NewQuietRegistry func() http.Handler
+	// NewTLSRegistry is registry.TLS.
+	NewTLSRegistry func(domain string) (*httptest.Server, error)
+)
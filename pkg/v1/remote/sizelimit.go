@@ -0,0 +1,179 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/stream"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SizeLimitError is returned when pushing a layer or manifest that is larger
+// than the registry accepts. It is returned before anything is uploaded.
+type SizeLimitError struct {
+	Registry  name.Registry
+	Digest    v1.Hash
+	MediaType types.MediaType
+	Size      int64
+	Limit     int64
+}
+
+// Error implements error.
+func (e *SizeLimitError) Error() string {
+	kind := "layer"
+	if e.MediaType.IsImage() || e.MediaType.IsIndex() {
+		kind = "manifest"
+	}
+	return fmt.Sprintf("%s %s (%s) is %d bytes, larger than the %d bytes accepted by %s", kind, e.Digest, e.MediaType, e.Size, e.Limit, e.Registry)
+}
+
+// sizeLimits are the largest layers and manifests a registry accepts, or zero
+// if there's no known limit.
+type sizeLimits struct {
+	layer    int64
+	manifest int64
+}
+
+// knownSizeLimits returns the documented limits of some popular registries.
+// These may change without notice; WithMaxLayerSize overrides them.
+func knownSizeLimits(reg name.Registry) sizeLimits {
+	host := reg.RegistryStr()
+	switch {
+	case host == "ghcr.io":
+		// https://docs.github.com/en/packages/working-with-a-github-packages-registry/working-with-the-container-registry
+		return sizeLimits{layer: 10 << 30}
+	case strings.Contains(host, ".dkr.ecr.") && strings.HasSuffix(host, ".amazonaws.com"):
+		// https://docs.aws.amazon.com/AmazonECR/latest/userguide/service-quotas.html
+		return sizeLimits{layer: 52000 << 20}
+	case host == name.DefaultRegistry:
+		// Docker Hub runs distribution, which rejects manifests over 4MiB.
+		return sizeLimits{manifest: 4 << 20}
+	}
+	return sizeLimits{}
+}
+
+// makeSizeLimits returns the limits to check before pushing to reg.
+func makeSizeLimits(reg name.Registry, o *options) sizeLimits {
+	l := knownSizeLimits(reg)
+	switch {
+	case o.maxLayerSize > 0:
+		l.layer = o.maxLayerSize
+	case o.maxLayerSize < 0:
+		l.layer = 0
+	}
+	return l
+}
+
+func (l sizeLimits) none() bool {
+	return l.layer <= 0 && l.manifest <= 0
+}
+
+// checkLayer returns a *SizeLimitError if layer is too large. Streaming layers
+// can't be checked, since their size isn't known until they're uploaded.
+func (l sizeLimits) checkLayer(reg name.Registry, layer v1.Layer, allowNondistributableArtifacts bool) error {
+	if l.layer <= 0 {
+		return nil
+	}
+	mt, err := layer.MediaType()
+	if err != nil {
+		return err
+	}
+	if !mt.IsDistributable() && !allowNondistributableArtifacts {
+		// We won't upload it.
+		return nil
+	}
+	size, err := layer.Size()
+	if errors.Is(err, stream.ErrNotComputed) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if size <= l.layer {
+		return nil
+	}
+	h, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+	return &SizeLimitError{Registry: reg, Digest: h, MediaType: mt, Size: size, Limit: l.layer}
+}
+
+// check returns a *SizeLimitError if t, or any layer or manifest it refers to,
+// is too large.
+func (l sizeLimits) check(reg name.Registry, t Taggable, allowNondistributableArtifacts bool) error {
+	if l.none() {
+		return nil
+	}
+	if l.manifest > 0 {
+		// Images with streaming layers don't have a manifest yet, so
+		// they're only checked once it's pushed.
+		if _, desc, err := unpackTaggable(t); err == nil && desc.Size > l.manifest {
+			return &SizeLimitError{Registry: reg, Digest: desc.Digest, MediaType: desc.MediaType, Size: desc.Size, Limit: l.manifest}
+		}
+	}
+
+	switch t := t.(type) {
+	case v1.Image:
+		ls, err := t.Layers()
+		if err != nil {
+			return err
+		}
+		for _, layer := range ls {
+			if err := l.checkLayer(reg, layer, allowNondistributableArtifacts); err != nil {
+				return err
+			}
+		}
+	case v1.ImageIndex:
+		index, err := t.IndexManifest()
+		if err != nil {
+			return err
+		}
+		for _, desc := range index.Manifests {
+			switch desc.MediaType {
+			case types.OCIImageIndex, types.DockerManifestList:
+				ii, err := t.ImageIndex(desc.Digest)
+				if err != nil {
+					return err
+				}
+				if err := l.check(reg, ii, allowNondistributableArtifacts); err != nil {
+					return err
+				}
+			case types.OCIManifestSchema1, types.DockerManifestSchema2:
+				img, err := t.Image(desc.Digest)
+				if err != nil {
+					return err
+				}
+				if err := l.check(reg, img, allowNondistributableArtifacts); err != nil {
+					return err
+				}
+			default:
+				// Layers are only pushed if the index can produce them,
+				// see writeIndex.
+				if _, ok := t.(withLayer); !ok {
+					continue
+				}
+				if l.layer > 0 && desc.Size > l.layer && (desc.MediaType.IsDistributable() || allowNondistributableArtifacts) {
+					return &SizeLimitError{Registry: reg, Digest: desc.Digest, MediaType: desc.MediaType, Size: desc.Size, Limit: l.layer}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestMaxLayerSize(t *testing.T) {
+	// Count the uploads the registry sees.
+	var uploads int32
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			atomic.AddInt32(&uploads, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/sizelimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		write func(...Option) error
+	}{{
+		name:  "Write",
+		write: func(opts ...Option) error { return Write(ref, img, opts...) },
+	}, {
+		name:  "WriteIndex",
+		write: func(opts ...Option) error { return WriteIndex(ref, idx, opts...) },
+	}, {
+		name:  "WriteLayer",
+		write: func(opts ...Option) error { return WriteLayer(ref.Context(), layer, opts...) },
+	}, {
+		name:  "MultiWrite",
+		write: func(opts ...Option) error { return MultiWrite(map[name.Reference]Taggable{ref: idx}, opts...) },
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&uploads, 0)
+			err := tc.write(WithMaxLayerSize(100))
+			var serr *SizeLimitError
+			if !errors.As(err, &serr) {
+				t.Fatalf("got %v, want *SizeLimitError", err)
+			}
+			if serr.Size <= serr.Limit || serr.Limit != 100 {
+				t.Errorf("got size %d, limit %d", serr.Size, serr.Limit)
+			}
+			if n := atomic.LoadInt32(&uploads); n != 0 {
+				t.Errorf("registry saw %d upload requests, want none", n)
+			}
+
+			// Limits that fit, or no limit, are fine.
+			if err := tc.write(WithMaxLayerSize(1 << 20)); err != nil {
+				t.Errorf("under limit: %v", err)
+			}
+			if err := tc.write(WithMaxLayerSize(0)); err != nil {
+				t.Errorf("no limit: %v", err)
+			}
+		})
+	}
+}
+
+func TestKnownSizeLimits(t *testing.T) {
+	for _, tc := range []struct {
+		registry string
+		want     sizeLimits
+	}{
+		{"ghcr.io", sizeLimits{layer: 10 << 30}},
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com", sizeLimits{layer: 52000 << 20}},
+		{"index.docker.io", sizeLimits{manifest: 4 << 20}},
+		{"docker.io", sizeLimits{manifest: 4 << 20}},
+		{"example.com", sizeLimits{}},
+	} {
+		reg, err := name.NewRegistry(tc.registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := knownSizeLimits(reg); got != tc.want {
+			t.Errorf("knownSizeLimits(%q) = %+v, want %+v", tc.registry, got, tc.want)
+		}
+	}
+}
+
+func TestMaxManifestSize(t *testing.T) {
+	reg, err := name.NewRegistry("index.docker.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := makeSizeLimits(reg, &options{})
+	if err := l.check(reg, img, false); err != nil {
+		t.Errorf("check: %v", err)
+	}
+
+	l.manifest = 10
+	var serr *SizeLimitError
+	if err := l.check(reg, img, false); !errors.As(err, &serr) {
+		t.Fatalf("got %v, want *SizeLimitError", err)
+	}
+	if !strings.HasPrefix(serr.Error(), "manifest ") {
+		t.Errorf("Error() = %q, want manifest error", serr.Error())
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/go-containerregistry/internal/and"
+	"github.com/google/go-containerregistry/internal/verify"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// VerificationError is returned when fetched content doesn't match its
+// digest. With WithDigestVerification, it's returned instead of the content;
+// otherwise, it may be returned after some of the content has been read.
+type VerificationError = verify.Error
+
+// verifyManifest returns a VerificationError if the manifest fetched for ref,
+// of size bytes with digest got, doesn't match ref's digest or, for a tag, the
+// Docker-Content-Digest header the registry sent with it, if any.
+func (f *fetcher) verifyManifest(ref name.Reference, got v1.Hash, size int64, header v1.Hash) error {
+	want := header
+	if dgst, ok := pinnedDigest(ref); ok {
+		h, err := v1.NewHash(dgst)
+		if err != nil {
+			return err
+		}
+		want = h
+	}
+	if want != (v1.Hash{}) && want != got {
+		return verify.NewError(got, want, size)
+	}
+	return nil
+}
+
+// verifiedBlob reads all of rc, which verifies its contents, into a temporary
+// file, so that none of it is returned before it has all been verified. The
+// file is removed when the returned reader is closed.
+func verifiedBlob(rc io.ReadCloser) (io.ReadCloser, error) {
+	defer rc.Close()
+	tmp, err := ioutil.TempFile("", "verified-blob-")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() error {
+		err := tmp.Close()
+		if rerr := os.Remove(tmp.Name()); err == nil {
+			err = rerr
+		}
+		return err
+	}
+	if _, err := io.Copy(tmp, rc); err != nil {
+		_ = remove()
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		_ = remove()
+		return nil, err
+	}
+	return &and.ReadCloser{Reader: tmp, CloseFunc: remove}, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestDigestVerification(t *testing.T) {
+	bogus := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0", 64)}
+	var (
+		corruptPath string
+		lieOnHead   bool
+		lieOnGet    bool
+	)
+	reg := NewRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == corruptPath {
+			w.Write([]byte("corrupt"))
+			return
+		}
+		if strings.Contains(r.URL.Path, "/manifests/") && ((r.Method == http.MethodHead && lieOnHead) || (r.Method == http.MethodGet && lieOnGet)) {
+			w = &lyingWriter{ResponseWriter: w, digest: bogus.String()}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(fmt.Sprintf("%s/test/strict:latest", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ld, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict := WithDigestVerification(true)
+
+	t.Run("blob", func(t *testing.T) {
+		corruptPath = fmt.Sprintf("/v2/test/strict/blobs/%s", ld)
+		defer func() { corruptPath = "" }()
+		l, err := Layer(ref.Context().Digest(ld.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Without strict verification, the blob is only verified at EOF.
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed() = %v", err)
+		}
+		if _, err := ioutil.ReadAll(rc); !errors.As(err, &VerificationError{}) {
+			t.Errorf("ReadAll() = %v, want VerificationError", err)
+		}
+		rc.Close()
+
+		l, err = Layer(ref.Context().Digest(ld.String()), strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.Compressed(); !errors.As(err, &VerificationError{}) {
+			t.Errorf("strict Compressed() = %v, want VerificationError", err)
+		}
+		ml := l.(*MountableLayer)
+		if _, err := ml.RangeReader(0, 2); !errors.As(err, &VerificationError{}) {
+			t.Errorf("strict RangeReader() = %v, want VerificationError", err)
+		}
+	})
+
+	t.Run("intact blob", func(t *testing.T) {
+		l, err := Layer(ref.Context().Digest(ld.String()), strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size, err := l.Size(); err != nil || int64(len(b)) != size {
+			t.Errorf("read %d bytes, want %d", len(b), size)
+		}
+		rr, err := l.(*MountableLayer).RangeReader(1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rr.Close()
+		part, err := ioutil.ReadAll(rr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(part) != string(b[1:3]) {
+			t.Errorf("RangeReader(1, 2) = %q, want %q", part, b[1:3])
+		}
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		lieOnGet = true
+		defer func() { lieOnGet = false }()
+		if _, err := Get(ref); err != nil {
+			t.Errorf("Get() = %v", err)
+		}
+		if _, err := Get(ref, strict); !errors.As(err, &VerificationError{}) {
+			t.Errorf("strict Get() = %v, want VerificationError", err)
+		}
+	})
+
+	t.Run("head", func(t *testing.T) {
+		lieOnHead = true
+		defer func() { lieOnHead = false }()
+		desc, err := Head(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Digest != bogus {
+			t.Errorf("Head() = %s, wanted the header's %s", desc.Digest, bogus)
+		}
+		desc, err = Head(ref, strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Digest != digest {
+			t.Errorf("strict Head() = %s, want %s", desc.Digest, digest)
+		}
+	})
+}
+
+// lyingWriter replaces the Docker-Content-Digest header of a response.
+type lyingWriter struct {
+	http.ResponseWriter
+	digest string
+}
+
+func (w *lyingWriter) WriteHeader(code int) {
+	if w.Header().Get("Docker-Content-Digest") != "" {
+		w.Header().Set("Docker-Content-Digest", w.digest)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
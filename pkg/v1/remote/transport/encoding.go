@@ -0,0 +1,93 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Identity is the Accept-Encoding that asks for content exactly as it's
+// stored, without any compression that the server or a proxy would otherwise
+// apply on the fly.
+const Identity = "identity"
+
+// AcceptEncoding returns the Accept-Encoding header to send with a request, or
+// "" to leave the request alone.
+type AcceptEncoding func(*http.Request) string
+
+// BlobGets returns an AcceptEncoding that asks for encoding when getting
+// blobs, including from any hosts the registry redirects the request to, and
+// leaves other requests alone.
+func BlobGets(encoding string) AcceptEncoding {
+	return func(req *http.Request) string {
+		// Find the request we were first asked to make, before any
+		// redirects.
+		for req.Response != nil && req.Response.Request != nil {
+			req = req.Response.Request
+		}
+		if req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v2/") && strings.Contains(req.URL.Path, "/blobs/") && !strings.Contains(req.URL.Path, "/blobs/uploads/") {
+			return encoding
+		}
+		return ""
+	}
+}
+
+type acceptEncodingTransport struct {
+	inner    http.RoundTripper
+	encoding AcceptEncoding
+}
+
+// NewAcceptEncoding returns an http.RoundTripper that sets the Accept-Encoding
+// header of requests that don't already have one to what encoding returns for
+// them.
+//
+// An http.Transport normally asks for gzip and transparently decompresses the
+// response, which corrupts the contents of blobs with registries that compress
+// them again, or that report the size of the compressed response as the size
+// of the blob. Setting the header stops it from doing either: the body of the
+// response is returned as it was sent. If the header is Identity, a response
+// with any other Content-Encoding is an error rather than something that won't
+// match its digest.
+func NewAcceptEncoding(inner http.RoundTripper, encoding AcceptEncoding) http.RoundTripper {
+	return &acceptEncodingTransport{
+		inner:    inner,
+		encoding: encoding,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *acceptEncodingTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	if in.Header.Get("Accept-Encoding") != "" {
+		return t.inner.RoundTrip(in)
+	}
+	enc := t.encoding(in)
+	if enc == "" {
+		return t.inner.RoundTrip(in)
+	}
+
+	out := in.Clone(in.Context())
+	out.Header.Set("Accept-Encoding", enc)
+	resp, err := t.inner.RoundTrip(out)
+	if err != nil || enc != Identity {
+		return resp, err
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" && !strings.EqualFold(ce, Identity) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("asked %s for %s content, got Content-Encoding %q", in.URL.Host, Identity, ce)
+	}
+	return resp, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/foo/blobs/redirect" {
+			http.Redirect(w, r, "/storage/sha256:abc", http.StatusTemporaryRedirect)
+			return
+		}
+		got = append(got, r.URL.Path+" "+r.Header.Get("Accept-Encoding"))
+		if r.URL.Path == "/v2/foo/blobs/gzipped" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}))
+	defer server.Close()
+
+	client := http.Client{Transport: NewAcceptEncoding(http.DefaultTransport, BlobGets(Identity))}
+	for _, path := range []string{"/v2/foo/blobs/sha256:abc", "/v2/foo/blobs/redirect", "/v2/foo/manifests/latest"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	want := []string{
+		"/v2/foo/blobs/sha256:abc identity",
+		"/storage/sha256:abc identity",
+		"/v2/foo/manifests/latest gzip",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	// Asking for identity and getting gzip is an error.
+	if resp, err := client.Get(server.URL + "/v2/foo/blobs/gzipped"); err == nil {
+		resp.Body.Close()
+		t.Error("expected error for gzip response")
+	}
+}
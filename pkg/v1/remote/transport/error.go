@@ -32,6 +32,11 @@ type Error struct {
 	StatusCode int
 	// The request that failed.
 	Request *http.Request
+	// The ID of the request that failed, as reported by the registry in a
+	// header like X-Request-Id or X-Amzn-RequestId, or as sent by us.
+	// Registry vendors usually ask for this when investigating failures.
+	// It isn't included in Error(), so that messages stay stable.
+	RequestID string
 	// The raw body if we couldn't understand it.
 	rawBody string
 }
@@ -168,6 +173,7 @@ func CheckError(resp *http.Response, codes ...int) error {
 	structuredError.rawBody = string(b)
 	structuredError.StatusCode = resp.StatusCode
 	structuredError.Request = resp.Request
+	structuredError.RequestID = requestID(resp)
 
 	return structuredError
 }
@@ -67,6 +67,13 @@ func TestTemporary(t *testing.T) {
 			StatusCode: http.StatusInternalServerError,
 		},
 		retry: true,
+	}, {
+		// Retried by the transport if there's a Retry-After header, but
+		// otherwise not.
+		error: &Error{
+			StatusCode: http.StatusTooManyRequests,
+		},
+		retry: false,
 	}}
 
 	for _, test := range tests {
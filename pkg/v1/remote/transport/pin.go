@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// TLSPin describes how to verify the identity of a single registry.
+type TLSPin struct {
+	// RootCAs, if set, replaces the system roots when verifying the
+	// registry's certificate chain.
+	RootCAs *x509.CertPool
+
+	// SPKIHashes, if set, are base64-encoded SHA-256 hashes of DER-encoded
+	// SubjectPublicKeyInfo, as in HPKP's pin-sha256. At least one
+	// certificate in the verified chain must match one of them.
+	SPKIHashes []string
+}
+
+var _ http.RoundTripper = (*pinnedTransport)(nil)
+
+type pinnedTransport struct {
+	inner http.RoundTripper
+
+	// maps registry host -> transport that enforces its pin
+	pinned map[string]http.RoundTripper
+}
+
+// NewPinned returns a transport that verifies TLS connections to each registry
+// in pins according to its TLSPin. Pins are keyed by registry, as returned by
+// name.Registry.RegistryStr, e.g. "registry.internal" or "localhost:5000".
+//
+// Requests to pinned registries are sent through clones of inner with the
+// pinned TLS configuration applied, so other hosts (including blob storage a
+// registry redirects to) are unaffected. Each clone has its own connection
+// pool, so the result should be reused rather than built for every request.
+func NewPinned(inner *http.Transport, pins map[string]TLSPin) http.RoundTripper {
+	pt := &pinnedTransport{
+		inner:  inner,
+		pinned: make(map[string]http.RoundTripper, len(pins)),
+	}
+	for host, pin := range pins {
+		t := inner.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		if pin.RootCAs != nil {
+			t.TLSClientConfig.RootCAs = pin.RootCAs
+		}
+		if len(pin.SPKIHashes) != 0 {
+			t.TLSClientConfig.VerifyConnection = verifySPKI(host, pin.SPKIHashes)
+		}
+		pt.pinned[host] = t
+	}
+	return pt
+}
+
+// RoundTrip implements http.RoundTripper
+func (pt *pinnedTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	if t, ok := pt.pinned[in.URL.Host]; ok {
+		return t.RoundTrip(in)
+	}
+	return pt.inner.RoundTrip(in)
+}
+
+// CloseIdleConnections closes the idle connections of the clones of inner,
+// leaving inner's alone.
+func (pt *pinnedTransport) CloseIdleConnections() {
+	for _, t := range pt.pinned {
+		if ci, ok := t.(interface{ CloseIdleConnections() }); ok {
+			ci.CloseIdleConnections()
+		}
+	}
+}
+
+func verifySPKI(host string, hashes []string) func(tls.ConnectionState) error {
+	want := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		want[h] = true
+	}
+	return func(cs tls.ConnectionState) error {
+		var certs []*x509.Certificate
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+		// With InsecureSkipVerify there are no verified chains, and only the
+		// leaf is known to belong to the peer.
+		if len(certs) == 0 && len(cs.PeerCertificates) != 0 {
+			certs = cs.PeerCertificates[:1]
+		}
+		for _, cert := range certs {
+			if want[SPKIHash(cert)] {
+				return nil
+			}
+		}
+		return fmt.Errorf("tls: no certificate presented by %s matches its pinned public keys", host)
+	}
+}
+
+// SPKIHash returns the base64-encoded SHA-256 hash of cert's
+// SubjectPublicKeyInfo, suitable for use in TLSPin.SPKIHashes.
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPinnedTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	good := SPKIHash(server.Certificate())
+	bad := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	for _, test := range []struct {
+		name    string
+		inner   *http.Transport
+		pins    map[string]TLSPin
+		wantErr bool
+	}{{
+		name:    "not pinned",
+		inner:   &http.Transport{},
+		wantErr: true,
+	}, {
+		name:    "other host pinned",
+		inner:   &http.Transport{},
+		pins:    map[string]TLSPin{"registry.internal": {RootCAs: roots}},
+		wantErr: true,
+	}, {
+		name:  "custom roots",
+		inner: &http.Transport{},
+		pins:  map[string]TLSPin{u.Host: {RootCAs: roots}},
+	}, {
+		name:  "custom roots and spki",
+		inner: &http.Transport{},
+		pins:  map[string]TLSPin{u.Host: {RootCAs: roots, SPKIHashes: []string{bad, good}}},
+	}, {
+		name:    "custom roots and wrong spki",
+		inner:   &http.Transport{},
+		pins:    map[string]TLSPin{u.Host: {RootCAs: roots, SPKIHashes: []string{bad}}},
+		wantErr: true,
+	}, {
+		name:  "insecure and spki",
+		inner: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		pins:  map[string]TLSPin{u.Host: {SPKIHashes: []string{good}}},
+	}, {
+		name:    "insecure and wrong spki",
+		inner:   &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		pins:    map[string]TLSPin{u.Host: {SPKIHashes: []string{bad}}},
+		wantErr: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			client := http.Client{Transport: NewPinned(test.inner, test.pins)}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != test.wantErr {
+				t.Errorf("Get() = %v, wantErr %t", err, test.wantErr)
+			}
+		})
+	}
+}
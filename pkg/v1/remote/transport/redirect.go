@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+type redirectAuthTransport struct {
+	inner    http.RoundTripper
+	registry string
+	keychain authn.Keychain
+
+	lock sync.Mutex
+	// hosts that have presented a Basic challenge
+	challenged map[string]bool
+}
+
+var _ http.RoundTripper = (*redirectAuthTransport)(nil)
+
+// NewRedirectAuth returns a transport that authenticates requests to hosts
+// other than registry which respond with their own Basic challenge, such as
+// artifact proxies that the registry redirects blob requests to, using the
+// credentials that keychain resolves for that host.
+//
+// The registry's own credentials are never sent to other hosts (see
+// NewWithContext), and requests to registry itself are passed through to
+// inner unchanged.
+func NewRedirectAuth(inner http.RoundTripper, registry string, keychain authn.Keychain) http.RoundTripper {
+	return &redirectAuthTransport{
+		inner:      inner,
+		registry:   registry,
+		keychain:   keychain,
+		challenged: map[string]bool{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *redirectAuthTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	host := in.URL.Host
+	if host == rt.registry || in.Header.Get("Authorization") != "" {
+		return rt.inner.RoundTrip(in)
+	}
+
+	// Once a host has challenged us, authenticate up front to avoid a round
+	// trip for every request.
+	rt.lock.Lock()
+	challenged := rt.challenged[host]
+	rt.lock.Unlock()
+	if challenged {
+		out, ok, err := rt.authenticate(in)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return rt.inner.RoundTrip(out)
+		}
+		return rt.inner.RoundTrip(in)
+	}
+
+	resp, err := rt.inner.RoundTrip(in)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !isBasic(resp) {
+		return resp, err
+	}
+	// We can only retry requests whose bodies can be replayed.
+	if in.Body != nil && in.Body != http.NoBody && in.GetBody == nil {
+		return resp, nil
+	}
+	out, ok, err := rt.authenticate(in)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if !ok {
+		// We don't have any credentials for this host.
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	rt.lock.Lock()
+	rt.challenged[host] = true
+	rt.lock.Unlock()
+	return rt.inner.RoundTrip(out)
+}
+
+// authenticate returns a copy of in with Basic auth for its host from the
+// keychain, or false if there are no credentials for the host.
+func (rt *redirectAuthTransport) authenticate(in *http.Request) (*http.Request, bool, error) {
+	reg, err := name.NewRegistry(in.URL.Host)
+	if err != nil {
+		// Not something the keychain could have credentials for.
+		return nil, false, nil
+	}
+	auth, err := rt.keychain.Resolve(reg)
+	if err != nil {
+		return nil, false, err
+	}
+	if auth == authn.Anonymous {
+		return nil, false, nil
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var hdr string
+	if user, pass := cfg.Username, cfg.Password; user != "" && pass != "" {
+		delimited := fmt.Sprintf("%s:%s", user, pass)
+		hdr = "Basic " + base64.StdEncoding.EncodeToString([]byte(delimited))
+	} else if token := cfg.Auth; token != "" {
+		hdr = "Basic " + token
+	} else {
+		return nil, false, nil
+	}
+
+	out := in.Clone(in.Context())
+	if in.GetBody != nil {
+		body, err := in.GetBody()
+		if err != nil {
+			return nil, false, err
+		}
+		out.Body = body
+	}
+	out.Header.Set("Authorization", hdr)
+	return out, true, nil
+}
+
+func isBasic(resp *http.Response) bool {
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(strings.ToLower(h), string(basic)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+type hostKeychain map[string]authn.Authenticator
+
+func (k hostKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k[r.RegistryStr()]; ok {
+		return auth, nil
+	}
+	return authn.Anonymous, nil
+}
+
+func TestRedirectAuth(t *testing.T) {
+	var challenges int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "proxy" || pass != "secret" {
+			challenges++
+			w.Header().Set("WWW-Authenticate", `Basic realm="proxy"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kc := hostKeychain{
+		u.Host:             &authn.Basic{Username: "proxy", Password: "secret"},
+		"registry.example": &authn.Basic{Username: "registry", Password: "secret"},
+	}
+	tr := NewRedirectAuth(http.DefaultTransport, "registry.example", kc)
+	client := &http.Client{Transport: tr}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(proxy.URL + "/blob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %d: got status %d, want %d", i, resp.StatusCode, http.StatusOK)
+		}
+	}
+	// Only the first request should have been challenged.
+	if challenges != 1 {
+		t.Errorf("got %d challenges, want 1", challenges)
+	}
+
+	// Without credentials for the host, the challenge is returned as-is.
+	tr = NewRedirectAuth(http.DefaultTransport, "registry.example", hostKeychain{})
+	client = &http.Client{Transport: tr}
+	resp, err := client.Get(proxy.URL + "/blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	// Requests to the registry itself are left to the registry's transport.
+	challenges = 0
+	tr = NewRedirectAuth(http.DefaultTransport, u.Host, kc)
+	client = &http.Client{Transport: tr}
+	resp, err = client.Get(proxy.URL + "/blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || challenges != 1 {
+		t.Errorf("got status %d after %d challenges, want %d after 1", resp.StatusCode, challenges, http.StatusUnauthorized)
+	}
+}
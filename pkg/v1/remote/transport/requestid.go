@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header NewRequestID sets if none is given.
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// requestIDHeaders are the headers registries use to identify a request, in
+// the order Error looks for them.
+var requestIDHeaders = []string{
+	DefaultRequestIDHeader,
+	"X-Amzn-RequestId",
+	"X-Amz-Request-Id",
+	"X-Ms-Request-Id",
+	"Docker-Request-Id",
+}
+
+type requestIDTransport struct {
+	inner  http.RoundTripper
+	header string
+	id     func() string
+}
+
+// NewRequestID returns an http.RoundTripper that sets header to a value from
+// id on every request that doesn't already have it, so the request can be
+// correlated with the registry's logs. If header is empty,
+// DefaultRequestIDHeader is used. If id is nil, a random ID is generated.
+func NewRequestID(inner http.RoundTripper, header string, id func() string) http.RoundTripper {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	if id == nil {
+		id = randomRequestID
+	}
+	return &requestIDTransport{
+		inner:  inner,
+		header: header,
+		id:     id,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *requestIDTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	if in.Header.Get(rt.header) == "" {
+		if id := rt.id(); id != "" {
+			in.Header.Set(rt.header, id)
+		}
+	}
+	return rt.inner.RoundTrip(in)
+}
+
+func randomRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID the registry assigned to the request that resp
+// answers or, failing that, the one we sent.
+func requestID(resp *http.Response) string {
+	for _, h := range requestIDHeaders {
+		if id := resp.Header.Get(h); id != "" {
+			return id
+		}
+	}
+	if resp.Request != nil {
+		for _, h := range requestIDHeaders {
+			if id := resp.Request.Header.Get(h); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
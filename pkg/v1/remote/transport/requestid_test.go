@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Correlation-Id"))
+	}))
+	defer server.Close()
+
+	n := 0
+	tr := NewRequestID(http.DefaultTransport, "X-Correlation-Id", func() string {
+		n++
+		return strings.Repeat("a", n)
+	})
+	client := http.Client{Transport: tr}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// A request that already has an ID keeps it.
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Correlation-Id", "mine")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := []string{"a", "aa", "mine"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got IDs %v, want %v", got, want)
+	}
+}
+
+func TestRequestIDDefaults(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(DefaultRequestIDHeader)
+	}))
+	defer server.Close()
+
+	client := http.Client{Transport: NewRequestID(http.DefaultTransport, "", nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(got) != 32 {
+		t.Errorf("got ID %q, want 32 hex characters", got)
+	}
+}
+
+func TestCheckErrorRequestID(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		header  http.Header
+		request *http.Request
+		want    string
+	}{{
+		desc: "none",
+	}, {
+		desc:   "X-Request-Id",
+		header: http.Header{"X-Request-Id": []string{"abc"}},
+		want:   "abc",
+	}, {
+		desc:   "X-Amzn-RequestId",
+		header: http.Header{"X-Amzn-Requestid": []string{"def"}},
+		want:   "def",
+	}, {
+		desc:    "from request",
+		request: &http.Request{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: "example.com"}, Header: http.Header{"X-Request-Id": []string{"ours"}}},
+		want:    "ours",
+	}, {
+		desc:    "response wins",
+		header:  http.Header{"X-Request-Id": []string{"theirs"}},
+		request: &http.Request{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: "example.com"}, Header: http.Header{"X-Request-Id": []string{"ours"}}},
+		want:    "theirs",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Header:     tc.header,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errors":[{"code":"NAME_INVALID","message":"bad"}]}`)),
+				Request:    tc.request,
+			}
+			var terr *Error
+			if err := CheckError(resp, http.StatusOK); !errors.As(err, &terr) {
+				t.Fatalf("CheckError() = %v, wanted *transport.Error", err)
+			}
+			if terr.RequestID != tc.want {
+				t.Errorf("RequestID = %q, want %q", terr.RequestID, tc.want)
+			}
+			if tc.want != "" && strings.Contains(terr.Error(), tc.want) {
+				t.Errorf("Error() = %q, wanted no request id", terr.Error())
+			}
+		})
+	}
+}
@@ -15,7 +15,11 @@
 package transport
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/go-containerregistry/internal/retry"
@@ -29,21 +33,38 @@ var defaultBackoff = retry.Backoff{
 	Steps:    5,
 }
 
+// Registries that send Retry-After can ask for long waits (Docker Hub's rate
+// limits reset every few hours), so don't wait more than a minute at a time.
+const defaultMaxRetryAfter = time.Minute
+
+// retryStatusCodes are the responses that ask us to try again later.
+//
+// 429 isn't a temporary error, since retrying on our own schedule would only
+// use up more of the quota, so it's only retried when the registry says when
+// to with Retry-After.
+var retryStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true, // only with Retry-After
+	http.StatusServiceUnavailable: false,
+}
+
 var _ http.RoundTripper = (*retryTransport)(nil)
 
-// retryTransport wraps a RoundTripper and retries temporary network errors.
+// retryTransport wraps a RoundTripper and retries temporary network errors,
+// as well as responses that ask us to try again later.
 type retryTransport struct {
-	inner     http.RoundTripper
-	backoff   retry.Backoff
-	predicate retry.Predicate
+	inner         http.RoundTripper
+	backoff       retry.Backoff
+	predicate     retry.Predicate
+	maxRetryAfter time.Duration
 }
 
 // Option is a functional option for retryTransport.
 type Option func(*options)
 
 type options struct {
-	backoff   retry.Backoff
-	predicate retry.Predicate
+	backoff       retry.Backoff
+	predicate     retry.Predicate
+	maxRetryAfter time.Duration
 }
 
 // Backoff is an alias of retry.Backoff to expose this configuration option to consumers of this lib
@@ -63,11 +84,26 @@ func WithRetryPredicate(predicate func(error) bool) Option {
 	}
 }
 
+// WithMaxRetryAfter caps how long to wait before retrying a 429 or 503
+// response with a Retry-After header. Longer waits are shortened to max.
+func WithMaxRetryAfter(max time.Duration) Option {
+	return func(o *options) {
+		o.maxRetryAfter = max
+	}
+}
+
 // NewRetry returns a transport that retries errors.
+//
+// Responses with a 429 or 503 status are also retried, after the delay in
+// their Retry-After header. 503 responses without one are retried after the
+// next backoff step, but 429 responses without one are returned as-is.
+// Requests are not retried if the request's context would expire before the
+// retry, or if the request's body can't be replayed.
 func NewRetry(inner http.RoundTripper, opts ...Option) http.RoundTripper {
 	o := &options{
-		backoff:   defaultBackoff,
-		predicate: retry.IsTemporary,
+		backoff:       defaultBackoff,
+		predicate:     retry.IsTemporary,
+		maxRetryAfter: defaultMaxRetryAfter,
 	}
 
 	for _, opt := range opts {
@@ -75,17 +111,102 @@ func NewRetry(inner http.RoundTripper, opts ...Option) http.RoundTripper {
 	}
 
 	return &retryTransport{
-		inner:     inner,
-		backoff:   o.backoff,
-		predicate: o.predicate,
+		inner:         inner,
+		backoff:       o.backoff,
+		predicate:     o.predicate,
+		maxRetryAfter: o.maxRetryAfter,
 	}
 }
 
-func (t *retryTransport) RoundTrip(in *http.Request) (out *http.Response, err error) {
-	roundtrip := func() error {
-		out, err = t.inner.RoundTrip(in)
-		return err
+// RoundTrip follows the same schedule as retry.Retry, but waits for as long as
+// the registry asks when it sends a Retry-After header.
+func (t *retryTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	ctx := context.Background()
+	if in != nil {
+		ctx = in.Context()
+	}
+	backoff := t.backoff
+	req := in
+	for {
+		out, err := t.inner.RoundTrip(req)
+		// This is the last attempt if we're out of steps, including when the
+		// previous step hit the backoff's Cap, which zeroes Steps.
+		if backoff.Steps <= 1 {
+			return out, err
+		}
+
+		delay := backoff.Step()
+		if err != nil {
+			if !t.predicate(err) {
+				return out, err
+			}
+		} else {
+			needRetryAfter, ok := retryStatusCodes[out.StatusCode]
+			if !ok || !replayable(in) {
+				return out, err
+			}
+			if d, ok := retryAfter(out); ok {
+				delay = d
+				if t.maxRetryAfter > 0 && delay > t.maxRetryAfter {
+					delay = t.maxRetryAfter
+				}
+			} else if needRetryAfter {
+				return out, err
+			}
+		}
+
+		// Don't bother waiting if we'd run out of time before trying again.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return out, err
+		}
+		if out != nil {
+			io.Copy(ioutil.Discard, out.Body)
+			out.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if in != nil && in.GetBody != nil {
+			body, err := in.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = in.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// replayable returns true if we can send the body of in again.
+func replayable(in *http.Request) bool {
+	return in.Body == nil || in.Body == http.NoBody || in.GetBody != nil
+}
+
+// retryAfter parses the Retry-After header of resp, which is either a number
+// of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(ra); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
 	}
-	retry.Retry(roundtrip, t.predicate, t.backoff)
-	return
+	return 0, false
 }
@@ -17,8 +17,10 @@ package transport
 import (
 	"context"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -130,3 +132,140 @@ func TestTimeoutContext(t *testing.T) {
 		t.Fatalf("deadline was not recognized by transport")
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		status     int
+		retryAfter string
+		opts       []Option
+		timeout    time.Duration
+		want       int
+		count      int
+	}{{
+		name:       "retry after seconds",
+		retryAfter: "0",
+		want:       http.StatusOK,
+		count:      2,
+	}, {
+		name:       "retry after date",
+		retryAfter: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat),
+		want:       http.StatusOK,
+		count:      2,
+	}, {
+		name:   "unavailable without retry after",
+		status: http.StatusServiceUnavailable,
+		opts:   []Option{WithRetryBackoff(retry.Backoff{Duration: time.Millisecond, Steps: 3})},
+		want:   http.StatusOK,
+		count:  2,
+	}, {
+		name:  "too many requests without retry after",
+		opts:  []Option{WithRetryBackoff(retry.Backoff{Duration: time.Millisecond, Steps: 3})},
+		want:  http.StatusTooManyRequests,
+		count: 1,
+	}, {
+		name:       "capped",
+		retryAfter: "3600",
+		opts:       []Option{WithMaxRetryAfter(time.Millisecond)},
+		want:       http.StatusOK,
+		count:      2,
+	}, {
+		name:       "past deadline",
+		retryAfter: "3600",
+		timeout:    time.Second,
+		want:       http.StatusTooManyRequests,
+		count:      1,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			count := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				count++
+				if b, err := ioutil.ReadAll(r.Body); err != nil || string(b) != "hello" {
+					t.Errorf("body = %q, %v", b, err)
+				}
+				if count > 1 {
+					return
+				}
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				status := http.StatusTooManyRequests
+				if test.status != 0 {
+					status = test.status
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			ctx := context.Background()
+			if test.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL, strings.NewReader("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := NewRetry(http.DefaultTransport, test.opts...).RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.want {
+				t.Errorf("StatusCode = %d, want %d", resp.StatusCode, test.want)
+			}
+			if count != test.count {
+				t.Errorf("wrong count, wanted %d, got %d", test.count, count)
+			}
+		})
+	}
+}
+
+func TestRetryLastResponse(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		backoff retry.Backoff
+		count   int
+	}{{
+		name:    "capped",
+		backoff: retry.Backoff{Duration: time.Millisecond, Factor: 10, Cap: 2 * time.Millisecond, Steps: 5},
+		count:   2,
+	}, {
+		name:    "no steps",
+		backoff: retry.Backoff{Duration: time.Millisecond, Steps: 0},
+		count:   1,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			count := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				count++
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := NewRetry(http.DefaultTransport, WithRetryBackoff(test.backoff)).RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp == nil {
+				t.Fatal("RoundTrip returned a nil response with a nil error")
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("StatusCode = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+			}
+			if b, err := ioutil.ReadAll(resp.Body); err != nil || !strings.Contains(string(b), "try again") {
+				t.Errorf("body = %q, %v; want the last response's body", b, err)
+			}
+			if count != test.count {
+				t.Errorf("wrong count, wanted %d, got %d", test.count, count)
+			}
+		})
+	}
+}
@@ -64,38 +64,13 @@ func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authentic
 		return nil, err
 	}
 
-	// Wrap t with a useragent transport unless we already have one.
-	if _, ok := t.(*userAgentTransport); !ok {
-		t = NewUserAgent(t, "")
-	}
-
-	// Wrap t in a transport that selects the appropriate scheme based on the ping response.
-	t = &schemeTransport{
-		scheme:   pr.scheme,
-		registry: reg,
-		inner:    t,
-	}
-
 	switch pr.challenge.Canonical() {
 	case anonymous, basic:
+		t = wrapTransport(pr, reg, t)
 		return &Wrapper{&basicTransport{inner: t, auth: auth, target: reg.RegistryStr()}}, nil
 	case bearer:
-		// We require the realm, which tells us where to send our Basic auth to turn it into Bearer auth.
-		realm, ok := pr.parameters["realm"]
-		if !ok {
-			return nil, fmt.Errorf("malformed www-authenticate, missing realm: %v", pr.parameters)
-		}
-		service := pr.parameters["service"]
-		bt := &bearerTransport{
-			inner:    t,
-			basic:    auth,
-			realm:    realm,
-			registry: reg,
-			service:  service,
-			scopes:   scopes,
-			scheme:   pr.scheme,
-		}
-		if err := bt.refresh(ctx); err != nil {
+		bt, err := newBearerTransport(ctx, pr, reg, auth, t, scopes)
+		if err != nil {
 			return nil, err
 		}
 		return &Wrapper{bt}, nil
@@ -104,6 +79,61 @@ func NewWithContext(ctx context.Context, reg name.Registry, auth authn.Authentic
 	}
 }
 
+// Exchange performs the same handshake as NewWithContext, but rather than
+// returning a RoundTripper it returns the bearer token that the registry's
+// token service issued for the specified scopes. It returns an error if the
+// registry doesn't use token authentication.
+func Exchange(ctx context.Context, reg name.Registry, auth authn.Authenticator, t http.RoundTripper, scopes []string) (string, error) {
+	pr, err := ping(ctx, reg, t)
+	if err != nil {
+		return "", err
+	}
+	if pr.challenge.Canonical() != bearer {
+		return "", fmt.Errorf("registry %s does not use token authentication (challenge: %q)", reg, pr.challenge)
+	}
+	bt, err := newBearerTransport(ctx, pr, reg, auth, t, scopes)
+	if err != nil {
+		return "", err
+	}
+	return bt.bearer.RegistryToken, nil
+}
+
+// wrapTransport wraps t with a useragent transport, unless it already is one,
+// and with a transport that selects the scheme determined by the ping response.
+func wrapTransport(pr *pingResp, reg name.Registry, t http.RoundTripper) http.RoundTripper {
+	if _, ok := t.(*userAgentTransport); !ok {
+		t = NewUserAgent(t, "")
+	}
+	return &schemeTransport{
+		scheme:   pr.scheme,
+		registry: reg,
+		inner:    t,
+	}
+}
+
+// newBearerTransport returns a bearerTransport for a registry that responded
+// to ping with a Bearer challenge, seeded with an initial token.
+func newBearerTransport(ctx context.Context, pr *pingResp, reg name.Registry, auth authn.Authenticator, t http.RoundTripper, scopes []string) (*bearerTransport, error) {
+	// We require the realm, which tells us where to send our Basic auth to turn it into Bearer auth.
+	realm, ok := pr.parameters["realm"]
+	if !ok {
+		return nil, fmt.Errorf("malformed www-authenticate, missing realm: %v", pr.parameters)
+	}
+	bt := &bearerTransport{
+		inner:    wrapTransport(pr, reg, t),
+		basic:    auth,
+		realm:    realm,
+		registry: reg,
+		service:  pr.parameters["service"],
+		scopes:   scopes,
+		scheme:   pr.scheme,
+	}
+	if err := bt.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return bt, nil
+}
+
 // Wrapper results in *not* wrapping supplied transport with additional logic such as retries, useragent and debug logging
 // Consumers are opt-ing into providing their own transport without any additional wrapping.
 type Wrapper struct {
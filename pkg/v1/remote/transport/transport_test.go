@@ -184,6 +184,58 @@ func TestTransportSelectionBearer(t *testing.T) {
 	}
 }
 
+func TestExchange(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/":
+				w.Header().Set("WWW-Authenticate", `Bearer realm="http://foo.io/token",service="foo.io"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			case "/token":
+				if got, want := r.FormValue("scope"), testReference.Scope(PullScope); got != want {
+					t.Errorf("FormValue(scope); got %v, want %v", got, want)
+				}
+				w.Write([]byte(`{"access_token": "dfskdjhfkhsjdhfkjhsdf"}`))
+			default:
+				// This is an https request that fails, causing us to fall back to http.
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}))
+	defer server.Close()
+	tprt := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(server.URL)
+		},
+	}
+
+	basic := &authn.Basic{Username: "foo", Password: "bar"}
+	token, err := Exchange(context.Background(), testReference.Context().Registry, basic, tprt, []string{testReference.Scope(PullScope)})
+	if err != nil {
+		t.Fatalf("Exchange() = %v", err)
+	}
+	if want := "dfskdjhfkhsjdhfkjhsdf"; token != want {
+		t.Errorf("Exchange(); got %q, want %q", token, want)
+	}
+}
+
+func TestExchangeNotBearer(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="localhost"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}))
+	defer server.Close()
+	tprt := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(server.URL)
+		},
+	}
+
+	if _, err := Exchange(context.Background(), testReference.Context().Registry, authn.Anonymous, tprt, nil); err == nil {
+		t.Error("Exchange() = nil, wanted error for a Basic challenge")
+	}
+}
+
 func TestTransportSelectionBearerMissingRealm(t *testing.T) {
 	server := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
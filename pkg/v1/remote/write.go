@@ -20,9 +20,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/google/go-containerregistry/internal/redact"
 	"github.com/google/go-containerregistry/internal/retry"
@@ -59,26 +61,40 @@ func Write(ref name.Reference, img v1.Image, options ...Option) (rerr error) {
 		defer close(o.updates)
 		defer func() { _ = p.err(rerr) }()
 	}
-	return writeImage(o.context, ref, img, o, p)
+	if o.events != nil {
+		defer close(o.events)
+	}
+	if err := makeSizeLimits(ref.Context().Registry, o).check(ref.Context().Registry, img, o.allowNondistributableArtifacts); err != nil {
+		return err
+	}
+	return writeImage(o.context, ref, img, o, p, newConverter(o.manifestConversion), nil)
 }
 
-func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *options, progress *progress) error {
+func writeImage(ctx context.Context, ref name.Reference, img v1.Image, o *options, progress *progress, conv *converter, uploads *blobUploads) error {
 	ls, err := img.Layers()
 	if err != nil {
 		return err
 	}
-	scopes := scopesForUploadingImage(ref.Context(), ls)
+	scopes := scopesForUploadingImage(ref.Context(), append(mountableConfig(img), ls...), o.mountFrom...)
 	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
 	if err != nil {
 		return err
 	}
 	w := writer{
-		repo:      ref.Context(),
-		client:    &http.Client{Transport: tr},
-		context:   ctx,
-		progress:  progress,
-		backoff:   o.retryBackoff,
-		predicate: o.retryPredicate,
+		repo:         ref.Context(),
+		client:       &http.Client{Transport: tr},
+		context:      ctx,
+		progress:     progress,
+		events:       o.events,
+		backoff:      o.retryBackoff,
+		predicate:    o.retryPredicate,
+		resumable:    o.resumableUploads,
+		bandwidth:    o.bandwidth,
+		mountFrom:    o.mountFrom,
+		expected:     o.expectedDigest,
+		capabilities: o.capabilities,
+		conv:         conv,
+		uploads:      uploads,
 	}
 
 	// Upload individual blobs and collect any errors.
@@ -174,8 +190,95 @@ type writer struct {
 	context context.Context
 
 	progress  *progress
+	events    chan<- Event
 	backoff   Backoff
 	predicate retry.Predicate
+
+	// resumable is whether to resume failed blob uploads, see
+	// WithResumableUploads.
+	resumable bool
+
+	// bandwidth limits how quickly blobs are uploaded, if set.
+	bandwidth *bandwidthLimiter
+
+	// mountFrom are repositories to try mounting blobs from, see
+	// WithMountFrom.
+	mountFrom []name.Repository
+
+	// expected is the digest tags must point at before they're pushed, if
+	// set, see WithExpectedDigest.
+	expected *v1.Hash
+
+	// capabilities records what the registry supports, if set.
+	capabilities *CapabilityRecorder
+
+	// conv converts manifests the registry rejects, if set.
+	conv *converter
+
+	// uploads dedupes blob uploads across writers, if set.
+	uploads *blobUploads
+}
+
+// blobUploads dedupes the blob uploads of the children of an index, which are
+// written concurrently, and limits how many run at once.
+type blobUploads struct {
+	sem chan struct{}
+
+	mu       sync.Mutex
+	inflight map[v1.Hash]*blobUpload
+}
+
+type blobUpload struct {
+	done chan struct{}
+	err  error
+}
+
+func newBlobUploads(jobs int) *blobUploads {
+	return &blobUploads{
+		sem:      make(chan struct{}, jobs),
+		inflight: map[v1.Hash]*blobUpload{},
+	}
+}
+
+// do calls upload to upload l, unless another writer has uploaded it or is
+// uploading it, in which case it returns false and the error of that upload.
+func (u *blobUploads) do(ctx context.Context, l v1.Layer, upload func() error) (bool, error) {
+	// Streaming layers don't know their digests yet, so they can't be deduped.
+	h, err := l.Digest()
+	if err != nil {
+		return true, u.limit(ctx, upload)
+	}
+
+	u.mu.Lock()
+	bu, ok := u.inflight[h]
+	if !ok {
+		bu = &blobUpload{done: make(chan struct{})}
+		u.inflight[h] = bu
+	}
+	u.mu.Unlock()
+	if ok {
+		select {
+		case <-bu.done:
+			return false, bu.err
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	bu.err = u.limit(ctx, upload)
+	close(bu.done)
+	return true, bu.err
+}
+
+// limit calls upload once there are fewer than jobs uploads running.
+func (u *blobUploads) limit(ctx context.Context, upload func() error) error {
+	select {
+	case u.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-u.sem }()
+	return upload()
 }
 
 // url returns a url.Url for the specified path in the context of this remote image reference.
@@ -306,27 +409,90 @@ func (w *writer) initiateUpload(from, mount, origin string) (location string, mo
 	}
 }
 
-// streamBlob streams the contents of the blob to the specified location.
-// On failure, this will return an error.  On success, this will return the location
-// header indicating how to commit the streamed blob.
-func (w *writer) streamBlob(ctx context.Context, layer v1.Layer, streamLocation string) (commitLocation string, rerr error) {
+// mountSources returns the repositories to try mounting l from, in order: the
+// repository a MountableLayer came from, then any from WithMountFrom.
+func (w *writer) mountSources(l v1.Layer) []name.Repository {
+	var sources []name.Repository
+	if ml, ok := l.(*MountableLayer); ok {
+		sources = append(sources, ml.Reference.Context())
+	}
+	for _, from := range w.mountFrom {
+		if from.String() == w.repo.String() || (len(sources) > 0 && from.String() == sources[0].String()) {
+			continue
+		}
+		sources = append(sources, from)
+	}
+	return sources
+}
+
+// initiateMount tries to mount the blob with digest mount from each of sources
+// in turn. If none of them have it, the upload initiated by the last attempt
+// is returned, as from initiateUpload.
+func (w *writer) initiateMount(mount string, sources []name.Repository) (location string, mounted bool, err error) {
+	if mount == "" || len(sources) == 0 {
+		return w.initiateUpload("", "", "")
+	}
+	for i, from := range sources {
+		location, mounted, err = w.initiateUpload(from.RepositoryStr(), mount, from.RegistryStr())
+		if err != nil || mounted || i == len(sources)-1 {
+			break
+		}
+		// The registry initiated an upload instead, which we don't need if
+		// the next source has it.
+		go w.cancelUpload(location)
+	}
+	return location, mounted, err
+}
+
+// streamBlob streams the contents of the blob, starting at offset, to the
+// specified location. On failure, this will return an error.  On success, this
+// will return the location header indicating how to commit the streamed blob.
+func (w *writer) streamBlob(ctx context.Context, layer v1.Layer, streamLocation string, offset int64) (commitLocation string, rerr error) {
 	reset := func() {}
 	defer func() {
 		if rerr != nil {
 			reset()
 		}
 	}()
-	blob, err := layer.Compressed()
+
+	compressed := layer.Compressed
+	if offset > 0 {
+		// Skip what the registry already has.
+		compressed = func() (io.ReadCloser, error) {
+			rc, err := layer.Compressed()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.CopyN(ioutil.Discard, rc, offset); err != nil {
+				rc.Close()
+				return nil, err
+			}
+			return rc, nil
+		}
+	}
+	if w.bandwidth != nil {
+		open := compressed
+		compressed = func() (io.ReadCloser, error) {
+			rc, err := open()
+			if err != nil {
+				return nil, err
+			}
+			return w.bandwidth.reader(ctx, rc), nil
+		}
+	}
+	blob, err := compressed()
 	if err != nil {
 		return "", err
 	}
 
-	getBody := layer.Compressed
+	getBody := compressed
 	if w.progress != nil {
-		var count int64
+		// Count what the registry already has, so that reset undoes it too.
+		count := offset
+		w.progress.complete(offset)
 		blob = &progressReader{rc: blob, progress: w.progress, count: &count}
 		getBody = func() (io.ReadCloser, error) {
-			blob, err := layer.Compressed()
+			blob, err := compressed()
 			if err != nil {
 				return nil, err
 			}
@@ -346,6 +512,14 @@ func (w *writer) streamBlob(ctx context.Context, layer v1.Layer, streamLocation
 		req.GetBody = getBody
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
+	if offset > 0 {
+		size, err := layer.Size()
+		if err != nil {
+			return "", err
+		}
+		req.ContentLength = size - offset
+		req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, size-1))
+	}
 
 	resp, err := w.client.Do(req.WithContext(ctx))
 	if err != nil {
@@ -362,6 +536,43 @@ func (w *writer) streamBlob(ctx context.Context, layer v1.Layer, streamLocation
 	return w.nextLocation(resp)
 }
 
+// uploadStatus returns the location to continue the upload at location from,
+// and how many bytes of the blob the registry has received so far.
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pushing-a-blob-in-chunks
+func (w *writer) uploadStatus(location string) (string, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := w.client.Do(req.WithContext(w.context))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusNoContent); err != nil {
+		return "", 0, err
+	}
+	loc, err := w.nextLocation(resp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	rng := resp.Header.Get("Range")
+	var start, end int64
+	if _, err := fmt.Sscanf(rng, "%d-%d", &start, &end); err != nil || start != 0 || end < 0 {
+		return "", 0, fmt.Errorf("unexpected upload Range %q", rng)
+	}
+	// The Range is inclusive, so "0-0" means the first byte was received,
+	// but registries also send it for uploads that haven't received
+	// anything. Neither resuming at 0 nor at 1 is right for both, so start
+	// over.
+	if end == 0 {
+		return "", 0, errors.New("upload Range 0-0 doesn't say whether any bytes were received")
+	}
+	return loc, end + 1, nil
+}
+
 // commitBlob commits this blob by sending a PUT to the location returned from
 // streaming the blob.
 func (w *writer) commitBlob(location, digest string) error {
@@ -398,8 +609,38 @@ func (w *writer) incrProgress(written int64) {
 
 // uploadOne performs a complete upload of a single layer.
 func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
+	if w.uploads == nil {
+		return w.upload(ctx, l)
+	}
+	uploaded, err := w.uploads.do(ctx, l, func() error {
+		return w.upload(ctx, l)
+	})
+	if err != nil || uploaded {
+		return err
+	}
+
+	// Another child of the index uploaded it.
+	h, err := l.Digest()
+	if err != nil {
+		return err
+	}
+	size, err := l.Size()
+	if err != nil {
+		return err
+	}
+	w.incrProgress(size)
+	logs.Progress.Printf("existing blob: %v", h)
+	w.blobEvent(BlobExisting, l, h, size)
+	return nil
+}
+
+// upload uploads l, unless it exists already.
+func (w *writer) upload(ctx context.Context, l v1.Layer) error {
+	// resumeLocation is the upload to resume on the next try, if any.
+	var resumeLocation string
+	_, streaming := l.(*stream.Layer)
 	tryUpload := func() error {
-		var from, mount, origin string
+		var mount string
 		if h, err := l.Digest(); err == nil {
 			// If we know the digest, this isn't a streaming layer. Do an existence
 			// check so we can skip uploading the layer if possible.
@@ -414,31 +655,45 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 				}
 				w.incrProgress(size)
 				logs.Progress.Printf("existing blob: %v", h)
+				w.blobEvent(BlobExisting, l, h, size)
 				return nil
 			}
 
 			mount = h.String()
 		}
-		if ml, ok := l.(*MountableLayer); ok {
-			from = ml.Reference.Context().RepositoryStr()
-			origin = ml.Reference.Context().RegistryStr()
-		}
 
-		location, mounted, err := w.initiateUpload(from, mount, origin)
-		if err != nil {
-			return err
-		} else if mounted {
-			size, err := l.Size()
+		var location string
+		var offset int64
+		if resumeLocation != "" {
+			loc, n, err := w.uploadStatus(resumeLocation)
 			if err != nil {
-				return err
+				logs.Warn.Printf("restarting upload: %v", err)
+			} else {
+				location, offset = loc, n
+				logs.Progress.Printf("resuming upload at byte %d", offset)
 			}
-			w.incrProgress(size)
-			h, err := l.Digest()
+			resumeLocation = ""
+		}
+
+		if location == "" {
+			loc, mounted, err := w.initiateMount(mount, w.mountSources(l))
 			if err != nil {
 				return err
+			} else if mounted {
+				size, err := l.Size()
+				if err != nil {
+					return err
+				}
+				w.incrProgress(size)
+				h, err := l.Digest()
+				if err != nil {
+					return err
+				}
+				logs.Progress.Printf("mounted blob: %s", h.String())
+				w.blobEvent(BlobMounted, l, h, size)
+				return nil
 			}
-			logs.Progress.Printf("mounted blob: %s", h.String())
-			return nil
+			location = loc
 		}
 
 		// Only log layers with +json or +yaml. We can let through other stuff if it becomes popular.
@@ -452,9 +707,25 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 			ctx = redact.NewContext(ctx, "omitting binary blobs from logs")
 		}
 
-		location, err = w.streamBlob(ctx, l, location)
-		if err != nil {
-			return err
+		// If committing the blob failed, the registry may already have all
+		// of it.
+		done := false
+		if offset > 0 {
+			size, err := l.Size()
+			if err != nil {
+				return err
+			}
+			done = offset == size
+		}
+		if !done {
+			streamLocation := location
+			location, err = w.streamBlob(ctx, l, location, offset)
+			if err != nil {
+				if w.resumable && !streaming {
+					resumeLocation = streamLocation
+				}
+				return err
+			}
 		}
 
 		h, err := l.Digest()
@@ -464,15 +735,41 @@ func (w *writer) uploadOne(ctx context.Context, l v1.Layer) error {
 		digest := h.String()
 
 		if err := w.commitBlob(location, digest); err != nil {
+			if w.resumable && !streaming {
+				resumeLocation = location
+			}
 			return err
 		}
 		logs.Progress.Printf("pushed blob: %s", digest)
+		if w.events != nil {
+			size, err := l.Size()
+			if err != nil {
+				return err
+			}
+			w.blobEvent(BlobPushed, l, h, size)
+		}
 		return nil
 	}
 
 	return retry.Retry(tryUpload, w.predicate, w.backoff)
 }
 
+// blobEvent sends an event of kind for the blob l, if WithEvents is used.
+func (w *writer) blobEvent(kind EventKind, l v1.Layer, h v1.Hash, size int64) {
+	if w.events == nil {
+		return
+	}
+	// The media type is only informational, so don't fail the write for it.
+	mt, _ := l.MediaType()
+	w.event(Event{
+		Kind:      kind,
+		Ref:       w.repo.Digest(h.String()),
+		Digest:    h,
+		MediaType: mt,
+		Size:      size,
+	})
+}
+
 type withLayer interface {
 	Layer(v1.Hash) (v1.Layer, error)
 }
@@ -488,47 +785,88 @@ func (w *writer) writeIndex(ctx context.Context, ref name.Reference, ii v1.Image
 		return err
 	}
 
-	// TODO(#803): Pipe through remote.WithJobs and upload these in parallel.
+	// Write the children concurrently. Their blob uploads are deduped and
+	// limited to o.jobs at once by w.uploads, if set.
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(o.jobs)
+	var (
+		mu       sync.Mutex
+		complete int
+	)
 	for _, desc := range index.Manifests {
-		ref := ref.Context().Digest(desc.Digest.String())
-		exists, err := w.checkExistingManifest(desc.Digest, desc.MediaType)
-		if err != nil {
-			return err
-		}
-		if exists {
-			logs.Progress.Print("existing manifest: ", desc.Digest)
-			continue
-		}
-
-		switch desc.MediaType {
-		case types.OCIImageIndex, types.DockerManifestList:
-			ii, err := ii.ImageIndex(desc.Digest)
-			if err != nil {
-				return err
+		desc := desc
+		g.Go(func() error {
+			ctx := gctx
+			ref := ref.Context().Digest(desc.Digest.String())
+			if w.conv != nil {
+				w.conv.addChild(desc.Digest)
 			}
-			if err := w.writeIndex(ctx, ref, ii, options...); err != nil {
-				return err
+			childComplete := func() {
+				mu.Lock()
+				defer mu.Unlock()
+				complete++
+				w.event(Event{
+					Kind:      ChildComplete,
+					Ref:       ref,
+					Digest:    desc.Digest,
+					MediaType: desc.MediaType,
+					Size:      desc.Size,
+					Child:     complete,
+					Children:  len(index.Manifests),
+				})
 			}
-		case types.OCIManifestSchema1, types.DockerManifestSchema2:
-			img, err := ii.Image(desc.Digest)
+			exists, err := w.checkExistingManifest(desc.Digest, desc.MediaType)
 			if err != nil {
 				return err
 			}
-			if err := writeImage(ctx, ref, img, o, w.progress); err != nil {
-				return err
+			if exists {
+				logs.Progress.Print("existing manifest: ", desc.Digest)
+				w.event(Event{
+					Kind:      ManifestExisting,
+					Ref:       ref,
+					Digest:    desc.Digest,
+					MediaType: desc.MediaType,
+					Size:      desc.Size,
+				})
+				childComplete()
+				return nil
 			}
-		default:
-			// Workaround for #819.
-			if wl, ok := ii.(withLayer); ok {
-				layer, err := wl.Layer(desc.Digest)
+
+			switch desc.MediaType {
+			case types.OCIImageIndex, types.DockerManifestList:
+				ii, err := ii.ImageIndex(desc.Digest)
+				if err != nil {
+					return err
+				}
+				if err := w.writeIndex(ctx, ref, ii, options...); err != nil {
+					return err
+				}
+			case types.OCIManifestSchema1, types.DockerManifestSchema2:
+				img, err := ii.Image(desc.Digest)
 				if err != nil {
 					return err
 				}
-				if err := w.uploadOne(ctx, layer); err != nil {
+				if err := writeImage(ctx, ref, img, o, w.progress, w.conv, w.uploads); err != nil {
 					return err
 				}
+			default:
+				// Workaround for #819.
+				if wl, ok := ii.(withLayer); ok {
+					layer, err := wl.Layer(desc.Digest)
+					if err != nil {
+						return err
+					}
+					if err := w.uploadOne(ctx, layer); err != nil {
+						return err
+					}
+				}
 			}
-		}
+			childComplete()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
 	}
 
 	// With all of the constituent elements uploaded, upload the manifest
@@ -578,15 +916,118 @@ func unpackTaggable(t Taggable) ([]byte, *v1.Descriptor, error) {
 	}, nil
 }
 
+// DigestMismatchError is returned when pushing to a name.DigestTag whose digest
+// does not match the digest of the manifest being pushed.
+type DigestMismatchError struct {
+	Ref    name.DigestTag
+	Digest v1.Hash
+}
+
+// Error implements error.
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("manifest digest: %q does not match pinned digest: %q for %q", e.Digest, e.Ref.DigestStr(), e.Ref)
+}
+
 // commitManifest does a PUT of the image's manifest.
+//
+// If ref is a name.DigestTag, the manifest is PUT to the tag after verifying
+// that it matches the digest.
+//
+// If the writer has a converter, indexes referring to converted manifests are
+// converted before they are PUT, and manifests the registry rejects are
+// converted and PUT again, unless the caller pinned their digest.
 func (w *writer) commitManifest(ctx context.Context, t Taggable, ref name.Reference) error {
+	target := ref.Identifier()
+	dt, tagged := name.AsDigestTag(ref)
+	if tagged {
+		_, desc, err := unpackTaggable(t)
+		if err != nil {
+			return err
+		}
+		if desc.Digest.String() != dt.DigestStr() {
+			return &DigestMismatchError{Ref: dt, Digest: desc.Digest}
+		}
+		target = dt.TagStr()
+	}
+	if _, ok := ref.(name.Digest); (!ok || tagged) && w.expected != nil {
+		if err := w.checkExpectedDigest(ctx, ref.Context().Tag(target)); err != nil {
+			return err
+		}
+	}
+	if w.conv == nil {
+		return w.putManifest(ctx, t, ref, target)
+	}
+
+	raw, desc, err := unpackTaggable(t)
+	if err != nil {
+		return err
+	}
+	var putErr error
+	if !w.conv.hasConvertedChildren(raw, desc.MediaType) {
+		putErr = w.putManifest(ctx, t, ref, target)
+		if putErr == nil || !rejected(putErr) {
+			return putErr
+		}
+	}
+	// Converting changes the digest, which is only allowed for the children
+	// of an index, whose digests the writer chose.
+	pinned := tagged
+	if _, ok := ref.(name.Digest); ok && !tagged {
+		pinned = !w.conv.isChild(desc.Digest)
+	}
+	if pinned {
+		if putErr != nil {
+			return putErr
+		}
+		return fmt.Errorf("%v: cannot convert manifest pinned by digest", ref)
+	}
+	if putErr != nil {
+		logs.Warn.Printf("%v: registry rejected %s, converting", ref, desc.MediaType)
+	}
+
+	converted, ok, cerr := w.conv.convert(raw, desc.MediaType)
+	if cerr != nil {
+		return fmt.Errorf("converting %s manifest: %w", desc.MediaType, cerr)
+	}
+	if !ok {
+		if putErr != nil {
+			// There's nothing we can convert it to.
+			return putErr
+		}
+		return w.putManifest(ctx, t, ref, target)
+	}
+	_, cdesc, err := unpackTaggable(converted)
+	if err != nil {
+		return err
+	}
+	if _, ok := ref.(name.Digest); ok {
+		ref = ref.Context().Digest(cdesc.Digest.String())
+		target = ref.Identifier()
+	}
+	if err := w.putManifest(ctx, converted, ref, target); err != nil {
+		return err
+	}
+	w.conv.record(desc.Digest, *cdesc)
+	return nil
+}
+
+// putManifest does a PUT of t's manifest to target.
+//
+// If the manifest has a subject and the registry doesn't say that it
+// processed it, the manifest is also added to the subject's referrers tag.
+func (w *writer) putManifest(ctx context.Context, t Taggable, ref name.Reference, target string) error {
+	var (
+		referrer        *v1.Descriptor
+		referrerSubject v1.Hash
+	)
 	tryUpload := func() error {
+		referrer = nil
 		raw, desc, err := unpackTaggable(t)
 		if err != nil {
 			return err
 		}
 
-		u := w.url(fmt.Sprintf("/v2/%s/manifests/%s", w.repo.RepositoryStr(), ref.Identifier()))
+		u := w.url(fmt.Sprintf("/v2/%s/manifests/%s", w.repo.RepositoryStr(), target))
 
 		// Make the request to PUT the serialized manifest
 		req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewBuffer(raw))
@@ -604,20 +1045,72 @@ func (w *writer) commitManifest(ctx context.Context, t Taggable, ref name.Refere
 		if err := transport.CheckError(resp, http.StatusOK, http.StatusCreated, http.StatusAccepted); err != nil {
 			return err
 		}
+		if subject, ok := manifestSubject(raw); ok {
+			// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pushing-manifests-with-subject
+			capability := CapabilityUnsupported
+			if resp.Header.Get("OCI-Subject") != "" {
+				capability = CapabilitySupported
+			} else {
+				d := referrerDescriptor(raw, *desc)
+				referrer, referrerSubject = &d, subject
+			}
+			w.capabilities.record(w.repo.Registry, func(c *Capabilities) { c.Subject = capability })
+		}
 
 		// The image was successfully pushed!
 		logs.Progress.Printf("%v: digest: %v size: %d", ref, desc.Digest, desc.Size)
 		w.incrProgress(int64(len(raw)))
+		kind := TagUpdated
+		if _, ok := ref.(name.Digest); ok {
+			if _, tagged := name.AsDigestTag(ref); !tagged {
+				kind = ManifestPushed
+			}
+		}
+		w.event(Event{
+			Kind:      kind,
+			Ref:       ref,
+			Digest:    desc.Digest,
+			MediaType: desc.MediaType,
+			Size:      desc.Size,
+		})
 		return nil
 	}
 
-	return retry.Retry(tryUpload, w.predicate, w.backoff)
+	if err := retry.Retry(tryUpload, w.predicate, w.backoff); err != nil {
+		return err
+	}
+	if referrer != nil {
+		if err := w.addReferrer(ctx, referrerSubject, *referrer); err != nil {
+			return fmt.Errorf("updating referrers tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// mountableConfig returns img's config layer if it's a MountableLayer, so
+// that the repository it can be mounted from is included in the scopes.
+func mountableConfig(img v1.Image) []v1.Layer {
+	cl, err := partial.ConfigLayer(img)
+	if err != nil {
+		return nil
+	}
+	if _, ok := cl.(*MountableLayer); !ok {
+		return nil
+	}
+	return []v1.Layer{cl}
 }
 
-func scopesForUploadingImage(repo name.Repository, layers []v1.Layer) []string {
+func scopesForUploadingImage(repo name.Repository, layers []v1.Layer, mountFrom ...name.Repository) []string {
 	// use a map as set to remove duplicates scope strings
 	scopeSet := map[string]struct{}{}
 
+	// Ask to pull from the hinted repositories, so we can mount from them.
+	for _, from := range mountFrom {
+		if from.String() != repo.String() && from.Registry.String() == repo.Registry.String() {
+			scopeSet[from.Scope(transport.PullScope)] = struct{}{}
+		}
+	}
+
 	for _, l := range layers {
 		if ml, ok := l.(*MountableLayer); ok {
 			// we will add push scope for ref.Context() after the loop.
@@ -654,13 +1147,24 @@ func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) (rerr e
 		return err
 	}
 	w := writer{
-		repo:      ref.Context(),
-		client:    &http.Client{Transport: tr},
-		context:   o.context,
-		backoff:   o.retryBackoff,
-		predicate: o.retryPredicate,
+		repo:         ref.Context(),
+		client:       &http.Client{Transport: tr},
+		context:      o.context,
+		events:       o.events,
+		backoff:      o.retryBackoff,
+		predicate:    o.retryPredicate,
+		resumable:    o.resumableUploads,
+		bandwidth:    o.bandwidth,
+		mountFrom:    o.mountFrom,
+		expected:     o.expectedDigest,
+		capabilities: o.capabilities,
+		conv:         newConverter(o.manifestConversion),
+		uploads:      newBlobUploads(o.jobs),
 	}
 
+	if o.events != nil {
+		defer close(o.events)
+	}
 	if o.updates != nil {
 		w.progress = &progress{updates: o.updates}
 		w.progress.lastUpdate = &v1.Update{}
@@ -674,6 +1178,9 @@ func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) (rerr e
 		}
 	}
 
+	if err := makeSizeLimits(ref.Context().Registry, o).check(ref.Context().Registry, ii, o.allowNondistributableArtifacts); err != nil {
+		return err
+	}
 	return w.writeIndex(o.context, ref, ii, options...)
 }
 
@@ -791,19 +1298,27 @@ func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) (rerr e
 	if err != nil {
 		return err
 	}
-	scopes := scopesForUploadingImage(repo, []v1.Layer{layer})
+	scopes := scopesForUploadingImage(repo, []v1.Layer{layer}, o.mountFrom...)
 	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
 	if err != nil {
 		return err
 	}
 	w := writer{
-		repo:      repo,
-		client:    &http.Client{Transport: tr},
-		context:   o.context,
-		backoff:   o.retryBackoff,
-		predicate: o.retryPredicate,
+		repo:         repo,
+		client:       &http.Client{Transport: tr},
+		context:      o.context,
+		events:       o.events,
+		backoff:      o.retryBackoff,
+		predicate:    o.retryPredicate,
+		resumable:    o.resumableUploads,
+		bandwidth:    o.bandwidth,
+		mountFrom:    o.mountFrom,
+		capabilities: o.capabilities,
 	}
 
+	if o.events != nil {
+		defer close(o.events)
+	}
 	if o.updates != nil {
 		w.progress = &progress{updates: o.updates}
 		w.progress.lastUpdate = &v1.Update{}
@@ -821,6 +1336,9 @@ func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) (rerr e
 		}
 		w.progress.total(size)
 	}
+	if err := makeSizeLimits(repo.Registry, o).checkLayer(repo.Registry, layer, true); err != nil {
+		return err
+	}
 	return w.uploadOne(o.context, layer)
 }
 
@@ -868,12 +1386,27 @@ func Put(ref name.Reference, t Taggable, options ...Option) error {
 		return err
 	}
 	w := writer{
-		repo:      ref.Context(),
-		client:    &http.Client{Transport: tr},
-		context:   o.context,
-		backoff:   o.retryBackoff,
-		predicate: o.retryPredicate,
+		repo:         ref.Context(),
+		client:       &http.Client{Transport: tr},
+		context:      o.context,
+		events:       o.events,
+		backoff:      o.retryBackoff,
+		predicate:    o.retryPredicate,
+		resumable:    o.resumableUploads,
+		bandwidth:    o.bandwidth,
+		mountFrom:    o.mountFrom,
+		expected:     o.expectedDigest,
+		capabilities: o.capabilities,
+		conv:         newConverter(o.manifestConversion),
 	}
 
+	if o.events != nil {
+		defer close(o.events)
+	}
+	// Only the manifest is pushed, so that's all there is to check.
+	limits := sizeLimits{manifest: makeSizeLimits(ref.Context().Registry, o).manifest}
+	if err := limits.check(ref.Context().Registry, t, o.allowNondistributableArtifacts); err != nil {
+		return err
+	}
 	return w.commitManifest(o.context, t, ref)
 }
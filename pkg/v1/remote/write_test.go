@@ -28,13 +28,14 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
@@ -508,6 +509,85 @@ func TestInitiateUploadMountsWithOriginFallback(t *testing.T) {
 	}
 }
 
+func TestWriteLayerMountFrom(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRepo := "write/mount"
+	uploadPath := fmt.Sprintf("/v2/%s/blobs/uploads/", expectedRepo)
+
+	var (
+		mu        sync.Mutex
+		froms     []string
+		cancelled []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead:
+			http.Error(w, "NotFound", http.StatusNotFound)
+		case r.Method == http.MethodPost && r.URL.Path == uploadPath:
+			if got := r.URL.Query().Get("mount"); got != h.String() {
+				t.Errorf("mount; got %v, want %v", got, h)
+			}
+			from := r.URL.Query().Get("from")
+			froms = append(froms, from)
+			if from == "has/blob" {
+				http.Error(w, "Mounted", http.StatusCreated)
+				return
+			}
+			w.Header().Set("Location", "/upload/"+from)
+			http.Error(w, "Initiated", http.StatusAccepted)
+		case r.Method == http.MethodDelete:
+			cancelled = append(cancelled, r.URL.Path)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Unexpected", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s", u.Host, expectedRepo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint := func(r string) name.Repository {
+		t.Helper()
+		repo, err := name.NewRepository(fmt.Sprintf("%s/%s", u.Host, r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}
+
+	// The target repo itself is skipped.
+	if err := WriteLayer(repo, layer, WithMountFrom(hint("no/blob"), repo, hint("has/blob"), hint("not/needed"))); err != nil {
+		t.Fatalf("WriteLayer() = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"no/blob", "has/blob"}; !cmp.Equal(froms, want) {
+		t.Errorf("mounted from %v, want %v", froms, want)
+	}
+	// Cancelling happens in the background, so it may not have happened yet.
+	for _, c := range cancelled {
+		if c != "/upload/no/blob" {
+			t.Errorf("cancelled %s, want /upload/no/blob", c)
+		}
+	}
+}
+
 func TestDedupeLayers(t *testing.T) {
 	newBlob := func() io.ReadCloser { return ioutil.NopCloser(bytes.NewReader(bytes.Repeat([]byte{'a'}, 10000))) }
 
@@ -637,7 +717,7 @@ func TestStreamBlob(t *testing.T) {
 		t.Fatalf("ConfigLayer: %v", err)
 	}
 
-	commitLocation, err := w.streamBlob(context.Background(), l, streamLocation.String())
+	commitLocation, err := w.streamBlob(context.Background(), l, streamLocation.String(), 0)
 	if err != nil {
 		t.Errorf("streamBlob() = %v", err)
 	}
@@ -685,7 +765,7 @@ func TestStreamLayer(t *testing.T) {
 	streamLocation := w.url(expectedPath)
 	sl := stream.NewLayer(newBlob())
 
-	commitLocation, err := w.streamBlob(context.Background(), sl, streamLocation.String())
+	commitLocation, err := w.streamBlob(context.Background(), sl, streamLocation.String(), 0)
 	if err != nil {
 		t.Errorf("streamBlob: %v", err)
 	}
@@ -1382,7 +1462,7 @@ func TestSkipForeignLayersByDefault(t *testing.T) {
 	}
 
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -1474,7 +1554,7 @@ func TestWriteForeignLayerIfOptionSet(t *testing.T) {
 func TestTag(t *testing.T) {
 	idx := setupIndex(t, 3)
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -1510,10 +1590,93 @@ func TestTag(t *testing.T) {
 	}
 }
 
+func TestWriteDigestTag(t *testing.T) {
+	// Set up a fake registry.
+	s := httptest.NewServer(NewRegistry())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigestTag(fmt.Sprintf("%s/test/digesttag:v1@%s", u.Host, d))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Write(ref, img); err != nil {
+		t.Fatalf("Write(%s): %v", ref, err)
+	}
+
+	// The tag should have been written.
+	desc, err := Head(ref.Tag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != d {
+		t.Errorf("Head(%s) = %s, want %s", ref.Tag(), desc.Digest, d)
+	}
+
+	// Pulling by DigestTag fetches by digest.
+	got, err := Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gd, err := got.Digest(); err != nil {
+		t.Fatal(err)
+	} else if gd != d {
+		t.Errorf("Image(%s).Digest() = %s, want %s", ref, gd, d)
+	}
+
+	// Pushing a different image to the same DigestTag should fail.
+	other, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Write(ref, other)
+	var mismatch *DigestMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Write(%s) = %v, want DigestMismatchError", ref, err)
+	}
+
+	// And the tag should not have moved.
+	if desc, err := Head(ref.Tag()); err != nil {
+		t.Fatal(err)
+	} else if desc.Digest != d {
+		t.Errorf("Head(%s) = %s, want %s", ref.Tag(), desc.Digest, d)
+	}
+
+	// ParseReference returns a Digest for the same name, which keeps the tag,
+	// so it is written the same way.
+	parsed, err := name.ParseReference(fmt.Sprintf("%s/test/digesttag:v2@%s", u.Host, d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(parsed, img); err != nil {
+		t.Fatalf("Write(%s): %v", parsed, err)
+	}
+	if desc, err := Head(parsed.Context().Tag("v2")); err != nil {
+		t.Fatal(err)
+	} else if desc.Digest != d {
+		t.Errorf("Head(%s) = %s, want %s", parsed.Context().Tag("v2"), desc.Digest, d)
+	}
+	if err := Write(parsed, other); !errors.As(err, &mismatch) {
+		t.Fatalf("Write(%s) = %v, want DigestMismatchError", parsed, err)
+	}
+}
+
 func TestTagDescriptor(t *testing.T) {
 	idx := setupIndex(t, 3)
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -1547,7 +1710,7 @@ func TestTagDescriptor(t *testing.T) {
 
 func TestNestedIndex(t *testing.T) {
 	// Set up a fake registry.
-	s := httptest.NewServer(registry.New())
+	s := httptest.NewServer(NewRegistry())
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -1615,12 +1778,182 @@ func TestNestedIndex(t *testing.T) {
 	}
 }
 
+func TestWriteLayerResumable(t *testing.T) {
+	l, err := random.Layer(100000, types.OCIUncompressedLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := l.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		resumable bool
+		// How much of the first upload the registry receives.
+		received int64
+		want     []string
+	}{{
+		name:      "resumable",
+		resumable: true,
+		received:  size / 2,
+		want:      []string{"", fmt.Sprintf("%d-%d", size/2, size-1)},
+	}, {
+		name:      "not resumable",
+		resumable: false,
+		received:  size / 2,
+		want:      []string{"", ""},
+	}, {
+		// The registry's Range of 0-0 is ambiguous, so start over.
+		name:      "one byte",
+		resumable: true,
+		received:  1,
+		want:      []string{"", ""},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := NewRegistry()
+			// The Content-Range of each PATCH.
+			var patches []string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPatch {
+					reg.ServeHTTP(w, r)
+					return
+				}
+				patches = append(patches, r.Header.Get("Content-Range"))
+				if len(patches) > 1 {
+					reg.ServeHTTP(w, r)
+					return
+				}
+				// Let the registry have some of the first upload, then
+				// drop the connection.
+				half := r.Clone(r.Context())
+				half.Body = ioutil.NopCloser(io.LimitReader(r.Body, tc.received))
+				half.ContentLength = -1
+				reg.ServeHTTP(httptest.NewRecorder(), half)
+				panic(http.ErrAbortHandler)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, err := name.NewRepository(fmt.Sprintf("%s/test/resumable", u.Host))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := WriteLayer(repo, l, WithResumableUploads(tc.resumable), WithRetryBackoff(Backoff{Duration: time.Millisecond, Steps: 3})); err != nil {
+				t.Fatalf("WriteLayer: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, patches); diff != "" {
+				t.Errorf("PATCH Content-Ranges (-want +got) = %s", diff)
+			}
+
+			got, err := Layer(repo.Digest(h.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validate.Layer(got); err != nil {
+				t.Errorf("validate.Layer: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteIndexJobs(t *testing.T) {
+	// Each image shares a layer.
+	shared, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var adds []mutate.IndexAddendum
+	for i := 0; i < 4; i++ {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.AppendLayers(img, shared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img})
+	}
+	idx := mutate.AppendManifests(empty.Index, adds...)
+
+	const jobs = 2
+	reg := NewRegistry()
+	var (
+		uploading, maxUploading int32
+		commits                 = map[string]int{}
+		mu                      sync.Mutex
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			n := atomic.AddInt32(&uploading, 1)
+			defer atomic.AddInt32(&uploading, -1)
+			mu.Lock()
+			if n > maxUploading {
+				maxUploading = n
+			}
+			mu.Unlock()
+			// Give other uploads a chance to overlap.
+			time.Sleep(10 * time.Millisecond)
+		}
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			mu.Lock()
+			commits[r.URL.Query().Get("digest")]++
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/jobs", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteIndex(ref, idx, WithJobs(jobs)); err != nil {
+		t.Fatal(err)
+	}
+	if maxUploading > jobs {
+		t.Errorf("got %d concurrent uploads, want at most %d", maxUploading, jobs)
+	}
+	h, err := shared.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commits[h.String()]; got != 1 {
+		t.Errorf("shared layer uploaded %d times, want 1", got)
+	}
+	// Each image's config and unique layer, and the shared layer.
+	if got, want := len(commits), 4*2+1; got != want {
+		t.Errorf("got %d blobs uploaded, want %d", got, want)
+	}
+
+	got, err := Index(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index: %v", err)
+	}
+}
+
 func BenchmarkWrite(b *testing.B) {
 	// unfortunately the registry _and_ the img have caching behaviour, so we need a new registry
 	// and image every iteration of benchmarking.
 	for i := 0; i < b.N; i++ {
 		// set up the registry
-		s := httptest.NewServer(registry.New())
+		s := httptest.NewServer(NewRegistry())
 		defer s.Close()
 
 		// load the image
@@ -25,8 +25,10 @@ import (
 	"os"
 	"sync"
 
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
 )
 
 var (
@@ -41,9 +43,10 @@ var (
 
 // Layer is a streaming implementation of v1.Layer.
 type Layer struct {
-	blob        io.ReadCloser
-	consumed    bool
-	compression int
+	blob             io.ReadCloser
+	consumed         bool
+	compression      compression.Compression
+	compressionLevel int
 
 	mu             sync.Mutex
 	digest, diffID *v1.Hash
@@ -59,7 +62,16 @@ type LayerOption func(*Layer)
 // WithCompressionLevel sets the gzip compression. See `gzip.NewWriterLevel` for possible values.
 func WithCompressionLevel(level int) LayerOption {
 	return func(l *Layer) {
-		l.compression = level
+		l.compressionLevel = level
+	}
+}
+
+// WithCompression sets the compression algorithm, which is gzip by default.
+// With compression.ZStd, the layer's media type is types.OCILayerZStd unless
+// overridden with WithMediaType.
+func WithCompression(comp compression.Compression) LayerOption {
+	return func(l *Layer) {
+		l.compression = comp
 	}
 }
 
@@ -73,8 +85,9 @@ func WithMediaType(mt types.MediaType) LayerOption {
 // NewLayer creates a Layer from an io.ReadCloser.
 func NewLayer(rc io.ReadCloser, opts ...LayerOption) *Layer {
 	layer := &Layer{
-		blob:        rc,
-		compression: gzip.BestSpeed,
+		blob:             rc,
+		compression:      compression.GZip,
+		compressionLevel: gzip.BestSpeed,
 		// We use DockerLayer for now as uncompressed layers
 		// are unimplemented
 		mediaType: types.DockerLayer,
@@ -83,6 +96,9 @@ func NewLayer(rc io.ReadCloser, opts ...LayerOption) *Layer {
 	for _, opt := range opts {
 		opt(layer)
 	}
+	if layer.compression == compression.ZStd && layer.mediaType == types.DockerLayer {
+		layer.mediaType = types.OCILayerZStd
+	}
 
 	return layer
 }
@@ -180,7 +196,13 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 	// Buffer the output of the gzip writer so we don't have to wait on pr to keep writing.
 	// 64K ought to be small enough for anybody.
 	bw := bufio.NewWriterSize(mw, 2<<16)
-	zw, err := gzip.NewWriterLevel(bw, l.compression)
+	var zw io.WriteCloser
+	var err error
+	if l.compression == compression.ZStd {
+		zw, err = zstd.NewWriter(bw, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(l.compressionLevel)))
+	} else {
+		zw, err = gzip.NewWriterLevel(bw, l.compressionLevel)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +25,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -297,3 +298,58 @@ func TestMediaTypeOption(t *testing.T) {
 		t.Errorf("MediaType(): want %q, got %q", want, got)
 	}
 }
+
+func TestZStd(t *testing.T) {
+	tl, err := tarball.LayerFromFile("../tarball/testdata/content.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := tl.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLayer(rc, WithCompression(compression.ZStd))
+
+	if got, err := l.MediaType(); err != nil {
+		t.Fatalf("MediaType(): %v", err)
+	} else if want := types.OCILayerZStd; got != want {
+		t.Errorf("MediaType(): want %q, got %q", want, got)
+	}
+
+	crc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(crc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same bytes should produce a layer with the same digest and diffid.
+	zl, err := tarball.LayerFromReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want func() (v1.Hash, error)
+	}{
+		{"Digest", l.Digest, zl.Digest},
+		{"DiffID", l.DiffID, tl.DiffID},
+	} {
+		got, err := c.got()
+		if err != nil {
+			t.Fatalf("%s(): %v", c.name, err)
+		}
+		want, err := c.want()
+		if err != nil {
+			t.Fatalf("%s(): %v", c.name, err)
+		}
+		if got != want {
+			t.Errorf("%s(): got %v, want %v", c.name, got, want)
+		}
+	}
+}
@@ -65,6 +65,90 @@ func pathOpener(path string) Opener {
 	}
 }
 
+// ReaderAtOpener returns an Opener for a tarball of the given size that is read
+// through r, e.g. with ranged reads of an object in remote storage.
+//
+// The tar headers are indexed the first time a file is looked up, skipping
+// over the contents of each file, and files are then read directly from their
+// offsets in r, so an image can be served without reading the whole tarball.
+func ReaderAtOpener(r io.ReaderAt, size int64) Opener {
+	idx := &tarIndex{r: r, size: size}
+	return func() (io.ReadCloser, error) {
+		return &indexedTar{
+			SectionReader: io.NewSectionReader(r, 0, size),
+			index:         idx,
+		}, nil
+	}
+}
+
+// indexedTar is returned by a ReaderAtOpener. It can be read as a normal
+// tarball, but extractFileFromTar uses its index to find files instead.
+type indexedTar struct {
+	*io.SectionReader
+	index *tarIndex
+}
+
+func (t *indexedTar) Close() error { return nil }
+
+// tarIndex records where the contents of each file in a tarball start.
+type tarIndex struct {
+	r    io.ReaderAt
+	size int64
+
+	once    sync.Once
+	err     error
+	headers map[string]*tar.Header
+	offsets map[string]int64
+}
+
+func (ti *tarIndex) load() error {
+	ti.once.Do(func() {
+		ti.headers = map[string]*tar.Header{}
+		ti.offsets = map[string]int64{}
+
+		// SectionReader implements io.Seeker, so tar.Reader seeks past the
+		// contents of each file instead of reading them.
+		sr := io.NewSectionReader(ti.r, 0, ti.size)
+		tf := tar.NewReader(sr)
+		for {
+			hdr, err := tf.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				ti.err = err
+				return
+			}
+			offset, err := sr.Seek(0, io.SeekCurrent)
+			if err != nil {
+				ti.err = err
+				return
+			}
+			// Like extractFileFromTar, the first entry for a path wins.
+			if _, ok := ti.headers[hdr.Name]; !ok {
+				ti.headers[hdr.Name] = hdr
+				ti.offsets[hdr.Name] = offset
+			}
+		}
+	})
+	return ti.err
+}
+
+func (ti *tarIndex) extract(filePath string) (io.ReadCloser, error) {
+	if err := ti.load(); err != nil {
+		return nil, err
+	}
+	hdr, ok := ti.headers[filePath]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found in tar", filePath)
+	}
+	if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+		currentDir := filepath.Dir(filePath)
+		return ti.extract(path.Join(currentDir, path.Clean(hdr.Linkname)))
+	}
+	return ioutil.NopCloser(io.NewSectionReader(ti.r, ti.offsets[filePath], hdr.Size)), nil
+}
+
 // ImageFromPath returns a v1.Image from a tarball located on path.
 func ImageFromPath(path string, tag *name.Tag) (v1.Image, error) {
 	return Image(pathOpener(path), tag)
@@ -217,6 +301,9 @@ func extractFileFromTar(opener Opener, filePath string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	if it, ok := f.(*indexedTar); ok {
+		return it.index.extract(filePath)
+	}
 	close := true
 	defer func() {
 		if close {
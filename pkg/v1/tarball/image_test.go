@@ -15,7 +15,10 @@
 package tarball
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
+	"sync/atomic"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -63,6 +66,74 @@ func TestNoManifest(t *testing.T) {
 	}
 }
 
+// countingReaderAt counts the bytes read through it.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func TestReaderAtOpener(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		tag  string
+	}{{
+		path: "testdata/test_image_1.tar",
+	}, {
+		// Layers are symlinks to other layers.
+		path: "testdata/test_link.tar",
+		tag:  "bazel/v1/tarball:test_image_3",
+	}} {
+		t.Run(tc.path, func(t *testing.T) {
+			var tag *name.Tag
+			if tc.tag != "" {
+				tg, err := name.NewTag(tc.tag, name.WeakValidation)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tag = &tg
+			}
+			b, err := ioutil.ReadFile(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cr := &countingReaderAt{r: bytes.NewReader(b)}
+
+			img, err := Image(ReaderAtOpener(cr, int64(len(b))), tag)
+			if err != nil {
+				t.Fatalf("Image: %v", err)
+			}
+			if n := atomic.LoadInt64(&cr.n); n >= int64(len(b)) {
+				t.Errorf("loading the image read %d bytes, want fewer than the %d in the tarball", n, len(b))
+			}
+
+			want, err := ImageFromPath(tc.path, tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validate.Image(img); err != nil {
+				t.Errorf("Validate() = %v", err)
+			}
+			wantDigest, err := want.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotDigest, err := img.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotDigest != wantDigest {
+				t.Errorf("Digest() = %s, want %s", gotDigest, wantDigest)
+			}
+		})
+	}
+}
+
 func TestBundleSingle(t *testing.T) {
 	img, err := ImageFromPath("testdata/test_bundle.tar", nil)
 	if err == nil {
@@ -27,6 +27,8 @@ import (
 	"github.com/google/go-containerregistry/internal/and"
 	gestargz "github.com/google/go-containerregistry/internal/estargz"
 	ggzip "github.com/google/go-containerregistry/internal/gzip"
+	"github.com/google/go-containerregistry/internal/zstd"
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -37,7 +39,8 @@ type layer struct {
 	size               int64
 	compressedopener   Opener
 	uncompressedopener Opener
-	compression        int
+	compression        compression.Compression
+	compressionLevel   int
 	annotations        map[string]string
 	estgzopts          []estargz.Option
 	mediaType          types.MediaType
@@ -49,12 +52,16 @@ func (l *layer) Descriptor() (*v1.Descriptor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &v1.Descriptor{
-		Size:        l.size,
-		Digest:      digest,
-		Annotations: l.annotations,
-		MediaType:   l.mediaType,
-	}, nil
+	desc := &v1.Descriptor{
+		Size:      l.size,
+		Digest:    digest,
+		MediaType: l.mediaType,
+	}
+	// Leave empty annotations nil so the descriptor round-trips through JSON.
+	if len(l.annotations) != 0 {
+		desc.Annotations = l.annotations
+	}
+	return desc, nil
 }
 
 // Digest implements v1.Layer
@@ -94,7 +101,18 @@ type LayerOption func(*layer)
 // compression level used for compressing uncompressed tarballs.
 func WithCompressionLevel(level int) LayerOption {
 	return func(l *layer) {
-		l.compression = level
+		l.compressionLevel = level
+	}
+}
+
+// WithCompression is a functional option for overriding the default gzip
+// compression used for compressing uncompressed tarballs, e.g. to produce
+// zstd-compressed layers with compression.ZStd, whose media type is then
+// types.OCILayerZStd unless overridden with WithMediaType. Tarballs that are
+// already compressed are used as is.
+func WithCompression(comp compression.Compression) LayerOption {
+	return func(l *layer) {
+		l.compression = comp
 	}
 }
 
@@ -149,7 +167,7 @@ func WithEstargz(l *layer) {
 		if err != nil {
 			return nil, err
 		}
-		eopts := append(l.estgzopts, estargz.WithCompressionLevel(l.compression))
+		eopts := append(l.estgzopts, estargz.WithCompressionLevel(l.compressionLevel))
 		rc, h, err := gestargz.ReadCloser(crc, eopts...)
 		if err != nil {
 			return nil, err
@@ -205,22 +223,24 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 	}
 	defer rc.Close()
 
-	compressed, err := ggzip.Is(rc)
+	comp, err := peekCompression(rc)
 	if err != nil {
 		return nil, err
 	}
 
 	layer := &layer{
-		compression: gzip.BestSpeed,
-		annotations: make(map[string]string, 1),
-		mediaType:   types.DockerLayer,
+		compression:      compression.GZip,
+		compressionLevel: gzip.BestSpeed,
+		annotations:      make(map[string]string, 1),
+		mediaType:        types.DockerLayer,
 	}
 
 	if estgz := os.Getenv("GGCR_EXPERIMENT_ESTARGZ"); estgz == "1" {
 		opts = append([]LayerOption{WithEstargz}, opts...)
 	}
 
-	if compressed {
+	switch comp {
+	case compression.GZip:
 		layer.compressedopener = opener
 		layer.uncompressedopener = func() (io.ReadCloser, error) {
 			urc, err := opener()
@@ -229,20 +249,39 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 			}
 			return ggzip.UnzipReadCloser(urc)
 		}
-	} else {
+	case compression.ZStd:
+		layer.compressedopener = opener
+		layer.uncompressedopener = func() (io.ReadCloser, error) {
+			urc, err := opener()
+			if err != nil {
+				return nil, err
+			}
+			return zstd.UnzipReadCloser(urc)
+		}
+	default:
 		layer.uncompressedopener = opener
 		layer.compressedopener = func() (io.ReadCloser, error) {
 			crc, err := opener()
 			if err != nil {
 				return nil, err
 			}
-			return ggzip.ReadCloserLevel(crc, layer.compression), nil
+			// Check this when opening, since it may be set by an option.
+			if layer.compression == compression.ZStd {
+				return zstd.ReadCloserLevel(crc, layer.compressionLevel), nil
+			}
+			return ggzip.ReadCloserLevel(crc, layer.compressionLevel), nil
 		}
 	}
 
 	for _, opt := range opts {
 		opt(layer)
 	}
+	if comp != compression.None {
+		layer.compression = comp
+	}
+	if layer.compression == compression.ZStd && layer.mediaType == types.DockerLayer {
+		layer.mediaType = types.OCILayerZStd
+	}
 
 	if layer.digest, layer.size, err = computeDigest(layer.compressedopener); err != nil {
 		return nil, err
@@ -274,6 +313,25 @@ func LayerFromReader(reader io.Reader, opts ...LayerOption) (v1.Layer, error) {
 	return LayerFromFile(tmp.Name(), opts...)
 }
 
+// peekCompression returns how the contents of r are compressed.
+func peekCompression(r io.Reader) (compression.Compression, error) {
+	gzipped, pr, err := ggzip.Peek(r)
+	if err != nil {
+		return "", err
+	}
+	if gzipped {
+		return compression.GZip, nil
+	}
+	zstdCompressed, _, err := zstd.Peek(pr)
+	if err != nil {
+		return "", err
+	}
+	if zstdCompressed {
+		return compression.ZStd, nil
+	}
+	return compression.None, nil
+}
+
 func computeDigest(opener Opener) (v1.Hash, int64, error) {
 	rc, err := opener()
 	if err != nil {
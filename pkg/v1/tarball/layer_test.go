@@ -24,6 +24,7 @@ import (
 
 	"github.com/containerd/stargz-snapshotter/estargz"
 	"github.com/google/go-containerregistry/internal/compare"
+	"github.com/google/go-containerregistry/pkg/compression"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -79,6 +80,64 @@ func TestLayerFromFile(t *testing.T) {
 	}
 }
 
+func TestLayerFromFileZStd(t *testing.T) {
+	setupFixtures(t)
+	defer teardownFixtures(t)
+
+	tarLayer, err := LayerFromFile("testdata/content.tar")
+	if err != nil {
+		t.Fatalf("Unable to create layer from tar file: %v", err)
+	}
+
+	zstdLayer, err := LayerFromFile("testdata/content.tar", WithCompression(compression.ZStd))
+	if err != nil {
+		t.Fatalf("Unable to create zstd layer from tar file: %v", err)
+	}
+	if err := validate.Layer(zstdLayer); err != nil {
+		t.Errorf("validate.Layer(zstdLayer): %v", err)
+	}
+
+	mt, err := zstdLayer.MediaType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := types.OCILayerZStd; mt != want {
+		t.Errorf("MediaType() = %v, want %v", mt, want)
+	}
+
+	want, err := tarLayer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := zstdLayer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("DiffID() = %v, want %v", got, want)
+	}
+
+	// Layers from zstd-compressed tarballs are used as is.
+	rc, err := zstdLayer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromCompressed, err := LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to create layer from zstd-compressed tarball: %v", err)
+	}
+	if err := compare.Layers(zstdLayer, fromCompressed); err != nil {
+		t.Errorf("compare.Layers: %v", err)
+	}
+}
+
 func TestLayerFromFileEstargz(t *testing.T) {
 	setupFixtures(t)
 	defer teardownFixtures(t)
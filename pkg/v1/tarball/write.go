@@ -337,6 +337,10 @@ func dedupRefToImage(refToImage map[name.Reference]v1.Image) map[v1.Image][]stri
 	imageToTags := make(map[v1.Image][]string)
 
 	for ref, img := range refToImage {
+		if dt, ok := name.AsDigestTag(ref); ok {
+			// The digest is implied by img, so just keep the tag.
+			ref = dt.Tag()
+		}
 		if tag, ok := ref.(name.Tag); ok {
 			if tags, ok := imageToTags[img]; !ok || tags == nil {
 				imageToTags[img] = []string{}
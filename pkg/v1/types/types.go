@@ -23,8 +23,11 @@ const (
 	OCIImageIndex                  MediaType = "application/vnd.oci.image.index.v1+json"
 	OCIManifestSchema1             MediaType = "application/vnd.oci.image.manifest.v1+json"
 	OCIConfigJSON                  MediaType = "application/vnd.oci.image.config.v1+json"
+	OCIEmptyJSON                   MediaType = "application/vnd.oci.empty.v1+json"
 	OCILayer                       MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	OCILayerZStd                   MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
 	OCIRestrictedLayer             MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+	OCIRestrictedLayerZStd         MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
 	OCIUncompressedLayer           MediaType = "application/vnd.oci.image.layer.v1.tar"
 	OCIUncompressedRestrictedLayer MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar"
 
@@ -46,7 +49,7 @@ const (
 // https://github.com/opencontainers/image-spec/blob/master/layer.md#non-distributable-layers
 func (m MediaType) IsDistributable() bool {
 	switch m {
-	case DockerForeignLayer, OCIRestrictedLayer, OCIUncompressedRestrictedLayer:
+	case DockerForeignLayer, OCIRestrictedLayer, OCIRestrictedLayerZStd, OCIUncompressedRestrictedLayer:
 		return false
 	}
 	return true
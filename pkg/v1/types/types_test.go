@@ -5,6 +5,7 @@ import "testing"
 func TestIsDistributable(t *testing.T) {
 	for _, mt := range []MediaType{
 		OCIRestrictedLayer,
+		OCIRestrictedLayerZStd,
 		OCIUncompressedRestrictedLayer,
 		DockerForeignLayer,
 	} {
@@ -19,6 +20,7 @@ func TestIsDistributable(t *testing.T) {
 		OCIManifestSchema1,
 		OCIConfigJSON,
 		OCILayer,
+		OCILayerZStd,
 		OCIUncompressedLayer,
 		DockerManifestSchema1,
 		DockerManifestSchema1Signed,
@@ -25,6 +25,7 @@ import (
 	"io/ioutil"
 	"strings"
 
+	"github.com/google/go-containerregistry/internal/zstd"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 )
@@ -128,11 +129,26 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 		pw.CloseWithError(compressed.Close())
 	}()
 
-	// Read the bytes through gzip.Reader to compute the DiffID.
-	uncompressed, err := gzip.NewReader(pr)
+	// Read the bytes through gzip.Reader or zstd.Decoder, depending on the
+	// compression, to compute the DiffID.
+	var uncompressed io.ReadCloser
+	zstdCompressed, zpr, err := zstd.Peek(pr)
 	if err != nil {
 		return nil, err
 	}
+	if zstdCompressed {
+		zr, err := zstd.UnzipReadCloser(ioutil.NopCloser(zpr))
+		if err != nil {
+			return nil, err
+		}
+		uncompressed = zr
+	} else {
+		gr, err := gzip.NewReader(zpr)
+		if err != nil {
+			return nil, err
+		}
+		uncompressed = gr
+	}
 	diffider := sha256.New()
 	hashUncompressed := io.TeeReader(uncompressed, diffider)
 